@@ -28,6 +28,7 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 
 	mq "github.com/muqsitnawaz/mq/lib"
@@ -36,7 +37,9 @@ import (
 
 // JSONParser parses JSON files.
 type JSONParser struct {
-	prettyPrint bool
+	prettyPrint    bool
+	truncateFields int // Display width for table cells (0 = no truncation)
+	maxSections    int // Maximum array items turned into sections (0 = unlimited)
 }
 
 // JSONOption configures the JSON parser.
@@ -53,6 +56,24 @@ func NewJSONParser(opts ...JSONOption) *JSONParser {
 	return p
 }
 
+// WithTruncateFields sets the width at which table cells are shortened
+// when a table is rendered with String. Table rows always keep the full
+// values; truncation is off by default.
+func WithTruncateFields(n int) JSONOption {
+	return func(p *JSONParser) {
+		p.truncateFields = n
+	}
+}
+
+// WithMaxSections makes Parse fail with an mq.LimitError on arrays of more
+// than n items that would each become a section. Arrays that form a table
+// are not affected. There is no limit by default.
+func WithMaxSections(n int) JSONOption {
+	return func(p *JSONParser) {
+		p.maxSections = n
+	}
+}
+
 // Format implements mq.FormatParser.
 func (p *JSONParser) Format() mq.Format {
 	return mq.FormatJSON
@@ -79,7 +100,9 @@ func (p *JSONParser) Parse(content []byte, path string) (*mq.Document, error) {
 
 // JSONLParser parses JSONL (JSON Lines) files.
 type JSONLParser struct {
-	maxLines int // Maximum lines to parse (0 = unlimited)
+	maxLines       int // Maximum lines to parse (0 = unlimited)
+	truncateFields int // Display width for table cells (0 = no truncation)
+	maxSections    int // Maximum lines turned into sections (0 = unlimited)
 }
 
 // JSONLOption configures the JSONL parser.
@@ -103,6 +126,21 @@ func WithMaxLines(n int) JSONLOption {
 	}
 }
 
+// WithJSONLTruncateFields is WithTruncateFields for JSONL.
+func WithJSONLTruncateFields(n int) JSONLOption {
+	return func(p *JSONLParser) {
+		p.truncateFields = n
+	}
+}
+
+// WithJSONLMaxSections is WithMaxSections for JSONL. Unlike WithMaxLines,
+// which silently stops reading, it rejects the file.
+func WithJSONLMaxSections(n int) JSONLOption {
+	return func(p *JSONLParser) {
+		p.maxSections = n
+	}
+}
+
 // Format implements mq.FormatParser.
 func (p *JSONLParser) Format() mq.Format {
 	return mq.FormatJSONL
@@ -151,16 +189,40 @@ func (p *JSONLParser) Parse(content []byte, path string) (*mq.Document, error) {
 	}
 
 	// Build document from array of items
-	jsonParser := &JSONParser{prettyPrint: true}
+	jsonParser := &JSONParser{prettyPrint: true, truncateFields: p.truncateFields, maxSections: p.maxSections}
 	return jsonParser.buildDocument(content, path, items, mq.FormatJSONL)
 }
 
 // YAMLParser parses YAML files.
-type YAMLParser struct{}
+type YAMLParser struct {
+	truncateFields int // Display width for table cells (0 = no truncation)
+	maxSections    int // Maximum array items turned into sections (0 = unlimited)
+}
+
+// YAMLOption configures the YAML parser.
+type YAMLOption func(*YAMLParser)
 
 // NewYAMLParser creates a new YAML parser.
-func NewYAMLParser() *YAMLParser {
-	return &YAMLParser{}
+func NewYAMLParser(opts ...YAMLOption) *YAMLParser {
+	p := &YAMLParser{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// WithYAMLTruncateFields is WithTruncateFields for YAML.
+func WithYAMLTruncateFields(n int) YAMLOption {
+	return func(p *YAMLParser) {
+		p.truncateFields = n
+	}
+}
+
+// WithYAMLMaxSections is WithMaxSections for YAML.
+func WithYAMLMaxSections(n int) YAMLOption {
+	return func(p *YAMLParser) {
+		p.maxSections = n
+	}
 }
 
 // Format implements mq.FormatParser.
@@ -184,7 +246,7 @@ func (p *YAMLParser) Parse(content []byte, path string) (*mq.Document, error) {
 		return nil, &mq.ParseError{Format: mq.FormatYAML, Path: path, Err: err}
 	}
 
-	jsonParser := &JSONParser{prettyPrint: true}
+	jsonParser := &JSONParser{prettyPrint: true, truncateFields: p.truncateFields, maxSections: p.maxSections}
 	return jsonParser.buildDocument(content, path, data, mq.FormatYAML)
 }
 
@@ -205,15 +267,17 @@ func (p *JSONParser) buildDocument(source []byte, path string, data interface{},
 		// Array: check if it's a table (array of uniform objects)
 		if len(v) > 0 {
 			if table := tryExtractTable(v); table != nil {
+				table.MaxCellWidth = p.truncateFields
 				tables = append(tables, table)
 				title = fmt.Sprintf("Array (%d items)", len(v))
 			} else {
 				// Not a table, create sections for each item
+				if p.maxSections > 0 && len(v) > p.maxSections {
+					err := &mq.LimitError{Limit: "sections", Max: p.maxSections, Actual: len(v)}
+					return nil, &mq.ParseError{Format: format, Path: path, Err: err}
+				}
 				title = fmt.Sprintf("Array (%d items)", len(v))
 				for i, item := range v {
-					if i >= 100 { // Limit sections for large arrays
-						break
-					}
 					h := &mq.Heading{
 						Level: 1,
 						Text:  fmt.Sprintf("Item %d", i+1),
@@ -238,7 +302,7 @@ func (p *JSONParser) buildDocument(source []byte, path string, data interface{},
 	// Generate readable text
 	readableText := generateReadableText(data)
 
-	return mq.NewDocument(
+	doc := mq.NewDocument(
 		source,
 		path,
 		format,
@@ -251,7 +315,10 @@ func (p *JSONParser) buildDocument(source []byte, path string, data interface{},
 		tables,
 		nil, // lists
 		readableText,
-	), nil
+	)
+	doc.SetData(data)
+
+	return doc, nil
 }
 
 // extractObjectStructure extracts headings and sections from an object.
@@ -361,19 +428,14 @@ func tryExtractTable(arr []interface{}) *mq.Table {
 	return table
 }
 
-// formatValue converts a value to string for table display.
+// formatValue converts a value to a table cell. Scalars are kept in full;
+// shortening for display is left to Table.String.
 func formatValue(v interface{}) string {
 	switch val := v.(type) {
 	case string:
-		if len(val) > 50 {
-			return val[:47] + "..."
-		}
 		return val
 	case float64:
-		if val == float64(int64(val)) {
-			return fmt.Sprintf("%d", int64(val))
-		}
-		return fmt.Sprintf("%.2f", val)
+		return strconv.FormatFloat(val, 'f', -1, 64)
 	case bool:
 		return fmt.Sprintf("%t", val)
 	case nil:
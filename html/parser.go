@@ -26,7 +26,9 @@ import (
 	"net/url"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/net/html"
 	"golang.org/x/net/html/atom"
@@ -40,6 +42,7 @@ type Parser struct {
 	extractReadable bool     // Use Readability algorithm for main content
 	baseURL         *url.URL // Base URL for resolving relative links
 	maxDepth        int      // Maximum DOM traversal depth (0 = unlimited)
+	profile         bool     // Record mq.ParseStats
 }
 
 // Option configures the parser.
@@ -84,6 +87,14 @@ func WithMaxDepth(depth int) Option {
 	}
 }
 
+// WithProfiling records mq.ParseStats on every parsed document: DOM
+// parsing time, extraction time and the number of DOM nodes.
+func WithProfiling() Option {
+	return func(p *Parser) {
+		p.profile = true
+	}
+}
+
 // Format implements mq.Parser.
 func (p *Parser) Format() mq.Format {
 	return mq.FormatHTML
@@ -100,11 +111,21 @@ func (p *Parser) ParseFile(path string) (*mq.Document, error) {
 
 // Parse parses HTML content and returns an mq.Document.
 func (p *Parser) Parse(content []byte, path string) (*mq.Document, error) {
+	var start time.Time
+	if p.profile {
+		start = time.Now()
+	}
+
 	node, err := html.Parse(bytes.NewReader(content))
 	if err != nil {
 		return nil, &mq.ParseError{Format: mq.FormatHTML, Path: path, Err: err}
 	}
 
+	var parsed time.Time
+	if p.profile {
+		parsed = time.Now()
+	}
+
 	ext := &extractor{
 		parser: p,
 		source: content,
@@ -113,7 +134,26 @@ func (p *Parser) Parse(content []byte, path string) (*mq.Document, error) {
 		seen:   make(map[*html.Node]bool),
 	}
 
-	return ext.extract()
+	doc, err := ext.extract()
+	if err == nil && p.profile {
+		end := time.Now()
+		doc.SetParseStats(mq.ParseStats{
+			Parse: parsed.Sub(start),
+			Index: end.Sub(parsed),
+			Total: end.Sub(start),
+			Nodes: countNodes(node),
+		})
+	}
+	return doc, err
+}
+
+// countNodes counts the nodes of a DOM tree.
+func countNodes(n *html.Node) int {
+	count := 1
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		count += countNodes(c)
+	}
+	return count
 }
 
 // ParseReader parses HTML from a reader.
@@ -138,10 +178,12 @@ type extractor struct {
 	headings   []*mq.Heading
 	links      []*mq.Link
 	images     []*mq.Image
+	imageAfter []int // Number of headings preceding each image
 	tables     []*mq.Table
 	lists      []*mq.List
 	codeBlocks []*mq.CodeBlock
 	sections   []*mq.Section
+	order      []mq.Element // Elements in the order they were found
 }
 
 func (e *extractor) extract() (*mq.Document, error) {
@@ -163,9 +205,10 @@ func (e *extractor) extract() (*mq.Document, error) {
 	e.buildSections()
 
 	// Extract readable text
-	readableText := e.extractReadableText(mainNode)
+	textBlocks := e.extractTextBlocks(mainNode)
+	readableText := mq.RenderPlainText(textBlocks, mq.PlainTextOptions{CodeBlocks: true})
 
-	return mq.NewDocument(
+	doc := mq.NewDocument(
 		e.source,
 		e.path,
 		mq.FormatHTML,
@@ -178,16 +221,61 @@ func (e *extractor) extract() (*mq.Document, error) {
 		e.tables,
 		e.lists,
 		readableText,
-	), nil
+	)
+	doc.SetTextBlocks(textBlocks)
+	doc.SetElementOrder(e.order)
+	if lang := e.extractLang(e.root); lang != "" {
+		doc.SetLanguage(lang)
+	}
+	return doc, nil
 }
 
-// extractTitle finds the <title> tag content.
+// extractLang returns the lang attribute of the <html> element.
+func (e *extractor) extractLang(n *html.Node) string {
+	if n.Type == html.ElementNode && n.DataAtom == atom.Html {
+		for _, attr := range n.Attr {
+			if attr.Key == "lang" {
+				return attr.Val
+			}
+		}
+		return ""
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if lang := e.extractLang(c); lang != "" {
+			return lang
+		}
+	}
+	return ""
+}
+
+// extractTitle finds the <title> tag content, falling back to the
+// og:title meta tag.
 func (e *extractor) extractTitle(n *html.Node) string {
+	if title := e.findTitleTag(n); title != "" {
+		return title
+	}
+	return e.findOGTitle(n)
+}
+
+func (e *extractor) findTitleTag(n *html.Node) string {
 	if n.Type == html.ElementNode && n.DataAtom == atom.Title {
 		return strings.TrimSpace(e.getTextContent(n))
 	}
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		if title := e.extractTitle(c); title != "" {
+		if title := e.findTitleTag(c); title != "" {
+			return title
+		}
+	}
+	return ""
+}
+
+// findOGTitle returns the content of <meta property="og:title">.
+func (e *extractor) findOGTitle(n *html.Node) string {
+	if n.Type == html.ElementNode && n.DataAtom == atom.Meta && getAttr(n, "property") == "og:title" {
+		return strings.TrimSpace(getAttr(n, "content"))
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if title := e.findOGTitle(c); title != "" {
 			return title
 		}
 	}
@@ -310,6 +398,10 @@ func (e *extractor) extractElements(n *html.Node, depth int) {
 		case atom.Img:
 			e.extractImage(n)
 		case atom.Table:
+			if isCodeTable(n) {
+				e.extractCodeBlock(n) // Highlighter layout with a line-number gutter
+				return
+			}
 			e.extractTable(n)
 			return // Don't recurse into table
 		case atom.Ul, atom.Ol:
@@ -422,11 +514,13 @@ func (e *extractor) extractHeading(n *html.Node) {
 		}
 	}
 
-	e.headings = append(e.headings, &mq.Heading{
+	h := &mq.Heading{
 		Level: level,
 		Text:  text,
 		ID:    id,
-	})
+	}
+	e.headings = append(e.headings, h)
+	e.order = append(e.order, h)
 }
 
 func (e *extractor) extractLink(n *html.Node) {
@@ -455,10 +549,12 @@ func (e *extractor) extractLink(n *html.Node) {
 		}
 	}
 
-	e.links = append(e.links, &mq.Link{
+	link := &mq.Link{
 		Text: text,
 		URL:  href,
-	})
+	}
+	e.links = append(e.links, link)
+	e.order = append(e.order, link)
 }
 
 func (e *extractor) extractImage(n *html.Node) {
@@ -483,12 +579,10 @@ func (e *extractor) extractImage(n *html.Node) {
 	}
 
 	// Skip tracking pixels and tiny images
-	for _, attr := range n.Attr {
-		if attr.Key == "width" || attr.Key == "height" {
-			if attr.Val == "1" || attr.Val == "0" {
-				return
-			}
-		}
+	width, hasWidth := parseDimension(getAttr(n, "width"))
+	height, hasHeight := parseDimension(getAttr(n, "height"))
+	if (hasWidth && width <= 1) || (hasHeight && height <= 1) {
+		return
 	}
 
 	// Resolve relative URLs
@@ -498,11 +592,28 @@ func (e *extractor) extractImage(n *html.Node) {
 		}
 	}
 
-	e.images = append(e.images, &mq.Image{
+	img := &mq.Image{
 		URL:     src,
 		AltText: alt,
 		Title:   title,
-	})
+		Width:   width,
+		Height:  height,
+		Format:  mq.ImageFormat(src),
+	}
+	e.images = append(e.images, img)
+	e.order = append(e.order, img)
+	e.imageAfter = append(e.imageAfter, len(e.headings))
+}
+
+// parseDimension parses a width or height attribute such as "640" or
+// "640px". Percentages and other units are not pixel sizes and report false.
+func parseDimension(val string) (int, bool) {
+	val = strings.TrimSuffix(strings.TrimSpace(val), "px")
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
 }
 
 func (e *extractor) extractTable(n *html.Node) {
@@ -526,16 +637,38 @@ func (e *extractor) extractTable(n *html.Node) {
 	}
 
 	if len(table.Headers) > 0 || len(table.Rows) > 0 {
+		table.Normalize()
 		e.tables = append(e.tables, table)
+		e.order = append(e.order, table)
+	}
+}
+
+// appendCell appends the text of a table cell to cells, once per column
+// it spans.
+func (e *extractor) appendCell(cells []string, cell *html.Node) []string {
+	text := e.getTextContent(cell)
+	span, err := strconv.Atoi(getAttr(cell, "colspan"))
+	if err != nil || span < 1 {
+		span = 1
+	}
+	if span > maxColspan {
+		span = maxColspan
+	}
+	for i := 0; i < span; i++ {
+		cells = append(cells, text)
 	}
+	return cells
 }
 
+// maxColspan caps colspan, as browsers do, against absurd values.
+const maxColspan = 1000
+
 func (e *extractor) extractTableHeaders(thead *html.Node, table *mq.Table) {
 	for row := thead.FirstChild; row != nil; row = row.NextSibling {
 		if row.DataAtom == atom.Tr {
 			for cell := row.FirstChild; cell != nil; cell = cell.NextSibling {
 				if cell.DataAtom == atom.Th || cell.DataAtom == atom.Td {
-					table.Headers = append(table.Headers, strings.TrimSpace(e.getTextContent(cell)))
+					table.Headers = e.appendCell(table.Headers, cell)
 				}
 			}
 			break // Only first header row
@@ -549,7 +682,7 @@ func (e *extractor) extractTableRows(tbody *html.Node, table *mq.Table) {
 			var rowData []string
 			for cell := row.FirstChild; cell != nil; cell = cell.NextSibling {
 				if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
-					rowData = append(rowData, strings.TrimSpace(e.getTextContent(cell)))
+					rowData = e.appendCell(rowData, cell)
 				}
 			}
 			if len(rowData) > 0 {
@@ -565,10 +698,10 @@ func (e *extractor) extractTableRow(tr *html.Node, table *mq.Table) {
 
 	for cell := tr.FirstChild; cell != nil; cell = cell.NextSibling {
 		if cell.DataAtom == atom.Th {
-			table.Headers = append(table.Headers, strings.TrimSpace(e.getTextContent(cell)))
+			table.Headers = e.appendCell(table.Headers, cell)
 		} else if cell.DataAtom == atom.Td {
 			isHeader = false
-			rowData = append(rowData, strings.TrimSpace(e.getTextContent(cell)))
+			rowData = e.appendCell(rowData, cell)
 		}
 	}
 
@@ -580,22 +713,35 @@ func (e *extractor) extractTableRow(tr *html.Node, table *mq.Table) {
 func (e *extractor) extractList(n *html.Node) {
 	list := &mq.List{
 		Ordered: n.DataAtom == atom.Ol,
+		Items:   e.extractListItems(n, 0),
 	}
-
-	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		if c.DataAtom == atom.Li {
-			item := e.extractListItem(c)
-			list.Items = append(list.Items, item)
+	if list.Ordered {
+		list.Start = 1
+		if start, err := strconv.Atoi(strings.TrimSpace(getAttr(n, "start"))); err == nil {
+			list.Start = start
 		}
 	}
 
 	if len(list.Items) > 0 {
 		e.lists = append(e.lists, list)
+		e.order = append(e.order, list)
 	}
 }
 
-func (e *extractor) extractListItem(li *html.Node) mq.ListItem {
-	item := mq.ListItem{}
+func (e *extractor) extractListItems(list *html.Node, depth int) []mq.ListItem {
+	var items []mq.ListItem
+	for c := list.FirstChild; c != nil; c = c.NextSibling {
+		if c.DataAtom == atom.Li {
+			item := e.extractListItem(c, depth)
+			item.Ordered = list.DataAtom == atom.Ol
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (e *extractor) extractListItem(li *html.Node, depth int) mq.ListItem {
+	item := mq.ListItem{Depth: depth}
 
 	// Check for checkbox input (task list)
 	for c := li.FirstChild; c != nil; c = c.NextSibling {
@@ -616,97 +762,255 @@ func (e *extractor) extractListItem(li *html.Node) mq.ListItem {
 		}
 	}
 
-	item.Text = strings.TrimSpace(e.getTextContent(li))
-
-	// Extract nested lists
+	// Item text excludes nested lists, which become children
+	var text strings.Builder
 	for c := li.FirstChild; c != nil; c = c.NextSibling {
 		if c.DataAtom == atom.Ul || c.DataAtom == atom.Ol {
-			for gc := c.FirstChild; gc != nil; gc = gc.NextSibling {
-				if gc.DataAtom == atom.Li {
-					item.Children = append(item.Children, e.extractListItem(gc))
-				}
-			}
+			item.Children = append(item.Children, e.extractListItems(c, depth+1)...)
+			continue
 		}
+		e.collectText(c, &text)
 	}
+	item.Text = strings.Join(strings.Fields(text.String()), " ")
 
 	return item
 }
 
+// extractCodeBlock extracts a code block from a <pre>, or from a table
+// produced by a highlighter's line-number plugin. Line-number gutters are
+// dropped, and per-line elements (<div>, <tr>, <span class="line">, or one
+// <code> per line) are joined with newlines.
 func (e *extractor) extractCodeBlock(pre *html.Node) {
-	var language string
-	var codeNode *html.Node = pre
+	if cb := e.codeBlock(pre); cb != nil {
+		e.codeBlocks = append(e.codeBlocks, cb)
+		e.order = append(e.order, cb)
+	}
+}
 
-	// Look for <code> inside <pre>
+// codeBlock builds the code block for a <pre> or code table, or returns
+// nil if it holds no code.
+func (e *extractor) codeBlock(pre *html.Node) *mq.CodeBlock {
+	var codes []*html.Node
 	for c := pre.FirstChild; c != nil; c = c.NextSibling {
 		if c.DataAtom == atom.Code {
-			codeNode = c
-			// Extract language from class
-			for _, attr := range c.Attr {
-				if attr.Key == "class" {
-					language = e.detectLanguageFromClass(attr.Val)
-					break
-				}
-			}
-			break
+			codes = append(codes, c)
 		}
 	}
 
-	// Also check pre's class for language
-	if language == "" {
-		for _, attr := range pre.Attr {
-			if attr.Key == "class" {
-				language = e.detectLanguageFromClass(attr.Val)
-				break
-			}
+	// Language from the <code> class, else the <pre> (or table) class, else
+	// any nested <pre>/<code> outside the gutter
+	var language string
+	for _, c := range codes {
+		if language = e.detectLanguageFromClass(classAttr(c)); language != "" {
+			break
 		}
 	}
+	if language == "" {
+		language = e.detectLanguageFromClass(classAttr(pre))
+	}
+	if language == "" && pre.DataAtom == atom.Table {
+		language = e.nestedCodeLanguage(pre)
+	}
 
-	content := e.getTextContent(codeNode)
+	root := pre
+	if len(codes) == 1 {
+		root = codes[0]
+	}
+	w := &codeWriter{multiCode: len(codes) > 1}
+	e.collectCode(root, w)
+	content := w.buf.String()
 	if strings.TrimSpace(content) == "" {
-		return
+		return nil
 	}
 
-	e.codeBlocks = append(e.codeBlocks, &mq.CodeBlock{
+	return &mq.CodeBlock{
 		Language: language,
 		Content:  content,
 		Lines:    strings.Count(content, "\n") + 1,
-	})
+	}
+}
+
+// codeWriter accumulates code text. Line elements end with a pending
+// newline, which is dropped when the source already has one (as in Shiki's
+// "<span class=line>a</span>\n<span class=line>b</span>").
+type codeWriter struct {
+	buf       strings.Builder
+	pending   bool
+	multiCode bool // each <code> child of the <pre> is one line
+}
+
+func (w *codeWriter) write(s string) {
+	if s == "" {
+		return
+	}
+	if w.pending && !strings.HasPrefix(s, "\n") {
+		w.buf.WriteString("\n")
+	}
+	w.pending = false
+	w.buf.WriteString(s)
+}
+
+func (w *codeWriter) endLine() {
+	if w.buf.Len() > 0 && !strings.HasSuffix(w.buf.String(), "\n") {
+		w.pending = true
+	}
+}
+
+func (e *extractor) collectCode(n *html.Node, w *codeWriter) {
+	switch n.Type {
+	case html.TextNode:
+		w.write(n.Data)
+		return
+	case html.ElementNode:
+		if e.shouldSkip(n) || isGutter(n) {
+			return
+		}
+		if n.DataAtom == atom.Br {
+			w.write("\n")
+			return
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		e.collectCode(c, w)
+	}
+
+	if n.Type == html.ElementNode && isCodeLine(n, w.multiCode) {
+		w.endLine()
+	}
+}
+
+// nestedCodeLanguage returns the first language declared on a <pre> or
+// <code> inside n, ignoring gutters.
+func (e *extractor) nestedCodeLanguage(n *html.Node) string {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || isGutter(c) {
+			continue
+		}
+		if c.DataAtom == atom.Pre || c.DataAtom == atom.Code {
+			if lang := e.detectLanguageFromClass(classAttr(c)); lang != "" {
+				return lang
+			}
+		}
+		if lang := e.nestedCodeLanguage(c); lang != "" {
+			return lang
+		}
+	}
+	return ""
+}
+
+// gutterClasses mark line-number columns added by highlighters (Pygments,
+// highlight.js line numbers, Prism, GitHub, CodeMirror).
+var gutterClasses = map[string]bool{
+	"linenos": true, "linenodiv": true, "lineno": true, "line-numbers-rows": true,
+	"hljs-ln-numbers": true, "hljs-ln-n": true, "blob-num": true, "gutter": true,
+	"line-number": true, "linenumber": true, "CodeMirror-gutters": true,
+	"react-syntax-highlighter-line-number": true,
+}
+
+// lineClasses mark elements that hold exactly one line of code.
+var lineClasses = map[string]bool{
+	"line": true, "code-line": true, "hljs-ln-line": true, "blob-code": true,
+}
+
+func isGutter(n *html.Node) bool {
+	for _, c := range strings.Fields(classAttr(n)) {
+		if gutterClasses[c] {
+			return true
+		}
+	}
+	return false
+}
+
+func isCodeLine(n *html.Node, multiCode bool) bool {
+	switch n.DataAtom {
+	case atom.Div, atom.P, atom.Tr, atom.Li:
+		return true
+	case atom.Code:
+		return multiCode
+	}
+	for _, c := range strings.Fields(classAttr(n)) {
+		if lineClasses[c] {
+			return true
+		}
+	}
+	return false
+}
+
+// isCodeTable reports whether a table is a highlighted code listing with a
+// line-number gutter rather than tabular data.
+func isCodeTable(n *html.Node) bool {
+	if strings.Contains(classAttr(n), "highlighttable") {
+		return true
+	}
+	var hasGutter func(*html.Node) bool
+	hasGutter = func(n *html.Node) bool {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && ((c.DataAtom == atom.Td && isGutter(c)) || hasGutter(c)) {
+				return true
+			}
+		}
+		return false
+	}
+	return hasGutter(n)
+}
+
+func classAttr(n *html.Node) string {
+	return getAttr(n, "class")
+}
+
+func getAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
 }
 
 // detectLanguageFromClass extracts programming language from CSS classes.
 // Common patterns:
 //   - language-python, lang-python
-//   - highlight-python
+//   - highlight-python, highlight-source-python
+//   - brush: python
 //   - python (as standalone class)
+//
+// Known languages and aliases are returned by canonical name ("C#" and
+// "cs" become "csharp"); other prefixed names are lowercased.
 func (e *extractor) detectLanguageFromClass(class string) string {
 	classes := strings.Fields(class)
-	for _, c := range classes {
+	for i, c := range classes {
+		// SyntaxHighlighter puts the language in the next class: "brush: cs"
+		if c == "brush:" && i+1 < len(classes) {
+			return canonicalLanguage(classes[i+1])
+		}
 		// Common prefixes
-		for _, prefix := range []string{"language-", "lang-", "highlight-", "brush:"} {
-			if strings.HasPrefix(c, prefix) {
-				return strings.TrimPrefix(c, prefix)
+		for _, prefix := range []string{"language-", "lang-", "highlight-source-", "highlight-", "brush:"} {
+			if lang := strings.TrimPrefix(c, prefix); lang != c && lang != "" {
+				return canonicalLanguage(lang)
 			}
 		}
 	}
 
-	// Known language names as standalone classes
-	knownLangs := map[string]bool{
-		"python": true, "javascript": true, "js": true, "typescript": true, "ts": true,
-		"go": true, "golang": true, "rust": true, "java": true, "kotlin": true,
-		"c": true, "cpp": true, "csharp": true, "ruby": true, "php": true,
-		"swift": true, "bash": true, "shell": true, "sql": true, "html": true,
-		"css": true, "json": true, "yaml": true, "xml": true, "markdown": true,
-	}
-
+	// Known language names (or aliases) as standalone classes
 	for _, c := range classes {
-		if knownLangs[strings.ToLower(c)] {
-			return strings.ToLower(c)
+		if mq.IsKnownLanguage(c) {
+			return mq.NormalizeLanguage(c)
 		}
 	}
 
 	return ""
 }
 
+// canonicalLanguage returns the canonical name of a known language, or
+// lang lowercased.
+func canonicalLanguage(lang string) string {
+	if mq.IsKnownLanguage(lang) {
+		return mq.NormalizeLanguage(lang)
+	}
+	return strings.ToLower(lang)
+}
+
 // buildSections creates a section hierarchy from headings.
 func (e *extractor) buildSections() {
 	if len(e.headings) == 0 {
@@ -740,6 +1044,13 @@ func (e *extractor) buildSections() {
 		stack = append(stack, section)
 		e.sections = append(e.sections, section)
 	}
+
+	// Images belong to the section of the heading before them
+	for i, img := range e.images {
+		if n := e.imageAfter[i]; n > 0 {
+			e.sections[n-1].AddImage(img)
+		}
+	}
 }
 
 // getTextContent extracts text from a node and its descendants.
@@ -764,20 +1075,44 @@ func (e *extractor) collectText(n *html.Node, buf *strings.Builder) {
 	}
 }
 
-// extractReadableText gets clean text content suitable for LLM context.
-func (e *extractor) extractReadableText(n *html.Node) string {
-	var buf strings.Builder
-	e.collectReadableText(n, &buf, 0)
-	return cleanText(buf.String())
+// extractTextBlocks splits the readable content into headings,
+// paragraphs, code blocks, list items and table rows, the blocks that
+// ReadableText and PlainText are rendered from.
+func (e *extractor) extractTextBlocks(n *html.Node) []mq.TextBlock {
+	w := &textBlockWriter{}
+	e.collectTextBlocks(n, w)
+	w.flush()
+	return w.blocks
 }
 
-func (e *extractor) collectReadableText(n *html.Node, buf *strings.Builder, depth int) {
-	if n.Type == html.TextNode {
-		text := strings.TrimSpace(n.Data)
-		if text != "" {
-			buf.WriteString(text)
-			buf.WriteString(" ")
+// textBlockWriter accumulates text blocks, gathering inline text into the
+// current paragraph until a block element ends it.
+type textBlockWriter struct {
+	blocks []mq.TextBlock
+	para   strings.Builder
+}
+
+func (w *textBlockWriter) add(block mq.TextBlock) {
+	w.flush()
+	w.blocks = append(w.blocks, block)
+}
+
+func (w *textBlockWriter) flush() {
+	var lines []string
+	for _, line := range strings.Split(w.para.String(), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
 		}
+	}
+	if len(lines) > 0 {
+		w.blocks = append(w.blocks, mq.TextBlock{Kind: mq.ElementProse, Text: strings.Join(lines, "\n")})
+	}
+	w.para.Reset()
+}
+
+func (e *extractor) collectTextBlocks(n *html.Node, w *textBlockWriter) {
+	if n.Type == html.TextNode {
+		w.para.WriteString(n.Data)
 		return
 	}
 
@@ -786,49 +1121,92 @@ func (e *extractor) collectReadableText(n *html.Node, buf *strings.Builder, dept
 			return
 		}
 
-		// Add newlines for block elements
-		isBlock := isBlockElement(n.DataAtom)
-		if isBlock && buf.Len() > 0 {
-			buf.WriteString("\n")
+		switch n.DataAtom {
+		case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+			w.add(mq.TextBlock{
+				Kind:  mq.ElementHeading,
+				Text:  strings.Join(strings.Fields(e.getTextContent(n)), " "),
+				Level: int(n.Data[1] - '0'),
+			})
+			return
+		case atom.Pre:
+			if cb := e.codeBlock(n); cb != nil {
+				w.add(mq.TextBlock{Kind: mq.ElementCode, Text: cb.Content, Language: cb.Language})
+			}
+			return
+		case atom.Table:
+			if isCodeTable(n) {
+				if cb := e.codeBlock(n); cb != nil {
+					w.add(mq.TextBlock{Kind: mq.ElementCode, Text: cb.Content, Language: cb.Language})
+				}
+				return
+			}
+			w.flush()
+			e.collectTableRows(n, w)
+			return
+		case atom.Ul, atom.Ol:
+			w.flush()
+			start := 1
+			if n.DataAtom == atom.Ol {
+				if v, err := strconv.Atoi(strings.TrimSpace(getAttr(n, "start"))); err == nil {
+					start = v
+				}
+			}
+			w.blocks = append(w.blocks, mq.ListTextBlocks(e.extractListItems(n, 0), start)...)
+			return
+		case atom.Br:
+			w.para.WriteString("\n")
+			return
 		}
 	}
 
+	isBlock := n.Type == html.ElementNode && isBlockElement(n.DataAtom)
+	if isBlock {
+		w.flush()
+	}
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		e.collectReadableText(c, buf, depth+1)
+		e.collectTextBlocks(c, w)
 	}
+	if isBlock {
+		w.flush()
+	}
+}
 
-	if n.Type == html.ElementNode && isBlockElement(n.DataAtom) {
-		buf.WriteString("\n")
+// collectTableRows adds one block per row of a table, cells joined with
+// " | ". Nested tables are not descended into.
+func (e *extractor) collectTableRows(n *html.Node, w *textBlockWriter) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || e.shouldSkip(c) {
+			continue
+		}
+		switch c.DataAtom {
+		case atom.Thead, atom.Tbody, atom.Tfoot:
+			e.collectTableRows(c, w)
+		case atom.Tr:
+			var cells []string
+			for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
+					cells = append(cells, strings.Join(strings.Fields(e.getTextContent(cell)), " "))
+				}
+			}
+			if len(cells) > 0 {
+				w.blocks = append(w.blocks, mq.TextBlock{Kind: mq.ElementTable, Text: strings.Join(cells, " | ")})
+			}
+		}
 	}
 }
 
 func isBlockElement(a atom.Atom) bool {
 	blocks := map[atom.Atom]bool{
 		atom.P: true, atom.Div: true, atom.Article: true, atom.Section: true,
-		atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
-		atom.Ul: true, atom.Ol: true, atom.Li: true,
-		atom.Blockquote: true, atom.Pre: true,
-		atom.Table: true, atom.Tr: true,
+		atom.Li: true, atom.Blockquote: true,
 		atom.Header: true, atom.Footer: true, atom.Main: true, atom.Aside: true, atom.Nav: true,
-		atom.Figure: true, atom.Figcaption: true,
-		atom.Br: true, atom.Hr: true,
+		atom.Figure: true, atom.Figcaption: true, atom.Hr: true,
+		atom.Dl: true, atom.Dt: true, atom.Dd: true, atom.Body: true,
 	}
 	return blocks[a]
 }
 
-func cleanText(s string) string {
-	// Normalize whitespace
-	s = regexp.MustCompile(`[ \t]+`).ReplaceAllString(s, " ")
-	// Normalize newlines
-	s = regexp.MustCompile(`\n{3,}`).ReplaceAllString(s, "\n\n")
-	// Trim lines
-	lines := strings.Split(s, "\n")
-	for i, line := range lines {
-		lines[i] = strings.TrimSpace(line)
-	}
-	return strings.TrimSpace(strings.Join(lines, "\n"))
-}
-
 // Ensure Parser implements mq.FormatParser
 var _ mq.FormatParser = (*Parser)(nil)
 
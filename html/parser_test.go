@@ -3,7 +3,9 @@ package html_test
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/muqsitnawaz/mq/html"
 	mq "github.com/muqsitnawaz/mq/lib"
@@ -296,6 +298,17 @@ func TestCodeLanguageDetection(t *testing.T) {
 			html:     `<pre class="language-ruby">puts "hello"</pre>`,
 			expected: "ruby",
 		},
+		{name: "csharp-symbol", html: `<pre><code class="language-C#">var x = 1;</code></pre>`, expected: "csharp"},
+		{name: "csharp-camel", html: `<pre><code class="CSharp">var x = 1;</code></pre>`, expected: "csharp"},
+		{name: "csharp-short", html: `<pre><code class="cs">var x = 1;</code></pre>`, expected: "csharp"},
+		{name: "fsharp-symbol", html: `<pre><code class="lang-F#">let x = 1</code></pre>`, expected: "fsharp"},
+		{name: "fsharp-standalone", html: `<pre><code class="fsharp">let x = 1</code></pre>`, expected: "fsharp"},
+		{name: "objc", html: `<pre><code class="hljs objc">@interface Foo</code></pre>`, expected: "objectivec"},
+		{name: "kotlin-short", html: `<pre><code class="kt">fun main() {}</code></pre>`, expected: "kotlin"},
+		{name: "js-alias", html: `<pre><code class="language-js">let a</code></pre>`, expected: "javascript"},
+		{name: "github-highlight", html: `<pre class="highlight-source-python">x = 1</pre>`, expected: "python"},
+		{name: "brush", html: `<pre class="brush: ps1">Get-Item</pre>`, expected: "powershell"},
+		{name: "unknown-prefixed", html: `<pre><code class="language-Nim">echo 1</code></pre>`, expected: "nim"},
 	}
 
 	for _, tt := range tests {
@@ -311,6 +324,172 @@ func TestCodeLanguageDetection(t *testing.T) {
 	}
 }
 
+func TestHighlightedCodeReconstruction(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		content  string
+		language string
+	}{
+		{
+			name: "hljs-line-numbers",
+			html: `<pre><code class="hljs language-go"><table class="hljs-ln"><tbody>` +
+				`<tr><td class="hljs-ln-line hljs-ln-numbers" data-line-number="1"><div class="hljs-ln-n" data-line-number="1"></div></td>` +
+				`<td class="hljs-ln-line hljs-ln-code" data-line-number="1"><span class="hljs-keyword">package</span> main</td></tr>` +
+				`<tr><td class="hljs-ln-line hljs-ln-numbers" data-line-number="2"><div class="hljs-ln-n" data-line-number="2"></div></td>` +
+				`<td class="hljs-ln-line hljs-ln-code" data-line-number="2"><span class="hljs-keyword">func</span> main() {}</td></tr>` +
+				`</tbody></table></code></pre>`,
+			content:  "package main\nfunc main() {}",
+			language: "go",
+		},
+		{
+			name: "prism-line-numbers",
+			html: `<pre class="line-numbers language-js"><code class="language-js">let a = 1;
+let b = 2;<span aria-hidden="true" class="line-numbers-rows"><span></span><span></span></span></code></pre>`,
+			content:  "let a = 1;\nlet b = 2;",
+			language: "javascript",
+		},
+		{
+			name:     "code-per-line",
+			html:     `<pre class="language-python"><code>import os</code><code>print(os.sep)</code></pre>`,
+			content:  "import os\nprint(os.sep)",
+			language: "python",
+		},
+		{
+			name: "shiki-lines",
+			html: `<pre class="shiki"><code class="language-ts"><span class="line"><span>const x = 1</span></span>
+<span class="line"><span>export default x</span></span></code></pre>`,
+			content:  "const x = 1\nexport default x",
+			language: "typescript",
+		},
+		{
+			name:     "br-lines",
+			html:     `<pre><code class="language-bash">echo one<br>echo two<br/>echo three</code></pre>`,
+			content:  "echo one\necho two\necho three",
+			language: "bash",
+		},
+		{
+			name: "pygments-table",
+			html: `<div class="highlight"><table class="highlighttable"><tr>` +
+				`<td class="linenos"><div class="linenodiv"><pre>1
+2</pre></div></td>` +
+				`<td class="code"><div class="highlight"><pre><span class="k">def</span> <span class="nf">f</span>():
+    <span class="k">return</span> 1
+</pre></div></td>` +
+				`</tr></table></div>`,
+			content: "def f():\n    return 1",
+		},
+		{
+			name: "github-blob",
+			html: `<table class="highlight tab-size"><tr><td class="blob-num" data-line-number="1"></td><td class="blob-code">x = 1</td></tr>` +
+				`<tr><td class="blob-num" data-line-number="2"></td><td class="blob-code">y = 2</td></tr></table>`,
+			content: "x = 1\ny = 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fullHTML := `<!DOCTYPE html><html><body><main>` + tt.html + `</main></body></html>`
+			doc, err := html.ParseHTML([]byte(fullHTML), "test.html")
+			require.NoError(t, err)
+
+			blocks := doc.GetCodeBlocks()
+			require.Len(t, blocks, 1)
+			assert.Equal(t, tt.content, strings.TrimSpace(blocks[0].Content))
+			assert.Equal(t, tt.language, blocks[0].Language)
+			assert.Empty(t, doc.GetTables(), "code layout tables are not data tables")
+		})
+	}
+}
+
+func TestListNesting(t *testing.T) {
+	htmlContent := `<!DOCTYPE html><html><body><main>
+<ol start="5">
+  <li>Download</li>
+  <li>Install
+    <ul>
+      <li><input type="checkbox" checked> verify checksum</li>
+      <li>run installer</li>
+    </ul>
+  </li>
+</ol>
+</main></body></html>`
+
+	doc, err := html.ParseHTML([]byte(htmlContent), "lists.html")
+	require.NoError(t, err)
+
+	lists := doc.GetLists(nil)
+	require.Len(t, lists, 1)
+	assert.True(t, lists[0].Ordered)
+	assert.Equal(t, 5, lists[0].Start)
+
+	items := lists[0].Items
+	require.Len(t, items, 2)
+	assert.Equal(t, "Install", items[1].Text)
+	require.Len(t, items[1].Children, 2)
+
+	nested := items[1].Children[0]
+	assert.Equal(t, 1, nested.Depth)
+	assert.Equal(t, "verify checksum", nested.Text)
+	require.NotNil(t, nested.Checked)
+	assert.True(t, *nested.Checked)
+}
+
+func TestImageDimensions(t *testing.T) {
+	htmlContent := `<!DOCTYPE html><html><body><main>
+<img src="/hero.JPG?v=2" alt="Hero" width="1200" height="600px">
+<img src="icons/star.svg" alt="Star" width="16" height="16">
+<img src="data:image/png;base64,iVBORw0KGgo=" alt="Inline" width="50%">
+<img src="https://tracker.example.com/pixel.gif" width="1" height="1">
+</main></body></html>`
+
+	doc, err := html.ParseHTML([]byte(htmlContent), "images.html")
+	require.NoError(t, err)
+
+	images := doc.GetImages()
+	require.Len(t, images, 3, "tracking pixel should be skipped")
+
+	assert.Equal(t, 1200, images[0].Width)
+	assert.Equal(t, 600, images[0].Height)
+	assert.Equal(t, "jpeg", images[0].Format)
+
+	assert.Equal(t, 16, images[1].Width)
+	assert.Equal(t, "svg", images[1].Format)
+
+	assert.Equal(t, 0, images[2].Width, "percentages are not pixel sizes")
+	assert.Equal(t, "png", images[2].Format)
+}
+
+func TestSizeReduction(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("testdata", "simple.html"))
+	require.NoError(t, err)
+
+	doc, err := html.ParseHTML(content, "simple.html")
+	require.NoError(t, err)
+
+	report := doc.SizeReduction()
+	assert.Equal(t, len(content), report.SourceBytes)
+	assert.Equal(t, utf8.RuneCountInString(doc.ReadableText()), report.ReadableChars)
+	assert.Greater(t, report.Ratio, 0.5, "boilerplate and markup should be stripped")
+	assert.Less(t, report.Ratio, 1.0)
+}
+
+func TestParseStats(t *testing.T) {
+	content := []byte(`<html><body><h1>Title</h1><p>Text <b>bold</b></p></body></html>`)
+
+	doc, err := html.NewParser().Parse(content, "plain.html")
+	require.NoError(t, err)
+	_, ok := doc.ParseStats()
+	assert.False(t, ok)
+
+	doc, err = html.NewParser(html.WithProfiling()).Parse(content, "profiled.html")
+	require.NoError(t, err)
+	stats, ok := doc.ParseStats()
+	require.True(t, ok)
+	assert.Equal(t, 10, stats.Nodes) // document, html, head, body, h1, text, p, text, b, text
+	assert.GreaterOrEqual(t, stats.Total, stats.Parse+stats.Index)
+}
+
 func TestSkipElements(t *testing.T) {
 	htmlContent := `<!DOCTYPE html>
 <html>
@@ -345,3 +524,117 @@ func TestSkipElements(t *testing.T) {
 	assert.NotContains(t, readable, "Comments section")
 	assert.NotContains(t, readable, "Hidden content")
 }
+
+func TestHTMLLangAttribute(t *testing.T) {
+	parser := html.NewParser()
+
+	doc, err := parser.Parse([]byte(`<html lang="de"><body><h1>Hallo</h1><p>Short.</p></body></html>`), "test.html")
+	require.NoError(t, err)
+	assert.Equal(t, "de", doc.Language())
+
+	doc, err = parser.Parse([]byte(`<html><body><h1>Hi</h1><p>Short.</p></body></html>`), "test.html")
+	require.NoError(t, err)
+	assert.Equal(t, "", doc.Language())
+}
+
+func TestPlainText(t *testing.T) {
+	htmlContent := `<html><body><main>
+<h1>Guide</h1>
+<p>Intro <b>bold</b> text.</p>
+<ul><li>one</li><li>two<ol><li>three</li></ol></li></ul>
+<pre><code class="language-go">fmt.Println(1)
+</code></pre>
+<table><tr><th>A</th><th>B</th></tr><tr><td>1</td><td>2</td></tr></table>
+</main></body></html>`
+
+	doc, err := html.NewParser().Parse([]byte(htmlContent), "test.html")
+	require.NoError(t, err)
+
+	// ReadableText keeps code but no markers
+	assert.Equal(t, "Guide\n\nIntro bold text.\n\none\ntwo\nthree\n\nfmt.Println(1)\n\nA | B\n1 | 2", doc.ReadableText())
+	assert.Equal(t, doc.ReadableText(), doc.PlainText(mq.PlainTextOptions{CodeBlocks: true}))
+
+	assert.Equal(t, "Guide\n\nIntro bold text.\n\none\ntwo\nthree\n\nA | B\n1 | 2", doc.PlainText(mq.PlainTextOptions{}))
+
+	full := doc.PlainText(mq.PlainTextOptions{HeadingMarkers: true, CodeBlocks: true, FenceCode: true, ListMarkers: true})
+	assert.Equal(t, "# Guide\n\nIntro bold text.\n\n- one\n- two\n  1. three\n\n```go\nfmt.Println(1)\n```\n\nA | B\n1 | 2", full)
+}
+
+func TestImageSections(t *testing.T) {
+	htmlContent := `<html><body><main>
+<p><img src="/logo.png" alt="Logo"></p>
+<h1>Guide</h1>
+<img src="/a.png" alt="">
+<h2>Setup</h2>
+<img src="/b.png" alt="Diagram">
+</main></body></html>`
+
+	doc, err := html.NewParser().Parse([]byte(htmlContent), "test.html")
+	require.NoError(t, err)
+
+	images := doc.GetImages()
+	require.Len(t, images, 3)
+	assert.Nil(t, images[0].Section)
+	require.NotNil(t, images[1].Section)
+	assert.Equal(t, "Guide", images[1].Section.Heading.Text)
+	require.NotNil(t, images[2].Section)
+	assert.Equal(t, "Setup", images[2].Section.Heading.Text)
+
+	section, ok := doc.GetSection("Guide")
+	require.True(t, ok)
+	assert.Len(t, section.GetImages(), 2)
+}
+
+func TestElementsInSourceOrder(t *testing.T) {
+	htmlContent := `<html><body><main>
+<h2>Two</h2>
+<img src="/a.png" alt="A">
+<h3>Three</h3>
+<p>See <a href="https://example.com">the docs</a>.</p>
+<pre><code>make</code></pre>
+<h2>Four</h2>
+</main></body></html>`
+
+	doc, err := html.NewParser().Parse([]byte(htmlContent), "test.html")
+	require.NoError(t, err)
+
+	var kinds []mq.ElementKind
+	for _, e := range doc.Elements() {
+		kinds = append(kinds, e.Kind())
+	}
+	assert.Equal(t, []mq.ElementKind{
+		mq.ElementHeading, mq.ElementImage, mq.ElementHeading, mq.ElementLink, mq.ElementCode, mq.ElementHeading,
+	}, kinds)
+}
+
+func TestSearchElements(t *testing.T) {
+	htmlContent := `<html><body><main>
+<h1>Deploy</h1>
+<p>Run deploy from CI.</p>
+<pre><code class="language-bash">./deploy.sh</code></pre>
+<h2>Targets</h2>
+<table><tr><th>Env</th><th>Command</th></tr><tr><td>prod</td><td>deploy prod</td></tr></table>
+<ul><li>build first</li><li>then deploy</li></ul>
+</main></body></html>`
+
+	doc, err := html.NewParser().Parse([]byte(htmlContent), "ops.html")
+	require.NoError(t, err)
+
+	results := doc.SearchWithOptions("deploy", mq.SearchOptions{Elements: true})
+	var got []string
+	for _, m := range results.Matches {
+		got = append(got, m.Where()+" "+m.Section+" "+m.Match)
+	}
+	assert.Equal(t, []string{
+		"heading Deploy Deploy",
+		"prose Deploy Run deploy from CI.",
+		"code block (bash) Deploy ./deploy.sh",
+		"table cell Targets deploy prod",
+		"list item Targets then deploy",
+	}, got)
+	assert.Contains(t, results.String(), "## Targets (in table cell)")
+
+	lists := doc.SearchWithOptions("build", mq.SearchOptions{Elements: true, Kinds: []mq.ElementKind{mq.ElementList}})
+	require.Len(t, lists.Matches, 1)
+	assert.Equal(t, "build first", lists.Matches[0].Match)
+}
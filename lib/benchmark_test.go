@@ -299,6 +299,68 @@ Final thoughts.
 	})
 }
 
+// BenchmarkSectionScan finds an early section of a large document by
+// scanning, comparing the copied slice of GetSections with IterSections.
+func BenchmarkSectionScan(b *testing.B) {
+	doc, err := New().ParseDocument(generateMarkdown(1024*1024), "test.md")
+	if err != nil {
+		b.Fatal(err)
+	}
+	isTarget := func(s *Section) bool { return s.Heading.Level == 3 }
+
+	b.Run("GetSections", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, s := range doc.GetSections() {
+				if isTarget(s) {
+					break
+				}
+			}
+		}
+	})
+
+	b.Run("IterSections", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			doc.IterSections(func(s *Section) bool {
+				return !isTarget(s)
+			})
+		}
+	})
+}
+
+// BenchmarkConcurrentQueries runs read accessors on one shared document from
+// many goroutines. Run with -race to verify the concurrency contract.
+func BenchmarkConcurrentQueries(b *testing.B) {
+	engine := New()
+	doc, err := engine.ParseDocument(generateMarkdown(100*1024), "test.md")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			switch i % 5 {
+			case 0:
+				_ = doc.GetHeadings(2)
+			case 1:
+				if section, ok := doc.GetSection("Section 10"); ok {
+					_ = section.GetCodeBlocks("python")
+				}
+			case 2:
+				_ = doc.GetCodeBlocks("python")
+			case 3:
+				_ = doc.BuildTree(TreeModePreview)
+			case 4:
+				_ = doc.Search("example")
+			}
+			i++
+		}
+	})
+}
+
 func BenchmarkReadableText(b *testing.B) {
 	sizes := []struct {
 		name string
@@ -0,0 +1,77 @@
+package mq
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrHeadingNotFound is returned when a named heading does not exist.
+var ErrHeadingNotFound = errors.New("heading not found")
+
+// BetweenOptions controls which boundaries GetContentBetweenWithOptions
+// includes.
+type BetweenOptions struct {
+	IncludeStart bool // Include the start heading line
+	IncludeEnd   bool // Include the end heading and its section
+}
+
+// GetContentBetween returns the raw markdown strictly between two headings:
+// everything after the startTitle heading line and before the endTitle
+// heading, whatever sections lie in between. The end heading is the first
+// one with that title after the start heading. Leading and trailing blank
+// lines are trimmed.
+func (d *Document) GetContentBetween(startTitle, endTitle string) (string, error) {
+	return d.GetContentBetweenWithOptions(startTitle, endTitle, BetweenOptions{})
+}
+
+// GetContentBetweenWithOptions is GetContentBetween with configurable
+// boundaries. It fails with ErrHeadingNotFound if either heading is
+// missing, and with an error if the end heading only occurs before the
+// start heading.
+func (d *Document) GetContentBetweenWithOptions(startTitle, endTitle string, opts BetweenOptions) (string, error) {
+	sections := d.GetSections()
+	startIdx := findHeadingSection(sections, startTitle, 0)
+	if startIdx < 0 {
+		return "", fmt.Errorf("%w: %q", ErrHeadingNotFound, startTitle)
+	}
+	endIdx := findHeadingSection(sections, endTitle, startIdx+1)
+	if endIdx < 0 {
+		if findHeadingSection(sections, endTitle, 0) >= 0 {
+			return "", fmt.Errorf("heading %q does not come after %q", endTitle, startTitle)
+		}
+		return "", fmt.Errorf("%w: %q", ErrHeadingNotFound, endTitle)
+	}
+
+	start, end := sections[startIdx], sections[endIdx]
+	if start.Start == 0 || end.Start == 0 {
+		return "", fmt.Errorf("%s documents have no heading line positions", d.format)
+	}
+
+	from, to := start.Start+1, end.Start-1
+	if opts.IncludeStart {
+		from = start.Start
+	}
+	if opts.IncludeEnd {
+		to = end.End
+	}
+	return strings.Trim(d.GetLines(from, to), "\n"), nil
+}
+
+// findHeadingSection returns the index of the first section from index
+// from on whose heading is title, preferring exact matches over
+// case-insensitive ones, or -1.
+func findHeadingSection(sections []*Section, title string, from int) int {
+	for _, exact := range []bool{true, false} {
+		for i := from; i < len(sections); i++ {
+			s := sections[i]
+			if s.Implicit {
+				continue
+			}
+			if s.Heading.Text == title || (!exact && strings.EqualFold(s.Heading.Text, title)) {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,72 @@
+package mq
+
+// DefaultCardFields are the fields of a Card when none are requested.
+var DefaultCardFields = []string{"title", "owner", "tags", "sections", "first_paragraph"}
+
+// Card returns a compact summary of the document for listings and feeds,
+// combining frontmatter with structure. Each requested field (by default
+// DefaultCardFields) becomes a key of the result:
+//
+//   - title, path, format, language
+//   - owner, priority (nil when absent), tags (empty when absent)
+//   - first_paragraph (alias lead): see Lead
+//   - sections, headings, code_blocks, links, images, tables: counts, with
+//     sections counted as GetSections lists them, so a document without
+//     headings has one section and no headings
+//
+// Any other name is looked up in the frontmatter as by GetNestedField,
+// and is nil when missing.
+func (d *Document) Card(fields ...string) map[string]interface{} {
+	if len(fields) == 0 {
+		fields = DefaultCardFields
+	}
+	card := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		card[field] = d.cardField(field)
+	}
+	return card
+}
+
+func (d *Document) cardField(name string) interface{} {
+	switch name {
+	case "title":
+		return d.Title()
+	case "path":
+		return d.Path()
+	case "format":
+		return d.Format().String()
+	case "language":
+		return d.Language()
+	case "owner":
+		if owner, ok := d.GetOwner(); ok {
+			return owner
+		}
+		return nil
+	case "priority":
+		if priority, ok := d.GetPriority(); ok {
+			return priority
+		}
+		return nil
+	case "tags":
+		if tags := d.GetTags(); tags != nil {
+			return tags
+		}
+		return []string{}
+	case "first_paragraph", "lead":
+		return d.Lead()
+	case "sections":
+		return len(d.GetSections())
+	case "headings":
+		return len(d.GetHeadings())
+	case "code_blocks":
+		return len(d.GetCodeBlocks())
+	case "links":
+		return len(d.GetLinks())
+	case "images":
+		return len(d.GetImages())
+	case "tables":
+		return len(d.GetTables())
+	}
+	val, _ := d.GetNestedField(name)
+	return val
+}
@@ -0,0 +1,86 @@
+package mq
+
+import (
+	"crypto/sha256"
+	"strconv"
+	"strings"
+)
+
+// ChangeKind classifies a section in ChangedSections.
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"    // Path not present in the old document
+	ChangeModified ChangeKind = "modified" // Same path, different content
+	ChangeRemoved  ChangeKind = "removed"  // Path no longer present in the new document
+)
+
+// SectionChange is a section that differs between two versions of a
+// document. Section belongs to the new document, except for removed
+// sections, which belong to the old one.
+type SectionChange struct {
+	Section *Section
+	Kind    ChangeKind
+}
+
+// ChangedSections compares two versions of a document and returns the
+// sections whose own content (up to their first subsection) changed, so
+// that embeddings or summaries can be regenerated for just those sections.
+// Sections are matched by heading path; repeated paths are matched in order
+// of appearance. Content is compared after collapsing whitespace, so
+// reflowing a paragraph is not a change. Added and modified sections are
+// returned in new-document order, followed by removed sections in
+// old-document order.
+func ChangedSections(oldDoc, newDoc *Document) []SectionChange {
+	oldHashes := make(map[string][32]byte)
+	for _, entry := range orderedKeyedSections(oldDoc) {
+		oldHashes[entry.key] = sectionHash(entry.section)
+	}
+
+	var changes []SectionChange
+	newKeys := make(map[string]bool)
+	for _, entry := range orderedKeyedSections(newDoc) {
+		newKeys[entry.key] = true
+		oldHash, ok := oldHashes[entry.key]
+		switch {
+		case !ok:
+			changes = append(changes, SectionChange{Section: entry.section, Kind: ChangeAdded})
+		case oldHash != sectionHash(entry.section):
+			changes = append(changes, SectionChange{Section: entry.section, Kind: ChangeModified})
+		}
+	}
+
+	for _, entry := range orderedKeyedSections(oldDoc) {
+		if !newKeys[entry.key] {
+			changes = append(changes, SectionChange{Section: entry.section, Kind: ChangeRemoved})
+		}
+	}
+	return changes
+}
+
+type keyedSection struct {
+	key     string
+	section *Section
+}
+
+// orderedKeyedSections returns the sections of doc in document order, keyed
+// by heading path and occurrence of that path.
+func orderedKeyedSections(doc *Document) []keyedSection {
+	if doc == nil {
+		return nil
+	}
+	seen := make(map[string]int)
+	var entries []keyedSection
+	for _, s := range doc.GetSections() {
+		path := s.PathString("\x00")
+		entries = append(entries, keyedSection{key: path + "\x00" + strconv.Itoa(seen[path]), section: s})
+		seen[path]++
+	}
+	return entries
+}
+
+// sectionHash hashes a section's own text, excluding its subsections, with
+// whitespace collapsed.
+func sectionHash(s *Section) [32]byte {
+	return sha256.Sum256([]byte(strings.Join(strings.Fields(s.OwnText()), " ")))
+}
@@ -0,0 +1,91 @@
+package mq
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// chunkSeparators are the boundaries ChunkText breaks text at, preferred
+// first: paragraphs, then lines, then words.
+var chunkSeparators = []string{"\n\n", "\n", " "}
+
+// ChunkText splits text into pieces of at most maxChars characters, such as
+// for feeding a long section to a model with a small context window.
+// Paragraphs are packed greedily, in order, into each piece; a paragraph
+// too long for a piece of its own is split between lines, a line between
+// words, and a word with no room at all is cut. Blank paragraphs and lines
+// are dropped. The same input always gives the same pieces. It returns nil
+// when maxChars <= 0, and an empty slice for blank text.
+func ChunkText(text string, maxChars int) []string {
+	if maxChars <= 0 {
+		return nil
+	}
+	text = strings.Trim(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if strings.TrimSpace(text) == "" {
+		return []string{}
+	}
+	return splitChunks(text, maxChars, 0)
+}
+
+// splitChunks packs the parts of text between chunkSeparators[level] into
+// pieces of at most maxChars characters, splitting oversized parts at the
+// next level.
+func splitChunks(text string, maxChars, level int) []string {
+	if utf8.RuneCountInString(text) <= maxChars {
+		return []string{text}
+	}
+	if level == len(chunkSeparators) {
+		runes := []rune(text)
+		var chunks []string
+		for len(runes) > maxChars {
+			chunks = append(chunks, string(runes[:maxChars]))
+			runes = runes[maxChars:]
+		}
+		return append(chunks, string(runes))
+	}
+
+	sep := chunkSeparators[level]
+	var chunks []string
+	current := ""
+	for _, part := range strings.Split(text, sep) {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		candidate := part
+		if current != "" {
+			candidate = current + sep + part
+		}
+		if utf8.RuneCountInString(candidate) <= maxChars {
+			current = candidate
+			continue
+		}
+		if current != "" {
+			chunks = append(chunks, current)
+		}
+		// Keep packing after the last piece of an oversized part
+		pieces := splitChunks(part, maxChars, level+1)
+		chunks = append(chunks, pieces[:len(pieces)-1]...)
+		current = pieces[len(pieces)-1]
+	}
+	if current != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// Chunks splits the block's content as ChunkText does, wrapping each piece
+// in a fence labeled with the block's language so that every chunk is a
+// complete code block of at most maxChars characters. It returns nil when
+// maxChars leaves no room for content inside the fence.
+func (cb *CodeBlock) Chunks(maxChars int) []string {
+	open, end := "```"+cb.Language+"\n", "\n```"
+	pieces := ChunkText(cb.Content, maxChars-utf8.RuneCountInString(open+end))
+	if pieces == nil {
+		return nil
+	}
+	chunks := make([]string, len(pieces))
+	for i, piece := range pieces {
+		chunks[i] = open + piece + end
+	}
+	return chunks
+}
@@ -0,0 +1,106 @@
+package mq
+
+import "strings"
+
+// codeLanguages are the canonical code fence languages recognized by name,
+// e.g. as standalone CSS classes on HTML code elements.
+var codeLanguages = map[string]bool{
+	"python": true, "javascript": true, "typescript": true, "go": true,
+	"rust": true, "java": true, "kotlin": true, "c": true, "cpp": true,
+	"csharp": true, "fsharp": true, "objectivec": true, "ruby": true,
+	"php": true, "swift": true, "scala": true, "haskell": true, "lua": true,
+	"perl": true, "r": true, "dart": true, "elixir": true, "erlang": true,
+	"clojure": true, "julia": true, "ocaml": true, "groovy": true,
+	"bash": true, "powershell": true, "sql": true, "html": true, "css": true,
+	"json": true, "yaml": true, "toml": true, "xml": true, "markdown": true,
+	"dockerfile": true, "graphql": true,
+}
+
+// defaultLanguageAliases maps common alternative fence labels to the
+// canonical name used for lookups.
+var defaultLanguageAliases = map[string]string{
+	"js":          "javascript",
+	"jsx":         "javascript",
+	"ts":          "typescript",
+	"tsx":         "typescript",
+	"py":          "python",
+	"python3":     "python",
+	"golang":      "go",
+	"rs":          "rust",
+	"kt":          "kotlin",
+	"kts":         "kotlin",
+	"rb":          "ruby",
+	"c++":         "cpp",
+	"cs":          "csharp",
+	"c#":          "csharp",
+	"f#":          "fsharp",
+	"fs":          "fsharp",
+	"objc":        "objectivec",
+	"obj-c":       "objectivec",
+	"objective-c": "objectivec",
+	"hs":          "haskell",
+	"pl":          "perl",
+	"ex":          "elixir",
+	"exs":         "elixir",
+	"erl":         "erlang",
+	"clj":         "clojure",
+	"jl":          "julia",
+	"ml":          "ocaml",
+	"sh":          "bash",
+	"shell":       "bash",
+	"zsh":         "bash",
+	"ps1":         "powershell",
+	"pwsh":        "powershell",
+	"yml":         "yaml",
+	"md":          "markdown",
+	"htm":         "html",
+	"docker":      "dockerfile",
+	"gql":         "graphql",
+	"postgresql":  "sql",
+}
+
+// DefaultLanguageAliases returns a copy of the alias table used to group
+// code fence languages (e.g. "js" -> "javascript"). Extend it and pass it to
+// WithLanguageAliases to customize normalization.
+func DefaultLanguageAliases() map[string]string {
+	aliases := make(map[string]string, len(defaultLanguageAliases))
+	for alias, lang := range defaultLanguageAliases {
+		aliases[alias] = lang
+	}
+	return aliases
+}
+
+// WithLanguageAliases replaces the alias table used to group code blocks by
+// language, so GetCodeBlocks("javascript") also returns "js" blocks. Pass
+// nil to match fence languages exactly. CodeBlock.Language always keeps the
+// label as written.
+func WithLanguageAliases(aliases map[string]string) ParserOption {
+	return func(p *Parser) {
+		p.langAliases = aliases
+	}
+}
+
+// IsKnownLanguage reports whether name is a recognized code language or one
+// of its default aliases.
+func IsKnownLanguage(name string) bool {
+	return codeLanguages[NormalizeLanguage(name)]
+}
+
+// NormalizeLanguage returns the canonical name for a code fence language
+// using the default aliases ("JS" -> "javascript").
+func NormalizeLanguage(lang string) string {
+	return normalizeLanguage(defaultLanguageAliases, lang)
+}
+
+// normalizeLanguage lowercases lang and resolves it through aliases. With
+// nil aliases, lang is returned unchanged.
+func normalizeLanguage(aliases map[string]string, lang string) string {
+	if aliases == nil {
+		return lang
+	}
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if canonical, ok := aliases[lang]; ok {
+		return canonical
+	}
+	return lang
+}
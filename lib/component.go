@@ -0,0 +1,163 @@
+package mq
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// Component is an HTML block or MDX/JSX component tag embedded in
+// markdown, such as <Callout type="warning">...</Callout> or <Tabs />.
+// Components are extracted only by parsers created WithComponents.
+type Component struct {
+	Name        string            // Tag name as written, e.g. "Callout"
+	Attributes  map[string]string // Attribute values; bare attributes are "true" and {expressions} keep their braces
+	Content     string            // Source between the opening and closing tags ("" when self-closing)
+	Text        string            // Content as plain text
+	SelfClosing bool              // Written as <Name />
+	Section     *Section          // Enclosing section (nil before the first heading)
+	Node        ast.Node          // The block the opening tag starts
+	Line        int               // Line number of the opening tag
+}
+
+// WithComponents makes the parser extract HTML blocks and MDX-style
+// component tags as Components (see GetComponents). It is off by default,
+// as plain markdown rarely holds HTML worth querying.
+func WithComponents() ParserOption {
+	return func(p *Parser) {
+		p.components = true
+	}
+}
+
+// GetComponents returns the document's components in document order, or
+// only those with one of the given tag names. Components nested inside
+// another component are part of its Content rather than listed.
+func (d *Document) GetComponents(names ...string) []*Component {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if len(names) == 0 {
+		return slices.Clone(d.components)
+	}
+	var matched []*Component
+	for _, c := range d.components {
+		if contains(names, c.Name) {
+			matched = append(matched, c)
+		}
+	}
+	return matched
+}
+
+var (
+	// componentTag matches an opening tag at the start of the input. JSX
+	// attribute values may be quoted or {expressions}.
+	componentTag  = regexp.MustCompile(`^<([A-Za-z][\w.:-]*)((?:\s+[^\s=/>{}"']+(?:\s*=\s*(?:"[^"]*"|'[^']*'|\{[^{}]*\}|[^\s"'=<>{}` + "`" + `]+))?)*)\s*(/?)>`)
+	htmlTag       = regexp.MustCompile(`</?[A-Za-z][^<>]*>`)
+	componentAttr = regexp.MustCompile(`([^\s=/>{}"']+)(?:\s*=\s*("[^"]*"|'[^']*'|\{[^{}]*\}|[^\s"'=<>{}` + "`" + `]+))?`)
+)
+
+// parseComponent parses the component whose opening tag starts at offset,
+// returning it with the offset just past its closing tag. Tags without a
+// closing tag, such as <img>, are treated as self-closing.
+func (p *Parser) parseComponent(source []byte, offset int) (*Component, int, bool) {
+	m := componentTag.FindSubmatchIndex(source[offset:])
+	if m == nil {
+		return nil, 0, false
+	}
+	c := &Component{
+		Name:        string(source[offset+m[2] : offset+m[3]]),
+		Attributes:  make(map[string]string),
+		SelfClosing: m[6] != m[7],
+	}
+	for _, attr := range componentAttr.FindAllSubmatch(source[offset+m[4]:offset+m[5]], -1) {
+		value := "true"
+		if len(attr[2]) > 0 {
+			value = string(attr[2])
+			if value[0] == '"' || value[0] == '\'' {
+				value = value[1 : len(value)-1]
+			}
+		}
+		c.Attributes[string(attr[1])] = value
+	}
+
+	end := offset + m[1]
+	if c.SelfClosing {
+		return c, end, true
+	}
+	closeStart, closeEnd, ok := matchingCloseTag(source, end, c.Name)
+	if !ok {
+		c.SelfClosing = true
+		return c, end, true
+	}
+	c.Content = strings.Trim(string(source[end:closeStart]), "\n")
+	c.Text = p.componentText(c.Content)
+	return c, closeEnd, true
+}
+
+// matchingCloseTag finds the closing tag for name after from, skipping
+// nested tags of the same name.
+func matchingCloseTag(source []byte, from int, name string) (start, end int, ok bool) {
+	tag := regexp.MustCompile(`<(/?)` + regexp.QuoteMeta(name) + `(?:\s[^>]*?)?(/?)>`)
+	depth := 1
+	for _, m := range tag.FindAllSubmatchIndex(source[from:], -1) {
+		switch {
+		case m[3] > m[2]: // </name>
+			depth--
+			if depth == 0 {
+				return from + m[0], from + m[1], true
+			}
+		case m[5] == m[4]: // <name>, not <name/>
+			depth++
+		}
+	}
+	return 0, 0, false
+}
+
+// componentText renders a component's content, which is usually
+// markdown, as plain text. Tags of nested components are dropped, keeping
+// their text, and the content is dedented so that indented children are
+// not read as a code block.
+func (p *Parser) componentText(content string) string {
+	source := []byte(dedent(htmlTag.ReplaceAllString(content, "")))
+	root := p.md.Parser().Parse(text.NewReader(source))
+	return RenderPlainText(markdownTextBlocks(root, source), PlainTextOptions{})
+}
+
+// dedent removes the indentation shared by all non-blank lines.
+func dedent(s string) string {
+	lines := strings.Split(s, "\n")
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent <= 0 {
+		return s
+	}
+	for i, line := range lines {
+		if len(line) >= indent {
+			lines[i] = line[indent:]
+		} else {
+			lines[i] = strings.TrimLeft(line, " \t")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// startsWithTag reports whether a block's text starts with an opening tag.
+func startsWithTag(source []byte, offset int) bool {
+	rest := source[offset:]
+	if len(rest) < 2 || rest[0] != '<' {
+		return false
+	}
+	c := rest[1]
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
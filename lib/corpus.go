@@ -0,0 +1,235 @@
+package mq
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// CorpusIndex is an in-memory inverted index over the markdown files in a
+// directory. Files are parsed once; searches are answered from the index
+// without re-reading the corpus. It is safe for concurrent use.
+type CorpusIndex struct {
+	root   string
+	parser *Parser
+
+	mu       sync.RWMutex
+	files    map[string]*corpusFile            // by path
+	postings map[string]map[*corpusSection]int // term -> section -> count
+}
+
+// corpusFile records the sections indexed for one file.
+type corpusFile struct {
+	modTime  time.Time
+	sections []*corpusSection
+}
+
+// corpusSection is the indexed view of a section's own text (excluding
+// subsections, which are indexed separately).
+type corpusSection struct {
+	file    string
+	heading string
+	start   int
+	end     int
+	text    string
+	terms   map[string]int
+}
+
+// BuildCorpusIndex parses every markdown file under dir and indexes its
+// sections by term.
+func BuildCorpusIndex(dir string) (*CorpusIndex, error) {
+	idx := &CorpusIndex{
+		root:     dir,
+		parser:   NewParser(),
+		files:    make(map[string]*corpusFile),
+		postings: make(map[string]map[*corpusSection]int),
+	}
+	if err := idx.Refresh(); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// Refresh re-indexes files that changed since they were last indexed,
+// indexes new files and drops files that no longer exist.
+func (idx *CorpusIndex) Refresh() error {
+	seen := make(map[string]bool)
+
+	err := filepath.Walk(idx.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Skip errors
+		}
+		if info.IsDir() || !strings.HasSuffix(strings.ToLower(path), ".md") {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+
+		seen[path] = true
+		idx.mu.RLock()
+		existing, ok := idx.files[path]
+		idx.mu.RUnlock()
+		if ok && existing.modTime.Equal(info.ModTime()) {
+			return nil
+		}
+		return idx.Update(path)
+	})
+	if err != nil {
+		return fmt.Errorf("indexing %s: %w", idx.root, err)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for path := range idx.files {
+		if !seen[path] {
+			idx.removeLocked(path)
+		}
+	}
+	return nil
+}
+
+// Update (re-)indexes a single file, replacing any previous entries for it.
+// Files that fail to parse are removed from the index.
+func (idx *CorpusIndex) Update(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		idx.Remove(path)
+		return nil
+	}
+	doc, err := idx.parser.ParseFile(path)
+	if err != nil {
+		idx.Remove(path)
+		return nil // Skip unparseable files
+	}
+
+	file := &corpusFile{modTime: info.ModTime()}
+	for _, section := range doc.GetSections() {
+		text := section.OwnText()
+		file.sections = append(file.sections, &corpusSection{
+			file:    path,
+			heading: section.Heading.Text,
+			start:   section.Start,
+			end:     section.End,
+			text:    text,
+			terms:   countTerms(text),
+		})
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.removeLocked(path)
+	idx.files[path] = file
+	for _, s := range file.sections {
+		for term, n := range s.terms {
+			if idx.postings[term] == nil {
+				idx.postings[term] = make(map[*corpusSection]int)
+			}
+			idx.postings[term][s] = n
+		}
+	}
+	return nil
+}
+
+// Remove drops a file from the index.
+func (idx *CorpusIndex) Remove(path string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.removeLocked(path)
+}
+
+func (idx *CorpusIndex) removeLocked(path string) {
+	file, ok := idx.files[path]
+	if !ok {
+		return
+	}
+	for _, s := range file.sections {
+		for term := range s.terms {
+			delete(idx.postings[term], s)
+			if len(idx.postings[term]) == 0 {
+				delete(idx.postings, term)
+			}
+		}
+	}
+	delete(idx.files, path)
+}
+
+// Files returns the number of indexed files.
+func (idx *CorpusIndex) Files() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.files)
+}
+
+// Search returns sections containing every term in query, ranked by the
+// total number of term occurrences (highest first).
+func (idx *CorpusIndex) Search(query string) *SearchResults {
+	results := &SearchResults{Query: query}
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return results
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	scores := make(map[*corpusSection]int)
+	for s, n := range idx.postings[terms[0]] {
+		scores[s] = n
+	}
+	for _, term := range terms[1:] {
+		for s := range scores {
+			n, ok := idx.postings[term][s]
+			if !ok {
+				delete(scores, s)
+				continue
+			}
+			scores[s] += n
+		}
+	}
+
+	ranked := make([]*corpusSection, 0, len(scores))
+	for s := range scores {
+		ranked = append(ranked, s)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		if a.file != b.file {
+			return a.file < b.file
+		}
+		return a.start < b.start
+	})
+
+	for _, s := range ranked {
+		results.Matches = append(results.Matches, &SearchResult{
+			File:    s.file,
+			Section: s.heading,
+			Lines:   fmt.Sprintf("%d-%d", s.start, s.end),
+			Match:   extractSnippet(s.text, terms[0], 60),
+		})
+	}
+	return results
+}
+
+// tokenize lowercases text and splits it into letter/digit terms.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func countTerms(text string) map[string]int {
+	counts := make(map[string]int)
+	for _, term := range tokenize(text) {
+		counts[term]++
+	}
+	return counts
+}
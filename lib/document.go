@@ -1,9 +1,17 @@
 package mq
 
 import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"unicode/utf8"
 
 	"github.com/yuin/goldmark/ast"
+	"gopkg.in/yaml.v3"
 )
 
 // Document represents a parsed document with pre-computed indexes.
@@ -12,30 +20,50 @@ import (
 //
 // The structural types (Heading, Section, CodeBlock, etc.) are format-agnostic.
 // This allows the same MQL queries to work on any document regardless of source format.
+//
+// Concurrency: a Document does not change once its parser returns it,
+// except through SetMetadataField, and all methods (and MQL queries) may be
+// called from multiple goroutines. Returned slices are copies; returned
+// elements, maps such as Metadata, and decoded Data are shared and must be
+// treated as read-only.
 type Document struct {
 	source   []byte
+	original []byte // source before line ending normalization (nil: unchanged)
+	stats    *ParseStats
 	path     string
 	format   Format
 	metadata Metadata
 
+	frontmatter *yaml.Node // edited frontmatter (nil: as in source)
+
 	// Markdown-specific: AST from goldmark (nil for other formats)
 	root ast.Node
 
 	// Format-agnostic content
-	title        string // Document title (HTML: <title>, PDF: metadata, MD: first H1)
-	readableText string // Main content as plain text (for LLM context)
+	title        string      // Document title (HTML: <title>, PDF: metadata, MD: first H1)
+	readableText string      // Main content as plain text (for LLM context)
+	lang         string      // Declared natural language (e.g. HTML lang attribute)
+	data         interface{} // Decoded value for data formats (JSON, JSONL, YAML)
+	textBlocks   []TextBlock // Blocks of the readable text (HTML), for PlainText
+	elementOrder []Element   // Elements in source order (HTML), for Elements
 
 	// Pre-computed indexes for O(1) lookups
 	mu              sync.RWMutex
 	headingIndex    map[string]*Heading     // by text
+	headings        []*Heading              // all headings in document order
 	headingsByLevel map[int][]*Heading      // by level
 	sectionIndex    map[string]*Section     // by title
+	sectionByID     map[string]*Section     // by heading anchor (see GetSectionByID)
+	sections        []*Section              // all sections in document order
 	codeBlocks      []*CodeBlock            // all code blocks
-	codeByLang      map[string][]*CodeBlock // by language
+	codeByLang      map[string][]*CodeBlock // by normalized language ("" for unlabeled)
+	langAliases     map[string]string       // code language aliases (nil: exact match)
 	links           []*Link                 // all links
 	images          []*Image                // all images
 	tables          []*Table                // all tables
 	lists           []*List                 // all lists
+	strikethroughs  []*Strikethrough        // all ~~deleted~~ spans
+	components      []*Component            // HTML and MDX component blocks (see WithComponents)
 }
 
 // NewDocument creates a Document from pre-extracted structural elements.
@@ -68,37 +96,88 @@ func NewDocument(
 		headingIndex:    make(map[string]*Heading),
 		headingsByLevel: make(map[int][]*Heading),
 		sectionIndex:    make(map[string]*Section),
+		sectionByID:     make(map[string]*Section),
 		codeBlocks:      codeBlocks,
 		codeByLang:      make(map[string][]*CodeBlock),
 		links:           links,
 		images:          images,
 		tables:          tables,
 		lists:           lists,
+		langAliases:     defaultLanguageAliases,
 	}
 
 	// Build heading indexes
 	for _, h := range headings {
 		doc.headingIndex[h.Text] = h
+		doc.headings = append(doc.headings, h)
 		doc.headingsByLevel[h.Level] = append(doc.headingsByLevel[h.Level], h)
 	}
 
 	// Build section index
 	for _, s := range sections {
+		s.langAliases = doc.langAliases
+		s.doc = doc
 		if s.Heading != nil {
 			doc.sectionIndex[s.Heading.Text] = s
+			doc.indexAnchor(s)
+			doc.sections = append(doc.sections, s)
 		}
 	}
 
 	// Build code block language index
 	for _, cb := range codeBlocks {
-		if cb.Language != "" {
-			doc.codeByLang[cb.Language] = append(doc.codeByLang[cb.Language], cb)
+		lang := normalizeLanguage(doc.langAliases, cb.Language)
+		doc.codeByLang[lang] = append(doc.codeByLang[lang], cb)
+	}
+
+	if len(doc.sections) == 0 {
+		text := []byte(readableText)
+		root := doc.addImplicitSection(text, 1, len(computeLineStarts(text)))
+		for _, cb := range codeBlocks {
+			root.AddCodeBlock(cb)
+		}
+		for _, t := range tables {
+			root.AddTable(t)
+		}
+		for _, img := range images {
+			root.AddImage(img)
 		}
 	}
 
 	return doc
 }
 
+// addImplicitSection gives a document without headings a single section
+// spanning lines start to end of source, so that its content stays
+// reachable through .sections, .section and the tree. The section's heading
+// is synthesized from the document title or file name and is not listed
+// among the document's headings.
+func (d *Document) addImplicitSection(source []byte, start, end int) *Section {
+	root := d.implicitSection(source, start, end)
+	d.sectionIndex[root.Heading.Text] = root
+	d.sections = append(d.sections, root)
+	return root
+}
+
+// implicitSection returns an implicit section spanning lines start to end
+// of source, without adding it to the document.
+func (d *Document) implicitSection(source []byte, start, end int) *Section {
+	title := d.Title()
+	if title == "" {
+		title = "Document"
+	}
+
+	return &Section{
+		Heading:     &Heading{Level: 1, Text: title},
+		Start:       start,
+		End:         end,
+		Implicit:    true,
+		source:      source,
+		doc:         d,
+		langAliases: d.langAliases,
+	}
+}
+
 // NewHTMLDocument is a convenience constructor for HTML documents.
 // Deprecated: Use NewDocument with FormatHTML instead.
 func NewHTMLDocument(
@@ -132,19 +211,48 @@ func (d *Document) Source() []byte {
 	return d.source
 }
 
-// Title returns the document title.
-// For HTML: <title> tag
-// For PDF: document metadata
-// For Markdown: first H1 heading or empty
+// Normalized reports whether the source was changed by line ending
+// normalization when parsing.
+func (d *Document) Normalized() bool {
+	return d.original != nil
+}
+
+// OriginalSource returns the content as it was passed to the parser, before
+// any normalization. It is the same as Source when nothing was changed.
+func (d *Document) OriginalSource() []byte {
+	if d.original != nil {
+		return d.original
+	}
+	return d.source
+}
+
+// Title returns the document title, resolved the same way for every
+// format, from the first of:
+//   - the frontmatter title field
+//   - the title the format declares: HTML <title> (else og:title), PDF
+//     metadata, or the title inferred for JSON/YAML data
+//   - the first H1 heading
+//   - the file name without its extension
+//
+// It is empty only for a document without any of these.
 func (d *Document) Title() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if title, ok := d.metadata["title"].(string); ok && strings.TrimSpace(title) != "" {
+		return strings.TrimSpace(title)
+	}
 	if d.title != "" {
 		return d.title
 	}
-	// Fall back to first H1 for markdown
 	if headings := d.headingsByLevel[1]; len(headings) > 0 {
 		return headings[0].Text
 	}
-	return ""
+	if d.path == "" {
+		return ""
+	}
+	base := filepath.Base(d.path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
 }
 
 // ReadableText returns the main content as plain text.
@@ -158,26 +266,207 @@ func (d *Document) ReadableText() string {
 	return d.readableText
 }
 
+// GetTextContent returns the document text without frontmatter or markup:
+// PlainText with code blocks and no markers for markdown, and the readable
+// text of other formats.
+func (d *Document) GetTextContent() string {
+	if d.root != nil {
+		return d.PlainText(PlainTextOptions{CodeBlocks: true})
+	}
+	return d.readableText
+}
+
+// SizeReport compares the size of a document's source with the size of its
+// extracted text.
+type SizeReport struct {
+	SourceBytes   int     // Length of the raw source in bytes
+	ReadableChars int     // Characters (runes) of the extracted text, as returned by GetTextContent
+	Ratio         float64 // Fraction of the source's bytes removed, from 0 to 1
+}
+
+// SizeReduction reports how much smaller the extracted text is than the
+// source, e.g. a Ratio of 0.9 for an HTML page whose readable text is a
+// tenth of its markup. The Ratio compares bytes with bytes, so non-ASCII
+// text does not shift it. An empty source reports a Ratio of 0.
+func (d *Document) SizeReduction() SizeReport {
+	text := d.GetTextContent()
+	report := SizeReport{
+		SourceBytes:   len(d.source),
+		ReadableChars: utf8.RuneCountInString(text),
+	}
+	if report.SourceBytes > 0 && len(text) < report.SourceBytes {
+		report.Ratio = 1 - float64(len(text))/float64(report.SourceBytes)
+	}
+	return report
+}
+
+// GetTextContentWithMetadata returns GetTextContent preceded by the
+// MetadataText block and a blank line, so metadata stays in context when
+// the text is embedded. Without frontmatter it equals GetTextContent.
+func (d *Document) GetTextContentWithMetadata() string {
+	return withMetadataText(d.MetadataText(), d.GetTextContent())
+}
+
+func withMetadataText(meta, text string) string {
+	if meta == "" {
+		return text
+	}
+	return meta + "\n" + text
+}
+
+// Data returns the decoded value for data formats (JSON, JSONL, YAML).
+// Objects decode to map[string]interface{}, arrays to []interface{}.
+// Returns nil for document formats.
+func (d *Document) Data() interface{} {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.data
+}
+
+// SetData attaches the decoded value of a data format document.
+// This is used by the data parsers after building the structural view.
+func (d *Document) SetData(data interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.data = data
+}
+
+// GetLines returns the raw source text for the 1-based, inclusive line range
+// [start, end], as reported by .tree and .search. Out-of-range bounds are
+// clamped; an empty string is returned when the range is empty.
+func (d *Document) GetLines(start, end int) string {
+	lines := strings.Split(string(d.source), "\n")
+	if start < 1 {
+		start = 1
+	}
+	if end > len(lines) {
+		end = len(lines)
+	}
+	if start > end {
+		return ""
+	}
+	return strings.Join(lines[start-1:end], "\n")
+}
+
+// Lead returns the text of the document's first non-empty top-level
+// paragraph, or "" if there is none (always "" for HTML and PDF).
+func (d *Document) Lead() string {
+	if d.root == nil {
+		return ""
+	}
+	for node := d.root.FirstChild(); node != nil; node = node.NextSibling() {
+		if text := leadText(node, d.source); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
 // AST returns the root AST node (Markdown only).
 // Returns nil for HTML and PDF documents.
 func (d *Document) AST() ast.Node {
 	return d.root
 }
 
-// Metadata returns the document's frontmatter metadata.
+// Metadata returns the document's frontmatter metadata. The map is not
+// modified afterwards (SetMetadataField replaces it), so it is safe to read
+// while other goroutines set fields.
 func (d *Document) Metadata() Metadata {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 	return d.metadata
 }
 
 // GetMetadataField retrieves a specific metadata field.
 func (d *Document) GetMetadataField(key string) (interface{}, bool) {
-	if d.metadata == nil {
+	metadata := d.Metadata()
+	if metadata == nil {
 		return nil, false
 	}
-	val, ok := d.metadata[key]
+	val, ok := metadata[key]
 	return val, ok
 }
 
+// GetNestedField retrieves a metadata field by dotted path, e.g.
+// "config.sidebar.position". Array elements are addressed by index, either
+// as "authors[0].name" or "authors.0.name". A top-level key containing dots
+// is matched literally before the path is split.
+func (d *Document) GetNestedField(path string) (interface{}, bool) {
+	if val, ok := d.GetMetadataField(path); ok {
+		return val, true
+	}
+	metadata := d.Metadata()
+	if metadata == nil || path == "" {
+		return nil, false
+	}
+
+	var current interface{} = map[string]interface{}(metadata)
+	for _, key := range splitFieldPath(path) {
+		val, ok := lookupField(current, key)
+		if !ok {
+			return nil, false
+		}
+		current = val
+	}
+	return current, true
+}
+
+// splitFieldPath splits "a.b[0].c" into ["a", "b", "0", "c"].
+func splitFieldPath(path string) []string {
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	var keys []string
+	for _, key := range strings.Split(path, ".") {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// lookupField reads key from a decoded object (see LookupKey), or an index
+// from a decoded array.
+func lookupField(obj interface{}, key string) (interface{}, bool) {
+	if m, ok := obj.([]interface{}); ok {
+		idx, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, false
+		}
+		if idx < 0 {
+			idx += len(m)
+		}
+		if idx < 0 || idx >= len(m) {
+			return nil, false
+		}
+		return m[idx], true
+	}
+	return LookupKey(obj, key)
+}
+
+// LookupKey reads key from a decoded object. Frontmatter and JSON decode
+// objects with string keys, while nested YAML (yaml.v2) objects use
+// interface{} keys that may have decoded as numbers or booleans (as in
+// `1: one`); those are matched by their text.
+func LookupKey(obj interface{}, key string) (interface{}, bool) {
+	switch m := obj.(type) {
+	case Metadata:
+		val, ok := m[key]
+		return val, ok
+	case map[string]interface{}:
+		val, ok := m[key]
+		return val, ok
+	case map[interface{}]interface{}:
+		if val, ok := m[key]; ok {
+			return val, true
+		}
+		for k, val := range m {
+			if fmt.Sprintf("%v", k) == key {
+				return val, true
+			}
+		}
+	}
+	return nil, false
+}
+
 // GetOwner returns the owner from metadata.
 func (d *Document) GetOwner() (string, bool) {
 	val, ok := d.GetMetadataField("owner")
@@ -228,25 +517,16 @@ func (d *Document) GetPriority() (string, bool) {
 	return priority, ok
 }
 
-// GetHeadings returns headings, optionally filtered by level.
+// GetHeadings returns headings in document order, optionally filtered by
+// level.
 func (d *Document) GetHeadings(levels ...int) []*Heading {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	if len(levels) == 0 {
-		// Return all headings
-		var all []*Heading
-		for level := 1; level <= 6; level++ {
-			all = append(all, d.headingsByLevel[level]...)
-		}
-		return all
-	}
-
-	// Return headings of specified levels
 	var result []*Heading
-	for _, level := range levels {
-		if level >= 1 && level <= 6 {
-			result = append(result, d.headingsByLevel[level]...)
+	for _, h := range d.headings {
+		if len(levels) == 0 || containsInt(levels, h.Level) {
+			result = append(result, h)
 		}
 	}
 	return result
@@ -261,6 +541,40 @@ func (d *Document) GetHeadingByText(text string) (*Heading, bool) {
 	return heading, ok
 }
 
+// GetHeadingByID returns a heading by its anchor, without the leading "#".
+// See GetSectionByID.
+func (d *Document) GetHeadingByID(id string) (*Heading, bool) {
+	section, ok := d.GetSectionByID(id)
+	if !ok {
+		return nil, false
+	}
+	return section.Heading, true
+}
+
+// GetSectionByID returns the section whose heading has the given anchor,
+// without the leading "#": its ID (auto-generated for markdown, e.g.
+// "oauth2-flow"), or for headings without one the slug GenerateTOC links
+// to. When anchors collide the first section wins.
+func (d *Document) GetSectionByID(id string) (*Section, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	section, ok := d.sectionByID[id]
+	return section, ok
+}
+
+// indexAnchor adds s to the anchor index unless an earlier section holds
+// the same anchor.
+func (d *Document) indexAnchor(s *Section) {
+	if s.Implicit {
+		return
+	}
+	anchor := headingAnchor(s.Heading)
+	if _, taken := d.sectionByID[anchor]; anchor != "" && !taken {
+		d.sectionByID[anchor] = s
+	}
+}
+
 // GetSection returns a section by title.
 func (d *Document) GetSection(title string) (*Section, bool) {
 	d.mu.RLock()
@@ -275,24 +589,142 @@ func (d *Document) GetSections() []*Section {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	sections := make([]*Section, 0, len(d.sectionIndex))
-	for _, section := range d.sectionIndex {
-		sections = append(sections, section)
-	}
+	sections := make([]*Section, len(d.sections))
+	copy(sections, d.sections)
 	return sections
 }
 
-// GetCodeBlocks returns code blocks, optionally filtered by language.
+// IterSections calls fn for each section that GetSections would return, in
+// the same order, until fn returns false. Unlike GetSections it does not
+// copy the section list, so scanning a huge document for the first match
+// allocates nothing. fn may call other Document methods.
+func (d *Document) IterSections(fn func(*Section) bool) {
+	d.mu.RLock()
+	sections := d.sections
+	d.mu.RUnlock()
+
+	for _, s := range sections {
+		if !fn(s) {
+			return
+		}
+	}
+}
+
+// GetSectionsMatching returns the sections whose heading text matches re,
+// in document order.
+func (d *Document) GetSectionsMatching(re *regexp.Regexp) []*Section {
+	var matched []*Section
+	for _, s := range d.sectionsInOrder() {
+		if !s.Implicit && re.MatchString(s.Heading.Text) {
+			matched = append(matched, s)
+		}
+	}
+	return matched
+}
+
+// GetHeadingsMatching returns the headings whose text matches re, in
+// document order, optionally only those of the given levels.
+func (d *Document) GetHeadingsMatching(re *regexp.Regexp, levels ...int) []*Heading {
+	var matched []*Heading
+	for _, s := range d.sectionsInOrder() {
+		h := s.Heading
+		if s.Implicit || (len(levels) > 0 && !containsInt(levels, h.Level)) {
+			continue
+		}
+		if re.MatchString(h.Text) {
+			matched = append(matched, h)
+		}
+	}
+	return matched
+}
+
+// sectionsInOrder returns every section with a heading in document order,
+// descending into children. Formats differ in whether GetSections lists
+// subsections, so the tree is walked from the top-level sections.
+func (d *Document) sectionsInOrder() []*Section {
+	var ordered []*Section
+	var walk func(sections []*Section)
+	walk = func(sections []*Section) {
+		for _, s := range sections {
+			if s.Heading != nil {
+				ordered = append(ordered, s)
+			}
+			walk(s.Children)
+		}
+	}
+
+	var roots []*Section
+	for _, s := range d.GetSections() {
+		if s.Parent == nil {
+			roots = append(roots, s)
+		}
+	}
+	walk(roots)
+	return ordered
+}
+
+// GetSectionByPath returns the section reached by following a chain of
+// heading texts, e.g. GetSectionByPath("API", "Authentication").
+// The first component may name any section; each following component must
+// name a direct child of the previous one. Exact matches are preferred over
+// case-insensitive ones, which disambiguates sections sharing a title.
+func (d *Document) GetSectionByPath(path ...string) (*Section, bool) {
+	if len(path) == 0 {
+		return nil, false
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	section := resolveSectionPath(d.sections, path)
+	return section, section != nil
+}
+
+// resolveSectionPath matches path[0] against candidates and descends into
+// children for the remaining components, backtracking on dead ends.
+func resolveSectionPath(candidates []*Section, path []string) *Section {
+	for _, exact := range []bool{true, false} {
+		for _, section := range candidates {
+			if section.Heading == nil || !headingMatches(section.Heading.Text, path[0], exact) {
+				continue
+			}
+			if len(path) == 1 {
+				return section
+			}
+			if found := resolveSectionPath(section.Children, path[1:]); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+func headingMatches(text, name string, exact bool) bool {
+	if exact {
+		return text == name
+	}
+	return strings.EqualFold(text, name)
+}
+
+// GetCodeBlocks returns code blocks, optionally filtered by language. The
+// empty language selects unlabeled fences. Languages are matched through the
+// parser's aliases, so "javascript" also returns "js" blocks.
 func (d *Document) GetCodeBlocks(languages ...string) []*CodeBlock {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
 	if len(languages) == 0 {
-		return d.codeBlocks
+		return slices.Clone(d.codeBlocks)
 	}
 
 	var result []*CodeBlock
+	seen := make(map[string]bool)
 	for _, lang := range languages {
+		lang = normalizeLanguage(d.langAliases, lang)
+		if seen[lang] {
+			continue
+		}
+		seen[lang] = true
 		result = append(result, d.codeByLang[lang]...)
 	}
 	return result
@@ -303,7 +735,7 @@ func (d *Document) GetLinks() []*Link {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	return d.links
+	return slices.Clone(d.links)
 }
 
 // GetImages returns all images in the document.
@@ -311,7 +743,7 @@ func (d *Document) GetImages() []*Image {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	return d.images
+	return slices.Clone(d.images)
 }
 
 // GetTables returns all tables in the document.
@@ -319,7 +751,15 @@ func (d *Document) GetTables() []*Table {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	return d.tables
+	return slices.Clone(d.tables)
+}
+
+// GetStrikethroughs returns all struck-through spans in document order.
+func (d *Document) GetStrikethroughs() []*Strikethrough {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return slices.Clone(d.strikethroughs)
 }
 
 // GetLists returns all lists in the document.
@@ -328,7 +768,7 @@ func (d *Document) GetLists(ordered *bool) []*List {
 	defer d.mu.RUnlock()
 
 	if ordered == nil {
-		return d.lists
+		return slices.Clone(d.lists)
 	}
 
 	var result []*List
@@ -340,19 +780,146 @@ func (d *Document) GetLists(ordered *bool) []*List {
 	return result
 }
 
-// GetTableOfContents returns the hierarchical structure of headings.
-func (d *Document) GetTableOfContents() []*Section {
+// GetListItems returns the items of every list in document order, nested
+// items following their parent, each with its nesting depth. Ordered items
+// are numbered from their list's Start; nested ordered lists are numbered
+// from 1, as ListItem does not record their start.
+func (d *Document) GetListItems() []FlatListItem {
+	var items []FlatListItem
+	var walk func([]ListItem, int)
+	walk = func(list []ListItem, start int) {
+		for i, item := range list {
+			flat := FlatListItem{
+				Text:    item.Text,
+				Depth:   item.Depth,
+				Ordered: item.Ordered,
+				Checked: item.Checked,
+			}
+			if item.Ordered {
+				flat.Number = start + i
+			}
+			items = append(items, flat)
+			walk(item.Children, 1)
+		}
+	}
+	for _, list := range d.GetLists(nil) {
+		walk(list.Items, list.Start)
+	}
+	return items
+}
+
+// GetTableOfContents returns the hierarchical structure of headings: the
+// top-level sections, with subsections as their Children. An optional
+// maxLevel (1-6) prunes sections with deeper headings, moving up any of
+// their subsections that are not; a maxLevel above the document's
+// shallowest heading is raised to it, so a document starting at H3 still
+// lists its H3 sections. The result is then a copy of the tree whose
+// Parent, Children, Next, Prev and Siblings point within the copy, and
+// whose Start/End lines still cover the pruned subsections. When no
+// heading is deeper than maxLevel, the document's own sections are
+// returned.
+func (d *Document) GetTableOfContents(maxLevel ...int) []*Section {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
+	limit := 0
+	if len(maxLevel) > 0 {
+		limit = maxLevel[0]
+	}
+
 	// Return top-level sections
 	var toc []*Section
-	for _, section := range d.sectionIndex {
-		if section.Parent == nil {
-			toc = append(toc, section)
+	shallowest := 0
+	for _, section := range d.sections {
+		if section.Parent != nil {
+			continue
+		}
+		toc = append(toc, section)
+		if shallowest == 0 || section.Heading.Level < shallowest {
+			shallowest = section.Heading.Level
+		}
+	}
+	if limit > 0 && limit < shallowest {
+		limit = shallowest
+	}
+	pruned := false
+	for _, section := range toc {
+		if limit > 0 && deeperThan(section, limit) {
+			pruned = true
 		}
 	}
-	return toc
+	if !pruned {
+		return toc
+	}
+
+	// Copy the whole top level, so that the copies are each other's peers
+	var level []*Section
+	for _, section := range toc {
+		level = append(level, pruneSection(section, nil, limit)...)
+	}
+	for _, section := range level {
+		section.topLevel = slices.Clone(level)
+	}
+	return level
+}
+
+// deeperThan reports whether s or any of its descendants has a heading
+// level above maxLevel.
+func deeperThan(s *Section, maxLevel int) bool {
+	if s.Heading.Level > maxLevel {
+		return true
+	}
+	for _, child := range s.Children {
+		if deeperThan(child, maxLevel) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSectionsAtDepth returns the sections nested depth levels deep in the
+// section tree, in document order: depth 1 is the top-level sections,
+// depth 2 their subsections, and so on. Depth counts nesting rather than
+// heading level, so an H3 directly under an H1 is at depth 2. With
+// deeper, sections nested below depth are included too.
+func (d *Document) GetSectionsAtDepth(depth int, deeper bool) []*Section {
+	var sections []*Section
+	var walk func(children []*Section, n int)
+	walk = func(children []*Section, n int) {
+		for _, s := range children {
+			if n == depth || deeper && n > depth {
+				sections = append(sections, s)
+			}
+			if n < depth || deeper {
+				walk(s.Children, n+1)
+			}
+		}
+	}
+	if depth >= 1 {
+		walk(d.GetTableOfContents(), 1)
+	}
+	return sections
+}
+
+// pruneSection copies s under parent, keeping only descendants whose
+// heading level is at most maxLevel. When s itself is deeper, its pruned
+// subsections take its place.
+func pruneSection(s, parent *Section, maxLevel int) []*Section {
+	if s.Heading.Level > maxLevel {
+		var promoted []*Section
+		for _, child := range s.Children {
+			promoted = append(promoted, pruneSection(child, parent, maxLevel)...)
+		}
+		return promoted
+	}
+
+	pruned := *s
+	pruned.Parent = parent
+	pruned.Children = nil
+	for _, child := range s.Children {
+		pruned.Children = append(pruned.Children, pruneSection(child, &pruned, maxLevel)...)
+	}
+	return []*Section{&pruned}
 }
 
 // Walk traverses the document AST with a visitor function.
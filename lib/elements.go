@@ -0,0 +1,118 @@
+package mq
+
+import (
+	"slices"
+	"sort"
+)
+
+// ElementKind identifies the type of a structural element.
+type ElementKind string
+
+const (
+	ElementHeading ElementKind = "heading"
+	ElementCode    ElementKind = "code"
+	ElementLink    ElementKind = "link"
+	ElementImage   ElementKind = "image"
+	ElementTable   ElementKind = "table"
+	ElementList    ElementKind = "list"
+)
+
+// Element is implemented by every structural element (Heading, CodeBlock,
+// Link, Image, Table and List) so documents can be walked linearly.
+// The accessor is GetLine rather than Line because each element already
+// exposes its line number as the Line field.
+type Element interface {
+	Kind() ElementKind
+	GetLine() int
+}
+
+func (h *Heading) Kind() ElementKind   { return ElementHeading }
+func (c *CodeBlock) Kind() ElementKind { return ElementCode }
+func (l *Link) Kind() ElementKind      { return ElementLink }
+func (i *Image) Kind() ElementKind     { return ElementImage }
+func (t *Table) Kind() ElementKind     { return ElementTable }
+func (l *List) Kind() ElementKind      { return ElementList }
+
+// GetLine returns the line number the heading starts on.
+func (h *Heading) GetLine() int { return h.Line }
+
+// GetLine returns the line number of the opening fence.
+func (c *CodeBlock) GetLine() int { return c.Line }
+
+// GetLine returns the line number the link starts on.
+func (l *Link) GetLine() int { return l.Line }
+
+// GetLine returns the line number the image starts on.
+func (i *Image) GetLine() int { return i.Line }
+
+// GetLine returns the line number of the header row.
+func (t *Table) GetLine() int { return t.Line }
+
+// GetLine returns the line number of the first item.
+func (l *List) GetLine() int { return l.Line }
+
+// Elements returns every structural element in source order. Markdown
+// elements are ordered by line, and elements on the same line by column, so
+// a link inside a heading follows the heading and an image inside a link
+// follows the link. HTML elements keep the order the parser found them in
+// (see SetElementOrder). PDF and data documents record no positions, so
+// their headings, code blocks, tables, lists, links and images come in
+// turn, each in document order.
+func (d *Document) Elements() []Element {
+	d.mu.RLock()
+	order := d.elementOrder
+	d.mu.RUnlock()
+	if order != nil {
+		return slices.Clone(order)
+	}
+
+	var elements []Element
+	for _, h := range d.GetHeadings() {
+		elements = append(elements, h)
+	}
+	for _, cb := range d.GetCodeBlocks() {
+		elements = append(elements, cb)
+	}
+	for _, t := range d.GetTables() {
+		elements = append(elements, t)
+	}
+	for _, l := range d.GetLists(nil) {
+		elements = append(elements, l)
+	}
+	for _, link := range d.GetLinks() {
+		elements = append(elements, link)
+	}
+	for _, img := range d.GetImages() {
+		elements = append(elements, img)
+	}
+
+	sort.SliceStable(elements, func(i, j int) bool {
+		a, b := elements[i], elements[j]
+		if a.GetLine() != b.GetLine() {
+			return a.GetLine() < b.GetLine()
+		}
+		return elementCol(a) < elementCol(b)
+	})
+	return elements
+}
+
+// elementCol returns the starting column of inline elements; block
+// elements start their line.
+func elementCol(e Element) int {
+	switch v := e.(type) {
+	case *Link:
+		return v.Col
+	case *Image:
+		return v.Col
+	}
+	return 1
+}
+
+// SetElementOrder records the document's elements in source order, for
+// Elements. This is used by parsers of formats without line numbers, which
+// find elements in order but cannot sort them afterwards.
+func (d *Document) SetElementOrder(elements []Element) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.elementOrder = elements
+}
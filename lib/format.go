@@ -60,6 +60,13 @@ func (f Format) String() string {
 	}
 }
 
+// HasPositions reports whether documents of the format record the line
+// and column of their elements. Only markdown does; elements of other
+// formats report line 0.
+func (f Format) HasPositions() bool {
+	return f == FormatMarkdown
+}
+
 // FormatParser converts raw content into a unified Document structure.
 // Each format implements this interface to produce the same structural types.
 //
@@ -108,7 +115,7 @@ func DetectFormat(path string, content []byte) Format {
 	// First try extension
 	ext := strings.ToLower(filepath.Ext(path))
 	switch ext {
-	case ".md", ".markdown", ".mdown", ".mkd":
+	case ".md", ".mdx", ".markdown", ".mdown", ".mkd":
 		return FormatMarkdown
 	case ".html", ".htm", ".xhtml":
 		return FormatHTML
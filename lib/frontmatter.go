@@ -0,0 +1,406 @@
+package mq
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark"
+	meta "github.com/yuin/goldmark-meta"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"gopkg.in/yaml.v3"
+)
+
+// frontmatterParser only understands the frontmatter block; it is used to
+// decode metadata without building the body's AST.
+var frontmatterParser = goldmark.New(goldmark.WithExtensions(meta.Meta))
+
+// ParseFrontmatterOnly decodes the leading YAML frontmatter of a markdown
+// document without parsing the body. It returns nil metadata when the
+// content has no frontmatter. Values decode exactly as Document.Metadata
+// would, but no structural accessors (headings, sections, ...) exist for
+// the result; use Parser.Parse when those are needed.
+func ParseFrontmatterOnly(content []byte) (Metadata, error) {
+	end := frontmatterEnd(content)
+	if end == 0 {
+		return nil, nil
+	}
+
+	ctx := parser.NewContext()
+	frontmatterParser.Parser().Parse(text.NewReader(content[:end]), parser.WithContext(ctx))
+	data, err := meta.TryGet(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("parsing frontmatter: %w", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+	return Metadata(data), nil
+}
+
+// ParseFrontmatterFile reads only as much of a file as needed to decode its
+// frontmatter, which keeps metadata scans over large corpora cheap.
+func ParseFrontmatterFile(path string) (Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+	defer f.Close()
+
+	var head bytes.Buffer
+	reader := bufio.NewReader(f)
+	for lineNum := 0; ; lineNum++ {
+		line, err := reader.ReadBytes('\n')
+		head.Write(line)
+		if lineNum == 0 && !isFrontmatterSeparator(line) {
+			return nil, nil
+		}
+		if lineNum > 0 && isFrontmatterSeparator(line) {
+			break
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading file: %w", err)
+		}
+	}
+
+	return ParseFrontmatterOnly(head.Bytes())
+}
+
+// FieldInfo describes a top-level frontmatter field.
+type FieldInfo struct {
+	Key  string // Field name
+	Type string // string, number, bool, date, array, object or null
+}
+
+// Fields describes each top-level frontmatter field and its inferred type,
+// in source order.
+func (d *Document) Fields() []FieldInfo {
+	var fields []FieldInfo
+	metadata := d.Metadata()
+	for _, key := range d.MetadataKeys() {
+		fields = append(fields, FieldInfo{Key: key, Type: valueType(metadata[key])})
+	}
+	return fields
+}
+
+// MetadataText formats the frontmatter as one "Key: value" line per field,
+// in source order, e.g. "Title: Guide\nTags: go, cli\n". Arrays are joined
+// with ", ", dates use YYYY-MM-DD and objects list their keys sorted.
+func (d *Document) MetadataText() string {
+	var b strings.Builder
+	metadata := d.Metadata()
+	for _, key := range d.MetadataKeys() {
+		label := key
+		if r, size := utf8.DecodeRuneInString(key); size > 0 {
+			label = string(unicode.ToUpper(r)) + key[size:]
+		}
+		fmt.Fprintf(&b, "%s: %s\n", label, formatMetadataValue(metadata[key]))
+	}
+	return b.String()
+}
+
+func formatMetadataValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return val.Format("2006-01-02")
+	case []interface{}:
+		parts := make([]string, len(val))
+		for i, item := range val {
+			parts[i] = formatMetadataValue(item)
+		}
+		return strings.Join(parts, ", ")
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			m[fmt.Sprint(k)] = item
+		}
+		return formatMetadataValue(m)
+	case Metadata:
+		return formatMetadataValue(map[string]interface{}(val))
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = k + ": " + formatMetadataValue(val[k])
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	}
+	return fmt.Sprint(v)
+}
+
+// MetadataKeys returns the top-level frontmatter keys in the order they
+// appear in the source. Documents whose metadata did not come from a
+// frontmatter block (or whose order cannot be recovered) get sorted keys.
+func (d *Document) MetadataKeys() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if len(d.metadata) == 0 {
+		return nil
+	}
+
+	var keys []string
+	seen := make(map[string]bool)
+	for _, key := range d.frontmatterKeys() {
+		if _, ok := d.metadata[key]; ok && !seen[key] {
+			keys = append(keys, key)
+			seen[key] = true
+		}
+	}
+
+	var rest []string
+	for key := range d.metadata {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
+
+// FrontmatterNode returns the frontmatter as a YAML node tree, preserving
+// key order, comments and scalar styles that the decoded Metadata map
+// loses. Tools that rewrite a field in place can edit the tree and encode
+// it back with yaml.Marshal, or use SetMetadataField. Node lines are
+// document lines (of RenderWithFrontmatter's output after edits). Each call
+// parses a fresh tree, so callers may modify it freely. It returns false
+// when the document has no frontmatter or it is not valid YAML.
+func (d *Document) FrontmatterNode() (*yaml.Node, bool) {
+	d.mu.RLock()
+	source := d.source
+	if d.frontmatter != nil {
+		source = d.renderWithFrontmatter()
+	}
+	d.mu.RUnlock()
+	node, err := parseFrontmatterNode(source)
+	return node, err == nil && node != nil
+}
+
+// SetMetadataField sets a top-level frontmatter field, adding it (and a
+// frontmatter block, if the document has none) when missing. Comments and
+// the formatting of other fields are preserved. The change shows in
+// Metadata and the other metadata accessors and is written out by
+// RenderWithFrontmatter; the parsed body and its line numbers are
+// unchanged. It is safe to call concurrently with the other methods.
+func (d *Document) SetMetadataField(key string, value interface{}) error {
+	if d.format != FormatMarkdown {
+		return fmt.Errorf("setting %q: frontmatter requires a markdown document, not %s", key, d.format)
+	}
+	if key == "" {
+		return fmt.Errorf("setting metadata: empty key")
+	}
+
+	var encoded yaml.Node
+	if err := encoded.Encode(value); err != nil {
+		return fmt.Errorf("setting %q: %w", key, err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	root := d.frontmatter
+	if root == nil {
+		var err error
+		if root, err = parseFrontmatterNode(d.source); err != nil {
+			return fmt.Errorf("setting %q: parsing frontmatter: %w", key, err)
+		}
+		if root == nil {
+			root = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+		}
+	}
+	mapping := root.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return fmt.Errorf("setting %q: frontmatter is not a mapping", key)
+	}
+	setMappingValue(mapping, key, &encoded)
+	d.frontmatter = root
+
+	// Copy rather than mutate: the old map may be shared with callers
+	metadata := make(Metadata, len(d.metadata)+1)
+	for k, v := range d.metadata {
+		metadata[k] = v
+	}
+	metadata[key] = value
+	d.metadata = metadata
+	return nil
+}
+
+// setMappingValue replaces key's value in mapping, keeping the old value's
+// comments (and quoting, for a scalar of the same type), or appends the key.
+func setMappingValue(mapping *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != key {
+			continue
+		}
+		old := mapping.Content[i+1]
+		value.HeadComment, value.LineComment, value.FootComment = old.HeadComment, old.LineComment, old.FootComment
+		if old.Kind == yaml.ScalarNode && value.Kind == yaml.ScalarNode && old.Tag == value.Tag {
+			value.Style = old.Style
+		}
+		mapping.Content[i+1] = value
+		return
+	}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+// RenderWithFrontmatter returns the document source with the frontmatter
+// re-encoded to include SetMetadataField edits. The body is kept byte for
+// byte, and a document without edits is returned unchanged.
+func (d *Document) RenderWithFrontmatter() []byte {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.renderWithFrontmatter()
+}
+
+// renderWithFrontmatter is RenderWithFrontmatter for callers holding d.mu.
+func (d *Document) renderWithFrontmatter() []byte {
+	if d.frontmatter == nil {
+		return bytes.Clone(d.source)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	// The tree only holds nodes yaml encoded itself, so this cannot fail
+	_ = enc.Encode(d.frontmatter)
+	_ = enc.Close()
+	buf.WriteString("---\n")
+
+	end := frontmatterEnd(d.source)
+	if end == 0 {
+		buf.WriteString("\n") // separate a new block from the body
+	}
+	buf.Write(d.source[end:])
+	return buf.Bytes()
+}
+
+// parseFrontmatterNode parses source's frontmatter block into a document
+// node. It returns nil when there is no block or the block is empty.
+func parseFrontmatterNode(source []byte) (*yaml.Node, error) {
+	end := frontmatterEnd(source)
+	if end == 0 {
+		return nil, nil
+	}
+	// Drop the opening and closing separator lines
+	lines := bytes.SplitAfter(bytes.TrimRight(source[:end], "\r\n"), []byte("\n"))[1:]
+	if n := len(lines); n > 0 && isFrontmatterSeparator(lines[n-1]) {
+		lines = lines[:n-1]
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(bytes.Join(lines, nil), &node); err != nil {
+		return nil, err
+	}
+	if len(node.Content) == 0 {
+		return nil, nil
+	}
+	shiftLines(&node, 1) // the opening separator
+	return &node, nil
+}
+
+func shiftLines(node *yaml.Node, delta int) {
+	node.Line += delta
+	for _, child := range node.Content {
+		shiftLines(child, delta)
+	}
+}
+
+// frontmatterKeys returns the top-level frontmatter keys in source order,
+// including keys added by SetMetadataField, or nil if there are none. The
+// caller must hold d.mu.
+func (d *Document) frontmatterKeys() []string {
+	root := d.frontmatter
+	if root == nil {
+		root, _ = parseFrontmatterNode(d.source)
+	}
+	if root == nil {
+		return nil
+	}
+	mapping := root.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	var keys []string
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		keys = append(keys, mapping.Content[i].Value)
+	}
+	return keys
+}
+
+// valueType names the type of a decoded frontmatter value.
+func valueType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case int, int64, uint64, float64:
+		return "number"
+	case time.Time:
+		return "date"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}, map[interface{}]interface{}, Metadata:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// frontmatterEnd returns the byte offset where the body starts, just past
+// the closing "---" line, or 0 when source has no frontmatter. A block that
+// is never closed extends to the end of the source, as in goldmark-meta.
+func frontmatterEnd(source []byte) int {
+	offset := 0
+	for lineNum := 0; offset < len(source); lineNum++ {
+		next := bytes.IndexByte(source[offset:], '\n')
+		lineEnd := len(source)
+		if next >= 0 {
+			lineEnd = offset + next + 1
+		}
+		line := source[offset:lineEnd]
+
+		if lineNum == 0 && !isFrontmatterSeparator(line) {
+			return 0
+		}
+		if lineNum > 0 && isFrontmatterSeparator(line) {
+			return lineEnd
+		}
+		offset = lineEnd
+	}
+	return len(source)
+}
+
+// isFrontmatterSeparator reports whether line is a non-blank line made only
+// of dashes, matching goldmark-meta's delimiter rule.
+func isFrontmatterSeparator(line []byte) bool {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return false
+	}
+	for _, b := range line {
+		if b != '-' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,162 @@
+package mq
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// stopwords holds the most frequent function words of each language
+// detected by Language. They rarely appear in other languages' prose, so
+// their share of a text identifies its language without a model.
+var stopwords = map[string]map[string]bool{
+	"en": wordSet("the and of to in is that for it with as was on are be this by not or from at which you have an but can"),
+	"es": wordSet("el la de que y en los se del las por un una para con no es al lo como más pero sus le ya o este"),
+	"fr": wordSet("le la les de des et en un une du est que pour qui dans pas sur au ce il avec sont plus par ne"),
+	"de": wordSet("der die und in den von zu das mit sich des auf für ist im dem nicht ein eine als auch es an wird"),
+	"it": wordSet("il di che la e in un per non una del sono con le si della gli da al anche come nel più ma"),
+	"pt": wordSet("o de que e do da em um para com não uma os no se na por mais as dos como mas ao ele"),
+	"nl": wordSet("de het een en van in is dat op te zijn voor met niet aan er als ook maar bij om door worden"),
+}
+
+// Detection thresholds: below minLanguageWords the sample is too small, and
+// the winning language must cover enough of the text and clearly beat the
+// runner-up.
+const (
+	minLanguageWords  = 20
+	minStopwordRatio  = 0.08
+	minLanguageMargin = 2.0
+)
+
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+// Language returns the document's natural language as an ISO 639-1 code
+// (e.g. "en", "de"). A frontmatter lang/language field or the HTML lang
+// attribute wins; otherwise the prose is classified by script (Chinese,
+// Japanese, Korean) or stopword frequency. Declared values are returned
+// lowercased as written (e.g. "en-us"), so a frontmatter language field
+// naming something else, such as a programming language, is returned as
+// well; read it with GetMetadataField to tell the two apart. It returns ""
+// when confidence is low rather than guessing.
+func (d *Document) Language() string {
+	metadata := d.Metadata()
+	for _, key := range []string{"lang", "language"} {
+		if lang, ok := metadata[key].(string); ok && strings.TrimSpace(lang) != "" {
+			return strings.ToLower(strings.TrimSpace(lang))
+		}
+	}
+
+	d.mu.RLock()
+	lang := d.lang
+	d.mu.RUnlock()
+	if lang != "" {
+		return lang
+	}
+
+	text := d.readableText
+	if text == "" {
+		text = d.proseText()
+	}
+	return detectLanguage(text)
+}
+
+// proseText returns the document body without frontmatter, code blocks
+// and tables.
+func (d *Document) proseText() string {
+	var nodes []ast.Node
+	if d.root != nil {
+		ast.Walk(d.root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+			if entering {
+				nodes = append(nodes, n)
+			}
+			return ast.WalkContinue, nil
+		})
+	}
+	start := frontmatterEnd(d.source)
+	first := bytes.Count(d.source[:start], []byte("\n")) + 1
+	return dropLines(strings.Split(string(d.source[start:]), "\n"), first, nonProseLines(d.source, nodes))
+}
+
+// SetLanguage records a declared language, such as the HTML lang
+// attribute. This is used by format parsers after building the document.
+func (d *Document) SetLanguage(lang string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lang = strings.ToLower(strings.TrimSpace(lang))
+}
+
+// detectLanguage classifies text by script, then by stopword frequency.
+func detectLanguage(text string) string {
+	if lang := detectScript(text); lang != "" {
+		return lang
+	}
+
+	words := tokenize(text)
+	if len(words) < minLanguageWords {
+		return ""
+	}
+
+	best, bestHits, secondHits := "", 0, 0
+	for lang, set := range stopwords {
+		hits := 0
+		for _, w := range words {
+			if set[w] {
+				hits++
+			}
+		}
+		switch {
+		case hits > bestHits:
+			best, bestHits, secondHits = lang, hits, bestHits
+		case hits > secondHits:
+			secondHits = hits
+		}
+	}
+
+	if float64(bestHits)/float64(len(words)) < minStopwordRatio {
+		return ""
+	}
+	if float64(bestHits) < minLanguageMargin*float64(secondHits) {
+		return ""
+	}
+	return best
+}
+
+// detectScript recognizes Chinese, Japanese and Korean text, whose
+// characters dominate the letters of the text.
+func detectScript(text string) string {
+	var letters, han, kana, hangul int
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case !unicode.IsLetter(r):
+			continue
+		}
+		letters++
+	}
+	if letters == 0 || han+kana+hangul < letters/2 {
+		return ""
+	}
+
+	switch {
+	case kana > 0 && kana*10 >= han:
+		return "ja"
+	case hangul > han:
+		return "ko"
+	case han > 0:
+		return "zh"
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+package mq
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Limits bounds the size of parsed documents and query results, to guard
+// against pathological input such as a JSON array of millions of items.
+// A zero field means no limit.
+type Limits struct {
+	MaxHeadings    int // Headings in a document
+	MaxSections    int // Sections in a document, as returned by GetSections
+	MaxElements    int // Code blocks, links, images, tables and lists in a document
+	MaxOutputBytes int // Size of a query result written as JSON (see mql's Engine.StreamResult)
+}
+
+// DefaultLimits are generous limits for running on untrusted input, such as
+// scraped pages: 100,000 headings or sections, 1,000,000 elements and
+// 64 MiB of output. The CLI applies them; engines have no limits unless
+// configured.
+var DefaultLimits = Limits{
+	MaxHeadings:    100_000,
+	MaxSections:    100_000,
+	MaxElements:    1_000_000,
+	MaxOutputBytes: 64 << 20,
+}
+
+// ErrLimitExceeded is matched (with errors.Is) by the *LimitError returned
+// when a document or result exceeds a configured limit.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// LimitError reports which limit was exceeded.
+type LimitError struct {
+	Limit  string // "headings", "sections", "elements" or "output bytes"
+	Max    int    // The configured limit
+	Actual int    // The size found, or the size reached when counting stopped
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %d %s exceeds the maximum of %d", ErrLimitExceeded, e.Actual, e.Limit, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// Check returns a *LimitError if doc has more headings, sections or
+// elements than allowed. MaxOutputBytes is not checked here.
+func (l Limits) Check(doc *Document) error {
+	if err := checkLimit("headings", l.MaxHeadings, len(doc.GetHeadings())); err != nil {
+		return err
+	}
+	if err := checkLimit("sections", l.MaxSections, len(doc.GetSections())); err != nil {
+		return err
+	}
+	elements := len(doc.GetCodeBlocks()) + len(doc.GetLinks()) + len(doc.GetImages()) +
+		len(doc.GetTables()) + len(doc.GetLists(nil))
+	return checkLimit("elements", l.MaxElements, elements)
+}
+
+func checkLimit(name string, max, actual int) error {
+	if max > 0 && actual > max {
+		return &LimitError{Limit: name, Max: max, Actual: actual}
+	}
+	return nil
+}
+
+// WithLimits makes the engine reject documents exceeding l (see
+// Limits.Check) with a *ParseError wrapping a *LimitError.
+func WithLimits(l Limits) MultiEngineOption {
+	return func(e *MultiFormatEngine) {
+		e.limits = l
+	}
+}
@@ -1,9 +1,17 @@
 package mq_test
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"unicode/utf8"
 
 	mq "github.com/muqsitnawaz/mq/lib"
+	"gopkg.in/yaml.v3"
 )
 
 // Sample markdown content with frontmatter
@@ -176,6 +184,30 @@ func TestGetCodeBlocks(t *testing.T) {
 	}
 }
 
+func TestGetCodeBlocksUnlabeled(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Mixed\n\n```go\nfunc main() {}\n```\n\n```\n$ go run .\nok\n```\n\n```python\nprint(1)\n```\n\n```\nraw data\n```\n"), "mixed.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	if got := len(doc.GetCodeBlocks()); got != 4 {
+		t.Errorf("Expected 4 code blocks, got %d", got)
+	}
+
+	plain := doc.GetCodeBlocks("")
+	if len(plain) != 2 {
+		t.Fatalf("Expected 2 unlabeled code blocks, got %d", len(plain))
+	}
+	if plain[0].Content != "$ go run .\nok\n" || plain[1].Content != "raw data\n" {
+		t.Errorf("Unexpected unlabeled blocks: %q, %q", plain[0].Content, plain[1].Content)
+	}
+
+	if got := len(doc.GetCodeBlocks("", "go")); got != 3 {
+		t.Errorf("Expected 3 blocks for \"\" and go, got %d", got)
+	}
+}
+
 func TestGetTables(t *testing.T) {
 	engine := mq.New()
 	doc, err := engine.ParseDocument([]byte(testMarkdown), "test.md")
@@ -339,3 +371,2398 @@ func TestComplexQueries(t *testing.T) {
 		t.Errorf("Expected 3 unique languages, got %d", len(uniqueLangs))
 	}
 }
+
+func TestSectionPath(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte(testMarkdown), "test.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	section, ok := doc.GetSection("Token Management")
+	if !ok {
+		t.Fatal("Expected to find Token Management section")
+	}
+
+	path := section.Path()
+	expected := []string{"API Documentation", "Authentication", "Token Management"}
+	if len(path) != len(expected) {
+		t.Fatalf("Expected path %v, got %v", expected, path)
+	}
+	for i := range expected {
+		if path[i] != expected[i] {
+			t.Errorf("Expected path %v, got %v", expected, path)
+			break
+		}
+	}
+
+	if got := section.PathString(" / "); got != "API Documentation / Authentication / Token Management" {
+		t.Errorf("Unexpected path string: %s", got)
+	}
+
+	root, _ := doc.GetSection("API Documentation")
+	if got := root.Path(); len(got) != 1 || got[0] != "API Documentation" {
+		t.Errorf("Expected root path to contain only itself, got %v", got)
+	}
+}
+
+func TestSectionSiblingsAndAncestors(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Guide\n\n## Install\n\n### Linux\n\n### macOS\n\n## Usage\n\n## FAQ\n\n# Appendix\n"), "nav.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	titles := func(sections []*mq.Section) string {
+		var names []string
+		for _, s := range sections {
+			names = append(names, s.Heading.Text)
+		}
+		return strings.Join(names, ",")
+	}
+
+	tests := []struct {
+		section   string
+		siblings  string
+		ancestors string
+	}{
+		{"Install", "Usage,FAQ", "Guide"},
+		{"macOS", "Linux", "Guide,Install"},
+		{"Guide", "Appendix", ""},
+		{"Appendix", "Guide", ""},
+	}
+	for _, tt := range tests {
+		section, ok := doc.GetSection(tt.section)
+		if !ok {
+			t.Fatalf("Expected to find %s section", tt.section)
+		}
+		if got := titles(section.Siblings()); got != tt.siblings {
+			t.Errorf("%s: expected siblings %q, got %q", tt.section, tt.siblings, got)
+		}
+		if got := titles(section.Ancestors()); got != tt.ancestors {
+			t.Errorf("%s: expected ancestors %q, got %q", tt.section, tt.ancestors, got)
+		}
+	}
+
+	// A detached section has no siblings
+	orphan := &mq.Section{Heading: &mq.Heading{Text: "Orphan", Level: 1}}
+	if got := orphan.Siblings(); len(got) != 0 {
+		t.Errorf("Expected no siblings for detached section, got %v", titles(got))
+	}
+}
+
+func TestSectionNextPrev(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Guide\n\n## Install\n\n### Linux\n\n### macOS\n\n## Usage\n\n# Appendix\n"), "nav.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	text := func(s *mq.Section) string {
+		if s == nil {
+			return "<nil>"
+		}
+		return s.Heading.Text
+	}
+
+	tests := []struct {
+		section string
+		next    string
+		prev    string
+	}{
+		{"Install", "Usage", "<nil>"},
+		{"Usage", "<nil>", "Install"},
+		{"Linux", "macOS", "<nil>"},
+		{"Guide", "Appendix", "<nil>"},
+		{"Appendix", "<nil>", "Guide"},
+	}
+	for _, tt := range tests {
+		section, ok := doc.GetSection(tt.section)
+		if !ok {
+			t.Fatalf("Expected to find %s section", tt.section)
+		}
+		if got := text(section.Next()); got != tt.next {
+			t.Errorf("%s: expected next %s, got %s", tt.section, tt.next, got)
+		}
+		if got := text(section.Prev()); got != tt.prev {
+			t.Errorf("%s: expected prev %s, got %s", tt.section, tt.prev, got)
+		}
+	}
+}
+
+func TestGetSectionByPath(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte(`# Guide
+
+## API
+
+### Authentication
+
+API auth details.
+
+## CLI
+
+### Authentication
+
+CLI auth details.
+`), "test.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	section, ok := doc.GetSectionByPath("CLI", "Authentication")
+	if !ok {
+		t.Fatal("Expected to find CLI > Authentication")
+	}
+	if section.Parent == nil || section.Parent.Heading.Text != "CLI" {
+		t.Errorf("Expected parent CLI, got %v", section.Path())
+	}
+
+	// Case-insensitive fallback
+	section, ok = doc.GetSectionByPath("api", "authentication")
+	if !ok || section.Parent.Heading.Text != "API" {
+		t.Error("Expected case-insensitive match for api > authentication")
+	}
+
+	// Full chain from the root
+	if _, ok := doc.GetSectionByPath("Guide", "API", "Authentication"); !ok {
+		t.Error("Expected to resolve Guide > API > Authentication")
+	}
+
+	// Broken chain
+	if _, ok := doc.GetSectionByPath("API", "Missing"); ok {
+		t.Error("Expected API > Missing to be not found")
+	}
+	if _, ok := doc.GetSectionByPath(); ok {
+		t.Error("Expected empty path to be not found")
+	}
+
+	// Duplicate titles are kept in document order
+	if got := len(doc.GetSections()); got != 5 {
+		t.Errorf("Expected 5 sections, got %d", got)
+	}
+}
+
+func TestTermFrequency(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte(testMarkdown), "test.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	counts := doc.TermFrequency("client")
+	tokenMgmt, _ := doc.GetSection("Token Management")
+	// "client" and "client.refresh()" match; "client_id" is a different word
+	if counts[tokenMgmt] != 2 {
+		t.Errorf("Expected 2 matches in Token Management, got %d", counts[tokenMgmt])
+	}
+
+	ranked := doc.RankByTermFrequency("API")
+	if len(ranked.Counts) == 0 {
+		t.Fatal("Expected sections mentioning API")
+	}
+	// Sections count only their own text, so the parent's heading does not
+	// outweigh the /api paths in the Rate Limiting table
+	if ranked.Counts[0].Section.Heading.Text != "Rate Limiting" {
+		t.Errorf("Expected Rate Limiting to rank first, got %s", ranked.Counts[0].Section.Heading.Text)
+	}
+	root, _ := doc.GetSection("API Documentation")
+	if counts := doc.TermFrequency("API"); counts[root] != 2 {
+		t.Errorf("Expected 2 matches in API Documentation's own text, got %d", counts[root])
+	}
+	for i := 1; i < len(ranked.Counts); i++ {
+		if ranked.Counts[i].Count > ranked.Counts[i-1].Count {
+			t.Errorf("Counts not sorted descending at %d", i)
+		}
+	}
+
+	if len(doc.TermFrequency("")) != 0 {
+		t.Error("Expected empty term to produce no counts")
+	}
+}
+
+func TestGetProseText(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte(testMarkdown), "test.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	auth, _ := doc.GetSection("Authentication")
+	prose := auth.GetProseText()
+	if strings.Contains(prose, "import oauth2") || strings.Contains(prose, "```") {
+		t.Errorf("Expected code blocks to be removed, got:\n%s", prose)
+	}
+	if !strings.Contains(prose, "register your application") {
+		t.Errorf("Expected narrative text to be kept, got:\n%s", prose)
+	}
+	if !strings.Contains(auth.GetText(), "import oauth2") {
+		t.Error("Expected GetText to keep code blocks")
+	}
+
+	limits, _ := doc.GetSection("Rate Limiting")
+	prose = limits.GetProseText()
+	if strings.Contains(prose, "|") {
+		t.Errorf("Expected table to be removed, got:\n%s", prose)
+	}
+	if !strings.Contains(prose, "limited to 1000 per hour") {
+		t.Errorf("Expected narrative text to be kept, got:\n%s", prose)
+	}
+
+	indented, err := engine.ParseDocument([]byte("# Setup\n\nRun this:\n\n    make install\n\n- Then:\n\n  ```\n  make test\n  ```\n\nDone.\n"), "setup.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	setup, _ := indented.GetSection("Setup")
+	if got, want := setup.GetProseText(), "# Setup\n\nRun this:\n\n- Then:\n\nDone."; got != want {
+		t.Errorf("Expected indented and nested code to be removed, got:\n%s", got)
+	}
+}
+
+func TestElementPositions(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte(testMarkdown), "test.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	// Line numbers count from the opening frontmatter delimiter
+	code := doc.GetCodeBlocks("go")
+	if len(code) != 1 || code[0].Line != 44 {
+		t.Errorf("Expected go block fence on line 44, got %+v", code)
+	}
+
+	tables := doc.GetTables()
+	if len(tables) != 1 || tables[0].Line != 55 {
+		t.Errorf("Expected table header on line 55, got %+v", tables)
+	}
+
+	links := doc.GetLinks()
+	if len(links) != 2 || links[0].Line != 65 || links[0].Col != 3 {
+		t.Errorf("Expected first link at 65:3, got %d:%d", links[0].Line, links[0].Col)
+	}
+
+	doc, err = engine.ParseDocument([]byte("Intro `code` and [![badge](b.svg)](https://x.io)\n"), "badge.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	links = doc.GetLinks()
+	images := doc.GetImages()
+	if len(links) != 1 || links[0].Col != 18 {
+		t.Errorf("Expected badge link at column 18, got %+v", links)
+	}
+	if len(images) != 1 || images[0].Col != 19 {
+		t.Errorf("Expected badge image at column 19, got %+v", images)
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte(testMarkdown), "test.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	symbols := doc.Symbols()
+	if len(symbols) != 1 || symbols[0].Name != "API Documentation" {
+		t.Fatalf("Expected a single root symbol, got %d", len(symbols))
+	}
+
+	var find func([]*mq.DocumentSymbol, string) *mq.DocumentSymbol
+	find = func(syms []*mq.DocumentSymbol, name string) *mq.DocumentSymbol {
+		for _, s := range syms {
+			if s.Name == name {
+				return s
+			}
+			if found := find(s.Children, name); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+
+	tokens := find(symbols, "Token Management")
+	if tokens == nil || tokens.Kind != mq.SymbolSection {
+		t.Fatal("Expected Token Management section symbol")
+	}
+	if len(tokens.Children) != 1 || tokens.Children[0].Kind != mq.SymbolCode {
+		t.Errorf("Expected one code child under Token Management, got %+v", tokens.Children)
+	}
+	if code := tokens.Children[0]; code.Range.Start.Line != 33 || code.Range.End.Line != 36 {
+		t.Errorf("Expected code range 33-36, got %d-%d", code.Range.Start.Line, code.Range.End.Line)
+	}
+
+	limits := find(symbols, "Rate Limiting")
+	if limits == nil || len(limits.Children) != 1 || limits.Children[0].Kind != mq.SymbolTable {
+		t.Fatalf("Expected table under Rate Limiting, got %+v", limits)
+	}
+	if table := limits.Children[0]; table.Range.Start.Line != 55 || table.Range.End.Line != 59 {
+		t.Errorf("Expected table range 55-59, got %d-%d", table.Range.Start.Line, table.Range.End.Line)
+	}
+
+	errs := find(symbols, "Error Handling")
+	if errs == nil || len(errs.Children) != 3 {
+		t.Fatalf("Expected list and two links under Error Handling, got %+v", errs)
+	}
+	if errs.Children[0].Kind != mq.SymbolList || errs.Children[1].Kind != mq.SymbolLink {
+		t.Errorf("Expected list before links, got %s, %s", errs.Children[0].Kind, errs.Children[1].Kind)
+	}
+
+	// HTML records no positions, so there are no ranges to report
+	heading := &mq.Heading{Level: 2, Text: "Setup"}
+	page := mq.NewDocument(nil, "page.html", mq.FormatHTML, "", []*mq.Heading{heading},
+		[]*mq.Section{{Heading: heading}}, nil, nil, nil, nil, nil, "Setup")
+	if symbols := page.Symbols(); symbols != nil {
+		t.Errorf("Expected no symbols for HTML, got %+v", symbols)
+	}
+}
+
+func TestConcurrentReads(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte(testMarkdown), "test.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if len(doc.GetHeadings()) == 0 {
+					t.Error("Expected headings")
+					return
+				}
+				section, ok := doc.GetSectionByPath("Authentication", "Token Management")
+				if !ok {
+					t.Error("Expected Token Management section")
+					return
+				}
+				_ = section.GetText()
+				_ = section.GetProseText()
+				for _, cb := range doc.GetCodeBlocks() {
+					_ = cb.GetLines()
+				}
+				_ = doc.GetSections()
+				_ = doc.BuildTree(mq.TreeModePreview)
+				_ = doc.Search("token")
+				_ = doc.RankByTermFrequency("api")
+				_ = doc.Symbols()
+				_, _ = doc.GetNestedField("owner")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCorpusIndex(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	authPath := write("auth.md", "# Auth\n\nOAuth tokens and more tokens.\n\n## Refresh\n\nRefresh tokens expire.\n")
+	write("deploy.md", "# Deploy\n\nShip it with tokens.\n")
+	write("notes.txt", "tokens everywhere tokens tokens\n")
+
+	idx, err := mq.BuildCorpusIndex(dir)
+	if err != nil {
+		t.Fatalf("Failed to build index: %v", err)
+	}
+	if idx.Files() != 2 {
+		t.Errorf("Expected 2 indexed files, got %d", idx.Files())
+	}
+
+	results := idx.Search("tokens")
+	if len(results.Matches) != 3 {
+		t.Fatalf("Expected 3 matches, got %d", len(results.Matches))
+	}
+	if results.Matches[0].Section != "Auth" {
+		t.Errorf("Expected Auth to rank first, got %s", results.Matches[0].Section)
+	}
+
+	// All terms must match
+	results = idx.Search("refresh expire")
+	if len(results.Matches) != 1 || results.Matches[0].Section != "Refresh" {
+		t.Errorf("Expected only Refresh to match, got %+v", results.Matches)
+	}
+
+	// Incremental update
+	write("auth.md", "# Auth\n\nNothing to see.\n")
+	if err := idx.Update(authPath); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if got := len(idx.Search("tokens").Matches); got != 1 {
+		t.Errorf("Expected 1 match after update, got %d", got)
+	}
+
+	// Deleted files are dropped on refresh
+	if err := os.Remove(authPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if idx.Files() != 1 || len(idx.Search("nothing").Matches) != 0 {
+		t.Errorf("Expected deleted file to be removed from the index")
+	}
+}
+
+func TestGetLines(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("one\ntwo\nthree\nfour"), "lines.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		start, end int
+		expected   string
+	}{
+		{2, 3, "two\nthree"},
+		{0, 1, "one"},
+		{3, 100, "three\nfour"},
+		{4, 2, ""},
+		{10, 12, ""},
+	}
+	for _, test := range tests {
+		if got := doc.GetLines(test.start, test.end); got != test.expected {
+			t.Errorf("GetLines(%d, %d) = %q, expected %q", test.start, test.end, got, test.expected)
+		}
+	}
+}
+
+func TestParseFrontmatterOnly(t *testing.T) {
+	meta, err := mq.ParseFrontmatterOnly([]byte(testMarkdown))
+	if err != nil {
+		t.Fatalf("Failed to parse frontmatter: %v", err)
+	}
+	if meta["owner"] != "alice" || meta["priority"] != "high" {
+		t.Errorf("Unexpected metadata: %v", meta)
+	}
+
+	// Same values as a full parse
+	doc, err := mq.New().ParseDocument([]byte(testMarkdown), "test.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	if len(meta) != len(doc.Metadata()) {
+		t.Errorf("Expected %d fields, got %d", len(doc.Metadata()), len(meta))
+	}
+
+	meta, err = mq.ParseFrontmatterOnly([]byte("# No frontmatter\n\n---\nowner: bob\n---\n"))
+	if err != nil || meta != nil {
+		t.Errorf("Expected no metadata, got %v (%v)", meta, err)
+	}
+
+	if _, err := mq.ParseFrontmatterOnly([]byte("---\nowner: [unclosed\n---\n")); err == nil {
+		t.Error("Expected error for invalid YAML")
+	}
+
+	path := filepath.Join(t.TempDir(), "doc.md")
+	if err := os.WriteFile(path, []byte(testMarkdown), 0644); err != nil {
+		t.Fatal(err)
+	}
+	meta, err = mq.ParseFrontmatterFile(path)
+	if err != nil || meta["owner"] != "alice" {
+		t.Errorf("Expected owner from file frontmatter, got %v (%v)", meta, err)
+	}
+}
+
+func TestElements(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Intro [docs](https://example.com)\n\nSee ![logo](logo.png).\n\n```go\nfunc main() {}\n```\n\n- one\n- two\n\n| A | B |\n|---|---|\n| 1 | 2 |\n"), "elements.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	elements := doc.Elements()
+	expected := []struct {
+		kind mq.ElementKind
+		line int
+	}{
+		{mq.ElementHeading, 1},
+		{mq.ElementLink, 1},
+		{mq.ElementImage, 3},
+		{mq.ElementCode, 5},
+		{mq.ElementList, 9},
+		{mq.ElementTable, 12},
+	}
+
+	if len(elements) != len(expected) {
+		t.Fatalf("Expected %d elements, got %d", len(expected), len(elements))
+	}
+	for i, want := range expected {
+		if elements[i].Kind() != want.kind || elements[i].GetLine() != want.line {
+			t.Errorf("Element %d: expected %s at line %d, got %s at line %d",
+				i, want.kind, want.line, elements[i].Kind(), elements[i].GetLine())
+		}
+	}
+}
+
+func TestAutoLinks(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Links\n\nSee https://example.com/docs for details.\nMail support@example.com or visit <https://angle.dev>.\n\nAn [explicit](https://explicit.dev) link.\n"), "auto.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	links := doc.GetLinks()
+	expected := []struct {
+		text string
+		url  string
+		auto bool
+		line int
+		col  int
+	}{
+		{"https://example.com/docs", "https://example.com/docs", true, 3, 5},
+		{"support@example.com", "mailto:support@example.com", true, 4, 6},
+		{"https://angle.dev", "https://angle.dev", true, 4, 35},
+		{"explicit", "https://explicit.dev", false, 6, 4},
+	}
+
+	if len(links) != len(expected) {
+		t.Fatalf("Expected %d links, got %d", len(expected), len(links))
+	}
+	for i, want := range expected {
+		got := links[i]
+		if got.Text != want.text || got.URL != want.url || got.Auto != want.auto {
+			t.Errorf("Link %d: expected %q -> %q (auto=%v), got %q -> %q (auto=%v)",
+				i, want.text, want.url, want.auto, got.Text, got.URL, got.Auto)
+		}
+		if got.Line != want.line || got.Col != want.col {
+			t.Errorf("Link %d: expected %d:%d, got %d:%d", i, want.line, want.col, got.Line, got.Col)
+		}
+	}
+}
+
+func TestGetStrikethroughs(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("Draft ~~intro~~.\n\n# Changelog\n\n## v2\n\n- ~~Removed **legacy** API~~\n- Added streaming\n"), "changelog.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	spans := doc.GetStrikethroughs()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 strikethroughs, got %d", len(spans))
+	}
+
+	if spans[0].Text != "intro" || spans[0].Section != nil || spans[0].Line != 1 {
+		t.Errorf("Unexpected first span: %q in %v at line %d", spans[0].Text, spans[0].Section, spans[0].Line)
+	}
+	if spans[1].Text != "Removed legacy API" || spans[1].Line != 7 {
+		t.Errorf("Unexpected second span: %q at line %d", spans[1].Text, spans[1].Line)
+	}
+	if spans[1].Section == nil || spans[1].Section.Heading.Text != "v2" {
+		t.Errorf("Expected second span in section v2, got %v", spans[1].Section)
+	}
+}
+
+func TestFields(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("---\ntitle: Guide\npriority: 2\ndraft: false\ntags: [api, auth]\nowner:\n  name: alice\nreviewer:\n---\n\n# Guide\n"), "fields.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	expected := []mq.FieldInfo{
+		{Key: "title", Type: "string"},
+		{Key: "priority", Type: "number"},
+		{Key: "draft", Type: "bool"},
+		{Key: "tags", Type: "array"},
+		{Key: "owner", Type: "object"},
+		{Key: "reviewer", Type: "null"},
+	}
+
+	fields := doc.Fields()
+	if len(fields) != len(expected) {
+		t.Fatalf("Expected %d fields, got %v", len(expected), fields)
+	}
+	for i, want := range expected {
+		if fields[i] != want {
+			t.Errorf("Field %d: expected %v, got %v", i, want, fields[i])
+		}
+	}
+
+	plain, err := engine.ParseDocument([]byte("# No frontmatter\n"), "plain.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	if got := plain.Fields(); len(got) != 0 {
+		t.Errorf("Expected no fields, got %v", got)
+	}
+}
+
+func TestValidateMetadata(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("---\ntitle: 42\npriority: urgent\ntags: [api, misc]\ndate: 2024-03-01\nextra: true\n---\n\n# Doc\n"), "doc.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	schema := map[string]mq.FieldSpec{
+		"title":    {Required: true, Type: "string"},
+		"owner":    {Required: true},
+		"priority": {Allowed: []interface{}{"low", "medium", "high", "critical"}},
+		"tags":     {Type: "array", Allowed: []interface{}{"api", "auth"}},
+		"date":     {Type: "date"},
+	}
+
+	var got []string
+	for _, e := range doc.ValidateMetadata(schema) {
+		got = append(got, e.Error())
+	}
+	expected := []string{
+		"title: expected string, got number",
+		`priority: value "urgent" not in {low, medium, high, critical}`,
+		`tags: value "misc" not in {api, auth}`,
+		"extra: unexpected field",
+		"owner: missing required field",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected validation errors:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+
+	dir := t.TempDir()
+	schemaPath := filepath.Join(dir, "schema.yaml")
+	if err := os.WriteFile(schemaPath, []byte("title:\n  required: true\n  type: string\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := mq.LoadMetadataSchema(schemaPath)
+	if err != nil {
+		t.Fatalf("LoadMetadataSchema failed: %v", err)
+	}
+	if spec := loaded["title"]; !spec.Required || spec.Type != "string" {
+		t.Errorf("Unexpected schema: %+v", loaded)
+	}
+
+	if err := os.WriteFile(schemaPath, []byte("title:\n  type: text\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mq.LoadMetadataSchema(schemaPath); err == nil {
+		t.Error("Expected error for unknown schema type")
+	}
+}
+
+func TestBuildDirTreeSortBy(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.md":       "---\npriority: low\n---\n\n# A\n",
+		"b.md":       "---\npriority: critical\n---\n\n# B\n\n## One\n\n## Two\n",
+		"c.md":       "# C\n\nNo frontmatter.\n",
+		"d.md":       "---\npriority: high\n---\n\n# D\n",
+		"sub/e.md":   "---\npriority: medium\n---\n\n# E\n",
+		"sub/f.md":   "---\npriority: critical\n---\n\n# F\n",
+		"notes.txt":  "ignored",
+		".hidden.md": "# Hidden\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names := func(nodes []*mq.DirFileNode) string {
+		var out []string
+		for _, n := range nodes {
+			out = append(out, n.Name)
+		}
+		return strings.Join(out, ",")
+	}
+
+	tests := []struct {
+		opts     mq.DirTreeOptions
+		expected string
+	}{
+		{mq.DirTreeOptions{}, "sub,a.md,b.md,c.md,d.md"},
+		{mq.DirTreeOptions{SortBy: "priority"}, "sub,b.md,d.md,a.md,c.md"},
+		{mq.DirTreeOptions{SortBy: "priority", Descending: true}, "sub,a.md,d.md,b.md,c.md"},
+		{mq.DirTreeOptions{SortBy: "sections", Descending: true}, "sub,b.md,a.md,c.md,d.md"},
+	}
+
+	for _, test := range tests {
+		result, err := mq.BuildDirTreeWithOptions(dir, mq.TreeModeDefault, test.opts)
+		if err != nil {
+			t.Fatalf("BuildDirTreeWithOptions failed: %v", err)
+		}
+		if got := names(result.Root); got != test.expected {
+			t.Errorf("SortBy %q (desc=%v): expected %s, got %s", test.opts.SortBy, test.opts.Descending, test.expected, got)
+		}
+		if test.opts.SortBy == "priority" && !test.opts.Descending {
+			if got := names(result.Root[0].Children); got != "f.md,e.md" {
+				t.Errorf("Expected subdirectory sorted by priority, got %s", got)
+			}
+		}
+	}
+}
+
+func TestFilterDirTree(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"api.md":          "# API\n\n```python\nprint(1)\n```\n",
+		"guide.md":        "# Guide\n\nProse only.\n",
+		"nested/deep.md":  "# Deep\n\n```python\npass\n```\n",
+		"nested/other.md": "# Other\n",
+		"empty/none.md":   "# None\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hasPython := func(doc *mq.Document) (bool, error) {
+		return len(doc.GetCodeBlocks("python")) > 0, nil
+	}
+
+	var first string
+	for i := 0; i < 5; i++ {
+		result, err := mq.FilterDirTree(dir, mq.TreeModeDefault, hasPython)
+		if err != nil {
+			t.Fatalf("FilterDirTree failed: %v", err)
+		}
+		if result.TotalFiles != 2 {
+			t.Errorf("Expected 2 matching files, got %d", result.TotalFiles)
+		}
+		out := strings.TrimPrefix(result.String(), dir)
+		if i == 0 {
+			first = out
+			if !strings.Contains(out, "deep.md") || !strings.Contains(out, "api.md") {
+				t.Errorf("Expected api.md and nested/deep.md in tree:\n%s", out)
+			}
+			if strings.Contains(out, "guide.md") || strings.Contains(out, "empty/") {
+				t.Errorf("Expected non-matching files and empty directories pruned:\n%s", out)
+			}
+		} else if out != first {
+			t.Errorf("Output not deterministic:\n%s\nvs\n%s", out, first)
+		}
+	}
+}
+
+func TestDirTreeHeadingHistogram(t *testing.T) {
+	dir := t.TempDir()
+	content := "# Guide\n\n## Install\n\n```bash\nmake\n```\n\n## Usage\n\n### Flags\n"
+	if err := os.WriteFile(filepath.Join(dir, "guide.md"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := mq.BuildDirTree(dir, mq.TreeModePreview)
+	if err != nil {
+		t.Fatalf("BuildDirTree failed: %v", err)
+	}
+	node := result.Root[0]
+	if node.Headings != [6]int{1, 2, 1, 0, 0, 0} || node.CodeBlocks != 1 {
+		t.Errorf("Unexpected stats: headings %v, code %d", node.Headings, node.CodeBlocks)
+	}
+	if out := result.String(); !strings.Contains(out, "guide.md (12 lines, 4 sections) [H1:1 H2:2 H3:1 code:1]") {
+		t.Errorf("Expected histogram in preview output:\n%s", out)
+	}
+
+	result, err = mq.BuildDirTree(dir, mq.TreeModeDefault)
+	if err != nil {
+		t.Fatalf("BuildDirTree failed: %v", err)
+	}
+	if out := result.String(); strings.Contains(out, "[H1:") {
+		t.Errorf("Expected no histogram in default output:\n%s", out)
+	}
+}
+
+func TestLead(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("---\ntitle: Lead\n---\n\n# Guide\n\n![badge](b.svg)\n\nThe first paragraph\nwraps across lines.\n\nSecond paragraph.\n\n## Install\n\n- a list item\n\n> quoted\n\nRun `make` now.\n\n## Empty\n\n### Child\n\nChild text.\n"), "lead.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	if got := doc.Lead(); got != "The first paragraph wraps across lines." {
+		t.Errorf("Unexpected document lead: %q", got)
+	}
+
+	tests := map[string]string{
+		"Guide":   "The first paragraph wraps across lines.",
+		"Install": "Run make now.",
+		"Empty":   "",
+		"Child":   "Child text.",
+	}
+	for name, expected := range tests {
+		section, ok := doc.GetSection(name)
+		if !ok {
+			t.Fatalf("Section %q not found", name)
+		}
+		if got := section.Lead(); got != expected {
+			t.Errorf("Section %q: expected lead %q, got %q", name, expected, got)
+		}
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"english", "# Guide\n\nThis is the guide for the project. It explains how to install it and how to use it with the tools that you have. The setup is simple and it can be done in a few minutes, but there are some steps that are important.\n", "en"},
+		{"german", "# Anleitung\n\nDies ist die Anleitung für das Projekt. Sie erklärt, wie man es installiert und mit den Werkzeugen verwendet, die auf dem System sind. Die Einrichtung ist nicht schwer und es wird auch in wenigen Minuten von selbst erledigt.\n", "de"},
+		{"french", "# Guide\n\nCeci est le guide pour le projet. Il explique comment installer le logiciel et comment il est utilisé avec les outils qui sont dans le système. La configuration est simple et elle peut être faite en quelques minutes par un utilisateur qui ne connaît pas le sujet.\n", "fr"},
+		{"spanish", "# Guía\n\nEsta es la guía para el proyecto. Explica cómo se instala y cómo se usa con las herramientas del sistema. La configuración no es difícil y se puede hacer en unos minutos por una persona que no conoce el tema, pero hay pasos que son importantes para los usuarios.\n", "es"},
+		{"japanese", "# ガイド\n\nこれはプロジェクトのガイドです。インストール方法と使い方を説明します。\n", "ja"},
+		{"frontmatter", "---\nlang: PT-BR\n---\n\n# Guide\n\nThis is the guide for the project and it is written in English, but the frontmatter says otherwise so that wins over the text.\n", "pt-br"},
+		{"frontmatter language", "---\nlanguage: Go\n---\n\n# Guide\n", "go"},
+		{"too short", "# Title\n\nHello world.\n", ""},
+		{"code only", "# API\n\n```go\nfunc main() {}\n```\n", ""},
+	}
+
+	engine := mq.New()
+	for _, test := range tests {
+		doc, err := engine.ParseDocument([]byte(test.content), "lang.md")
+		if err != nil {
+			t.Fatalf("Failed to parse document: %v", err)
+		}
+		if got := doc.Language(); got != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestLanguageAliases(t *testing.T) {
+	content := []byte("# Code\n\n```js\nlet a\n```\n\n```javascript\nlet b\n```\n\n```py\nx = 1\n```\n\n```Python\ny = 2\n```\n\n```sh\nls\n```\n\n```shell\npwd\n```\n\n```bash\necho\n```\n\n## More\n\n```yml\nk: v\n```\n")
+
+	doc, err := mq.NewParser().Parse(content, "aliases.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		langs    []string
+		expected []string
+	}{
+		{[]string{"javascript"}, []string{"js", "javascript"}},
+		{[]string{"js"}, []string{"js", "javascript"}},
+		{[]string{"js", "javascript"}, []string{"js", "javascript"}},
+		{[]string{"python"}, []string{"py", "Python"}},
+		{[]string{"bash"}, []string{"sh", "shell", "bash"}},
+		{[]string{"yaml"}, []string{"yml"}},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, cb := range doc.GetCodeBlocks(test.langs...) {
+			got = append(got, cb.Language)
+		}
+		if strings.Join(got, ",") != strings.Join(test.expected, ",") {
+			t.Errorf("GetCodeBlocks(%v): expected %v, got %v", test.langs, test.expected, got)
+		}
+	}
+
+	section, _ := doc.GetSection("Code")
+	if got := len(section.GetCodeBlocks("shell")); got != 3 {
+		t.Errorf("Expected 3 shell blocks in section, got %d", got)
+	}
+
+	exact, err := mq.NewParser(mq.WithLanguageAliases(nil)).Parse(content, "aliases.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	if got := len(exact.GetCodeBlocks("javascript")); got != 1 {
+		t.Errorf("Expected exact matching without aliases, got %d blocks", got)
+	}
+
+	aliases := mq.DefaultLanguageAliases()
+	aliases["mjs"] = "javascript"
+	custom, err := mq.NewParser(mq.WithLanguageAliases(aliases)).Parse([]byte("```mjs\nexport {}\n```\n"), "custom.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	if got := len(custom.GetCodeBlocks("js")); got != 1 {
+		t.Errorf("Expected custom alias to match, got %d blocks", got)
+	}
+}
+
+func TestHTMLRendering(t *testing.T) {
+	checked := true
+	tests := []struct {
+		name     string
+		got      string
+		expected string
+	}{
+		{"heading", (&mq.Heading{Level: 2, Text: "A & B", ID: "a-b"}).HTML(), "<h2 id=\"a-b\">A &amp; B</h2>\n"},
+		{"code", (&mq.CodeBlock{Language: "go", Content: "if a < b {}\n"}).HTML(), "<pre><code class=\"language-go\">if a &lt; b {}\n</code></pre>\n"},
+		{"code without language", (&mq.CodeBlock{Content: "x"}).HTML(), "<pre><code>x</code></pre>\n"},
+		{"table", (&mq.Table{Headers: []string{"Name"}, Rows: [][]string{{"<b>"}}}).HTML(), "<table>\n<thead>\n<tr>\n<th>Name</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>&lt;b&gt;</td>\n</tr>\n</tbody>\n</table>\n"},
+		{"ordered list", (&mq.List{Ordered: true, Items: []mq.ListItem{{Text: "one"}, {Text: "two", Children: []mq.ListItem{{Text: "2a"}}}}}).HTML(), "<ol>\n<li>one</li>\n<li>two\n<ol>\n<li>2a</li>\n</ol>\n</li>\n</ol>\n"},
+		{"task list", (&mq.List{Items: []mq.ListItem{{Text: "done", Checked: &checked}}}).HTML(), "<ul>\n<li><input checked=\"\" disabled=\"\" type=\"checkbox\"> done</li>\n</ul>\n"},
+		{"link", (&mq.Link{Text: "Docs", URL: "https://example.com/?a=1&b=2"}).HTML(), "<a href=\"https://example.com/?a=1&amp;b=2\">Docs</a>\n"},
+		{"image", (&mq.Image{AltText: "Logo \"v2\"", URL: "logo.png"}).HTML(), "<img src=\"logo.png\" alt=\"Logo &#34;v2&#34;\">\n"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, tt.got)
+		}
+	}
+
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("---\ntitle: x\n---\n# Guide\n\nIntro <script>alert(1)</script> text.\n\n## Setup\n\n```sh\nmake\n```\n\n# Other\n"), "render.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	section, _ := doc.GetSection("Guide")
+	got := section.HTML()
+	for _, want := range []string{"<h1 id=\"guide\">Guide</h1>", "<h2 id=\"setup\">Setup</h2>", "<pre><code class=\"language-sh\">make\n</code></pre>"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Section HTML missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "<script>") || strings.Contains(got, "Other") {
+		t.Errorf("Section HTML should drop raw HTML and stop at the next section:\n%s", got)
+	}
+
+	if body := doc.HTML(); strings.Contains(body, "title: x") || !strings.Contains(body, "<h1 id=\"other\">Other</h1>") {
+		t.Errorf("Document HTML should render the body without frontmatter:\n%s", body)
+	}
+}
+
+func TestTextContentWithMetadata(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("---\ntitle: Guide\ntags: [go, cli]\ncreated: 2024-03-01\nauthor:\n  name: Ann\n  email: ann@example.com\ndraft: false\n---\n\n# Guide\n\nBody text.\n"), "meta.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	if got := doc.GetTextContent(); got != "Guide\n\nBody text." {
+		t.Errorf("Expected plain body without frontmatter, got %q", got)
+	}
+
+	expectedMeta := "Title: Guide\nTags: go, cli\nCreated: 2024-03-01\nAuthor: {email: ann@example.com, name: Ann}\nDraft: false\n"
+	if got := doc.MetadataText(); got != expectedMeta {
+		t.Errorf("Expected metadata text %q, got %q", expectedMeta, got)
+	}
+
+	if got := doc.GetTextContentWithMetadata(); got != expectedMeta+"\nGuide\n\nBody text." {
+		t.Errorf("Unexpected text with metadata: %q", got)
+	}
+
+	plain, err := engine.ParseDocument([]byte("# Plain\n"), "plain.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	if got := plain.GetTextContentWithMetadata(); got != "Plain" {
+		t.Errorf("Expected unchanged text without frontmatter, got %q", got)
+	}
+}
+
+func TestSectionHeadTail(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Intro\n\none\ntwo\nthree\n\n## Details\n\nfour\nfive\n\n# Empty\n"), "headtail.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	intro, _ := doc.GetSection("Intro")
+	details, _ := doc.GetSection("Details")
+	empty, _ := doc.GetSection("Empty")
+
+	tests := []struct {
+		name     string
+		got      string
+		expected string
+	}{
+		{"head", intro.Head(2), "one\ntwo"},
+		{"head spans subsections", intro.Head(5), "one\ntwo\nthree\n\n## Details"},
+		{"tail", intro.Tail(2), "four\nfive"},
+		{"clamped head", details.Head(10), "four\nfive"},
+		{"clamped tail", details.Tail(10), "four\nfive"},
+		{"zero", intro.Head(0), ""},
+		{"empty section", empty.Tail(3), ""},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, tt.got)
+		}
+	}
+}
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := []struct {
+		lang     string
+		expected string
+		known    bool
+	}{
+		{"C#", "csharp", true},
+		{"CSharp", "csharp", true},
+		{"F#", "fsharp", true},
+		{"objective-c", "objectivec", true},
+		{"ObjC", "objectivec", true},
+		{"kts", "kotlin", true},
+		{"pwsh", "powershell", true},
+		{"Dockerfile", "dockerfile", true},
+		{"golang", "go", true},
+		{"nim", "nim", false},
+	}
+	for _, tt := range tests {
+		if got := mq.NormalizeLanguage(tt.lang); got != tt.expected {
+			t.Errorf("NormalizeLanguage(%q): expected %q, got %q", tt.lang, tt.expected, got)
+		}
+		if got := mq.IsKnownLanguage(tt.lang); got != tt.known {
+			t.Errorf("IsKnownLanguage(%q): expected %v, got %v", tt.lang, tt.known, got)
+		}
+	}
+}
+
+func TestListNestingAndStart(t *testing.T) {
+	engine := mq.New()
+	content := "# Steps\n\n3. Install\n4. Configure\n   - edit config\n     - [x] set token\n   - restart\n\n- a\n\n- b\n"
+	doc, err := engine.ParseDocument([]byte(content), "lists.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	lists := doc.GetLists(nil)
+	if len(lists) != 2 {
+		t.Fatalf("Expected 2 top-level lists, got %d", len(lists))
+	}
+
+	steps := lists[0]
+	if !steps.Ordered || steps.Start != 3 || steps.Loose {
+		t.Errorf("Expected tight ordered list starting at 3, got ordered=%v start=%d loose=%v", steps.Ordered, steps.Start, steps.Loose)
+	}
+	if len(steps.Items) != 2 || steps.Items[1].Text != "Configure" {
+		t.Fatalf("Expected nested text to stay out of the parent item, got %+v", steps.Items)
+	}
+
+	nested := steps.Items[1].Children
+	if len(nested) != 2 || nested[0].Text != "edit config" || nested[0].Depth != 1 || nested[1].Text != "restart" {
+		t.Errorf("Unexpected nested items: %+v", nested)
+	}
+	if len(nested) > 0 && len(nested[0].Children) == 1 {
+		task := nested[0].Children[0]
+		if task.Depth != 2 || task.Text != "set token" || task.Checked == nil || !*task.Checked {
+			t.Errorf("Unexpected task item: %+v", task)
+		}
+	} else {
+		t.Errorf("Expected one item below 'edit config'")
+	}
+
+	bullets := lists[1]
+	if bullets.Ordered || bullets.Start != 0 || !bullets.Loose {
+		t.Errorf("Expected loose bullet list, got ordered=%v start=%d loose=%v", bullets.Ordered, bullets.Start, bullets.Loose)
+	}
+
+	if html := steps.HTML(); !strings.HasPrefix(html, "<ol start=\"3\">") {
+		t.Errorf("Expected start attribute in rendered list, got %q", html)
+	}
+}
+
+func TestGetListItems(t *testing.T) {
+	engine := mq.New()
+	content := "# Mixed\n\n1. Build\n2. Ship\n   - [ ] tag release\n   - announce\n\n- Notes\n  1. first\n"
+	doc, err := engine.ParseDocument([]byte(content), "flat.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	type flat struct {
+		text    string
+		depth   int
+		ordered bool
+		task    bool
+	}
+	expected := []flat{
+		{"Build", 0, true, false},
+		{"Ship", 0, true, false},
+		{"tag release", 1, false, true},
+		{"announce", 1, false, false},
+		{"Notes", 0, false, false},
+		{"first", 1, true, false},
+	}
+
+	items := doc.GetListItems()
+	if len(items) != len(expected) {
+		t.Fatalf("Expected %d items, got %d: %+v", len(expected), len(items), items)
+	}
+	for i, want := range expected {
+		got := items[i]
+		if got.Text != want.text || got.Depth != want.depth || got.Ordered != want.ordered || (got.Checked != nil) != want.task {
+			t.Errorf("Item %d: expected %+v, got %+v", i, want, got)
+		}
+	}
+	if items[2].Checked != nil && *items[2].Checked {
+		t.Error("Expected unchecked task")
+	}
+	if items[0].Number != 1 || items[1].Number != 2 || items[2].Number != 0 || items[5].Number != 1 {
+		t.Errorf("Expected ordered items numbered from 1 and bullets unnumbered, got %+v", items)
+	}
+
+	late, err := engine.ParseDocument([]byte("3. third\n4. fourth\n"), "late.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	if items := late.GetListItems(); len(items) != 2 || items[0].Number != 3 || items[1].Number != 4 {
+		t.Errorf("Expected numbering from the list's start, got %+v", items)
+	}
+}
+
+func TestExtractPreview(t *testing.T) {
+	long := "# Title\n\nThe quick brown fox jumps over the lazy dog again and again.\n"
+	tests := []struct {
+		name     string
+		text     string
+		max      int
+		expected string
+	}{
+		{"fits", "# A\n\nShort line.\n", 50, "Short line."},
+		{"word boundary", long, 20, "The quick brown fox…"},
+		{"boundary at limit", long, 19, "The quick brown fox…"},
+		{"mid word", long, 22, "The quick brown fox…"},
+		{"single long word", "# A\n\nSupercalifragilistic\n", 5, "Super…"},
+		{"collapses whitespace", "# A\n\n  Some   **bold**\ttext  \n", 50, "Some bold text"},
+		{"skips fences only", "# A\n\n```go\nx := 1\n```\n\nAfter code.\n", 50, "x := 1"},
+		{"keeps nested heading", "# A\n\n## B\n\nChild text.\n", 50, "## B"},
+		{"no prose", "# A\n\n![img](x.png)\n", 50, ""},
+		{"heading only", "# A", 50, ""},
+		{"zero length", long, 0, ""},
+	}
+	for _, tt := range tests {
+		if got := mq.ExtractPreview(tt.text, tt.max); got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestSizeReduction(t *testing.T) {
+	engine := mq.New()
+	source := "---\ntitle: x\n---\n# Body\n"
+	doc, err := engine.ParseDocument([]byte(source), "size.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	report := doc.SizeReduction()
+	if report.SourceBytes != len(source) || report.ReadableChars != len("Body") {
+		t.Errorf("Unexpected sizes: %+v", report)
+	}
+	expected := 1 - float64(report.ReadableChars)/float64(report.SourceBytes)
+	if report.Ratio != expected {
+		t.Errorf("Expected ratio %f, got %f", expected, report.Ratio)
+	}
+
+	empty, err := engine.ParseDocument([]byte(""), "empty.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	if r := empty.SizeReduction(); r.SourceBytes != 0 || r.Ratio != 0 {
+		t.Errorf("Expected zero report for empty document, got %+v", r)
+	}
+
+	accented, err := engine.ParseDocument([]byte("# Café\n"), "cafe.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	if r := accented.SizeReduction(); r.SourceBytes != 8 || r.ReadableChars != 4 || r.Ratio != 0.375 {
+		t.Errorf("Expected characters counted as runes, got %+v", r)
+	}
+}
+
+func TestSectionDetector(t *testing.T) {
+	allCaps := func(line string) (int, bool) {
+		if len(line) < 3 || strings.ToUpper(line) != line || strings.ToLower(line) == line {
+			return 0, false
+		}
+		if strings.HasSuffix(line, ":") {
+			return 2, true
+		}
+		return 1, true
+	}
+
+	content := "INTRODUCTION\nSome pasted notes.\n\nDETAILS:\nMore text here.\n\n## Real Heading\n\n```\nNOT A HEADING\n```\n\n- LIST ITEM\n"
+	parser := mq.NewParser(mq.WithSectionDetector(allCaps))
+	doc, err := parser.Parse([]byte(content), "notes.txt")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	headings := doc.GetHeadings()
+	var got []string
+	for _, h := range headings {
+		got = append(got, fmt.Sprintf("%d:%s@%d", h.Level, h.Text, h.Line))
+	}
+	expected := []string{"1:INTRODUCTION@1", "2:DETAILS:@4", "2:Real Heading@7"}
+	if strings.Join(got, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("Expected headings %v, got %v", expected, got)
+	}
+
+	intro, ok := doc.GetSection("INTRODUCTION")
+	if !ok {
+		t.Fatal("Expected a section for the detected heading")
+	}
+	if len(intro.Children) != 2 || intro.Children[0].Heading.Text != "DETAILS:" {
+		t.Errorf("Expected detected and markdown headings to nest under INTRODUCTION, got %d children", len(intro.Children))
+	}
+	details, _ := doc.GetSection("DETAILS:")
+	if details.Start != 4 || details.End != 6 {
+		t.Errorf("Expected DETAILS: to span lines 4-6, got %d-%d", details.Start, details.End)
+	}
+
+	plain, err := mq.NewParser().Parse([]byte(content), "notes.txt")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	if n := len(plain.GetHeadings()); n != 1 {
+		t.Errorf("Expected only the markdown heading without a detector, got %d", n)
+	}
+
+	// One paragraph holding two detected headings belongs to both sections
+	joined, err := parser.Parse([]byte("INTRODUCTION\nSome notes.\nDETAILS:\nMore text.\n"), "joined.txt")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	intro, _ = joined.GetSection("INTRODUCTION")
+	details, _ = joined.GetSection("DETAILS:")
+	if len(intro.Content) != 1 || len(details.Content) == 0 || intro.Content[0] != details.Content[0] {
+		t.Errorf("Expected the paragraph in both sections, got %d and %d nodes", len(intro.Content), len(details.Content))
+	}
+}
+
+func TestChangedSections(t *testing.T) {
+	engine := mq.New()
+	oldDoc, err := engine.ParseDocument([]byte("# Guide\n\nIntro text.\n\n## Install\n\nRun make.\n\n## Usage\n\nCall it.\n\n## Legacy\n\nOld stuff.\n"), "v1.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	newDoc, err := engine.ParseDocument([]byte("# Guide\n\nIntro\ntext.\n\n## Install\n\nRun make install.\n\n## Usage\n\nCall it.\n\n## FAQ\n\nQuestions.\n"), "v2.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	var got []string
+	for _, c := range mq.ChangedSections(oldDoc, newDoc) {
+		got = append(got, string(c.Kind)+" "+c.Section.PathString(" > "))
+	}
+	expected := []string{"modified Guide > Install", "added Guide > FAQ", "removed Guide > Legacy"}
+	if strings.Join(got, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	if changes := mq.ChangedSections(newDoc, newDoc); len(changes) != 0 {
+		t.Errorf("Expected no changes between identical documents, got %d", len(changes))
+	}
+}
+
+func TestPackSections(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# A\n\naaaa\n\n## A1\n\nbbbb\n\n## A2\n\ncccc\n\n# B\n\ndddd\n"), "pack.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	// Own sizes: A=10, A1=12, A2=12, B=10
+	tests := []struct {
+		max      int
+		expected string
+	}{
+		{100, "[A A1 A2 B]"},
+		{34, "[A A1 A2] [B]"},
+		{25, "[A A1] [A2 B]"},
+		{5, "[A] [A1] [A2] [B]"},
+		{0, ""},
+	}
+	for _, tt := range tests {
+		var bundles []string
+		for _, bundle := range doc.PackSections(tt.max) {
+			var names []string
+			for _, s := range bundle {
+				names = append(names, s.Heading.Text)
+			}
+			bundles = append(bundles, "["+strings.Join(names, " ")+"]")
+		}
+		if got := strings.Join(bundles, " "); got != tt.expected {
+			t.Errorf("PackSections(%d): expected %s, got %s", tt.max, tt.expected, got)
+		}
+	}
+
+	// Text before the first heading is packed as an implicit section
+	intro, err := engine.ParseDocument([]byte("---\ntitle: Notes\n---\nIntro text.\n\n# A\n\naaaa\n"), "intro.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	bundles := intro.PackSections(100)
+	if len(bundles) != 1 || len(bundles[0]) != 2 {
+		t.Fatalf("Expected the preamble and A in one bundle, got %v", bundles)
+	}
+	if preamble := bundles[0][0]; !preamble.Implicit || preamble.GetText() != "Intro text.\n" {
+		t.Errorf("Expected the preamble first, got %q", preamble.GetText())
+	}
+	if bundles := doc.PackSections(100); bundles[0][0].Implicit {
+		t.Error("Expected no preamble for a document starting with a heading")
+	}
+}
+
+func TestImplicitRootSection(t *testing.T) {
+	engine := mq.New()
+	content := "---\ntags: [a]\n---\nJust a note.\n\n```go\nx\n```\n\nMore text.\n"
+	doc, err := engine.ParseDocument([]byte(content), "notes/todo.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	sections := doc.GetSections()
+	if len(sections) != 1 {
+		t.Fatalf("Expected 1 implicit section, got %d", len(sections))
+	}
+	root := sections[0]
+	if !root.Implicit || root.Heading.Text != "todo" || root.Heading.Level != 1 {
+		t.Errorf("Expected implicit level-1 section titled after the file, got %+v", root.Heading)
+	}
+	if root.Start != 4 {
+		t.Errorf("Expected section to start after the frontmatter at line 4, got %d", root.Start)
+	}
+	if text := root.GetText(); !strings.HasPrefix(text, "Just a note.") || !strings.Contains(text, "More text.") {
+		t.Errorf("Expected the whole body, got %q", text)
+	}
+	if head := root.Head(1); head != "Just a note." {
+		t.Errorf("Expected Head to keep the first body line, got %q", head)
+	}
+	if code := root.GetCodeBlocks("go"); len(code) != 1 {
+		t.Errorf("Expected the code block in the implicit section, got %d", len(code))
+	}
+	// Like any section's, Content holds the top-level blocks only
+	if len(root.Content) != 3 {
+		t.Errorf("Expected two paragraphs and a code block as content, got %d nodes", len(root.Content))
+	}
+	if got, ok := doc.GetSection("todo"); !ok || got != root {
+		t.Error("Expected the implicit section to be found by title")
+	}
+	if len(doc.GetHeadings()) != 0 {
+		t.Errorf("Expected no headings, got %d", len(doc.GetHeadings()))
+	}
+	if toc := doc.GetTableOfContents(); len(toc) != 1 || toc[0] != root {
+		t.Errorf("Expected the implicit section as the only top-level section, got %v", toc)
+	}
+
+	titled, err := engine.ParseDocument([]byte("# Real\n\nBody\n"), "titled.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	if sections := titled.GetSections(); len(sections) != 1 || sections[0].Implicit {
+		t.Error("Expected documents with headings to have no implicit section")
+	} else if len(sections[0].Content) != 1 {
+		t.Errorf("Expected the paragraph as the only content, got %d nodes", len(sections[0].Content))
+	}
+}
+
+func TestGenerateTOC(t *testing.T) {
+	engine := mq.New()
+	content := "## Install\n\n### From [source]\n\n#### Details\n\n## Usage\n\n## Usage\n"
+	doc, err := engine.ParseDocument([]byte(content), "readme.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	expected := "- [Install](#install)\n" +
+		"  - [From \\[source\\]](#from-source)\n" +
+		"    - [Details](#details)\n" +
+		"- [Usage](#usage)\n" +
+		"- [Usage](#usage-1)\n"
+	if toc := doc.GenerateTOC(0); toc != expected {
+		t.Errorf("Expected TOC:\n%s\ngot:\n%s", expected, toc)
+	}
+
+	shallow := "- [Install](#install)\n  - [From \\[source\\]](#from-source)\n- [Usage](#usage)\n- [Usage](#usage-1)\n"
+	if toc := doc.GenerateTOC(3); toc != shallow {
+		t.Errorf("Expected TOC up to level 3:\n%s\ngot:\n%s", shallow, toc)
+	}
+
+	top := "- [Install](#install)\n- [Usage](#usage)\n- [Usage](#usage-1)\n"
+	if toc := doc.GenerateTOC(1); toc != top {
+		t.Errorf("Expected the shallowest headings when none is shallow enough, got %q", toc)
+	}
+}
+
+func TestGetContentBetween(t *testing.T) {
+	engine := mq.New()
+	content := "# Guide\n\n## Install\n\nRun it.\n\n## Configure\n\nEdit it.\n\n## FAQ\n\nAsk.\n"
+	doc, err := engine.ParseDocument([]byte(content), "guide.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	between, err := doc.GetContentBetween("Install", "FAQ")
+	if err != nil {
+		t.Fatalf("GetContentBetween failed: %v", err)
+	}
+	if expected := "Run it.\n\n## Configure\n\nEdit it."; between != expected {
+		t.Errorf("Expected %q, got %q", expected, between)
+	}
+
+	inclusive, err := doc.GetContentBetweenWithOptions("install", "FAQ", mq.BetweenOptions{IncludeStart: true, IncludeEnd: true})
+	if err != nil {
+		t.Fatalf("GetContentBetweenWithOptions failed: %v", err)
+	}
+	if !strings.HasPrefix(inclusive, "## Install") || !strings.HasSuffix(inclusive, "## FAQ\n\nAsk.") {
+		t.Errorf("Expected both headings included, got %q", inclusive)
+	}
+
+	if _, err := doc.GetContentBetween("Install", "Missing"); !errors.Is(err, mq.ErrHeadingNotFound) {
+		t.Errorf("Expected ErrHeadingNotFound, got %v", err)
+	}
+	_, err = doc.GetContentBetween("FAQ", "Install")
+	if err == nil || errors.Is(err, mq.ErrHeadingNotFound) || !strings.Contains(err.Error(), "does not come after") {
+		t.Errorf("Expected an out-of-order error, got %v", err)
+	}
+}
+
+func TestLineEndingNormalization(t *testing.T) {
+	content := "---\r\ntitle: Notes\r\n---\r\n# Intro\r\n\r\nHello.\r\n\r\n## Next\rOld mac line.\r\n"
+	doc, err := mq.New().ParseDocument([]byte(content), "crlf.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	if !doc.Normalized() {
+		t.Error("Expected CRLF input to be normalized")
+	}
+	if string(doc.OriginalSource()) != content {
+		t.Error("Expected the original source to be kept")
+	}
+	if strings.Contains(string(doc.Source()), "\r") {
+		t.Errorf("Expected no carriage returns in the source, got %q", doc.Source())
+	}
+	if title, _ := doc.GetMetadataField("title"); title != "Notes" {
+		t.Errorf("Expected frontmatter title, got %v", title)
+	}
+
+	intro, ok := doc.GetSection("Intro")
+	if !ok {
+		t.Fatal("Section Intro not found")
+	}
+	if intro.Start != 4 || intro.End != 10 {
+		t.Errorf("Expected Intro at lines 4-10, got %d-%d", intro.Start, intro.End)
+	}
+	if head := intro.Head(1); head != "Hello." {
+		t.Errorf("Expected %q, got %q", "Hello.", head)
+	}
+	next, ok := doc.GetSection("Next")
+	if !ok || next.Start != 8 || next.Head(1) != "Old mac line." {
+		t.Errorf("Expected a lone CR to end the heading line, got %+v", next)
+	}
+
+	plain, err := mq.New().ParseDocument([]byte("# A\n\nText\n"), "lf.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	if plain.Normalized() {
+		t.Error("Expected LF input to be left alone")
+	}
+
+	trimmed, err := mq.New(mq.WithParser(mq.NewParser(mq.WithTrimTrailingSpace()))).ParseDocument([]byte("# A \n\nText\t\n"), "trim.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	if string(trimmed.Source()) != "# A\n\nText\n" {
+		t.Errorf("Expected trailing whitespace trimmed, got %q", trimmed.Source())
+	}
+
+	raw, err := mq.New(mq.WithParser(mq.NewParser(mq.WithRawLineEndings()))).ParseDocument([]byte(content), "raw.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	if raw.Normalized() || string(raw.Source()) != content {
+		t.Error("Expected WithRawLineEndings to keep the source as is")
+	}
+}
+
+func TestParseStats(t *testing.T) {
+	content := []byte("# A\n\nSome *text*.\n\n- one\n- two\n")
+
+	doc, err := mq.New().ParseDocument(content, "plain.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	if _, ok := doc.ParseStats(); ok {
+		t.Error("Expected no stats without profiling")
+	}
+
+	profiled, err := mq.New(mq.WithParser(mq.NewParser(mq.WithProfiling()))).ParseDocument(content, "profiled.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	stats, ok := profiled.ParseStats()
+	if !ok {
+		t.Fatal("Expected stats with profiling")
+	}
+	if stats.Nodes < 8 {
+		t.Errorf("Expected the AST nodes to be counted, got %d", stats.Nodes)
+	}
+	if stats.Total <= 0 || stats.Total < stats.Parse+stats.Index {
+		t.Errorf("Expected total to cover parse and index, got %+v", stats)
+	}
+	if s := stats.String(); !strings.Contains(s, "nodes") || !strings.HasPrefix(s, "parse ") {
+		t.Errorf("Unexpected stats string %q", s)
+	}
+}
+
+func TestSearchOptions(t *testing.T) {
+	content := "# Pets\n\nThe Cat sleeps.\n\n# Shop\n\nBrowse by category.\n\n# Notes\n\nA cat nap.\n"
+	doc, err := mq.New().ParseDocument([]byte(content), "pets.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		opts mq.SearchOptions
+		want []string
+	}{
+		{mq.SearchOptions{}, []string{"Pets", "Shop", "Notes"}},
+		{mq.SearchOptions{CaseSensitive: true}, []string{"Shop", "Notes"}},
+		{mq.SearchOptions{WholeWord: true}, []string{"Pets", "Notes"}},
+		{mq.SearchOptions{CaseSensitive: true, WholeWord: true}, []string{"Notes"}},
+	}
+	for _, tt := range tests {
+		results := doc.SearchWithOptions("cat", tt.opts)
+		var got []string
+		for _, m := range results.Matches {
+			got = append(got, m.Section)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%+v: expected %v, got %v", tt.opts, tt.want, got)
+		}
+	}
+
+	if got := len(doc.Search("CAT").Matches); got != 3 {
+		t.Errorf("Expected Search to stay case-insensitive substring, got %d matches", got)
+	}
+	if m := doc.SearchWithOptions("cat", mq.SearchOptions{WholeWord: true}).Matches[0]; m.Match != "# Pets The Cat sleeps." {
+		t.Errorf("Expected snippet around the match, got %q", m.Match)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "pets.md"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	results, err := mq.SearchDirWithOptions(dir, "cat", mq.SearchOptions{CaseSensitive: true, WholeWord: true})
+	if err != nil {
+		t.Fatalf("SearchDirWithOptions failed: %v", err)
+	}
+	if len(results.Matches) != 1 || results.Matches[0].Section != "Notes" {
+		t.Errorf("Expected SearchDirWithOptions to apply options, got %+v", results.Matches)
+	}
+}
+
+func TestSearchElements(t *testing.T) {
+	content := "# Deploy\n\nRun deploy from CI.\n\n```bash\nmake build\n./deploy.sh\n```\n\n## Targets\n\n| Env | Command |\n|-----|---------|\n| prod | deploy prod |\n\n- build first\n- then deploy\n"
+	doc, err := mq.New().ParseDocument([]byte(content), "ops.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	results := doc.SearchWithOptions("deploy", mq.SearchOptions{Elements: true})
+	var got []string
+	for _, m := range results.Matches {
+		got = append(got, fmt.Sprintf("%d %s %s", m.Line, m.Where(), m.Section))
+	}
+	want := []string{
+		"1 heading Deploy",
+		"3 prose Deploy",
+		"7 code block (bash) Deploy",
+		"14 table cell Targets",
+		"17 list item Targets",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected element matches:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	if m := results.Matches[2]; m.Kind != mq.ElementCode || m.Language != "bash" {
+		t.Errorf("Expected a bash code block match, got %+v", m)
+	}
+
+	headings := doc.SearchWithOptions("deploy", mq.SearchOptions{Elements: true, Kinds: []mq.ElementKind{mq.ElementHeading}})
+	if len(headings.Matches) != 1 || headings.Matches[0].Match != "Deploy" {
+		t.Errorf("Expected only the heading match, got %+v", headings.Matches)
+	}
+
+	prose := doc.SearchWithOptions("build", mq.SearchOptions{Elements: true, Kinds: []mq.ElementKind{mq.ElementProse, mq.ElementList}})
+	if len(prose.Matches) != 1 || prose.Matches[0].Kind != mq.ElementList || prose.Matches[0].Line != 16 {
+		t.Errorf("Expected the code block to be skipped, got %+v", prose.Matches)
+	}
+
+	if s := results.String(); !strings.Contains(s, "(line 7, in code block (bash))") {
+		t.Errorf("Expected String to describe the element, got:\n%s", s)
+	}
+}
+
+func TestFrontmatterNode(t *testing.T) {
+	content := "---\n# Owned by docs\ntitle: 'Guide'\nstatus: draft # pending review\ntags: [go, cli]\n---\n\n# Guide\n"
+	doc, err := mq.New().ParseDocument([]byte(content), "guide.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	node, ok := doc.FrontmatterNode()
+	if !ok {
+		t.Fatal("Expected a frontmatter node")
+	}
+	mapping := node.Content[0]
+	if mapping.Kind != yaml.MappingNode || len(mapping.Content) != 6 {
+		t.Fatalf("Expected a mapping of three fields, got %+v", mapping)
+	}
+	status := mapping.Content[3]
+	if status.Value != "draft" || status.Line != 4 {
+		t.Errorf("Expected status on document line 4, got %q on line %d", status.Value, status.Line)
+	}
+	if title := mapping.Content[1]; title.Style != yaml.SingleQuotedStyle {
+		t.Errorf("Expected the title's quoting style to be kept, got %v", title.Style)
+	}
+
+	// Edit one field; the rest round-trips unchanged
+	status.Value = "published"
+	out, err := yaml.Marshal(node)
+	if err != nil {
+		t.Fatalf("Failed to encode node: %v", err)
+	}
+	want := "# Owned by docs\ntitle: 'Guide'\nstatus: published # pending review\ntags: [go, cli]\n"
+	if string(out) != want {
+		t.Errorf("Expected round-trip:\n%s\ngot:\n%s", want, out)
+	}
+
+	// Each call returns a fresh tree
+	again, _ := doc.FrontmatterNode()
+	if again.Content[0].Content[3].Value != "draft" {
+		t.Error("Expected edits not to leak into later calls")
+	}
+	if v, _ := doc.GetMetadataField("status"); v != "draft" {
+		t.Errorf("Expected Metadata to be unaffected, got %v", v)
+	}
+
+	plain, err := mq.New().ParseDocument([]byte("# No frontmatter\n"), "plain.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	if _, ok := plain.FrontmatterNode(); ok {
+		t.Error("Expected no frontmatter node")
+	}
+}
+
+func TestSetMetadataField(t *testing.T) {
+	content := "---\n# Owned by docs\ntitle: 'Guide'\nstatus: 'draft' # pending review\n---\n\n# Guide\n\nBody text.\n"
+	doc, err := mq.New().ParseDocument([]byte(content), "guide.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	if got := string(doc.RenderWithFrontmatter()); got != content {
+		t.Errorf("Expected an unedited document to render unchanged, got:\n%s", got)
+	}
+
+	if err := doc.SetMetadataField("status", "published"); err != nil {
+		t.Fatalf("SetMetadataField failed: %v", err)
+	}
+	if err := doc.SetMetadataField("tags", []string{"go", "cli"}); err != nil {
+		t.Fatalf("SetMetadataField failed: %v", err)
+	}
+	want := "---\n# Owned by docs\ntitle: 'Guide'\nstatus: 'published' # pending review\ntags:\n  - go\n  - cli\n---\n\n# Guide\n\nBody text.\n"
+	if got := string(doc.RenderWithFrontmatter()); got != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, got)
+	}
+	if v, _ := doc.GetMetadataField("status"); v != "published" {
+		t.Errorf("Expected Metadata to reflect the edit, got %v", v)
+	}
+	if keys := strings.Join(doc.MetadataKeys(), ","); keys != "title,status,tags" {
+		t.Errorf("Expected new keys after existing ones, got %s", keys)
+	}
+	if node, ok := doc.FrontmatterNode(); !ok || node.Content[0].Content[4].Value != "tags" {
+		t.Errorf("Expected FrontmatterNode to include edits")
+	}
+
+	// A document without frontmatter gets a new block
+	plain, err := mq.New().ParseDocument([]byte("# Notes\n"), "notes.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	if err := plain.SetMetadataField("status", "published"); err != nil {
+		t.Fatalf("SetMetadataField failed: %v", err)
+	}
+	if got := string(plain.RenderWithFrontmatter()); got != "---\nstatus: published\n---\n\n# Notes\n" {
+		t.Errorf("Expected a new frontmatter block, got:\n%s", got)
+	}
+
+	invalid, err := mq.New().ParseDocument([]byte("---\n- a\n- b\n---\n# X\n"), "list.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	if err := invalid.SetMetadataField("status", "x"); err == nil {
+		t.Error("Expected an error for non-mapping frontmatter")
+	}
+}
+
+func TestSetMetadataFieldConcurrent(t *testing.T) {
+	doc, err := mq.New().ParseDocument([]byte("---\ntitle: Guide\n---\n\n# Guide\n"), "guide.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				if g%2 == 0 {
+					if err := doc.SetMetadataField(fmt.Sprintf("key%d", g), i); err != nil {
+						t.Errorf("SetMetadataField failed: %v", err)
+						return
+					}
+					continue
+				}
+				_ = doc.Title()
+				_ = doc.Language()
+				_ = doc.MetadataKeys()
+				_ = doc.MetadataText()
+				_ = doc.RenderWithFrontmatter()
+				_, _ = doc.FrontmatterNode()
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if got := len(doc.Metadata()); got != 5 {
+		t.Errorf("Expected title and 4 set keys, got %d fields", got)
+	}
+}
+
+func TestSectionTextOptions(t *testing.T) {
+	content := "# Install\n\nRun make.\n\nUsage\n=====\n\n\nCall it.\n"
+	doc, err := mq.New().ParseDocument([]byte(content), "install.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		section  string
+		opts     mq.SectionTextOptions
+		expected string
+	}{
+		{"Install", mq.SectionTextOptions{}, "# Install\n\nRun make.\n"},
+		{"Install", mq.SectionTextOptions{BodyOnly: true}, "Run make.\n"},
+		{"Usage", mq.SectionTextOptions{}, "Usage\n=====\n\n\nCall it.\n"},
+		{"Usage", mq.SectionTextOptions{BodyOnly: true}, "Call it.\n"},
+	}
+	for _, tt := range tests {
+		section, ok := doc.GetSection(tt.section)
+		if !ok {
+			t.Fatalf("Section %q not found", tt.section)
+		}
+		if got := section.GetTextWithOptions(tt.opts); got != tt.expected {
+			t.Errorf("%s %+v: expected %q, got %q", tt.section, tt.opts, tt.expected, got)
+		}
+	}
+
+	usage, _ := doc.GetSection("Usage")
+	if got := usage.Head(5); got != "Call it." {
+		t.Errorf("Expected Head to skip the setext underline, got %q", got)
+	}
+}
+
+func TestTableOfContentsMaxLevel(t *testing.T) {
+	content := "# Guide\n\n## Install\n\n### Linux\n\n#### Debian\n\n## Usage\n\n### Flags\n\n# FAQ\n"
+	doc, err := mq.New().ParseDocument([]byte(content), "guide.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	var outline func(sections []*mq.Section, parent *mq.Section) string
+	outline = func(sections []*mq.Section, parent *mq.Section) string {
+		var parts []string
+		for _, s := range sections {
+			if s.Parent != parent {
+				t.Errorf("Section %q has a dangling parent", s.Heading.Text)
+			}
+			part := s.Heading.Text
+			if len(s.Children) > 0 {
+				part += "(" + outline(s.Children, s) + ")"
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, " ")
+	}
+
+	tests := []struct {
+		maxLevel []int
+		expected string
+	}{
+		{nil, "Guide(Install(Linux(Debian)) Usage(Flags)) FAQ"},
+		{[]int{0}, "Guide(Install(Linux(Debian)) Usage(Flags)) FAQ"},
+		{[]int{1}, "Guide FAQ"},
+		{[]int{2}, "Guide(Install Usage) FAQ"},
+		{[]int{3}, "Guide(Install(Linux) Usage(Flags)) FAQ"},
+	}
+	for _, tt := range tests {
+		if got := outline(doc.GetTableOfContents(tt.maxLevel...), nil); got != tt.expected {
+			t.Errorf("maxLevel %v: expected %s, got %s", tt.maxLevel, tt.expected, got)
+		}
+	}
+
+	// Pruning copies; the document's own tree is untouched
+	doc.GetTableOfContents(1)
+	if guide, _ := doc.GetSection("Guide"); len(guide.Children) != 2 {
+		t.Errorf("Expected the original tree to keep its children, got %d", len(guide.Children))
+	}
+	if pruned := doc.GetTableOfContents(2)[0]; pruned.End != 12 || !strings.Contains(pruned.GetText(), "Debian") {
+		t.Errorf("Expected pruned sections to keep their line range, got %d-%d", pruned.Start, pruned.End)
+	}
+
+	// Copies navigate among copies; without pruning the originals are returned
+	toc := doc.GetTableOfContents(1)
+	if toc[0].Next() != toc[1] || toc[1].Prev() != toc[0] || len(toc[0].Siblings()) != 1 || toc[0].Siblings()[0] != toc[1] {
+		t.Error("Expected pruned top-level sections to be each other's peers")
+	}
+	if guide, _ := doc.GetSection("Guide"); doc.GetTableOfContents(4)[0] != guide {
+		t.Error("Expected the original sections when nothing is pruned")
+	}
+
+	// Deep top-level sections give way to shallower ones, and a document
+	// starting below maxLevel keeps its shallowest headings
+	for content, expected := range map[string]string{
+		"### Note\n\n#### Aside\n\n# Title\n\n## Part\n\n### Detail\n": "Title(Part)",
+		"### Setup\n\n#### Linux\n\n### Usage\n":                       "Setup Usage",
+	} {
+		doc, err := mq.New().ParseDocument([]byte(content), "deep.md")
+		if err != nil {
+			t.Fatalf("Failed to parse document: %v", err)
+		}
+		if got := outline(doc.GetTableOfContents(2), nil); got != expected {
+			t.Errorf("%q: expected %s, got %s", content, expected, got)
+		}
+	}
+}
+
+func TestDocumentTitle(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"frontmatter", "---\ntitle: Field Guide\n---\n# Heading\n", "Field Guide"},
+		{"blank frontmatter title", "---\ntitle: \"  \"\n---\n# Heading\n", "Heading"},
+		{"first H1", "Intro.\n\n## Setup\n\n# Heading\n\n# Later\n", "Heading"},
+		{"file name", "## Setup\n\nText.\n", "doc"},
+	}
+	for _, tt := range tests {
+		doc, err := mq.New().ParseDocument([]byte(tt.content), "doc.md")
+		if err != nil {
+			t.Fatalf("%s: failed to parse document: %v", tt.name, err)
+		}
+		if got := doc.Title(); got != tt.expected {
+			t.Errorf("%s: expected title %q, got %q", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	content := []byte(`---
+title: Guide
+---
+# Guide
+
+Some *emphasis* and ` + "`code`" + `,
+wrapped.
+
+## Steps
+
+1. Install
+2. Configure
+   - [x] keys
+   - [ ] tokens
+
+> Quoted note.
+
+` + "```sh\nmake build\n```" + `
+
+| Name | Value |
+|------|-------|
+| a    | 1     |
+`)
+	doc, err := mq.NewParser().Parse(content, "guide.md")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	plain := doc.PlainText(mq.PlainTextOptions{})
+	want := "Guide\n\nSome emphasis and code, wrapped.\n\nSteps\n\nInstall\nConfigure\nkeys\ntokens\n\nQuoted note.\n\nName | Value\na | 1"
+	if plain != want {
+		t.Errorf("Unexpected bare text:\n%s\nwant:\n%s", plain, want)
+	}
+
+	structured := doc.PlainText(mq.PlainTextOptions{HeadingMarkers: true, CodeBlocks: true, FenceCode: true, ListMarkers: true})
+	want = "# Guide\n\nSome emphasis and code, wrapped.\n\n## Steps\n\n1. Install\n2. Configure\n  - [x] keys\n  - [ ] tokens\n\nQuoted note.\n\n```sh\nmake build\n```\n\nName | Value\na | 1"
+	if structured != want {
+		t.Errorf("Unexpected structured text:\n%s\nwant:\n%s", structured, want)
+	}
+
+	if got := doc.PlainText(mq.PlainTextOptions{CodeBlocks: true}); !strings.Contains(got, "Quoted note.\n\nmake build\n\nName") {
+		t.Errorf("Expected an unfenced code block, got:\n%s", got)
+	}
+
+	if strings.Contains(plain, "title:") {
+		t.Error("Expected frontmatter to be left out")
+	}
+
+	// Headings share the inline text walker, so wrapped setext headings and
+	// autolinks read the same as in paragraphs
+	wrapped, err := mq.NewParser().Parse([]byte("Release\nnotes for <https://example.com>\n===\n"), "notes.md")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if got := wrapped.GetHeadings()[0].Text; got != "Release notes for https://example.com" {
+		t.Errorf("Expected the heading text joined with spaces, got %q", got)
+	}
+}
+
+func TestComponents(t *testing.T) {
+	content := []byte(`# Guide
+
+<Callout type="warning" dismissible>
+  Back up your **data** first.
+</Callout>
+
+## Install
+
+<Tabs items={["npm", "yarn"]}>
+
+<Tab label="npm">
+npm install
+</Tab>
+
+</Tabs>
+
+<Badge label='new' />
+
+<details>
+<summary>More</summary>
+Hidden text.
+</details>
+`)
+
+	doc, err := mq.NewParser().Parse(content, "guide.mdx")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if n := len(doc.GetComponents()); n != 0 {
+		t.Errorf("Expected no components without WithComponents, got %d", n)
+	}
+
+	doc, err = mq.NewParser(mq.WithComponents()).Parse(content, "guide.mdx")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	components := doc.GetComponents()
+	var names []string
+	for _, c := range components {
+		names = append(names, c.Name)
+	}
+	if strings.Join(names, ",") != "Callout,Tabs,Badge,details" {
+		t.Fatalf("Expected Callout, Tabs, Badge and details (Tab nested in Tabs), got %v", names)
+	}
+
+	callout := components[0]
+	if callout.Attributes["type"] != "warning" || callout.Attributes["dismissible"] != "true" {
+		t.Errorf("Unexpected Callout attributes: %v", callout.Attributes)
+	}
+	if callout.Text != "Back up your data first." {
+		t.Errorf("Expected Callout text without markup, got %q", callout.Text)
+	}
+	if callout.Line != 3 || callout.Section == nil || callout.Section.Heading.Text != "Guide" {
+		t.Errorf("Expected Callout on line 3 in Guide, got line %d", callout.Line)
+	}
+	if callout.SelfClosing {
+		t.Error("Expected Callout to wrap its content")
+	}
+
+	tabs := components[1]
+	if tabs.Attributes["items"] != `{["npm", "yarn"]}` {
+		t.Errorf("Expected the items expression with braces, got %q", tabs.Attributes["items"])
+	}
+	if !strings.Contains(tabs.Content, `<Tab label="npm">`) || tabs.Text != "npm install" {
+		t.Errorf("Expected nested Tab in content and its text, got content %q, text %q", tabs.Content, tabs.Text)
+	}
+	if tabs.Section == nil || tabs.Section.Heading.Text != "Install" {
+		t.Error("Expected Tabs in the Install section")
+	}
+
+	badge := components[2]
+	if !badge.SelfClosing || badge.Attributes["label"] != "new" || badge.Content != "" {
+		t.Errorf("Expected self-closing Badge with label new, got %+v", badge)
+	}
+
+	if got := doc.GetComponents("Callout", "Badge"); len(got) != 2 {
+		t.Errorf("Expected 2 components by name, got %d", len(got))
+	}
+	if got := doc.GetComponents("Tab"); len(got) != 0 {
+		t.Errorf("Expected nested Tab not to be listed, got %d", len(got))
+	}
+}
+
+func TestValidateStructure(t *testing.T) {
+	parse := func(content string) *mq.Document {
+		t.Helper()
+		doc, err := mq.NewParser().Parse([]byte(content), "test.md")
+		if err != nil {
+			t.Fatalf("Failed to parse: %v", err)
+		}
+		return doc
+	}
+
+	clean := parse("# Guide\n\nIntro.\n\n## Install\n\nSteps.\n\n### Linux\n\napt install\n\n## Usage\n\nRun it.\n")
+	if issues := clean.ValidateStructure(); len(issues) != 0 {
+		t.Errorf("Expected no issues, got %v", issues)
+	}
+
+	skipped := parse("# Guide\n\nIntro.\n\n### Options\n\nText.\n\n## Usage\n\nText.\n")
+	issues := skipped.ValidateStructure()
+	if len(issues) != 1 || issues[0].Rule != mq.RuleSkippedLevel {
+		t.Fatalf("Expected one skipped-level issue, got %v", issues)
+	}
+	if issues[0].Heading != "Options" || issues[0].Level != 3 || issues[0].Line != 5 {
+		t.Errorf("Expected issue for H3 Options on line 5, got %+v", issues[0])
+	}
+
+	multiple := parse("# One\n\nText.\n\n# Two\n\nText.\n\n# Three\n\nText.\n")
+	issues = multiple.ValidateStructure()
+	if len(issues) != 2 {
+		t.Fatalf("Expected two multiple-h1 issues, got %v", issues)
+	}
+	for i, want := range []string{"Two", "Three"} {
+		if issues[i].Rule != mq.RuleMultipleH1 || issues[i].Heading != want {
+			t.Errorf("Expected multiple-h1 issue for %s, got %+v", want, issues[i])
+		}
+	}
+
+	empty := parse("# Guide\n\n## Empty\n\n## Full\n\nText.\n\n## Last\n")
+	issues = empty.ValidateStructure()
+	var headings []string
+	for _, issue := range issues {
+		if issue.Rule != mq.RuleEmptySection {
+			t.Errorf("Expected only empty-section issues, got %+v", issue)
+		}
+		headings = append(headings, issue.Heading)
+	}
+	// Guide has subsections, so it is not empty
+	if strings.Join(headings, ",") != "Empty,Last" {
+		t.Errorf("Expected Empty and Last to be empty sections, got %v", headings)
+	}
+	if s := issues[0].String(); s != `line 3: section "Empty" has no content (empty-section)` {
+		t.Errorf("Unexpected issue string: %s", s)
+	}
+}
+
+func TestCard(t *testing.T) {
+	content := []byte(`---
+title: Deploy Guide
+owner: ops
+tags: [deploy, k8s]
+config:
+  team: platform
+---
+
+# Deploy
+
+Ship it safely.
+
+## Rollback
+
+See [runbook](https://example.com).
+`)
+	doc, err := mq.NewParser().Parse(content, "deploy.md")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	card := doc.Card()
+	if len(card) != len(mq.DefaultCardFields) {
+		t.Errorf("Expected the default fields, got %v", card)
+	}
+	if card["title"] != "Deploy Guide" || card["owner"] != "ops" || card["sections"] != 2 || card["first_paragraph"] != "Ship it safely." {
+		t.Errorf("Unexpected card: %v", card)
+	}
+	if tags, ok := card["tags"].([]string); !ok || strings.Join(tags, ",") != "deploy,k8s" {
+		t.Errorf("Expected tags, got %v", card["tags"])
+	}
+
+	card = doc.Card("path", "links", "priority", "config.team")
+	if card["path"] != "deploy.md" || card["links"] != 1 || card["priority"] != nil || card["config.team"] != "platform" {
+		t.Errorf("Unexpected custom card: %v", card)
+	}
+
+	bare, err := mq.NewParser().Parse([]byte("Just text.\n"), "bare.md")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	card = bare.Card("owner", "sections", "headings", "tags")
+	if card["owner"] != nil || card["sections"] != len(bare.GetSections()) || card["headings"] != 0 {
+		t.Errorf("Expected no owner, the implicit section and no headings, got %v", card)
+	}
+	if tags, ok := card["tags"].([]string); !ok || len(tags) != 0 {
+		t.Errorf("Expected empty tags, got %#v", card["tags"])
+	}
+}
+
+func TestIterSections(t *testing.T) {
+	doc, err := mq.NewParser().Parse([]byte("# A\n\n## B\n\n### C\n\n## D\n"), "test.md")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	var all []*mq.Section
+	doc.IterSections(func(s *mq.Section) bool {
+		all = append(all, s)
+		return true
+	})
+	sections := doc.GetSections()
+	if len(all) != len(sections) {
+		t.Fatalf("Expected %d sections, got %d", len(sections), len(all))
+	}
+	for i := range all {
+		if all[i] != sections[i] {
+			t.Errorf("Section %d: expected %s, got %s", i, sections[i].Heading.Text, all[i].Heading.Text)
+		}
+	}
+
+	// Returning false stops the iteration
+	var visited []string
+	doc.IterSections(func(s *mq.Section) bool {
+		visited = append(visited, s.Heading.Text)
+		return s.Heading.Text != "B"
+	})
+	if strings.Join(visited, ",") != "A,B" {
+		t.Errorf("Expected iteration to stop at B, got %v", visited)
+	}
+
+	// The callback may use the document
+	doc.IterSections(func(s *mq.Section) bool {
+		if _, ok := doc.GetSection(s.Heading.Text); !ok {
+			t.Errorf("Expected to look up %s during iteration", s.Heading.Text)
+		}
+		return true
+	})
+}
+
+func TestTableNormalize(t *testing.T) {
+	table := &mq.Table{
+		Rows: [][]string{
+			{" Key ", "Value"},
+			{"a", "line one\n   line two", "extra"},
+			{"b"},
+		},
+	}
+	table.Normalize()
+
+	if got := strings.Join(table.Headers, ","); got != "Key,Value,Column 3" {
+		t.Errorf("Expected the first row as headers plus Column 3, got %s", got)
+	}
+	if len(table.Rows) != 2 || table.Rows[0][1] != "line one line two" || len(table.Rows[1]) != 3 {
+		t.Errorf("Expected collapsed whitespace and padded rows, got %q", table.Rows)
+	}
+
+	records := table.Records()
+	if len(records) != 2 || records[0]["Column 3"] != "extra" || records[1]["Key"] != "b" || records[1]["Value"] != "" {
+		t.Errorf("Unexpected records: %v", records)
+	}
+
+	contacts := &mq.Table{
+		Headers: []string{"Name", "Contact", "Contact", "Contact 2", "", ""},
+		Rows:    [][]string{{"Ann", "ann@example.com", "555-0100", "@ann", "x", "y"}},
+	}
+	contacts.Normalize()
+	if got := strings.Join(contacts.Headers, ","); got != "Name,Contact,Contact 3,Contact 2,,Column 6" {
+		t.Errorf("Expected unique headers, got %s", got)
+	}
+	if record := contacts.Records()[0]; len(record) != 6 || record["Contact 3"] != "555-0100" {
+		t.Errorf("Expected every column in the record, got %v", record)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	text := "First sentence here. Second one is longer than that!\nThird?"
+	tests := []struct {
+		max      int
+		expected string
+	}{
+		{100, "First sentence here. Second one is longer than that! Third?"},
+		{25, "First sentence here. …"},
+		{55, "First sentence here. Second one is longer than that! …"},
+		{15, "First …"},
+		{3, "F …"},
+		{2, "…"},
+		{0, ""},
+	}
+	for _, tt := range tests {
+		if got := mq.Summarize(text, tt.max); got != tt.expected {
+			t.Errorf("Summarize(%d) = %q, want %q", tt.max, got, tt.expected)
+		}
+	}
+	for n := 1; n <= 70; n++ {
+		if got := mq.Summarize(text, n); utf8.RuneCountInString(got) > n {
+			t.Errorf("Summarize(%d) = %q has %d characters", n, got, utf8.RuneCountInString(got))
+		}
+	}
+
+	doc, err := mq.NewParser().Parse([]byte("# API\n\nThe API is small. It has two endpoints.\n"), "test.md")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	section, _ := doc.GetSection("API")
+	if got := section.Summary(30); got != "The API is small. …" {
+		t.Errorf("Unexpected section summary: %q", got)
+	}
+	if got := doc.Summary(10); got != "API The …" {
+		t.Errorf("Unexpected document summary: %q", got)
+	}
+
+	// Markup and code are left out, as in the document summary
+	doc, err = mq.NewParser().Parse([]byte("# Setup\n\nRun the **installer**:\n\n- download it\n- run it\n\n```sh\n./install\n```\n"), "setup.md")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	section, _ = doc.GetSection("Setup")
+	if got := section.Summary(200); got != "Run the installer: download it run it" {
+		t.Errorf("Expected a plain-text section summary, got %q", got)
+	}
+}
+
+func TestChunkText(t *testing.T) {
+	text := "First paragraph here.\n\nSecond one.\n\nThird paragraph is much longer than the rest.\nIt has two lines."
+
+	tests := []struct {
+		max      int
+		expected []string
+	}{
+		{1000, []string{text}},
+		{40, []string{
+			"First paragraph here.\n\nSecond one.",
+			"Third paragraph is much longer than the",
+			"rest.\nIt has two lines.",
+		}},
+		{8, []string{"First", "paragrap", "h here.", "Second", "one.", "Third", "paragrap", "h is", "much", "longer", "than the", "rest.", "It has", "two", "lines."}},
+	}
+	for _, test := range tests {
+		chunks := mq.ChunkText(text, test.max)
+		if strings.Join(chunks, "|") != strings.Join(test.expected, "|") {
+			t.Errorf("ChunkText(%d): expected %q, got %q", test.max, test.expected, chunks)
+		}
+		for _, chunk := range chunks {
+			if len([]rune(chunk)) > test.max {
+				t.Errorf("ChunkText(%d): chunk %q is too long", test.max, chunk)
+			}
+		}
+	}
+
+	if chunks := mq.ChunkText("\n \n", 10); chunks == nil || len(chunks) != 0 {
+		t.Errorf("Expected no chunks for blank text, got %q", chunks)
+	}
+	if chunks := mq.ChunkText(text, 0); chunks != nil {
+		t.Errorf("Expected nil for a zero size, got %q", chunks)
+	}
+
+	cb := &mq.CodeBlock{Language: "go", Content: "a := 1\nb := 2"}
+	if chunks := cb.Chunks(20); strings.Join(chunks, "|") != "```go\na := 1\n```|```go\nb := 2\n```" {
+		t.Errorf("Unexpected code chunks: %q", chunks)
+	}
+	if chunks := cb.Chunks(10); chunks != nil {
+		t.Errorf("Expected nil when the fence does not fit, got %q", chunks)
+	}
+}
+
+func TestSectionLineRanges(t *testing.T) {
+	content := "# Title\n\nIntro paragraph\nspanning two lines.\n\n## Install\n\n```sh\n# not a heading\nmake install\n```\n\n### Linux\n\nUse apt.\n\nSetup\n-----\n\nConfigure it.\n\n# Appendix\n\nLast line."
+	doc, err := mq.NewParser().Parse([]byte(content), "ranges.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	expected := map[string][2]int{
+		"Title":    {1, 21},
+		"Install":  {6, 16},
+		"Linux":    {13, 16},
+		"Setup":    {17, 21},
+		"Appendix": {22, 24},
+	}
+	if sections := doc.GetSections(); len(sections) != len(expected) {
+		t.Errorf("Expected %d sections, got %d", len(expected), len(sections))
+	}
+	for title, lines := range expected {
+		section, ok := doc.GetSection(title)
+		if !ok {
+			t.Errorf("Missing section %q", title)
+			continue
+		}
+		if section.Start != lines[0] || section.End != lines[1] {
+			t.Errorf("Expected %s at lines %d-%d, got %d-%d", title, lines[0], lines[1], section.Start, section.End)
+		}
+		if section.Heading.Line != lines[0] {
+			t.Errorf("Expected %s heading on line %d, got %d", title, lines[0], section.Heading.Line)
+		}
+	}
+
+	found := false
+	for _, m := range doc.Search("apt").Matches {
+		if m.Section == "Linux" {
+			found = true
+			if m.Lines != "13-16" {
+				t.Errorf("Expected the Linux search match at lines 13-16, got %s", m.Lines)
+			}
+		}
+	}
+	if !found {
+		t.Error("Expected a search match in Linux")
+	}
+}
+
+func TestLookupKey(t *testing.T) {
+	tests := []struct {
+		obj      interface{}
+		key      string
+		expected interface{}
+		found    bool
+	}{
+		{mq.Metadata{"owner": "alice"}, "owner", "alice", true},
+		{map[string]interface{}{"a": 1}, "a", 1, true},
+		{map[interface{}]interface{}{1: "one", true: "yes"}, "1", "one", true},
+		{map[interface{}]interface{}{1: "one", true: "yes"}, "true", "yes", true},
+		{map[string]interface{}{"a": 1}, "b", nil, false},
+		{[]interface{}{"x"}, "0", nil, false},
+	}
+	for _, tt := range tests {
+		val, ok := mq.LookupKey(tt.obj, tt.key)
+		if ok != tt.found || val != tt.expected {
+			t.Errorf("LookupKey(%v, %q) = %v, %v; want %v, %v", tt.obj, tt.key, val, ok, tt.expected, tt.found)
+		}
+	}
+}
+
+func TestReturnedSlicesAreCopies(t *testing.T) {
+	doc, err := mq.New().ParseDocument([]byte(testMarkdown), "test.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	blocks := doc.GetCodeBlocks()
+	links := doc.GetLinks()
+	if len(blocks) == 0 || len(links) == 0 {
+		t.Fatal("Expected code blocks and links")
+	}
+	blocks[0], links[0] = nil, nil
+	if doc.GetCodeBlocks()[0] == nil || doc.GetLinks()[0] == nil {
+		t.Error("Expected changes to a returned slice not to affect the document")
+	}
+}
@@ -25,6 +25,8 @@ type MultiFormatEngine struct {
 
 	// Default parser for unknown formats
 	defaultFormat Format
+
+	limits Limits
 }
 
 // MultiEngineOption configures the multi-format engine.
@@ -61,6 +63,14 @@ func WithFormatParser(p FormatParser) MultiEngineOption {
 	}
 }
 
+// WithMarkdownParser replaces the default markdown parser, e.g. to pass
+// ParserOptions such as WithProfiling.
+func WithMarkdownParser(p *Parser) MultiEngineOption {
+	return func(e *MultiFormatEngine) {
+		e.registry.Register(&markdownParserAdapter{parser: p})
+	}
+}
+
 // WithDefaultFormat sets the format to use when detection fails.
 func WithDefaultFormat(f Format) MultiEngineOption {
 	return func(e *MultiFormatEngine) {
@@ -93,7 +103,7 @@ func (e *MultiFormatEngine) Parse(content []byte, path string) (*Document, error
 		}
 	}
 
-	return parser.Parse(content, path)
+	return e.parse(parser, content, path)
 }
 
 // ParseWithFormat parses content using a specific parser.
@@ -103,7 +113,19 @@ func (e *MultiFormatEngine) ParseWithFormat(content []byte, path string, format
 		return nil, fmt.Errorf("no parser registered for format: %s", format)
 	}
 
-	return parser.Parse(content, path)
+	return e.parse(parser, content, path)
+}
+
+// parse runs parser and checks the document against the engine's limits.
+func (e *MultiFormatEngine) parse(parser FormatParser, content []byte, path string) (*Document, error) {
+	doc, err := parser.Parse(content, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.limits.Check(doc); err != nil {
+		return nil, &ParseError{Format: parser.Format(), Path: path, Err: err}
+	}
+	return doc, nil
 }
 
 // RegisterParser adds a parser for a format.
@@ -224,3 +224,27 @@ Description here
 
 	assert.Equal(t, mdSection.Heading.Text, htmlSection.Heading.Text)
 }
+
+func TestTitleResolutionAcrossFormats(t *testing.T) {
+	engine := mq.NewMultiFormatEngine(mq.WithFormatParser(html.NewParser()))
+
+	tests := []struct {
+		name     string
+		path     string
+		content  string
+		expected string
+	}{
+		{"markdown frontmatter", "guide.md", "---\ntitle: Declared\n---\n# Heading\n", "Declared"},
+		{"markdown H1", "guide.md", "Intro.\n\n# Heading\n", "Heading"},
+		{"markdown file name", "guide.md", "## Setup\n", "guide"},
+		{"html title", "guide.html", `<html><head><title>Declared</title><meta property="og:title" content="Social"></head><body><h1>Heading</h1></body></html>`, "Declared"},
+		{"html og:title", "guide.html", `<html><head><meta property="og:title" content="Declared"></head><body><h1>Heading</h1></body></html>`, "Declared"},
+		{"html H1", "guide.html", `<html><body><main><h1>Heading</h1><p>Text</p></main></body></html>`, "Heading"},
+		{"html file name", "guide.html", `<html><body><main><h2>Setup</h2><p>Text</p></main></body></html>`, "guide"},
+	}
+	for _, tt := range tests {
+		doc, err := engine.Parse([]byte(tt.content), tt.path)
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.expected, doc.Title(), tt.name)
+	}
+}
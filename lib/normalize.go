@@ -0,0 +1,63 @@
+package mq
+
+import "bytes"
+
+// WithRawLineEndings disables line ending normalization, so documents are
+// parsed exactly as read. Line numbers and line-based text extraction then
+// assume "\n" line endings.
+func WithRawLineEndings() ParserOption {
+	return func(p *Parser) {
+		p.rawLineEndings = true
+	}
+}
+
+// WithTrimTrailingSpace strips trailing spaces and tabs from every line
+// while normalizing. Note that this removes markdown hard line breaks
+// written as two trailing spaces.
+func WithTrimTrailingSpace() ParserOption {
+	return func(p *Parser) {
+		p.trimTrailingSpace = true
+	}
+}
+
+// normalizeSource converts CRLF and lone CR line endings to LF and, with
+// trimSpace, strips trailing spaces and tabs from each line. It returns
+// source itself and false when nothing needed changing.
+func normalizeSource(source []byte, trimSpace bool) ([]byte, bool) {
+	if !bytes.ContainsRune(source, '\r') && !(trimSpace && hasTrailingSpace(source)) {
+		return source, false
+	}
+
+	out := make([]byte, 0, len(source))
+	for len(source) > 0 {
+		end := bytes.IndexAny(source, "\r\n")
+		line, sep := source, 0
+		if end >= 0 {
+			line, sep = source[:end], 1
+			if source[end] == '\r' && end+1 < len(source) && source[end+1] == '\n' {
+				sep = 2
+			}
+		} else {
+			end = len(source)
+		}
+		if trimSpace {
+			line = bytes.TrimRight(line, " \t")
+		}
+		out = append(out, line...)
+		if sep > 0 {
+			out = append(out, '\n')
+		}
+		source = source[end+sep:]
+	}
+	return out, true
+}
+
+// hasTrailingSpace reports whether any line of source ends in a space or tab.
+func hasTrailingSpace(source []byte) bool {
+	for _, line := range bytes.Split(source, []byte("\n")) {
+		if n := len(line); n > 0 && (line[n-1] == ' ' || line[n-1] == '\t') {
+			return true
+		}
+	}
+	return false
+}
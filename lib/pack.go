@@ -0,0 +1,118 @@
+package mq
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// PackSections groups the document's sections into bundles of consecutive
+// sections (in document order) whose combined text is at most maxChars
+// characters, as structure-preserving chunks for retrieval. A section is
+// kept in one bundle with all of its subsections whenever they fit
+// together; otherwise it starts a new bundle and its subsections are packed
+// after it. A section whose own text alone exceeds maxChars gets a bundle to
+// itself. Sizes count each section's own text, up to its first subsection.
+// Text before the first heading is packed first, as an implicit section
+// like the one a document without headings has. It returns nil when
+// maxChars <= 0.
+func (d *Document) PackSections(maxChars int) [][]*Section {
+	if maxChars <= 0 {
+		return nil
+	}
+
+	p := &sectionPacker{max: maxChars, own: make(map[*Section]int), total: make(map[*Section]int)}
+	toc := d.GetTableOfContents()
+	if preamble := d.preamble(toc); preamble != nil {
+		toc = append([]*Section{preamble}, toc...)
+	}
+	for _, s := range toc {
+		p.measure(s)
+	}
+	for _, s := range toc {
+		p.pack(s)
+	}
+	p.flush()
+	return p.bundles
+}
+
+// preamble returns an implicit section holding the text between the
+// frontmatter and the first of the top-level sections toc, or nil if there
+// is none.
+func (d *Document) preamble(toc []*Section) *Section {
+	if len(toc) == 0 || toc[0].Implicit || toc[0].source == nil {
+		return nil
+	}
+	first := toc[0]
+	start := getLineNumber(computeLineStarts(first.source), frontmatterEnd(first.source))
+	if start >= first.Start {
+		return nil
+	}
+	preamble := d.implicitSection(first.source, start, first.Start-1)
+	if strings.TrimSpace(preamble.GetText()) == "" {
+		return nil
+	}
+	return preamble
+}
+
+// sectionPacker accumulates bundles for PackSections.
+type sectionPacker struct {
+	max     int
+	own     map[*Section]int // size of each section's own text
+	total   map[*Section]int // size including subsections
+	bundles [][]*Section
+	current []*Section
+	size    int
+}
+
+func (p *sectionPacker) measure(s *Section) int {
+	p.own[s] = utf8.RuneCountInString(s.OwnText())
+	total := p.own[s]
+	for _, child := range s.Children {
+		total += p.measure(child)
+	}
+	p.total[s] = total
+	return total
+}
+
+func (p *sectionPacker) pack(s *Section) {
+	if p.total[s] <= p.max {
+		p.add(flattenSections(s), p.total[s])
+		return
+	}
+
+	// Too big to keep whole: start a bundle with the section so its heading
+	// stays with as many subsections as fit
+	p.flush()
+	p.add([]*Section{s}, p.own[s])
+	for _, child := range s.Children {
+		p.pack(child)
+	}
+}
+
+func (p *sectionPacker) add(sections []*Section, size int) {
+	if len(p.current) > 0 && p.size+size > p.max {
+		p.flush()
+	}
+	p.current = append(p.current, sections...)
+	p.size += size
+	if p.size > p.max {
+		p.flush() // Oversized section stands alone
+	}
+}
+
+func (p *sectionPacker) flush() {
+	if len(p.current) > 0 {
+		p.bundles = append(p.bundles, p.current)
+	}
+	p.current = nil
+	p.size = 0
+}
+
+// flattenSections returns s followed by its subsections in document order.
+func flattenSections(s *Section) []*Section {
+	sections := []*Section{s}
+	for _, child := range s.Children {
+		sections = append(sections, flattenSections(child)...)
+	}
+	return sections
+}
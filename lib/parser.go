@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/yuin/goldmark"
 	meta "github.com/yuin/goldmark-meta"
@@ -17,13 +19,21 @@ import (
 
 // Parser parses markdown documents with frontmatter support.
 type Parser struct {
-	md goldmark.Markdown
+	md             goldmark.Markdown
+	langAliases    map[string]string // code language aliases (nil: exact match)
+	detectSections SectionDetector   // extra heading detection (nil: markdown headings only)
+	components     bool              // extract HTML and MDX component blocks
+
+	rawLineEndings    bool // skip line ending normalization
+	trimTrailingSpace bool // strip trailing whitespace while normalizing
+	profile           bool // record ParseStats
 }
 
 // ParserOption configures the parser.
 type ParserOption func(*Parser)
 
-// NewParser creates a parser with frontmatter and table support.
+// NewParser creates a parser with frontmatter and table support. Bare URLs
+// and email addresses in prose are recognized as links (GFM autolinks).
 func NewParser(opts ...ParserOption) *Parser {
 	md := goldmark.New(
 		goldmark.WithExtensions(
@@ -31,13 +41,14 @@ func NewParser(opts ...ParserOption) *Parser {
 			extension.Table,
 			extension.TaskList,
 			extension.Strikethrough,
+			extension.Linkify,
 		),
 		goldmark.WithParserOptions(
 			parser.WithAutoHeadingID(),
 		),
 	)
 
-	p := &Parser{md: md}
+	p := &Parser{md: md, langAliases: defaultLanguageAliases}
 
 	for _, opt := range opts {
 		opt(p)
@@ -55,6 +66,7 @@ func WithExtensions(exts ...goldmark.Extender) ParserOption {
 				extension.Table,
 				extension.TaskList,
 				extension.Strikethrough,
+				extension.Linkify,
 			}, exts...)...),
 			goldmark.WithParserOptions(
 				parser.WithAutoHeadingID(),
@@ -63,6 +75,23 @@ func WithExtensions(exts ...goldmark.Extender) ParserOption {
 	}
 }
 
+// SectionDetector reports whether a line of paragraph text starts a section,
+// and at which heading level (1-6).
+type SectionDetector func(line string) (level int, ok bool)
+
+// WithSectionDetector adds heading detection for semi-structured text, such
+// as pasted notes with ALL-CAPS titles. The detector sees each line of the
+// document's top-level paragraphs (lines already parsed as markdown headings,
+// code or lists are not offered), trimmed of surrounding whitespace; lines
+// it accepts become headings that open sections like markdown headings do.
+// A paragraph split by detected headings is in the Content of every section
+// it spans. Levels outside 1-6 are ignored.
+func WithSectionDetector(detect SectionDetector) ParserOption {
+	return func(p *Parser) {
+		p.detectSections = detect
+	}
+}
+
 // ParseFile parses a markdown file.
 func (p *Parser) ParseFile(path string) (*Document, error) {
 	content, err := os.ReadFile(path)
@@ -73,26 +102,53 @@ func (p *Parser) ParseFile(path string) (*Document, error) {
 	return p.Parse(content, path)
 }
 
-// Parse parses markdown content.
+// Parse parses markdown content. Unless WithRawLineEndings is set, CRLF
+// and CR line endings are converted to LF first so that line numbers and
+// extracted text are consistent; the unmodified input stays available from
+// Document.OriginalSource.
 func (p *Parser) Parse(source []byte, path string) (*Document, error) {
+	var start time.Time
+	if p.profile {
+		start = time.Now()
+	}
+
+	var original []byte
+	if !p.rawLineEndings {
+		if normalized, changed := normalizeSource(source, p.trimTrailingSpace); changed {
+			original, source = source, normalized
+		}
+	}
+
+	var parsed time.Time
 	reader := text.NewReader(source)
 	ctx := parser.NewContext()
+	if p.profile {
+		parsed = time.Now()
+	}
 	node := p.md.Parser().Parse(reader, parser.WithContext(ctx))
 
 	doc := &Document{
 		source:          source,
+		original:        original,
 		path:            path,
 		format:          FormatMarkdown,
 		root:            node,
 		headingIndex:    make(map[string]*Heading),
 		headingsByLevel: make(map[int][]*Heading),
 		sectionIndex:    make(map[string]*Section),
+		sectionByID:     make(map[string]*Section),
 		codeByLang:      make(map[string][]*CodeBlock),
 		codeBlocks:      []*CodeBlock{},
 		links:           []*Link{},
 		images:          []*Image{},
 		tables:          []*Table{},
 		lists:           []*List{},
+		langAliases:     p.langAliases,
+	}
+
+	var indexed time.Time
+	if p.profile {
+		indexed = time.Now()
 	}
 
 	// Extract metadata from frontmatter
@@ -106,9 +162,31 @@ func (p *Parser) Parse(source []byte, path string) (*Document, error) {
 		return nil, fmt.Errorf("building indexes: %w", err)
 	}
 
+	if p.profile {
+		end := time.Now()
+		doc.SetParseStats(ParseStats{
+			Parse: indexed.Sub(parsed),
+			Index: end.Sub(indexed),
+			Total: end.Sub(start),
+			Nodes: countNodes(node),
+		})
+	}
+
 	return doc, nil
 }
 
+// countNodes counts the nodes of an AST.
+func countNodes(root ast.Node) int {
+	n := 0
+	ast.Walk(root, func(_ ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			n++
+		}
+		return ast.WalkContinue, nil
+	})
+	return n
+}
+
 // buildIndexes walks the AST and builds document indexes.
 func (p *Parser) buildIndexes(doc *Document) error {
 	var currentSection *Section
@@ -117,6 +195,75 @@ func (p *Parser) buildIndexes(doc *Document) error {
 	// Pre-compute line starts for efficient line number lookups
 	lineStarts := computeLineStarts(doc.source)
 
+	// addComponent extracts a component starting a block, unless the block
+	// lies inside the previous component
+	componentEnd := 0
+	addComponent := func(node ast.Node) {
+		offset, ok := nodeOffset(node)
+		if !ok || offset < componentEnd || !startsWithTag(doc.source, offset) {
+			return
+		}
+		c, end, ok := p.parseComponent(doc.source, offset)
+		if !ok {
+			return
+		}
+		c.Section, c.Node = currentSection, node
+		c.Line = getLineNumber(lineStarts, offset)
+		doc.components = append(doc.components, c)
+		componentEnd = end
+	}
+
+	// openSection indexes a heading and starts its section, closing open
+	// sections at the same or a deeper level
+	openSection := func(heading *Heading) {
+		// Add to heading indexes
+		doc.headingIndex[heading.Text] = heading
+		doc.headings = append(doc.headings, heading)
+		doc.headingsByLevel[heading.Level] = append(
+			doc.headingsByLevel[heading.Level],
+			heading,
+		)
+
+		// Create section
+		section := &Section{
+			Heading:     heading,
+			Start:       heading.Line,
+			Content:     []ast.Node{},
+			source:      doc.source,
+			doc:         doc,
+			langAliases: doc.langAliases,
+		}
+
+		// Manage section hierarchy
+		for len(sectionStack) > 0 && sectionStack[len(sectionStack)-1].Heading.Level >= heading.Level {
+			// Close previous section at the line before this heading
+			prev := sectionStack[len(sectionStack)-1]
+			prev.End = heading.Line - 1
+			sectionStack = sectionStack[:len(sectionStack)-1]
+		}
+
+		// Set parent if exists
+		if len(sectionStack) > 0 {
+			parent := sectionStack[len(sectionStack)-1]
+			section.Parent = parent
+			parent.Children = append(parent.Children, section)
+		}
+
+		sectionStack = append(sectionStack, section)
+		currentSection = section
+		doc.sectionIndex[heading.Text] = section
+		doc.indexAnchor(section)
+		doc.sections = append(doc.sections, section)
+	}
+
+	// addContent adds a top-level block to the current section; nested
+	// nodes are reached through it
+	addContent := func(node ast.Node) {
+		if currentSection != nil && node.Parent() == doc.root {
+			currentSection.Content = append(currentSection.Content, node)
+		}
+	}
+
 	err := ast.Walk(doc.root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
 		if !entering {
 			return ast.WalkContinue, nil
@@ -129,93 +276,100 @@ func (p *Parser) buildIndexes(doc *Document) error {
 			if lines := node.Lines(); lines.Len() > 0 {
 				heading.Line = getLineNumber(lineStarts, lines.At(0).Start)
 			}
-
-			// Add to heading indexes
-			doc.headingIndex[heading.Text] = heading
-			doc.headingsByLevel[heading.Level] = append(
-				doc.headingsByLevel[heading.Level],
-				heading,
-			)
-
-			// Create section
-			section := &Section{
-				Heading: heading,
-				Start:   heading.Line,
-				Content: []ast.Node{},
-				source:  doc.source,
-			}
-
-			// Manage section hierarchy
-			for len(sectionStack) > 0 && sectionStack[len(sectionStack)-1].Heading.Level >= heading.Level {
-				// Close previous section at the line before this heading
-				prev := sectionStack[len(sectionStack)-1]
-				prev.End = heading.Line - 1
-				sectionStack = sectionStack[:len(sectionStack)-1]
-			}
-
-			// Set parent if exists
-			if len(sectionStack) > 0 {
-				parent := sectionStack[len(sectionStack)-1]
-				section.Parent = parent
-				parent.Children = append(parent.Children, section)
-			}
-
-			sectionStack = append(sectionStack, section)
-			currentSection = section
-			doc.sectionIndex[heading.Text] = section
+			openSection(heading)
 
 		case *ast.FencedCodeBlock:
 			cb := p.extractCodeBlock(node, doc.source)
+			cb.Line = fenceLine(node, lineStarts)
 			doc.codeBlocks = append(doc.codeBlocks, cb)
-			if cb.Language != "" {
-				doc.codeByLang[cb.Language] = append(
-					doc.codeByLang[cb.Language],
-					cb,
-				)
-			}
+			lang := normalizeLanguage(doc.langAliases, cb.Language)
+			doc.codeByLang[lang] = append(doc.codeByLang[lang], cb)
+			addContent(node)
 			if currentSection != nil {
-				currentSection.Content = append(currentSection.Content, node)
 				currentSection.AddCodeBlock(cb) // Store reference in section
 			}
 
 		case *ast.Link:
 			link := p.extractLink(node, doc.source)
+			link.Line, link.Col = inlinePosition(node, lineStarts)
 			doc.links = append(doc.links, link)
-			if currentSection != nil {
-				currentSection.Content = append(currentSection.Content, node)
+
+		case *ast.AutoLink:
+			link := p.extractAutoLink(node, doc.source)
+			if offset, ok := autoLinkStart(node, doc.source); ok {
+				line := getLineNumber(lineStarts, offset)
+				link.Line, link.Col = line, offset-lineStarts[line-1]+1
 			}
+			doc.links = append(doc.links, link)
 
 		case *ast.Image:
 			image := p.extractImage(node, doc.source)
+			image.Line, image.Col = inlinePosition(node, lineStarts)
 			doc.images = append(doc.images, image)
 			if currentSection != nil {
-				currentSection.Content = append(currentSection.Content, node)
+				currentSection.AddImage(image)
 			}
 
+		case *east.Strikethrough:
+			var text bytes.Buffer
+			ast.Walk(node, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+				if t, ok := child.(*ast.Text); ok && entering {
+					text.Write(t.Segment.Value(doc.source))
+				}
+				return ast.WalkContinue, nil
+			})
+			st := &Strikethrough{Text: text.String(), Section: currentSection, Node: node}
+			st.Line, _ = inlinePosition(node, lineStarts)
+			doc.strikethroughs = append(doc.strikethroughs, st)
+
 		case *east.Table:
 			table := p.extractTable(node, doc.source)
+			if offset, ok := nodeOffset(node); ok {
+				table.Line = getLineNumber(lineStarts, offset)
+			}
 			doc.tables = append(doc.tables, table)
+			addContent(node)
 			if currentSection != nil {
-				currentSection.Content = append(currentSection.Content, node)
+				currentSection.AddTable(table)
 			}
 
 		case *ast.List:
+			if _, nested := node.Parent().(*ast.ListItem); nested {
+				break // Part of the enclosing list's items
+			}
 			list := p.extractList(node, doc.source)
+			if offset, ok := nodeOffset(node); ok {
+				list.Line = getLineNumber(lineStarts, offset)
+			}
 			doc.lists = append(doc.lists, list)
-			if currentSection != nil {
-				currentSection.Content = append(currentSection.Content, node)
+			addContent(node)
+
+		case *ast.HTMLBlock:
+			if p.components {
+				addComponent(node)
 			}
+			addContent(node)
 
 		case *ast.Paragraph:
-			if currentSection != nil {
-				currentSection.Content = append(currentSection.Content, node)
+			if p.components {
+				addComponent(node)
 			}
+			if p.detectSections != nil && node.Parent() == doc.root {
+				first := getLineNumber(lineStarts, node.Lines().At(0).Start)
+				for i, heading := range p.detectHeadings(node, doc.source, lineStarts) {
+					// A paragraph split by detected headings is content of
+					// every section it spans, not only the last
+					if currentSection != nil && (i > 0 || heading.Line > first) {
+						currentSection.Content = append(currentSection.Content, node)
+					}
+					openSection(heading)
+				}
+			}
+			addContent(node)
 
 		default:
 			// Add other nodes to current section
-			if currentSection != nil {
-				currentSection.Content = append(currentSection.Content, node)
-			}
+			addContent(node)
 		}
 
 		return ast.WalkContinue, nil
@@ -229,9 +383,34 @@ func (p *Parser) buildIndexes(doc *Document) error {
 		}
 	}
 
+	if err == nil && len(doc.sections) == 0 {
+		p.addImplicitSection(doc, getLineNumber(lineStarts, frontmatterEnd(doc.source)), totalLines)
+	}
+
 	return err
 }
 
+// addImplicitSection wraps the body of a document without headings in a
+// single section holding all of its content and elements.
+func (p *Parser) addImplicitSection(doc *Document, start, end int) {
+	root := doc.addImplicitSection(doc.source, start, end)
+	for n := doc.root.FirstChild(); n != nil; n = n.NextSibling() {
+		root.Content = append(root.Content, n)
+	}
+	for _, cb := range doc.codeBlocks {
+		root.AddCodeBlock(cb)
+	}
+	for _, t := range doc.tables {
+		root.AddTable(t)
+	}
+	for _, img := range doc.images {
+		root.AddImage(img)
+	}
+	for _, st := range doc.strikethroughs {
+		st.Section = root
+	}
+}
+
 // computeLineStarts returns byte offsets where each line starts.
 // lineStarts[i] is the byte offset where line i+1 starts (0-indexed internally).
 func computeLineStarts(source []byte) []int {
@@ -244,6 +423,124 @@ func computeLineStarts(source []byte) []int {
 	return starts
 }
 
+// nodeOffset returns the byte offset where a node's text content starts,
+// searching descendants when the node itself carries no line segments.
+func nodeOffset(n ast.Node) (int, bool) {
+	if t, ok := n.(*ast.Text); ok {
+		return t.Segment.Start, true
+	}
+	if n.Type() == ast.TypeBlock {
+		if lines := n.Lines(); lines.Len() > 0 {
+			return lines.At(0).Start, true
+		}
+	}
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		if offset, ok := nodeOffset(child); ok {
+			return offset, true
+		}
+	}
+	return 0, false
+}
+
+// fenceLine returns the line of a fenced code block's opening fence.
+func fenceLine(node *ast.FencedCodeBlock, lineStarts []int) int {
+	if node.Info != nil {
+		return getLineNumber(lineStarts, node.Info.Segment.Start)
+	}
+	if lines := node.Lines(); lines.Len() > 0 {
+		return getLineNumber(lineStarts, lines.At(0).Start) - 1
+	}
+	return 0
+}
+
+// inlinePosition returns the line and column where an inline node starts.
+func inlinePosition(n ast.Node, lineStarts []int) (int, int) {
+	offset, ok := inlineStart(n)
+	if !ok || offset < 0 {
+		return 0, 0
+	}
+	line := getLineNumber(lineStarts, offset)
+	return line, offset - lineStarts[line-1] + 1
+}
+
+// inlineStart derives an inline node's starting byte offset from its first
+// text segment, stepping back over the opening markers of each wrapper
+// ("[" for links, "![" for images, "*"/"_" for emphasis).
+func inlineStart(n ast.Node) (int, bool) {
+	if n == nil {
+		return 0, false
+	}
+
+	switch node := n.(type) {
+	case *ast.Text:
+		return node.Segment.Start, true
+	case *ast.CodeSpan:
+		offset, ok := inlineStart(node.FirstChild())
+		return offset - 1, ok
+	}
+
+	if n.FirstChild() == nil {
+		return 0, false
+	}
+	offset, ok := inlineStart(n.FirstChild())
+	if !ok {
+		return 0, false
+	}
+
+	switch node := n.(type) {
+	case *ast.Link:
+		offset--
+	case *ast.Image:
+		offset -= 2
+	case *ast.Emphasis:
+		offset -= node.Level
+	}
+	return offset, true
+}
+
+// autoLinkStart returns the byte offset where an autolink starts, including
+// the "<" of the angle-bracketed form. Autolinks do not expose their label
+// segment, so the label is searched for after the preceding inline content.
+func autoLinkStart(n *ast.AutoLink, source []byte) (int, bool) {
+	from := -1
+	for prev := n.PreviousSibling(); prev != nil && from < 0; prev = prev.PreviousSibling() {
+		from = inlineEnd(prev)
+	}
+	if from < 0 {
+		for parent := n.Parent(); parent != nil; parent = parent.Parent() {
+			if parent.Type() == ast.TypeBlock && parent.Lines().Len() > 0 {
+				from = parent.Lines().At(0).Start
+				break
+			}
+		}
+	}
+	if from < 0 {
+		return 0, false
+	}
+
+	idx := bytes.Index(source[from:], n.Label(source))
+	if idx < 0 {
+		return 0, false
+	}
+	offset := from + idx
+	if offset > 0 && source[offset-1] == '<' {
+		offset--
+	}
+	return offset, true
+}
+
+// inlineEnd returns the end offset of the last text segment under n, or -1.
+func inlineEnd(n ast.Node) int {
+	end := -1
+	ast.Walk(n, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if t, ok := child.(*ast.Text); ok && entering && t.Segment.Stop > end {
+			end = t.Segment.Stop
+		}
+		return ast.WalkContinue, nil
+	})
+	return end
+}
+
 // getLineNumber returns the 1-based line number for a given byte offset.
 func getLineNumber(lineStarts []int, offset int) int {
 	// Binary search for the line containing this offset
@@ -259,27 +556,33 @@ func getLineNumber(lineStarts []int, offset int) int {
 	return lo + 1 // Convert to 1-based line number
 }
 
-// extractHeading extracts heading information from an AST node.
-func (p *Parser) extractHeading(node *ast.Heading, source []byte) *Heading {
-	var text string
-	var buf bytes.Buffer
-
-	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
-		if t, ok := child.(*ast.Text); ok {
-			buf.Write(t.Segment.Value(source))
-		} else {
-			ast.Walk(child, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
-				if entering {
-					if t, ok := n.(*ast.Text); ok {
-						buf.Write(t.Segment.Value(source))
-					}
-				}
-				return ast.WalkContinue, nil
-			})
+// detectHeadings runs the section detector over the lines of a paragraph
+// and returns a heading for each line it accepts.
+func (p *Parser) detectHeadings(para *ast.Paragraph, source []byte, lineStarts []int) []*Heading {
+	var headings []*Heading
+	lines := para.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		line := strings.TrimSpace(string(seg.Value(source)))
+		if line == "" {
+			continue
 		}
+		level, ok := p.detectSections(line)
+		if !ok || level < 1 || level > 6 {
+			continue
+		}
+		headings = append(headings, &Heading{
+			Level: level,
+			Text:  line,
+			Node:  para,
+			Line:  getLineNumber(lineStarts, seg.Start),
+		})
 	}
-	text = buf.String()
+	return headings
+}
 
+// extractHeading extracts heading information from an AST node.
+func (p *Parser) extractHeading(node *ast.Heading, source []byte) *Heading {
 	id := ""
 	if v, ok := node.AttributeString("id"); ok {
 		id = string(util.EscapeHTML(v.([]byte)))
@@ -287,7 +590,7 @@ func (p *Parser) extractHeading(node *ast.Heading, source []byte) *Heading {
 
 	return &Heading{
 		Level: node.Level,
-		Text:  text,
+		Text:  inlineText(node, source, false),
 		ID:    id,
 		Node:  node,
 	}
@@ -300,19 +603,11 @@ func (p *Parser) extractCodeBlock(node *ast.FencedCodeBlock, source []byte) *Cod
 		language = string(node.Info.Segment.Value(source))
 	}
 
-	var content bytes.Buffer
-	lines := node.Lines()
-	for i := 0; i < lines.Len(); i++ {
-		line := lines.At(i)
-		content.Write(line.Value(source))
-	}
-
-	code := content.String()
 	return &CodeBlock{
 		Language: language,
-		Content:  code,
+		Content:  linesText(node, source),
 		Node:     node,
-		Lines:    lines.Len(),
+		Lines:    node.Lines().Len(),
 	}
 }
 
@@ -332,6 +627,21 @@ func (p *Parser) extractLink(node *ast.Link, source []byte) *Link {
 	}
 }
 
+// extractAutoLink extracts link information from an autolink, either an
+// angle-bracketed <url> or a bare URL/email recognized by Linkify.
+func (p *Parser) extractAutoLink(node *ast.AutoLink, source []byte) *Link {
+	url := string(node.URL(source))
+	if node.AutoLinkType == ast.AutoLinkEmail && !bytes.HasPrefix(node.URL(source), []byte("mailto:")) {
+		url = "mailto:" + url
+	}
+	return &Link{
+		Text: string(node.Label(source)),
+		URL:  url,
+		Node: node,
+		Auto: true,
+	}
+}
+
 // extractImage extracts image information from an AST node.
 func (p *Parser) extractImage(node *ast.Image, source []byte) *Image {
 	var altText bytes.Buffer
@@ -345,6 +655,7 @@ func (p *Parser) extractImage(node *ast.Image, source []byte) *Image {
 		AltText: altText.String(),
 		URL:     string(node.Destination),
 		Title:   string(node.Title),
+		Format:  ImageFormat(string(node.Destination)),
 		Node:    node,
 	}
 }
@@ -391,6 +702,7 @@ func (p *Parser) extractTable(node *east.Table, source []byte) *Table {
 		}
 	}
 
+	table.Normalize()
 	return table
 }
 
@@ -398,54 +710,62 @@ func (p *Parser) extractTable(node *east.Table, source []byte) *Table {
 func (p *Parser) extractList(node *ast.List, source []byte) *List {
 	list := &List{
 		Ordered: node.IsOrdered(),
+		Loose:   !node.IsTight,
+		Items:   listItems(node, source, 0),
 		Node:    node,
 	}
+	if list.Ordered {
+		list.Start = node.Start
+	}
 
+	return list
+}
+
+// listItems extracts the items of a list at the given nesting depth.
+func listItems(node *ast.List, source []byte, depth int) []ListItem {
+	var items []ListItem
 	for item := node.FirstChild(); item != nil; item = item.NextSibling() {
 		if li, ok := item.(*ast.ListItem); ok {
-			listItem := p.extractListItem(li, source)
-			list.Items = append(list.Items, listItem)
+			item := listItem(li, source, depth)
+			item.Ordered = node.IsOrdered()
+			items = append(items, item)
 		}
 	}
-
-	return list
+	return items
 }
 
-// extractListItem extracts list item information.
-func (p *Parser) extractListItem(node *ast.ListItem, source []byte) ListItem {
-	item := ListItem{}
+// listItem extracts list item information. Nested lists become the item's
+// children rather than part of its text.
+func listItem(node *ast.ListItem, source []byte, depth int) ListItem {
+	item := ListItem{Depth: depth}
 
-	// Check if it's a task list item
+	var parts []string
 	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
-		if tl, ok := child.(*east.TaskCheckBox); ok {
-			checked := tl.IsChecked
-			item.Checked = &checked
+		// Handle nested lists
+		if list, ok := child.(*ast.List); ok {
+			item.Children = append(item.Children, listItems(list, source, depth+1)...)
 			continue
 		}
 
-		// Extract text
+		// Extract text, noting a task checkbox at the start of the item
 		var text bytes.Buffer
 		ast.Walk(child, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
 			if entering {
-				if t, ok := n.(*ast.Text); ok {
+				switch t := n.(type) {
+				case *east.TaskCheckBox:
+					checked := t.IsChecked
+					item.Checked = &checked
+				case *ast.Text:
 					text.Write(t.Segment.Value(source))
 				}
 			}
 			return ast.WalkContinue, nil
 		})
 		if text.Len() > 0 {
-			item.Text += text.String()
-		}
-
-		// Handle nested lists
-		if list, ok := child.(*ast.List); ok {
-			for subItem := list.FirstChild(); subItem != nil; subItem = subItem.NextSibling() {
-				if li, ok := subItem.(*ast.ListItem); ok {
-					item.Children = append(item.Children, p.extractListItem(li, source))
-				}
-			}
+			parts = append(parts, strings.TrimSpace(text.String()))
 		}
 	}
+	item.Text = strings.Join(parts, " ")
 
 	return item
 }
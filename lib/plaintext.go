@@ -0,0 +1,263 @@
+package mq
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+// PlainTextOptions controls how much structure PlainText keeps. The zero
+// value gives bare text: headings, list items and table rows as lines of
+// text, without markers, and no code blocks.
+type PlainTextOptions struct {
+	HeadingMarkers bool // Prefix headings with one "#" per level
+	CodeBlocks     bool // Include code blocks
+	FenceCode      bool // Wrap included code blocks in ``` fences with their language
+	ListMarkers    bool // Prefix list items with "-", "1." or a task checkbox, indented by depth
+}
+
+// TextBlock is one block of document text: a heading, a paragraph, a code
+// block, a list item or a table row. Parsers of formats without a markdown
+// AST attach their blocks with SetTextBlocks so that PlainText can render
+// them.
+type TextBlock struct {
+	Kind     ElementKind // ElementHeading, ElementProse, ElementCode, ElementList (one item) or ElementTable (one row)
+	Text     string      // Block text; table cells are joined with " | "
+	Level    int         // Heading level, or nesting depth of a list item
+	Ordered  bool        // List item of a numbered list
+	Number   int         // Number of an ordered list item
+	Checked  *bool       // Task list state (nil if not a task item)
+	Language string      // Code block language
+}
+
+// RenderPlainText joins blocks into text. Blocks are separated by a blank
+// line, except consecutive list items and table rows, which are kept on
+// adjacent lines.
+func RenderPlainText(blocks []TextBlock, opts PlainTextOptions) string {
+	var b strings.Builder
+	var prev ElementKind
+	for _, block := range blocks {
+		if block.Kind == ElementCode && !opts.CodeBlocks {
+			continue
+		}
+		text := block.render(opts)
+		if text == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			if block.Kind == prev && (block.Kind == ElementList || block.Kind == ElementTable) {
+				b.WriteString("\n")
+			} else {
+				b.WriteString("\n\n")
+			}
+		}
+		b.WriteString(text)
+		prev = block.Kind
+	}
+	return b.String()
+}
+
+func (t TextBlock) render(opts PlainTextOptions) string {
+	switch t.Kind {
+	case ElementHeading:
+		if opts.HeadingMarkers && t.Level > 0 {
+			return strings.Repeat("#", t.Level) + " " + t.Text
+		}
+	case ElementCode:
+		code := strings.TrimRight(t.Text, "\n")
+		if opts.FenceCode {
+			return "```" + t.Language + "\n" + code + "\n```"
+		}
+		return code
+	case ElementList:
+		if !opts.ListMarkers {
+			return t.Text
+		}
+		marker := "-"
+		if t.Ordered {
+			marker = fmt.Sprintf("%d.", t.Number)
+		}
+		if t.Checked != nil {
+			if *t.Checked {
+				marker += " [x]"
+			} else {
+				marker += " [ ]"
+			}
+		}
+		return strings.Repeat("  ", t.Level) + marker + " " + t.Text
+	}
+	return t.Text
+}
+
+// ListTextBlocks flattens list items into TextBlocks, numbering ordered
+// items from start. Nested ordered lists are numbered from 1, as ListItem
+// does not record their start.
+func ListTextBlocks(items []ListItem, start int) []TextBlock {
+	var blocks []TextBlock
+	for i, item := range items {
+		blocks = append(blocks, TextBlock{
+			Kind:    ElementList,
+			Text:    item.Text,
+			Level:   item.Depth,
+			Ordered: item.Ordered,
+			Number:  start + i,
+			Checked: item.Checked,
+		})
+		blocks = append(blocks, ListTextBlocks(item.Children, 1)...)
+	}
+	return blocks
+}
+
+// SetTextBlocks attaches the text blocks of a non-markdown document, as
+// rendered by PlainText. This is used by parsers after building the
+// structural view.
+func (d *Document) SetTextBlocks(blocks []TextBlock) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.textBlocks = blocks
+}
+
+// PlainText returns the document text with as much structure as opts asks
+// for, between bare text and RenderMarkdown. Markdown is rendered from its
+// AST and HTML from the blocks of its readable content. GetTextContent, and
+// for HTML ReadableText, is PlainText with code blocks and no markers.
+// Formats without text blocks return ReadableText.
+func (d *Document) PlainText(opts PlainTextOptions) string {
+	if d.root != nil {
+		return RenderPlainText(markdownTextBlocks(d.root, d.source), opts)
+	}
+	d.mu.RLock()
+	blocks := d.textBlocks
+	d.mu.RUnlock()
+	if blocks != nil {
+		return RenderPlainText(blocks, opts)
+	}
+	return d.readableText
+}
+
+// markdownTextBlocks collects the text blocks of a markdown AST. Block
+// quotes contribute their contents; raw HTML and thematic breaks are
+// dropped.
+func markdownTextBlocks(root ast.Node, source []byte) []TextBlock {
+	var blocks []TextBlock
+	for n := root.FirstChild(); n != nil; n = n.NextSibling() {
+		blocks = append(blocks, nodeTextBlocks(n, source)...)
+	}
+	return blocks
+}
+
+// nodeTextBlocks returns the text blocks of a single block node.
+func nodeTextBlocks(n ast.Node, source []byte) []TextBlock {
+	switch node := n.(type) {
+	case *ast.Heading:
+		return []TextBlock{{Kind: ElementHeading, Text: inlineText(node, source, false), Level: node.Level}}
+	case *ast.Paragraph, *ast.TextBlock:
+		if text := inlineText(node, source, false); text != "" {
+			return []TextBlock{{Kind: ElementProse, Text: text}}
+		}
+	case *ast.FencedCodeBlock:
+		var language string
+		if node.Info != nil {
+			language = string(node.Info.Segment.Value(source))
+		}
+		return []TextBlock{{Kind: ElementCode, Text: linesText(node, source), Language: language}}
+	case *ast.CodeBlock:
+		return []TextBlock{{Kind: ElementCode, Text: linesText(node, source)}}
+	case *ast.List:
+		start := 1
+		if node.IsOrdered() {
+			start = node.Start
+		}
+		return ListTextBlocks(listItems(node, source, 0), start)
+	case *east.Table:
+		var blocks []TextBlock
+		for row := node.FirstChild(); row != nil; row = row.NextSibling() {
+			var cells []string
+			for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+				cells = append(cells, inlineText(cell, source, false))
+			}
+			blocks = append(blocks, TextBlock{Kind: ElementTable, Text: strings.Join(cells, " | ")})
+		}
+		return blocks
+	case *ast.Blockquote:
+		return markdownTextBlocks(node, source)
+	}
+	return nil
+}
+
+// plainText renders the section's body below its heading, subsections
+// included, as PlainText renders a document. Sections of documents without
+// a markdown AST fall back to their source text.
+func (s *Section) plainText(opts PlainTextOptions) string {
+	if s.doc == nil || s.doc.root == nil {
+		return s.GetTextWithOptions(SectionTextOptions{BodyOnly: true})
+	}
+
+	// An implicit section has no heading line to skip
+	first := s.Start + 1
+	if s.Implicit {
+		first = s.Start
+	}
+	lineStarts := computeLineStarts(s.source)
+	var blocks []TextBlock
+	for n := s.doc.root.FirstChild(); n != nil; n = n.NextSibling() {
+		offset, ok := nodeOffset(n)
+		if !ok {
+			continue
+		}
+		if line := getLineNumber(lineStarts, offset); line >= first && line <= s.End {
+			blocks = append(blocks, nodeTextBlocks(n, s.source)...)
+		}
+	}
+	return RenderPlainText(blocks, opts)
+}
+
+// inlineText returns the text of an inline container, with soft line
+// breaks as spaces and hard breaks as newlines. Raw HTML is dropped, and
+// so is image alt text when skipImages is set. Headings, leads and the
+// PlainText blocks all take their text from here.
+func inlineText(node ast.Node, source []byte, skipImages bool) string {
+	var buf bytes.Buffer
+	ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := n.(type) {
+		case *ast.Text:
+			buf.Write(t.Segment.Value(source))
+			if t.HardLineBreak() {
+				buf.WriteString("\n")
+			} else if t.SoftLineBreak() {
+				buf.WriteString(" ")
+			}
+		case *ast.String:
+			buf.Write(t.Value)
+		case *ast.AutoLink:
+			buf.Write(t.Label(source))
+			return ast.WalkSkipChildren, nil
+		case *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		case *ast.Image:
+			if skipImages {
+				return ast.WalkSkipChildren, nil
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(buf.String())
+}
+
+// linesText returns the raw lines of a block node, such as the content of a
+// code block.
+func linesText(node ast.Node, source []byte) string {
+	var buf bytes.Buffer
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		buf.Write(line.Value(source))
+	}
+	return buf.String()
+}
@@ -0,0 +1,65 @@
+package mq
+
+import (
+	"math"
+	"sort"
+)
+
+// RelevantSections returns the k sections most relevant to query, highest
+// score first, for pulling whole sections into an agent's context. Each
+// section is scored on its heading and its own prose (see GetProseText, up
+// to its first subsection): every distinct query word it contains adds
+// 1 + ln(n), n being the word's count with heading words counted twice.
+// Sections containing no query word are left out, and ties keep document
+// order. k <= 0 returns every matching section.
+func (d *Document) RelevantSections(query string, k int) []*Section {
+	terms := distinctTerms(tokenize(query))
+	if len(terms) == 0 {
+		return nil
+	}
+
+	scores := make(map[*Section]float64)
+	var ranked []*Section
+	for _, s := range d.sectionsInOrder() {
+		if score := relevanceScore(s, terms); score > 0 {
+			scores[s] = score
+			ranked = append(ranked, s)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scores[ranked[i]] > scores[ranked[j]]
+	})
+
+	if k > 0 && len(ranked) > k {
+		ranked = ranked[:k]
+	}
+	return ranked
+}
+
+// relevanceScore scores s against the distinct query terms.
+func relevanceScore(s *Section, terms []string) float64 {
+	counts := countTerms(s.ownSection().proseText(SectionTextOptions{BodyOnly: true}))
+	for _, term := range tokenize(s.Heading.Text) {
+		counts[term] += 2
+	}
+
+	score := 0.0
+	for _, term := range terms {
+		if n := counts[term]; n > 0 {
+			score += 1 + math.Log(float64(n))
+		}
+	}
+	return score
+}
+
+func distinctTerms(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+	var distinct []string
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			distinct = append(distinct, term)
+		}
+	}
+	return distinct
+}
@@ -0,0 +1,244 @@
+package mq
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+)
+
+// htmlMarkdown renders markdown source to HTML. Raw HTML in the source is
+// dropped, so the output is safe to embed.
+var htmlMarkdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.Table,
+		extension.TaskList,
+		extension.Strikethrough,
+		extension.Linkify,
+	),
+	goldmark.WithParserOptions(
+		parser.WithAutoHeadingID(),
+	),
+)
+
+// HTML renders the heading as an <h1>-<h6> element.
+func (h *Heading) HTML() string {
+	level := h.Level
+	if level < 1 || level > 6 {
+		level = 1
+	}
+	id := ""
+	if h.ID != "" {
+		id = fmt.Sprintf(` id="%s"`, html.EscapeString(h.ID))
+	}
+	return fmt.Sprintf("<h%d%s>%s</h%d>\n", level, id, html.EscapeString(h.Text), level)
+}
+
+// HTML renders the code block as <pre><code>, with a language-* class when
+// the fence has a language.
+func (c *CodeBlock) HTML() string {
+	class := ""
+	if c.Language != "" {
+		class = fmt.Sprintf(` class="language-%s"`, html.EscapeString(c.Language))
+	}
+	return fmt.Sprintf("<pre><code%s>%s</code></pre>\n", class, html.EscapeString(c.Content))
+}
+
+// HTML renders the table with a <thead> for its headers and a <tbody> for
+// its rows.
+func (t *Table) HTML() string {
+	var b strings.Builder
+	b.WriteString("<table>\n")
+	if len(t.Headers) > 0 {
+		b.WriteString("<thead>\n")
+		writeTableRow(&b, "th", t.Headers)
+		b.WriteString("</thead>\n")
+	}
+	if len(t.Rows) > 0 {
+		b.WriteString("<tbody>\n")
+		for _, row := range t.Rows {
+			writeTableRow(&b, "td", row)
+		}
+		b.WriteString("</tbody>\n")
+	}
+	b.WriteString("</table>\n")
+	return b.String()
+}
+
+// String renders the table as a pipe table, one row per line. Cells
+// longer than MaxCellWidth are shortened with "..." for display; Headers
+// and Rows always hold the full values.
+func (t *Table) String() string {
+	var b strings.Builder
+	writePipeRow(&b, t.Headers, t.MaxCellWidth)
+	if len(t.Headers) > 0 {
+		sep := make([]string, len(t.Headers))
+		for i := range sep {
+			sep[i] = "---"
+		}
+		writePipeRow(&b, sep, 0)
+	}
+	for _, row := range t.Rows {
+		writePipeRow(&b, row, t.MaxCellWidth)
+	}
+	return b.String()
+}
+
+func writePipeRow(b *strings.Builder, values []string, maxWidth int) {
+	if len(values) == 0 {
+		return
+	}
+	b.WriteString("|")
+	for _, v := range values {
+		v = pipeCellReplacer.Replace(truncateCell(v, maxWidth))
+		fmt.Fprintf(b, " %s |", v)
+	}
+	b.WriteString("\n")
+}
+
+// pipeCellReplacer keeps a cell on one line and inside its column.
+var pipeCellReplacer = strings.NewReplacer("|", "\\|", "\n", " ")
+
+// truncateCell shortens s to at most maxWidth runes, ending in "...".
+func truncateCell(s string, maxWidth int) string {
+	if maxWidth <= 0 || utf8.RuneCountInString(s) <= maxWidth {
+		return s
+	}
+	runes := []rune(s)
+	if maxWidth <= 3 {
+		return string(runes[:maxWidth])
+	}
+	return string(runes[:maxWidth-3]) + "..."
+}
+
+func writeTableRow(b *strings.Builder, cell string, values []string) {
+	b.WriteString("<tr>\n")
+	for _, v := range values {
+		fmt.Fprintf(b, "<%s>%s</%s>\n", cell, html.EscapeString(v), cell)
+	}
+	b.WriteString("</tr>\n")
+}
+
+// HTML renders the list as <ul> or <ol>, including nested items, task
+// checkboxes and the start number of an ordered list.
+func (l *List) HTML() string {
+	var b strings.Builder
+	writeListItems(&b, l.Ordered, l.Start, l.Items)
+	return b.String()
+}
+
+func writeListItems(b *strings.Builder, ordered bool, start int, items []ListItem) {
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	if ordered && start != 1 && start != 0 {
+		fmt.Fprintf(b, "<%s start=\"%d\">\n", tag, start)
+	} else {
+		fmt.Fprintf(b, "<%s>\n", tag)
+	}
+	for _, item := range items {
+		b.WriteString("<li>")
+		if item.Checked != nil {
+			if *item.Checked {
+				b.WriteString(`<input checked="" disabled="" type="checkbox"> `)
+			} else {
+				b.WriteString(`<input disabled="" type="checkbox"> `)
+			}
+		}
+		b.WriteString(html.EscapeString(item.Text))
+		if len(item.Children) > 0 {
+			b.WriteString("\n")
+			writeListItems(b, ordered, 1, item.Children)
+		}
+		b.WriteString("</li>\n")
+	}
+	fmt.Fprintf(b, "</%s>\n", tag)
+}
+
+// HTML renders the link as an <a> element.
+func (l *Link) HTML() string {
+	return fmt.Sprintf("<a href=\"%s\">%s</a>\n", html.EscapeString(l.URL), html.EscapeString(l.Text))
+}
+
+// HTML renders the image as an <img> element.
+func (i *Image) HTML() string {
+	title := ""
+	if i.Title != "" {
+		title = fmt.Sprintf(` title="%s"`, html.EscapeString(i.Title))
+	}
+	return fmt.Sprintf("<img src=\"%s\" alt=\"%s\"%s>\n", html.EscapeString(i.URL), html.EscapeString(i.AltText), title)
+}
+
+// HTML renders the section and its subsections. Markdown sections are
+// rendered from source; sections of other formats are rebuilt from their
+// heading, code blocks and tables.
+func (s *Section) HTML() string {
+	if text := s.GetText(); text != "" {
+		return markdownToHTML(text)
+	}
+
+	var b strings.Builder
+	if s.Heading != nil {
+		b.WriteString(s.Heading.HTML())
+	}
+
+	type block struct {
+		line int
+		html string
+	}
+	var blocks []block
+	for _, cb := range s.codeBlocks {
+		blocks = append(blocks, block{cb.Line, cb.HTML()})
+	}
+	for _, t := range s.tables {
+		blocks = append(blocks, block{t.Line, t.HTML()})
+	}
+	sort.SliceStable(blocks, func(i, j int) bool { return blocks[i].line < blocks[j].line })
+	for _, bl := range blocks {
+		b.WriteString(bl.html)
+	}
+
+	for _, child := range s.Children {
+		b.WriteString(child.HTML())
+	}
+	return b.String()
+}
+
+// HTML renders the document body (without frontmatter). Non-markdown
+// documents are rendered section by section, or as paragraphs of their
+// readable text when they have no headings.
+func (d *Document) HTML() string {
+	if d.format == FormatMarkdown {
+		return markdownToHTML(string(d.source[frontmatterEnd(d.source):]))
+	}
+
+	var b strings.Builder
+	if sections := d.GetTableOfContents(); len(sections) > 0 {
+		for _, s := range sections {
+			b.WriteString(s.HTML())
+		}
+		return b.String()
+	}
+
+	for _, para := range strings.Split(d.readableText, "\n\n") {
+		if para = strings.TrimSpace(para); para != "" {
+			fmt.Fprintf(&b, "<p>%s</p>\n", html.EscapeString(para))
+		}
+	}
+	return b.String()
+}
+
+func markdownToHTML(source string) string {
+	var buf bytes.Buffer
+	if err := htmlMarkdown.Convert([]byte(source), &buf); err != nil {
+		return ""
+	}
+	return buf.String()
+}
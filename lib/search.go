@@ -0,0 +1,223 @@
+package mq
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ElementProse marks element-level search matches in paragraph text, which
+// is not an Element of its own.
+const ElementProse ElementKind = "prose"
+
+// Where describes the element a match was found in, e.g. "code block (go)"
+// or "prose". It is empty for section-level results.
+func (r *SearchResult) Where() string {
+	switch r.Kind {
+	case ElementHeading:
+		return "heading"
+	case ElementCode:
+		if r.Language != "" {
+			return fmt.Sprintf("code block (%s)", r.Language)
+		}
+		return "code block"
+	case ElementTable:
+		return "table cell"
+	case ElementList:
+		return "list item"
+	}
+	return string(r.Kind)
+}
+
+// searchElements matches re against each element of each section's own
+// content (up to its first subsection) and returns one result per matching
+// heading, code block, table cell, list item or prose paragraph.
+func (d *Document) searchElements(re *regexp.Regexp, kinds []ElementKind) []*SearchResult {
+	wanted := func(kind ElementKind) bool {
+		if len(kinds) == 0 {
+			return true
+		}
+		for _, k := range kinds {
+			if k == kind {
+				return true
+			}
+		}
+		return false
+	}
+	if !d.Format().HasPositions() {
+		return d.searchTextBlocks(re, wanted)
+	}
+
+	lines := strings.Split(string(d.source), "\n")
+	lineStarts := computeLineStarts(d.source)
+	codeBlocks := d.GetCodeBlocks()
+	tables := d.GetTables()
+	lists := d.GetLists(nil)
+
+	var matches []*SearchResult
+	for _, section := range d.GetSections() {
+		start, end := section.ownLines()
+		if start < 1 || end > len(lines) {
+			continue
+		}
+
+		add := func(kind ElementKind, line int, text string) {
+			loc := re.FindStringIndex(text)
+			if loc == nil || !wanted(kind) {
+				return
+			}
+			matches = append(matches, &SearchResult{
+				File:    d.path,
+				Section: section.Heading.Text,
+				Lines:   fmt.Sprintf("%d-%d", section.Start, section.End),
+				Match:   snippetAround(text, loc[0], loc[1], 60),
+				Kind:    kind,
+				Line:    line,
+			})
+		}
+		// firstMatch returns the first line in [from, to] matching re.
+		firstMatch := func(from, to int) int {
+			for line := from; line <= to && line <= len(lines); line++ {
+				if re.MatchString(lines[line-1]) {
+					return line
+				}
+			}
+			return from
+		}
+
+		covered := make(map[int]bool)
+		if !section.Implicit {
+			covered[section.Heading.Line] = true
+			add(ElementHeading, section.Heading.Line, section.Heading.Text)
+		}
+		for _, cb := range codeBlocks {
+			if cb.Line < start || cb.Line > end {
+				continue
+			}
+			last := cb.Line + cb.GetLines() + 1
+			cover(covered, cb.Line, last)
+			before := len(matches)
+			add(ElementCode, firstMatch(cb.Line+1, last-1), cb.Content)
+			if len(matches) > before {
+				matches[len(matches)-1].Language = cb.Language
+			}
+		}
+		for _, t := range tables {
+			if t.Line < start || t.Line > end {
+				continue
+			}
+			cover(covered, t.Line, nodeEndLine(t.Node, lineStarts, t.Line))
+			for _, cell := range t.Headers {
+				add(ElementTable, t.Line, cell)
+			}
+			for i, row := range t.Rows {
+				for _, cell := range row {
+					add(ElementTable, t.Line+2+i, cell) // after the header and delimiter rows
+				}
+			}
+		}
+		for _, l := range lists {
+			if l.Line < start || l.Line > end {
+				continue
+			}
+			last := nodeEndLine(l.Node, lineStarts, l.Line)
+			cover(covered, l.Line, last)
+			next := l.Line
+			var walk func([]ListItem)
+			walk = func(items []ListItem) {
+				for _, item := range items {
+					if re.MatchString(item.Text) {
+						line := firstMatch(next, last)
+						add(ElementList, line, item.Text)
+						next = line + 1
+					}
+					walk(item.Children)
+				}
+			}
+			walk(l.Items)
+		}
+
+		// Whatever is left is prose, split into paragraphs at blank lines
+		for line := start; line <= end; line++ {
+			if covered[line] || strings.TrimSpace(lines[line-1]) == "" {
+				continue
+			}
+			first := line
+			var para []string
+			for ; line <= end && !covered[line] && strings.TrimSpace(lines[line-1]) != ""; line++ {
+				para = append(para, strings.TrimSpace(lines[line-1]))
+			}
+			add(ElementProse, firstMatch(first, line-1), strings.Join(para, " "))
+		}
+	}
+
+	// Own ranges do not overlap, so ordering by line keeps sections in order
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Line < matches[j].Line
+	})
+	return matches
+}
+
+// searchTextBlocks is searchElements for formats without source lines. It
+// matches the text blocks PlainText renders, or failing those the
+// paragraphs of the readable text, and attributes each match to the section
+// of the heading before it. Results have no line numbers.
+func (d *Document) searchTextBlocks(re *regexp.Regexp, wanted func(ElementKind) bool) []*SearchResult {
+	d.mu.RLock()
+	blocks, text := d.textBlocks, d.readableText
+	d.mu.RUnlock()
+
+	sections := d.sectionsInOrder()
+	if blocks == nil {
+		next := 0
+		for _, para := range strings.Split(text, "\n\n") {
+			if para = strings.TrimSpace(para); para == "" {
+				continue
+			}
+			kind := ElementProse
+			if next < len(sections) && para == sections[next].Heading.Text {
+				kind = ElementHeading
+				next++
+			}
+			blocks = append(blocks, TextBlock{Kind: kind, Text: para})
+		}
+	}
+
+	var matches []*SearchResult
+	var section string
+	next := 0
+	for _, block := range blocks {
+		if block.Kind == ElementHeading && next < len(sections) && block.Text == sections[next].Heading.Text {
+			section = block.Text
+			next++
+		}
+		if !wanted(block.Kind) {
+			continue
+		}
+		texts := []string{block.Text}
+		if block.Kind == ElementTable {
+			texts = strings.Split(block.Text, " | ")
+		}
+		for _, text := range texts {
+			loc := re.FindStringIndex(text)
+			if loc == nil {
+				continue
+			}
+			matches = append(matches, &SearchResult{
+				File:     d.path,
+				Section:  section,
+				Match:    snippetAround(text, loc[0], loc[1], 60),
+				Kind:     block.Kind,
+				Language: block.Language,
+			})
+		}
+	}
+	return matches
+}
+
+func cover(covered map[int]bool, from, to int) {
+	for line := from; line <= to; line++ {
+		covered[line] = true
+	}
+}
@@ -0,0 +1,49 @@
+package mq
+
+import (
+	"fmt"
+	"time"
+)
+
+// ParseStats is a profile of how a document was parsed, recorded when the
+// parser has profiling enabled (WithProfiling for markdown; the HTML and
+// PDF parsers have their own option).
+type ParseStats struct {
+	Parse time.Duration // Source to syntax tree: lexing and parsing, or text extraction for PDF
+	Index time.Duration // Structure extraction and index building
+	Total time.Duration // Whole parse, including normalization and bookkeeping
+	Nodes int           // Syntax tree nodes (AST or DOM); 0 when not applicable
+}
+
+// String formats the breakdown on one line, e.g.
+// "parse 1.2ms, index 300µs, total 1.6ms, 5321 nodes".
+func (s ParseStats) String() string {
+	return fmt.Sprintf("parse %s, index %s, total %s, %d nodes", s.Parse, s.Index, s.Total, s.Nodes)
+}
+
+// WithProfiling records ParseStats on every parsed document. Without it no
+// timing or counting is done.
+func WithProfiling() ParserOption {
+	return func(p *Parser) {
+		p.profile = true
+	}
+}
+
+// ParseStats returns the document's parse profile, or false if it was
+// parsed without profiling.
+func (d *Document) ParseStats() (ParseStats, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.stats == nil {
+		return ParseStats{}, false
+	}
+	return *d.stats, true
+}
+
+// SetParseStats records a parse profile. It is used by format parsers that
+// support profiling.
+func (d *Document) SetParseStats(stats ParseStats) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stats = &stats
+}
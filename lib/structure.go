@@ -0,0 +1,66 @@
+package mq
+
+import "fmt"
+
+// StructureRule names a heading hierarchy rule checked by ValidateStructure.
+type StructureRule string
+
+const (
+	RuleSkippedLevel StructureRule = "skipped-level" // A heading more than one level below the previous heading (H1 → H3)
+	RuleMultipleH1   StructureRule = "multiple-h1"   // An H1 after the document's first H1
+	RuleEmptySection StructureRule = "empty-section" // A heading with neither content nor subsections
+)
+
+// StructureIssue is a problem with the document's heading hierarchy.
+type StructureIssue struct {
+	Rule    StructureRule `json:"rule"`
+	Heading string        `json:"heading"` // Text of the offending heading
+	Level   int           `json:"level"`   // Level of the offending heading
+	Line    int           `json:"line"`    // Line of the offending heading (0 if unknown)
+	Message string        `json:"message"`
+}
+
+// String formats the issue as "line N: message (rule)".
+func (i StructureIssue) String() string {
+	return fmt.Sprintf("line %d: %s (%s)", i.Line, i.Message, i.Rule)
+}
+
+// ValidateStructure lints the heading hierarchy, reporting headings that
+// skip a level, every H1 after the first, and sections with no content and
+// no subsections, in document order. Empty sections are only detected in
+// formats whose sections carry source text, such as markdown.
+func (d *Document) ValidateStructure() []StructureIssue {
+	var issues []StructureIssue
+	var prev *Heading
+	var firstH1 *Heading
+
+	for _, s := range d.sectionsInOrder() {
+		h := s.Heading
+		if s.Implicit {
+			continue
+		}
+		issue := StructureIssue{Heading: h.Text, Level: h.Level, Line: h.Line}
+
+		if prev != nil && h.Level > prev.Level+1 {
+			issue.Rule = RuleSkippedLevel
+			issue.Message = fmt.Sprintf("H%d %q follows H%d %q, skipping a level", h.Level, h.Text, prev.Level, prev.Text)
+			issues = append(issues, issue)
+		}
+		if h.Level == 1 {
+			if firstH1 != nil {
+				issue.Rule = RuleMultipleH1
+				issue.Message = fmt.Sprintf("second H1 %q; the first is %q", h.Text, firstH1.Text)
+				issues = append(issues, issue)
+			} else {
+				firstH1 = h
+			}
+		}
+		if len(s.Children) == 0 && s.GetText() != "" && len(s.bodyLines()) == 0 {
+			issue.Rule = RuleEmptySection
+			issue.Message = fmt.Sprintf("section %q has no content", h.Text)
+			issues = append(issues, issue)
+		}
+		prev = h
+	}
+	return issues
+}
@@ -0,0 +1,56 @@
+package mq
+
+import "strings"
+
+// Summarize shortens text to at most maxChars characters for a one-line
+// summary. Whitespace, including line breaks, is collapsed first. Text
+// that does not fit is cut after the last sentence ending (".", "!" or "?"
+// followed by a space), or failing that at the last word boundary, that
+// leaves room for a trailing " …" within maxChars. With maxChars below 3
+// only "…" fits. It returns "" when maxChars <= 0.
+func Summarize(text string, maxChars int) string {
+	if maxChars <= 0 {
+		return ""
+	}
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	if len(runes) <= maxChars {
+		return string(runes)
+	}
+	if maxChars < len(summaryEllipsis)+1 {
+		return "…"
+	}
+
+	budget := maxChars - len(summaryEllipsis)
+	for i := budget - 1; i > 0; i-- {
+		if (runes[i] == '.' || runes[i] == '!' || runes[i] == '?') && runes[i+1] == ' ' {
+			return string(runes[:i+1]) + string(summaryEllipsis)
+		}
+	}
+
+	cut := runes[:budget]
+	if runes[budget] != ' ' {
+		for i := len(cut) - 1; i > 0; i-- {
+			if cut[i] == ' ' {
+				cut = cut[:i]
+				break
+			}
+		}
+	}
+	return strings.TrimRight(string(cut), " ,;:") + string(summaryEllipsis)
+}
+
+// summaryEllipsis marks a cut summary.
+var summaryEllipsis = []rune(" …")
+
+// Summary summarizes the plain text below the section's heading, as
+// Summarize does, so that it reads like Document.Summary: without markdown
+// markers or code blocks.
+func (s *Section) Summary(maxChars int) string {
+	return Summarize(s.plainText(PlainTextOptions{}), maxChars)
+}
+
+// Summary summarizes the document's plain text (see PlainText), as
+// Summarize does.
+func (d *Document) Summary(maxChars int) string {
+	return Summarize(d.PlainText(PlainTextOptions{}), maxChars)
+}
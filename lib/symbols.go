@@ -0,0 +1,215 @@
+package mq
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// SymbolKind classifies a document symbol.
+type SymbolKind string
+
+const (
+	SymbolSection SymbolKind = "section"
+	SymbolCode    SymbolKind = "code"
+	SymbolTable   SymbolKind = "table"
+	SymbolList    SymbolKind = "list"
+	SymbolLink    SymbolKind = "link"
+	SymbolImage   SymbolKind = "image"
+)
+
+// Position is a 1-based line and column in the document source.
+type Position struct {
+	Line int `json:"line"`
+	Col  int `json:"col"`
+}
+
+// Range spans from Start to End (inclusive of the End line).
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// DocumentSymbol is a node in the document outline, shaped after the LSP
+// DocumentSymbol structure so editors can map it directly. Sections are
+// containers; code blocks, tables, lists, links and images are leaves.
+type DocumentSymbol struct {
+	Name           string            `json:"name"`
+	Detail         string            `json:"detail,omitempty"`
+	Kind           SymbolKind        `json:"kind"`
+	Range          Range             `json:"range"`
+	SelectionRange Range             `json:"selectionRange"`
+	Children       []*DocumentSymbol `json:"children,omitempty"`
+}
+
+// Symbols returns the document outline as a nested symbol tree. Elements
+// are attached to the innermost section containing their line; elements
+// before the first heading are returned at the top level. Formats without
+// positions (see Format.HasPositions) have no outline to report and return
+// nil.
+func (d *Document) Symbols() []*DocumentSymbol {
+	if !d.Format().HasPositions() {
+		return nil
+	}
+
+	lineStarts := computeLineStarts(d.source)
+	lines := strings.Split(string(d.source), "\n")
+
+	var roots []*DocumentSymbol
+	var containers []*sectionSymbol
+	for _, section := range d.GetTableOfContents() {
+		roots = append(roots, buildSectionSymbol(section, lines, &containers))
+	}
+
+	attach := func(sym *DocumentSymbol) {
+		if parent := innermostSection(containers, sym.Range.Start.Line); parent != nil {
+			parent.symbol.Children = append(parent.symbol.Children, sym)
+		} else {
+			roots = append(roots, sym)
+		}
+	}
+
+	for _, cb := range d.GetCodeBlocks() {
+		lang := cb.Language
+		if lang == "" {
+			lang = "plain"
+		}
+		end := cb.Line + cb.GetLines() + 1
+		attach(leafSymbol(SymbolCode, lang, fmt.Sprintf("%d lines", cb.GetLines()),
+			cb.Line, 1, end, lineEndCol(lines, end)))
+	}
+	for _, t := range d.GetTables() {
+		end := nodeEndLine(t.Node, lineStarts, t.Line)
+		attach(leafSymbol(SymbolTable, strings.Join(t.Headers, " | "), fmt.Sprintf("%d rows", len(t.Rows)),
+			t.Line, 1, end, lineEndCol(lines, end)))
+	}
+	for _, l := range d.GetLists(nil) {
+		kind := "unordered"
+		if l.Ordered {
+			kind = "ordered"
+		}
+		end := nodeEndLine(l.Node, lineStarts, l.Line)
+		attach(leafSymbol(SymbolList, fmt.Sprintf("%s list", kind), fmt.Sprintf("%d items", len(l.Items)),
+			l.Line, 1, end, lineEndCol(lines, end)))
+	}
+	for _, link := range d.GetLinks() {
+		width := len(link.Text) + len(link.URL) + 4 // [text](url)
+		if link.Auto {
+			width = len(link.Text)
+			if link.Line >= 1 && link.Line <= len(lines) && link.Col >= 1 && strings.HasPrefix(lines[link.Line-1][link.Col-1:], "<") {
+				width += 2 // <url>
+			}
+		}
+		attach(leafSymbol(SymbolLink, link.Text, link.URL,
+			link.Line, link.Col, link.Line, link.Col+width))
+	}
+	for _, img := range d.GetImages() {
+		width := len(img.AltText) + len(img.URL) + 5 // ![alt](url)
+		attach(leafSymbol(SymbolImage, img.AltText, img.URL,
+			img.Line, img.Col, img.Line, img.Col+width))
+	}
+
+	sortSymbols(roots)
+	return roots
+}
+
+// sectionSymbol links a section's line span to its symbol while attaching
+// leaf elements.
+type sectionSymbol struct {
+	section *Section
+	symbol  *DocumentSymbol
+	depth   int
+}
+
+func buildSectionSymbol(section *Section, lines []string, containers *[]*sectionSymbol) *DocumentSymbol {
+	heading := section.Heading
+	sym := &DocumentSymbol{
+		Name:   heading.Text,
+		Detail: fmt.Sprintf("H%d", heading.Level),
+		Kind:   SymbolSection,
+		Range: Range{
+			Start: Position{Line: section.Start, Col: 1},
+			End:   Position{Line: section.End, Col: lineEndCol(lines, section.End)},
+		},
+		SelectionRange: Range{
+			Start: Position{Line: heading.Line, Col: 1},
+			End:   Position{Line: heading.Line, Col: lineEndCol(lines, heading.Line)},
+		},
+	}
+	*containers = append(*containers, &sectionSymbol{section: section, symbol: sym, depth: len(section.Path())})
+
+	for _, child := range section.Children {
+		sym.Children = append(sym.Children, buildSectionSymbol(child, lines, containers))
+	}
+	return sym
+}
+
+// innermostSection returns the deepest section whose span contains line.
+func innermostSection(containers []*sectionSymbol, line int) *sectionSymbol {
+	var best *sectionSymbol
+	for _, c := range containers {
+		if line >= c.section.Start && line <= c.section.End && (best == nil || c.depth > best.depth) {
+			best = c
+		}
+	}
+	return best
+}
+
+func leafSymbol(kind SymbolKind, name, detail string, startLine, startCol, endLine, endCol int) *DocumentSymbol {
+	r := Range{
+		Start: Position{Line: startLine, Col: startCol},
+		End:   Position{Line: endLine, Col: endCol},
+	}
+	return &DocumentSymbol{Name: name, Detail: detail, Kind: kind, Range: r, SelectionRange: r}
+}
+
+// lineEndCol returns the column just past the last character of line.
+func lineEndCol(lines []string, line int) int {
+	if line < 1 || line > len(lines) {
+		return 1
+	}
+	return len(strings.TrimRight(lines[line-1], "\r")) + 1
+}
+
+// nodeEndLine returns the last source line covered by a node's descendants,
+// or fallback when the node has no positioned content.
+func nodeEndLine(n ast.Node, lineStarts []int, fallback int) int {
+	if n == nil {
+		return fallback
+	}
+	end := -1
+	ast.Walk(n, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		if t, ok := child.(*ast.Text); ok && t.Segment.Stop > end {
+			end = t.Segment.Stop
+		} else if child.Type() == ast.TypeBlock {
+			if lines := child.Lines(); lines.Len() > 0 && lines.At(lines.Len()-1).Stop > end {
+				end = lines.At(lines.Len() - 1).Stop
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	if end <= 0 {
+		return fallback
+	}
+	// Stop is exclusive; step back so a trailing newline stays on its line
+	return getLineNumber(lineStarts, end-1)
+}
+
+// sortSymbols orders symbols (recursively) by their starting position.
+func sortSymbols(symbols []*DocumentSymbol) {
+	sort.SliceStable(symbols, func(i, j int) bool {
+		a, b := symbols[i].Range.Start, symbols[j].Range.Start
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+	for _, s := range symbols {
+		sortSymbols(s.Children)
+	}
+}
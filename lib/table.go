@@ -0,0 +1,109 @@
+package mq
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Normalize gives a table the shape shared by every format, so that
+// .tables behaves the same whether it came from markdown, HTML or PDF:
+//
+//   - Header and body cells are trimmed, with runs of whitespace inside a
+//     cell (such as line breaks in HTML) collapsed to one space.
+//   - A table with rows but no headers takes its first row as the header
+//     row, as a markdown table would.
+//   - Rows shorter than the header row are padded with empty cells; when a
+//     row is longer, the header row is extended with "Column N" (N counting
+//     from 1) for the extra columns.
+//   - Repeated headers get a number, e.g. "Contact", "Contact 2", so every
+//     column has its own key in Records. A repeated empty header becomes
+//     "Column N" for its position.
+//
+// HTML parsers repeat the text of a cell spanning several columns
+// (colspan) in each of them before normalizing, so a spanning header is
+// numbered from its second column on; rowspan is not expanded.
+// Tables from JSON, JSONL and YAML are built in this shape, but keep their
+// cell values exactly and are not trimmed.
+func (t *Table) Normalize() {
+	for i, h := range t.Headers {
+		t.Headers[i] = normalizeCell(h)
+	}
+	for _, row := range t.Rows {
+		for i, cell := range row {
+			row[i] = normalizeCell(cell)
+		}
+	}
+
+	if len(t.Headers) == 0 && len(t.Rows) > 0 {
+		t.Headers, t.Rows = t.Rows[0], t.Rows[1:]
+	}
+
+	width := len(t.Headers)
+	for _, row := range t.Rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	for i := len(t.Headers); i < width; i++ {
+		t.Headers = append(t.Headers, fmt.Sprintf("Column %d", i+1))
+	}
+	for i, row := range t.Rows {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		t.Rows[i] = row
+	}
+	uniqueHeaders(t.Headers)
+}
+
+// uniqueHeaders renames repeated headers in place, numbering later copies
+// from 2 and skipping names already in use.
+func uniqueHeaders(headers []string) {
+	used := make(map[string]bool, len(headers))
+	for _, h := range headers {
+		used[h] = true
+	}
+	seen := make(map[string]bool, len(headers))
+	for i, h := range headers {
+		if !seen[h] {
+			seen[h] = true
+			continue
+		}
+		base, n := h, 2
+		if h == "" {
+			base, n = "Column", i+1
+		}
+		name := fmt.Sprintf("%s %d", base, n)
+		for used[name] {
+			n++
+			name = fmt.Sprintf("%s %d", base, n)
+		}
+		headers[i] = name
+		used[name] = true
+		seen[name] = true
+	}
+}
+
+// Records returns each row as a map from header to cell. Rows are padded
+// or cut to the header row. Normalize makes headers unique; in a table
+// that still repeats a header, the last such column wins.
+func (t *Table) Records() []map[string]string {
+	records := make([]map[string]string, len(t.Rows))
+	for i, row := range t.Rows {
+		record := make(map[string]string, len(t.Headers))
+		for j, h := range t.Headers {
+			if j < len(row) {
+				record[h] = row[j]
+			} else {
+				record[h] = ""
+			}
+		}
+		records[i] = record
+	}
+	return records
+}
+
+// normalizeCell trims a cell and collapses its inner whitespace.
+func normalizeCell(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
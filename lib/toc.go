@@ -0,0 +1,190 @@
+package mq
+
+import (
+	"strings"
+	"unicode"
+)
+
+// GenerateTOC renders the document's headings as a nested markdown list of
+// anchor links, "- [Title](#anchor)", ready to paste into a README.
+// Headings deeper than maxLevel are left out as GetTableOfContents prunes
+// them (maxLevel <= 0 keeps all levels), and items are indented two spaces per level below the
+// shallowest heading listed. Anchors use heading IDs, falling back to a
+// GitHub-style slug of the heading text.
+func (d *Document) GenerateTOC(maxLevel int) string {
+	var headings []*Heading
+	var collect func(sections []*Section)
+	collect = func(sections []*Section) {
+		for _, s := range sections {
+			if !s.Implicit {
+				headings = append(headings, s.Heading)
+			}
+			collect(s.Children)
+		}
+	}
+	collect(d.GetTableOfContents(maxLevel))
+	if len(headings) == 0 {
+		return ""
+	}
+
+	minLevel := headings[0].Level
+	for _, h := range headings {
+		minLevel = min(minLevel, h.Level)
+	}
+
+	var b strings.Builder
+	for _, h := range headings {
+		b.WriteString(strings.Repeat("  ", h.Level-minLevel))
+		b.WriteString("- [")
+		b.WriteString(tocLinkText.Replace(h.Text))
+		b.WriteString("](#")
+		b.WriteString(headingAnchor(h))
+		b.WriteString(")\n")
+	}
+	return b.String()
+}
+
+// TOCEntry is a heading listed in a TOCResult.
+type TOCEntry struct {
+	Text  string `json:"text"`
+	Level int    `json:"level"`
+	Line  int    `json:"line"` // 0 if unknown
+}
+
+// TOCResult is a flat table of contents: one entry per heading, in
+// document order.
+type TOCResult struct {
+	Entries []TOCEntry `json:"entries"`
+}
+
+// TOC returns the document's table of contents, leaving out headings
+// deeper than maxLevel as GetTableOfContents prunes them (maxLevel <= 0
+// keeps all levels). A document without headings gives an empty TOCResult.
+func (d *Document) TOC(maxLevel int) *TOCResult {
+	toc := &TOCResult{Entries: []TOCEntry{}}
+	for _, s := range d.GetTableOfContents(maxLevel) {
+		toc.add(s, 0)
+	}
+	return toc
+}
+
+// TOC returns the table of contents of the section's subtree, starting
+// with the section itself, as Document.TOC does.
+func (s *Section) TOC(maxLevel int) *TOCResult {
+	toc := &TOCResult{Entries: []TOCEntry{}}
+	toc.add(s, maxLevel)
+	return toc
+}
+
+func (t *TOCResult) add(s *Section, maxLevel int) {
+	if maxLevel > 0 && s.Heading.Level > maxLevel {
+		return
+	}
+	if !s.Implicit {
+		t.Entries = append(t.Entries, TOCEntry{Text: s.Heading.Text, Level: s.Heading.Level, Line: s.Heading.Line})
+	}
+	for _, child := range s.Children {
+		t.add(child, maxLevel)
+	}
+}
+
+// Lines renders each entry as its heading text, indented two spaces per
+// level below H1.
+func (t *TOCResult) Lines() []string {
+	lines := make([]string, len(t.Entries))
+	for i, e := range t.Entries {
+		lines[i] = strings.Repeat("  ", max(e.Level-1, 0)) + e.Text
+	}
+	return lines
+}
+
+// String renders the table of contents one line per entry, as Lines does.
+func (t *TOCResult) String() string {
+	var b strings.Builder
+	for _, line := range t.Lines() {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// HeadingOutline renders headings as an indented plain-text outline,
+// "- Title", in the order given with two spaces per level below the
+// shallowest heading. Unlike GenerateTOC it needs no section tree, so it
+// works on any heading list, such as a filtered GetHeadings.
+func HeadingOutline(headings []*Heading) string {
+	if len(headings) == 0 {
+		return ""
+	}
+	minLevel := headings[0].Level
+	for _, h := range headings {
+		minLevel = min(minLevel, h.Level)
+	}
+
+	var b strings.Builder
+	for _, h := range headings {
+		b.WriteString(strings.Repeat("  ", h.Level-minLevel))
+		b.WriteString("- ")
+		b.WriteString(h.Text)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// HeadingNode is a heading with the headings nested under it, as built by
+// HeadingTree.
+type HeadingNode struct {
+	Text     string         `json:"text"`
+	Level    int            `json:"level"`
+	ID       string         `json:"id"` // Heading ID, or a GitHub-style slug of the text
+	Children []*HeadingNode `json:"children"`
+}
+
+// HeadingTree nests headings by level, in the order given: each heading
+// becomes a child of the closest preceding heading with a lower level.
+// Like HeadingOutline it needs no section tree, so skipped levels and
+// filtered lists nest under whatever heading precedes them.
+func HeadingTree(headings []*Heading) []*HeadingNode {
+	roots := []*HeadingNode{}
+	var stack []*HeadingNode
+	for _, h := range headings {
+		node := &HeadingNode{Text: h.Text, Level: h.Level, ID: headingAnchor(h), Children: []*HeadingNode{}}
+		for len(stack) > 0 && stack[len(stack)-1].Level >= h.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) > 0 {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+		stack = append(stack, node)
+	}
+	return roots
+}
+
+// tocLinkText escapes characters that would end a link's text early.
+var tocLinkText = strings.NewReplacer(`[`, `\[`, `]`, `\]`)
+
+// headingAnchor returns the fragment that links to h.
+func headingAnchor(h *Heading) string {
+	if h.ID != "" {
+		return h.ID
+	}
+	return slugify(h.Text)
+}
+
+// slugify lowercases text, turns spaces into hyphens and drops other
+// punctuation, as GitHub does for heading anchors.
+func slugify(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
 )
 
 // TreeMode represents tree display modes.
@@ -36,11 +39,28 @@ type TreeResult struct {
 	Lines    int         // Total line count
 	Mode     TreeMode    // Display mode
 	Root     []*TreeNode // Top-level nodes
-	Metadata []string    // Frontmatter field names
+	Metadata []string    // Frontmatter field names in source order
+}
+
+// TreeOptions controls how BuildTreeWithOptions renders a document tree.
+type TreeOptions struct {
+	// PreviewLength is the maximum number of characters in section previews
+	// (preview and full modes). Zero means DefaultPreviewLength.
+	PreviewLength int
 }
 
 // BuildTree creates a tree representation of the document.
 func (d *Document) BuildTree(mode TreeMode) *TreeResult {
+	return d.BuildTreeWithOptions(mode, TreeOptions{})
+}
+
+// BuildTreeWithOptions is BuildTree with control over preview length, e.g.
+// TreeOptions{PreviewLength: 80} for longer previews.
+func (d *Document) BuildTreeWithOptions(mode TreeMode, opts TreeOptions) *TreeResult {
+	if opts.PreviewLength <= 0 {
+		opts.PreviewLength = DefaultPreviewLength
+	}
+
 	result := &TreeResult{
 		Path:  d.path,
 		Lines: d.countLines(),
@@ -48,18 +68,14 @@ func (d *Document) BuildTree(mode TreeMode) *TreeResult {
 	}
 
 	// Add frontmatter if present
-	if d.metadata != nil && len(d.metadata) > 0 {
-		var fields []string
-		for key := range d.metadata {
-			fields = append(fields, key)
-		}
-		result.Metadata = fields
+	if len(d.Metadata()) > 0 {
+		result.Metadata = d.MetadataKeys()
 	}
 
 	// Build section tree
 	toc := d.GetTableOfContents()
 	for _, section := range toc {
-		node := d.buildSectionTree(section, mode)
+		node := d.buildSectionTree(section, mode, opts.PreviewLength)
 		result.Root = append(result.Root, node)
 	}
 
@@ -67,7 +83,7 @@ func (d *Document) BuildTree(mode TreeMode) *TreeResult {
 }
 
 // buildSectionTree recursively builds tree nodes from sections.
-func (d *Document) buildSectionTree(section *Section, mode TreeMode) *TreeNode {
+func (d *Document) buildSectionTree(section *Section, mode TreeMode, previewLen int) *TreeNode {
 	node := &TreeNode{
 		Type:  "section",
 		Text:  section.Heading.Text,
@@ -78,12 +94,12 @@ func (d *Document) buildSectionTree(section *Section, mode TreeMode) *TreeNode {
 
 	// Add preview text for preview/full modes
 	if mode == TreeModePreview || mode == TreeModeFull {
-		node.Preview = ExtractPreview(section.GetText(), 50)
+		node.Preview = ExtractPreview(section.GetText(), previewLen)
 	}
 
 	// Add child sections
 	for _, child := range section.Children {
-		childNode := d.buildSectionTree(child, mode)
+		childNode := d.buildSectionTree(child, mode, previewLen)
 		node.Children = append(node.Children, childNode)
 	}
 
@@ -121,25 +137,43 @@ func (d *Document) buildSectionTree(section *Section, mode TreeMode) *TreeNode {
 	return node
 }
 
-// ExtractPreview extracts the first few words from section content.
+// TruncateText shortens text to at most maxChars characters, preferring a
+// word boundary, and appends "..." when anything was cut.
+func TruncateText(text string, maxChars int) string {
+	runes := []rune(text)
+	if len(runes) <= maxChars {
+		return text
+	}
+	truncated := string(runes[:maxChars])
+	if lastSpace := strings.LastIndex(truncated, " "); lastSpace > len(truncated)/2 {
+		truncated = truncated[:lastSpace]
+	}
+	return truncated + "..."
+}
+
+// DefaultPreviewLength is the preview length used by BuildTree and the
+// directory tree.
+const DefaultPreviewLength = 50
+
+// ExtractPreview returns the first content line of a section's text (the
+// heading line, blank lines, code fences, rules and link- or image-only
+// lines are skipped), with bold and code markers removed and whitespace
+// collapsed. A line longer than maxChars characters is cut at the last word
+// boundary within maxChars (or at maxChars for a single long word) and "…"
+// is appended. It returns "" when there is no prose or maxChars <= 0.
 func ExtractPreview(text string, maxChars int) string {
-	// Skip the heading line
-	lines := strings.SplitN(text, "\n", 2)
-	if len(lines) < 2 {
+	if maxChars <= 0 {
 		return ""
 	}
-	content := strings.TrimSpace(lines[1])
 
-	// Skip empty content
-	if content == "" {
+	// Skip the heading line
+	lines := strings.SplitN(text, "\n", 2)
+	if len(lines) < 2 {
 		return ""
 	}
 
-	// Clean up: remove code blocks, collapse whitespace
-	// Simple approach: take first non-empty, non-code line
-	for _, line := range strings.Split(content, "\n") {
+	for _, line := range strings.Split(lines[1], "\n") {
 		line = strings.TrimSpace(line)
-		// Skip empty lines, code fences, list markers at start
 		if line == "" || strings.HasPrefix(line, "```") || strings.HasPrefix(line, "---") {
 			continue
 		}
@@ -153,20 +187,30 @@ func ExtractPreview(text string, maxChars int) string {
 		line = strings.ReplaceAll(line, "__", "")
 		line = strings.ReplaceAll(line, "`", "")
 
-		// Truncate to maxChars
-		if len(line) > maxChars {
-			// Try to break at word boundary
-			truncated := line[:maxChars]
-			if lastSpace := strings.LastIndex(truncated, " "); lastSpace > maxChars/2 {
-				truncated = truncated[:lastSpace]
-			}
-			return truncated + "..."
-		}
-		return line
+		return cutAtWord(strings.Join(strings.Fields(line), " "), maxChars)
 	}
 	return ""
 }
 
+// cutAtWord shortens text to at most maxChars characters at a word
+// boundary and appends "…" when anything was cut.
+func cutAtWord(text string, maxChars int) string {
+	runes := []rune(text)
+	if len(runes) <= maxChars {
+		return text
+	}
+	cut := maxChars
+	if runes[cut] != ' ' {
+		for cut > 0 && runes[cut-1] != ' ' {
+			cut--
+		}
+		if cut == 0 {
+			cut = maxChars // A single word longer than maxChars
+		}
+	}
+	return strings.TrimRight(string(runes[:cut]), " ") + "…"
+}
+
 // countLines counts the total lines in the document.
 func (d *Document) countLines() int {
 	return strings.Count(string(d.source), "\n") + 1
@@ -268,6 +312,11 @@ type SearchResult struct {
 	Section string // Section heading
 	Lines   string // Line range (e.g., "34-89")
 	Match   string // Snippet with match context
+
+	// Set for element-level results (SearchOptions.Elements) only
+	Kind     ElementKind // Kind of the matching element, or ElementProse
+	Language string      // Code block language when Kind is ElementCode
+	Line     int         // Line of the match (0 for formats without positions)
 }
 
 // SearchResults holds all search matches.
@@ -276,21 +325,43 @@ type SearchResults struct {
 	Matches []*SearchResult
 }
 
-// Search finds sections containing the query term.
+// SearchOptions controls how SearchWithOptions matches the query. The zero
+// value is a case-insensitive substring search.
+type SearchOptions struct {
+	CaseSensitive bool // Match the query's exact casing
+	WholeWord     bool // Match only on word boundaries, so "cat" skips "category"
+
+	// Elements reports each matching heading, code block, table cell, list
+	// item or prose paragraph rather than the enclosing section.
+	Elements bool
+	// Kinds limits element-level results to these kinds (all when empty).
+	// Use ElementProse for paragraph text.
+	Kinds []ElementKind
+}
+
+// Search finds sections containing the query term, ignoring case.
 func (d *Document) Search(query string) *SearchResults {
+	return d.SearchWithOptions(query, SearchOptions{})
+}
+
+// SearchWithOptions finds sections containing the query term, matched
+// according to opts.
+func (d *Document) SearchWithOptions(query string, opts SearchOptions) *SearchResults {
 	results := &SearchResults{Query: query}
-	query = strings.ToLower(query)
+	re := searchPattern(query, opts)
+	if opts.Elements {
+		results.Matches = d.searchElements(re, opts.Kinds)
+		return results
+	}
 
 	for _, section := range d.GetSections() {
 		text := section.GetText()
-		if strings.Contains(strings.ToLower(text), query) {
-			// Find a snippet around the match
-			snippet := extractSnippet(text, query, 60)
+		if loc := re.FindStringIndex(text); loc != nil {
 			results.Matches = append(results.Matches, &SearchResult{
 				File:    d.path,
 				Section: section.Heading.Text,
 				Lines:   fmt.Sprintf("%d-%d", section.Start, section.End),
-				Match:   snippet,
+				Match:   snippetAround(text, loc[0], loc[1], 60),
 			})
 		}
 	}
@@ -298,6 +369,18 @@ func (d *Document) Search(query string) *SearchResults {
 	return results
 }
 
+// searchPattern builds the regexp SearchWithOptions matches with.
+func searchPattern(query string, opts SearchOptions) *regexp.Regexp {
+	pattern := regexp.QuoteMeta(query)
+	if opts.WholeWord && query != "" {
+		pattern = wordBounded(pattern, query)
+	}
+	if !opts.CaseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.MustCompile(pattern)
+}
+
 // extractSnippet extracts text around the first match.
 func extractSnippet(text, query string, contextLen int) string {
 	lower := strings.ToLower(text)
@@ -305,15 +388,20 @@ func extractSnippet(text, query string, contextLen int) string {
 	if idx < 0 {
 		return ""
 	}
+	return snippetAround(text, idx, idx+len(query), contextLen)
+}
 
-	start := idx - contextLen
-	if start < 0 {
-		start = 0
-	}
-	end := idx + len(query) + contextLen
+// snippetAround returns text[start:end] with up to contextLen bytes of
+// context on either side, whitespace collapsed.
+func snippetAround(text string, start, end, contextLen int) string {
+	end += contextLen
 	if end > len(text) {
 		end = len(text)
 	}
+	start -= contextLen
+	if start < 0 {
+		start = 0
+	}
 
 	snippet := text[start:end]
 	// Clean up whitespace
@@ -348,7 +436,13 @@ func (r *SearchResults) String() string {
 			buf.WriteString(fmt.Sprintf("%s:\n", m.File))
 			currentFile = m.File
 		}
-		buf.WriteString(fmt.Sprintf("  ## %s (lines %s)\n", m.Section, m.Lines))
+		if m.Kind != "" && m.Line == 0 {
+			buf.WriteString(fmt.Sprintf("  ## %s (in %s)\n", m.Section, m.Where()))
+		} else if m.Kind != "" {
+			buf.WriteString(fmt.Sprintf("  ## %s (line %d, in %s)\n", m.Section, m.Line, m.Where()))
+		} else {
+			buf.WriteString(fmt.Sprintf("  ## %s (lines %s)\n", m.Section, m.Lines))
+		}
 		if m.Match != "" {
 			buf.WriteString(fmt.Sprintf("     %q\n", m.Match))
 		}
@@ -357,8 +451,105 @@ func (r *SearchResults) String() string {
 	return buf.String()
 }
 
+// TermCount pairs a section with the number of times a term occurs in it.
+type TermCount struct {
+	Section *Section
+	Count   int
+}
+
+// TermFrequencies holds per-section term counts, highest count first.
+type TermFrequencies struct {
+	Term   string
+	Counts []*TermCount
+}
+
+// TermFrequency counts case-insensitive, whole-word occurrences of term in
+// each section's own text, up to its first subsection, so that a match is
+// counted once rather than again for every enclosing section. Sections
+// without a match are omitted.
+func (d *Document) TermFrequency(term string) map[*Section]int {
+	counts := make(map[*Section]int)
+	re := termPattern(term)
+	if re == nil {
+		return counts
+	}
+
+	for _, section := range d.GetSections() {
+		if n := len(re.FindAllStringIndex(section.OwnText(), -1)); n > 0 {
+			counts[section] = n
+		}
+	}
+	return counts
+}
+
+// RankByTermFrequency returns sections containing term ordered by match
+// count (descending), with ties kept in document order.
+func (d *Document) RankByTermFrequency(term string) *TermFrequencies {
+	result := &TermFrequencies{Term: term}
+	counts := d.TermFrequency(term)
+
+	for _, section := range d.GetSections() {
+		if n, ok := counts[section]; ok {
+			result.Counts = append(result.Counts, &TermCount{Section: section, Count: n})
+		}
+	}
+	sort.SliceStable(result.Counts, func(i, j int) bool {
+		return result.Counts[i].Count > result.Counts[j].Count
+	})
+
+	return result
+}
+
+// termPattern builds a case-insensitive regexp matching term on word
+// boundaries.
+func termPattern(term string) *regexp.Regexp {
+	term = strings.TrimSpace(term)
+	if term == "" {
+		return nil
+	}
+
+	return regexp.MustCompile("(?i)" + wordBounded(regexp.QuoteMeta(term), term))
+}
+
+// wordBounded adds word boundaries to pattern, the quoted form of term.
+// Boundaries are only enforced next to word characters so terms like "c++"
+// or ".env" still match.
+func wordBounded(pattern, term string) string {
+	if isWordChar(rune(term[0])) {
+		pattern = `\b` + pattern
+	}
+	if isWordChar(rune(term[len(term)-1])) {
+		pattern = pattern + `\b`
+	}
+	return pattern
+}
+
+func isWordChar(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// String renders term frequencies.
+func (r *TermFrequencies) String() string {
+	if len(r.Counts) == 0 {
+		return fmt.Sprintf("No matches for %q\n", r.Term)
+	}
+
+	var buf strings.Builder
+	buf.WriteString(fmt.Sprintf("Term frequency for %q:\n\n", r.Term))
+	for _, c := range r.Counts {
+		buf.WriteString(fmt.Sprintf("  %4d  %s (lines %d-%d)\n", c.Count, c.Section.Heading.Text, c.Section.Start, c.Section.End))
+	}
+	return buf.String()
+}
+
 // SearchDir searches all markdown files in a directory.
 func SearchDir(dirPath string, query string) (*SearchResults, error) {
+	return SearchDirWithOptions(dirPath, query, SearchOptions{})
+}
+
+// SearchDirWithOptions searches all markdown files in a directory, matching
+// the query according to opts.
+func SearchDirWithOptions(dirPath string, query string, opts SearchOptions) (*SearchResults, error) {
 	results := &SearchResults{Query: query}
 	parser := NewParser()
 
@@ -378,7 +569,7 @@ func SearchDir(dirPath string, query string) (*SearchResults, error) {
 			return nil // Skip unparseable files
 		}
 
-		fileResults := doc.Search(query)
+		fileResults := doc.SearchWithOptions(query, opts)
 		results.Matches = append(results.Matches, fileResults.Matches...)
 		return nil
 	})
@@ -399,7 +590,10 @@ type DirFileNode struct {
 	IsDir       bool           // True if directory
 	Lines       int            // Line count (files only)
 	Sections    int            // Section count (files only)
+	Headings    [6]int         // Heading count per level, H1 at index 0 (files only)
+	CodeBlocks  int            // Code block count (files only)
 	TopHeadings []*DirHeading  // Top-level headings for expand/full modes
+	Metadata    Metadata       // Frontmatter (files only)
 	Children    []*DirFileNode // Child files/directories
 }
 
@@ -412,25 +606,122 @@ type DirTreeResult struct {
 	Root       []*DirFileNode // Top-level entries
 }
 
+// DirTreeOptions controls how BuildDirTreeWithOptions orders files.
+type DirTreeOptions struct {
+	// SortBy orders the files in each directory: "" for name, "lines" (or
+	// "size"), "sections", or the name of a frontmatter field. Files missing
+	// the field sort last. Directories always come first, by name.
+	SortBy string
+	// Descending reverses the order; files missing the field stay last.
+	Descending bool
+}
+
 // BuildDirTree creates a tree representation of markdown files in a directory.
 func BuildDirTree(dirPath string, mode TreeMode) (*DirTreeResult, error) {
+	return BuildDirTreeWithOptions(dirPath, mode, DirTreeOptions{})
+}
+
+// BuildDirTreeWithOptions is BuildDirTree with control over file ordering,
+// e.g. DirTreeOptions{SortBy: "priority"} for a prioritized overview.
+func BuildDirTreeWithOptions(dirPath string, mode TreeMode, opts DirTreeOptions) (*DirTreeResult, error) {
 	result := &DirTreeResult{
 		Path: dirPath,
 		Mode: mode,
 	}
 
 	parser := NewParser()
-	root, err := buildDirNode(dirPath, parser, mode, result)
+	root, err := buildDirNode(dirPath, parser, mode, result, nil)
 	if err != nil {
 		return nil, err
 	}
 
+	if opts.SortBy != "" {
+		sortDirNodes(root, opts)
+	}
 	result.Root = root.Children
 	return result, nil
 }
 
-// buildDirNode recursively builds directory tree nodes.
-func buildDirNode(path string, parser *Parser, mode TreeMode, result *DirTreeResult) (*DirFileNode, error) {
+// FilterDirTree builds a directory tree containing only the markdown files
+// for which keep reports true; directories left without files are pruned.
+// Files are parsed and tested concurrently, but the tree is identical to a
+// sequential build. Files that fail to parse are dropped. The first error
+// from keep, in path order, is returned.
+func FilterDirTree(dirPath string, mode TreeMode, keep func(*Document) (bool, error)) (*DirTreeResult, error) {
+	var paths []string
+	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Skip errors
+		}
+		if strings.HasPrefix(info.Name(), ".") && path != dirPath {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() && strings.HasSuffix(strings.ToLower(path), ".md") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]*Document, len(paths))
+	errs := make([]error, len(paths))
+	parser := NewParser()
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, path string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			doc, err := parser.ParseFile(path)
+			if err != nil {
+				return // Skip unparseable files
+			}
+			ok, err := keep(doc)
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", path, err)
+				return
+			}
+			if ok {
+				docs[i] = doc
+			}
+		}(i, path)
+	}
+	wg.Wait()
+
+	kept := make(map[string]*Document)
+	for i, path := range paths {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if docs[i] != nil {
+			kept[path] = docs[i]
+		}
+	}
+
+	result := &DirTreeResult{
+		Path: dirPath,
+		Mode: mode,
+	}
+	root, err := buildDirNode(dirPath, parser, mode, result, kept)
+	if err != nil {
+		return nil, err
+	}
+	result.Root = root.Children
+	return result, nil
+}
+
+// buildDirNode recursively builds directory tree nodes. When docs is
+// non-nil, only the files it contains are included, using the parsed
+// documents instead of re-reading them.
+func buildDirNode(path string, parser *Parser, mode TreeMode, result *DirTreeResult, docs map[string]*Document) (*DirFileNode, error) {
 	info, err := os.Stat(path)
 	if err != nil {
 		return nil, err
@@ -445,16 +736,25 @@ func buildDirNode(path string, parser *Parser, mode TreeMode, result *DirTreeRes
 	if !info.IsDir() {
 		// It's a file - parse it
 		if strings.HasSuffix(strings.ToLower(path), ".md") {
-			doc, err := parser.ParseFile(path)
-			if err != nil {
+			var doc *Document
+			if docs != nil {
+				if doc = docs[path]; doc == nil {
+					return nil, nil
+				}
+			} else if doc, err = parser.ParseFile(path); err != nil {
 				// Skip files that can't be parsed
 				node.Lines = -1
 				return node, nil
 			}
 
 			node.Lines = doc.countLines()
+			node.Metadata = doc.Metadata()
 			sections := doc.GetSections()
 			node.Sections = len(sections)
+			for _, h := range doc.GetHeadings() {
+				node.Headings[h.Level-1]++
+			}
+			node.CodeBlocks = len(doc.GetCodeBlocks())
 
 			result.TotalFiles++
 			result.TotalLines += node.Lines
@@ -469,7 +769,7 @@ func buildDirNode(path string, parser *Parser, mode TreeMode, result *DirTreeRes
 					}
 					// Add preview for full mode
 					if mode == TreeModeFull {
-						heading.Preview = ExtractPreview(section.GetText(), 50)
+						heading.Preview = ExtractPreview(section.GetText(), DefaultPreviewLength)
 					}
 					node.TopHeadings = append(node.TopHeadings, heading)
 
@@ -480,7 +780,7 @@ func buildDirNode(path string, parser *Parser, mode TreeMode, result *DirTreeRes
 								Text: fmt.Sprintf("%s %s", strings.Repeat("#", child.Heading.Level), child.Heading.Text),
 							}
 							if mode == TreeModeFull {
-								childHeading.Preview = ExtractPreview(child.GetText(), 50)
+								childHeading.Preview = ExtractPreview(child.GetText(), DefaultPreviewLength)
 							}
 							node.TopHeadings = append(node.TopHeadings, childHeading)
 						}
@@ -518,9 +818,9 @@ func buildDirNode(path string, parser *Parser, mode TreeMode, result *DirTreeRes
 			continue
 		}
 
-		child, err := buildDirNode(childPath, parser, mode, result)
-		if err != nil {
-			continue // Skip entries that error
+		child, err := buildDirNode(childPath, parser, mode, result, docs)
+		if err != nil || child == nil {
+			continue // Skip entries that error or are filtered out
 		}
 
 		// Skip empty directories (no .md files)
@@ -534,6 +834,115 @@ func buildDirNode(path string, parser *Parser, mode TreeMode, result *DirTreeRes
 	return node, nil
 }
 
+// fileStats renders a file's heading histogram and code block count for
+// the preview and full modes, e.g. " [H1:1 H2:5 code:3]".
+func (t *DirTreeResult) fileStats(node *DirFileNode) string {
+	if t.Mode != TreeModePreview && t.Mode != TreeModeFull {
+		return ""
+	}
+	var parts []string
+	for i, n := range node.Headings {
+		if n > 0 {
+			parts = append(parts, fmt.Sprintf("H%d:%d", i+1, n))
+		}
+	}
+	if node.CodeBlocks > 0 {
+		parts = append(parts, fmt.Sprintf("code:%d", node.CodeBlocks))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(parts, " ") + "]"
+}
+
+// sortDirNodes reorders the files of each directory under node by opts,
+// keeping directories first and name order among ties.
+func sortDirNodes(node *DirFileNode, opts DirTreeOptions) {
+	sort.SliceStable(node.Children, func(i, j int) bool {
+		a, b := node.Children[i], node.Children[j]
+		if a.IsDir || b.IsDir {
+			return a.IsDir && !b.IsDir
+		}
+
+		va, okA := dirSortKey(a, opts.SortBy)
+		vb, okB := dirSortKey(b, opts.SortBy)
+		if !okA || !okB {
+			return okA && !okB
+		}
+		if opts.Descending {
+			return compareSortKeys(vb, va) < 0
+		}
+		return compareSortKeys(va, vb) < 0
+	})
+
+	for _, child := range node.Children {
+		if child.IsDir {
+			sortDirNodes(child, opts)
+		}
+	}
+}
+
+// dirSortKey returns the value a file sorts by, and false when the file has
+// no such value (unparseable, or missing the frontmatter field).
+func dirSortKey(node *DirFileNode, sortBy string) (interface{}, bool) {
+	if node.Lines < 0 {
+		return nil, false
+	}
+	switch sortBy {
+	case "lines", "size":
+		return node.Lines, true
+	case "sections":
+		return node.Sections, true
+	}
+	val, ok := node.Metadata[sortBy]
+	if !ok || val == nil {
+		return nil, false
+	}
+	return val, true
+}
+
+// priorityRanks orders conventional priority names from most to least
+// urgent, so sorting by a priority field puts critical documents first.
+var priorityRanks = map[string]int{"critical": 0, "high": 1, "medium": 2, "low": 3}
+
+// compareSortKeys compares numbers numerically, known priority names by
+// urgency, and everything else (including ISO dates) as text.
+func compareSortKeys(a, b interface{}) int {
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	sa, sb := fmt.Sprint(a), fmt.Sprint(b)
+	ra, okA := priorityRanks[strings.ToLower(sa)]
+	rb, okB := priorityRanks[strings.ToLower(sb)]
+	if okA && okB {
+		return ra - rb
+	}
+	return strings.Compare(sa, sb)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
 // String renders the directory tree as a string.
 func (t *DirTreeResult) String() string {
 	var buf strings.Builder
@@ -563,9 +972,9 @@ func (t *DirTreeResult) renderNode(buf *strings.Builder, node *DirFileNode, pref
 		if node.Lines < 0 {
 			buf.WriteString(fmt.Sprintf("%s%s%s (parse error)\n", prefix, connector, node.Name))
 		} else if node.Sections == 0 {
-			buf.WriteString(fmt.Sprintf("%s%s%s (%d lines, no sections)\n", prefix, connector, node.Name, node.Lines))
+			buf.WriteString(fmt.Sprintf("%s%s%s (%d lines, no sections)%s\n", prefix, connector, node.Name, node.Lines, t.fileStats(node)))
 		} else {
-			buf.WriteString(fmt.Sprintf("%s%s%s (%d lines, %d sections)\n", prefix, connector, node.Name, node.Lines, node.Sections))
+			buf.WriteString(fmt.Sprintf("%s%s%s (%d lines, %d sections)%s\n", prefix, connector, node.Name, node.Lines, node.Sections, t.fileStats(node)))
 		}
 	}
 
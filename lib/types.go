@@ -1,9 +1,11 @@
 package mq
 
 import (
+	"bytes"
 	"strings"
 
 	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
 )
 
 // Metadata represents YAML frontmatter in a markdown document.
@@ -21,18 +23,34 @@ type Heading struct {
 // Section represents a document section defined by a heading.
 type Section struct {
 	Heading  *Heading   // The heading that starts this section
-	Content  []ast.Node // All nodes in this section
+	Content  []ast.Node // Top-level blocks of this section; a paragraph split by detected headings is in each section it spans
 	Parent   *Section   // Parent section (if nested)
 	Children []*Section // Child sections
 	Start    int        // Starting line number
 	End      int        // Ending line number
+	Implicit bool       // Spans a document without headings; Heading is synthesized
 	source   []byte     // Reference to document source for text extraction
+	doc      *Document  // Owning document, for top-level sibling lookups
+	topLevel []*Section // Peers of a pruned top-level copy (nil: the document's)
+
+	langAliases map[string]string // code language aliases (nil: exact match)
 
 	// Store references to extracted elements for this section
 	codeBlocks []*CodeBlock // Code blocks in this section (not children)
+	tables     []*Table     // Tables in this section (not children)
+	images     []*Image     // Images in this section (not children)
+}
+
+// SectionTextOptions controls GetTextWithOptions.
+type SectionTextOptions struct {
+	// BodyOnly leaves out the heading line (both lines of a setext heading)
+	// and the blank lines after it, e.g. to splice the section under a new
+	// heading.
+	BodyOnly bool
 }
 
-// GetText extracts the raw markdown content from the section using line numbers.
+// GetText extracts the raw markdown content from the section using line
+// numbers, starting with the heading line.
 func (s *Section) GetText() string {
 	if s.source == nil || s.Start == 0 || s.End == 0 {
 		return ""
@@ -53,13 +71,347 @@ func (s *Section) GetText() string {
 	return strings.Join(sectionLines, "\n")
 }
 
+// GetTextWithOptions extracts the section's raw markdown like GetText,
+// shaped by opts.
+func (s *Section) GetTextWithOptions(opts SectionTextOptions) string {
+	text := s.GetText()
+	if !opts.BodyOnly || text == "" {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	lines = lines[s.headingLines(lines):]
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// headingLines returns how many of the section's first lines hold its
+// heading: 0 for an implicit section, 2 for a setext heading and 1
+// otherwise.
+func (s *Section) headingLines(lines []string) int {
+	if s.Heading == nil || s.Implicit || len(lines) == 0 {
+		return 0
+	}
+	if len(lines) > 1 && !strings.HasPrefix(strings.TrimSpace(lines[0]), "#") && isSetextUnderline(lines[1]) {
+		return 2
+	}
+	return 1
+}
+
+// isSetextUnderline reports whether line is a run of "=" or "-" (up to
+// three spaces of indentation), which turns the line above into a heading.
+func isSetextUnderline(line string) bool {
+	if len(line)-len(strings.TrimLeft(line, " ")) > 3 {
+		return false
+	}
+	line = strings.TrimSpace(line)
+	return line != "" && (strings.Trim(line, "=") == "" || strings.Trim(line, "-") == "")
+}
+
+// ownLines returns the line range of the section's own content: from its
+// heading up to its first subsection.
+func (s *Section) ownLines() (start, end int) {
+	start, end = s.Start, s.End
+	if len(s.Children) > 0 && s.Children[0].Start > start {
+		end = s.Children[0].Start - 1
+	}
+	return start, end
+}
+
+// ownSection returns a copy of the section ending at its first
+// subsection, so that GetText and GetTextWithOptions see only its own
+// content.
+func (s *Section) ownSection() *Section {
+	own := *s
+	_, own.End = s.ownLines()
+	return &own
+}
+
+// OwnText returns the section's text like GetText, but only up to its
+// first subsection, so texts of a section and its subsections do not
+// overlap.
+func (s *Section) OwnText() string {
+	return s.ownSection().GetText()
+}
+
+// GetProseText returns the section's narrative text: the same content as
+// GetText but with code blocks (fenced or indented) and tables removed. Word counts,
+// summaries and relevance scoring should prefer it so code does not skew
+// the results; GetText remains the full-content version.
+func (s *Section) GetProseText() string {
+	return s.proseText(SectionTextOptions{})
+}
+
+// Lead returns the text of the section's first non-empty top-level
+// paragraph, ignoring subsections, or "" if it has none. It is a cheap,
+// predictable summary for previews.
+func (s *Section) Lead() string {
+	for _, node := range s.Content {
+		if text := leadText(node, s.source); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// leadText returns the plain text of node when it is a top-level paragraph
+// (not inside a list or blockquote), joining wrapped lines with spaces.
+// Image alt text is left out: a paragraph of badges has no lead.
+func leadText(node ast.Node, source []byte) string {
+	para, ok := node.(*ast.Paragraph)
+	if !ok || para.Parent() == nil || para.Parent().Kind() != ast.KindDocument {
+		return ""
+	}
+	return strings.ReplaceAll(inlineText(para, source, true), "\n", " ")
+}
+
+// proseText returns GetTextWithOptions(opts) without the lines of the code
+// blocks and tables in the section and its subsections.
+func (s *Section) proseText(opts SectionTextOptions) string {
+	text := s.GetTextWithOptions(opts)
+	if text == "" {
+		return ""
+	}
+	var nodes []ast.Node
+	var collect func(*Section)
+	collect = func(sec *Section) {
+		nodes = append(nodes, sec.Content...)
+		for _, child := range sec.Children {
+			collect(child)
+		}
+	}
+	collect(s)
+
+	// BodyOnly drops leading lines, never trailing ones
+	lines := strings.Split(text, "\n")
+	last := min(s.End, bytes.Count(s.source, []byte("\n"))+1)
+	return dropLines(lines, last-len(lines)+1, nonProseLines(s.source, nodes))
+}
+
+// nonProseLines returns the source lines covered by the code blocks (fenced
+// or indented) and tables among nodes, including those nested in lists and
+// blockquotes.
+func nonProseLines(source []byte, nodes []ast.Node) map[int]bool {
+	skip := make(map[int]bool)
+	var lineStarts []int
+	for _, node := range nodes {
+		ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+			if !entering || n.Type() != ast.TypeBlock {
+				return ast.WalkSkipChildren, nil
+			}
+			switch n.(type) {
+			case *ast.FencedCodeBlock, *ast.CodeBlock, *east.Table:
+			default:
+				return ast.WalkContinue, nil
+			}
+			if lineStarts == nil {
+				lineStarts = computeLineStarts(source)
+			}
+			start, end := blockLines(n, source, lineStarts)
+			for line := start; line <= end; line++ {
+				skip[line] = true
+			}
+			return ast.WalkSkipChildren, nil
+		})
+	}
+	return skip
+}
+
+// blockLines returns the first and last source lines of a code block or
+// table, including the fences of a fenced block. The range is empty when
+// the node has no position.
+func blockLines(node ast.Node, source []byte, lineStarts []int) (int, int) {
+	switch n := node.(type) {
+	case *ast.FencedCodeBlock:
+		start := fenceLine(n, lineStarts)
+		if start == 0 {
+			return 0, -1
+		}
+		end := start
+		if lines := n.Lines(); lines.Len() > 0 {
+			end = getLineNumber(lineStarts, lines.At(lines.Len()-1).Stop-1)
+		}
+		// The closing fence, unless the block runs to the end of its container
+		if end < len(lineStarts) {
+			next := source[lineStarts[end]:]
+			if i := bytes.IndexByte(next, '\n'); i >= 0 {
+				next = next[:i]
+			}
+			next = bytes.TrimLeft(next, " \t>")
+			if bytes.HasPrefix(next, []byte("```")) || bytes.HasPrefix(next, []byte("~~~")) {
+				end++
+			}
+		}
+		return start, end
+	case *ast.CodeBlock:
+		lines := n.Lines()
+		if lines.Len() == 0 {
+			return 0, -1
+		}
+		return getLineNumber(lineStarts, lines.At(0).Start), getLineNumber(lineStarts, lines.At(lines.Len()-1).Stop-1)
+	}
+	offset, ok := nodeOffset(node)
+	if !ok {
+		return 0, -1
+	}
+	start := getLineNumber(lineStarts, offset)
+	return start, nodeEndLine(node, lineStarts, start)
+}
+
+// dropLines removes the lines in skip from lines, numbered from first,
+// collapsing the blank lines they leave behind.
+func dropLines(lines []string, first int, skip map[int]bool) string {
+	var kept []string
+	for i, line := range lines {
+		if skip[first+i] {
+			continue
+		}
+		if strings.TrimSpace(line) == "" && (len(kept) == 0 || kept[len(kept)-1] == "") {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.TrimRight(strings.Join(kept, "\n"), "\n")
+}
+
+// Head returns the first n lines of the section body (below the heading,
+// leading blank lines skipped), including any subsections. It returns the
+// whole body when the section is shorter than n.
+func (s *Section) Head(n int) string {
+	lines := s.bodyLines()
+	if n < len(lines) {
+		lines = lines[:max(n, 0)]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Tail returns the last n lines of the section body, ignoring trailing
+// blank lines. It returns the whole body when the section is shorter than n.
+func (s *Section) Tail(n int) string {
+	lines := s.bodyLines()
+	if n < len(lines) {
+		lines = lines[len(lines)-max(n, 0):]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// bodyLines returns the section's source lines after the heading, without
+// leading and trailing blank lines.
+func (s *Section) bodyLines() []string {
+	text := s.GetText()
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+	lines = lines[s.headingLines(lines):]
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Document returns the document the section belongs to, or nil for
+// sections built outside a parser.
+func (s *Section) Document() *Document {
+	return s.doc
+}
+
+// Path returns the heading texts from the root section down to this section,
+// e.g. ["API", "Authentication", "OAuth2 Flow"].
+func (s *Section) Path() []string {
+	var path []string
+	for sec := s; sec != nil; sec = sec.Parent {
+		if sec.Heading != nil {
+			path = append([]string{sec.Heading.Text}, path...)
+		}
+	}
+	return path
+}
+
+// PathString returns the section path joined with sep (e.g. "API > Authentication").
+func (s *Section) PathString(sep string) string {
+	return strings.Join(s.Path(), sep)
+}
+
+// Ancestors returns the enclosing sections from the root down to the
+// parent. It is empty for a top-level section.
+func (s *Section) Ancestors() []*Section {
+	var ancestors []*Section
+	for sec := s.Parent; sec != nil; sec = sec.Parent {
+		ancestors = append([]*Section{sec}, ancestors...)
+	}
+	return ancestors
+}
+
+// Siblings returns the other sections that share this section's parent, in
+// document order. For a top-level section these are the other top-level
+// sections of its document.
+func (s *Section) Siblings() []*Section {
+	var siblings []*Section
+	for _, sec := range s.peers() {
+		if sec != s {
+			siblings = append(siblings, sec)
+		}
+	}
+	return siblings
+}
+
+// Next returns the following section at the same level, or nil if this is
+// the last one.
+func (s *Section) Next() *Section {
+	return s.adjacent(1)
+}
+
+// Prev returns the preceding section at the same level, or nil if this is
+// the first one.
+func (s *Section) Prev() *Section {
+	return s.adjacent(-1)
+}
+
+// adjacent returns the peer offset positions away from s, or nil.
+func (s *Section) adjacent(offset int) *Section {
+	peers := s.peers()
+	for i, sec := range peers {
+		if sec == s {
+			if j := i + offset; j >= 0 && j < len(peers) {
+				return peers[j]
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// peers returns the sections at this section's level, including itself.
+func (s *Section) peers() []*Section {
+	if s.Parent != nil {
+		return s.Parent.Children
+	}
+	if s.topLevel != nil {
+		return s.topLevel
+	}
+	if s.doc != nil {
+		return s.doc.GetTableOfContents()
+	}
+	return []*Section{s}
+}
+
 // GetCodeBlocks returns all code blocks in this section and its children.
 func (s *Section) GetCodeBlocks(languages ...string) []*CodeBlock {
 	var blocks []*CodeBlock
 
+	wanted := make([]string, len(languages))
+	for i, lang := range languages {
+		wanted[i] = normalizeLanguage(s.langAliases, lang)
+	}
+
 	// Return stored code blocks for this section
 	for _, cb := range s.codeBlocks {
-		if len(languages) == 0 || contains(languages, cb.Language) {
+		if len(languages) == 0 || contains(wanted, normalizeLanguage(s.langAliases, cb.Language)) {
 			blocks = append(blocks, cb)
 		}
 	}
@@ -78,58 +430,159 @@ func (s *Section) AddCodeBlock(cb *CodeBlock) {
 	s.codeBlocks = append(s.codeBlocks, cb)
 }
 
+// GetTables returns all tables in this section and its children.
+func (s *Section) GetTables() []*Table {
+	tables := append([]*Table{}, s.tables...)
+	for _, child := range s.Children {
+		tables = append(tables, child.GetTables()...)
+	}
+	return tables
+}
+
+// AddTable adds a table to this section.
+func (s *Section) AddTable(t *Table) {
+	s.tables = append(s.tables, t)
+}
+
+// GetImages returns all images in this section and its children.
+func (s *Section) GetImages() []*Image {
+	images := append([]*Image{}, s.images...)
+	for _, child := range s.Children {
+		images = append(images, child.GetImages()...)
+	}
+	return images
+}
+
+// AddImage adds an image to this section and makes it the image's
+// Section.
+func (s *Section) AddImage(img *Image) {
+	s.images = append(s.images, img)
+	img.Section = s
+}
+
 // CodeBlock represents a fenced code block.
 type CodeBlock struct {
 	Language string   // Programming language identifier
 	Content  string   // The code content
 	Node     ast.Node // Reference to the AST node
 	Lines    int      // Number of lines in the code block
+	Line     int      // Line number of the opening fence
 }
 
 // GetLines returns the number of lines in the code block.
+// It never mutates the block, so it is safe to call from multiple goroutines.
 func (c *CodeBlock) GetLines() int {
 	if c.Lines == 0 {
-		c.Lines = strings.Count(c.Content, "\n") + 1
+		return strings.Count(c.Content, "\n") + 1
 	}
 	return c.Lines
 }
 
+// ContentLines returns the code split into lines, without line endings.
+// A trailing newline does not produce an empty last line.
+func (c *CodeBlock) ContentLines() []string {
+	if c.Content == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(c.Content, "\n"), "\n")
+}
+
 // Link represents a markdown link.
 type Link struct {
 	Text string // Display text
 	URL  string // Target URL
 	Node ast.Node
+	Line int  // Line number in the document
+	Col  int  // Column of the opening bracket
+	Auto bool // true for autolinks (<url> or a bare URL/email)
 }
 
 // Image represents a markdown image.
 type Image struct {
-	AltText string // Alternative text
-	URL     string // Image URL
-	Title   string // Optional title
+	AltText string   // Alternative text
+	URL     string   // Image URL
+	Title   string   // Optional title
+	Width   int      // Declared width in pixels (0 if unknown)
+	Height  int      // Declared height in pixels (0 if unknown)
+	Format  string   // Format inferred from the URL ("png", "jpeg", "svg", ...; "" if unknown)
+	Section *Section // Enclosing section (nil before the first heading)
 	Node    ast.Node
+	Line    int // Line number in the document
+	Col     int // Column of the leading "!"
+}
+
+// imageFormats maps file extensions to image format names.
+var imageFormats = map[string]string{
+	"png": "png", "jpg": "jpeg", "jpeg": "jpeg", "gif": "gif", "webp": "webp",
+	"svg": "svg", "avif": "avif", "bmp": "bmp", "ico": "ico", "tif": "tiff",
+	"tiff": "tiff", "heic": "heic",
+}
+
+// ImageFormat infers an image format from a URL's file extension, or from
+// the media type of a data: URL. It returns "" when the format is unknown.
+func ImageFormat(url string) string {
+	if rest, ok := strings.CutPrefix(url, "data:image/"); ok {
+		mediaType, _, _ := strings.Cut(rest, ";")
+		mediaType, _, _ = strings.Cut(mediaType, ",")
+		mediaType, _, _ = strings.Cut(mediaType, "+") // svg+xml
+		return imageFormats[strings.ToLower(mediaType)]
+	}
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		url = url[:i]
+	}
+	dot := strings.LastIndex(url, ".")
+	if dot < 0 || strings.Contains(url[dot:], "/") {
+		return ""
+	}
+	return imageFormats[strings.ToLower(url[dot+1:])]
 }
 
 // Table represents a markdown table.
 type Table struct {
-	Headers []string
-	Rows    [][]string
-	Node    ast.Node
+	Headers      []string
+	Rows         [][]string
+	Node         ast.Node
+	Line         int // Line number of the header row
+	MaxCellWidth int // Cells longer than this are shortened by String (0 = no limit)
 }
 
 // List represents a markdown list.
 type List struct {
 	Ordered bool       // true for numbered lists
+	Start   int        // First number of an ordered list (0 for bullet lists)
+	Loose   bool       // true when items are separated by blank lines
 	Items   []ListItem // List items
 	Node    ast.Node
+	Line    int // Line number of the first item
+}
+
+// Strikethrough represents a ~~deleted~~ span.
+type Strikethrough struct {
+	Text    string   // The struck-through text
+	Section *Section // Enclosing section (nil before the first heading)
+	Node    ast.Node
+	Line    int // Line number in the document
 }
 
 // ListItem represents an item in a list.
 type ListItem struct {
 	Text     string
 	Checked  *bool // For task lists (nil if not a task item)
+	Depth    int   // Nesting level (0 for top-level items)
+	Ordered  bool  // true when the item belongs to a numbered list
 	Children []ListItem
 }
 
+// FlatListItem is a list item taken out of its hierarchy, as returned by
+// GetListItems.
+type FlatListItem struct {
+	Text    string
+	Depth   int   // Nesting level (0 for top-level items)
+	Ordered bool  // true when the item belongs to a numbered list
+	Number  int   // Number of an ordered item (0 for bullets), from its list's Start
+	Checked *bool // For task lists (nil if not a task item)
+}
+
 // helper functions
 func contains(slice []string, item string) bool {
 	for _, s := range slice {
@@ -140,6 +593,15 @@ func contains(slice []string, item string) bool {
 	return false
 }
 
+func containsInt(slice []int, item int) bool {
+	for _, n := range slice {
+		if n == item {
+			return true
+		}
+	}
+	return false
+}
+
 func extractText(node ast.Node, buf *strings.Builder) {
 	if node == nil {
 		return
@@ -0,0 +1,151 @@
+package mq
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FieldSpec describes the expected shape of a frontmatter field.
+type FieldSpec struct {
+	Required bool          `yaml:"required"` // Field must be present
+	Type     string        `yaml:"type"`     // string, number, bool, date, array or object ("" for any)
+	Allowed  []interface{} `yaml:"allowed"`  // Permitted values; for arrays, permitted elements
+}
+
+// ValidationError reports a frontmatter field that does not match its schema.
+type ValidationError struct {
+	Field   string // Field name
+	Message string // What is wrong with the field
+}
+
+// Error implements the error interface.
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// LoadMetadataSchema reads a YAML schema mapping field names to FieldSpecs:
+//
+//	title:
+//	  required: true
+//	  type: string
+//	priority:
+//	  allowed: [low, medium, high, critical]
+func LoadMetadataSchema(path string) (map[string]FieldSpec, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading schema: %w", err)
+	}
+
+	var schema map[string]FieldSpec
+	if err := yaml.Unmarshal(content, &schema); err != nil {
+		return nil, fmt.Errorf("parsing schema: %w", err)
+	}
+	for field, spec := range schema {
+		if spec.Type != "" && !validFieldType(spec.Type) {
+			return nil, fmt.Errorf("schema field %s: unknown type %q", field, spec.Type)
+		}
+	}
+	return schema, nil
+}
+
+// ValidateMetadata checks the document's frontmatter against schema. It
+// reports fields that are missing, not declared in the schema, of the wrong
+// type, or outside their allowed values. Errors for present fields follow
+// source order; missing fields are reported last, sorted by name.
+func (d *Document) ValidateMetadata(schema map[string]FieldSpec) []ValidationError {
+	var errs []ValidationError
+	metadata := d.Metadata()
+
+	for _, key := range d.MetadataKeys() {
+		value := metadata[key]
+		spec, ok := schema[key]
+		if !ok {
+			errs = append(errs, ValidationError{Field: key, Message: "unexpected field"})
+			continue
+		}
+		if spec.Type != "" && !matchesFieldType(value, spec.Type) {
+			errs = append(errs, ValidationError{
+				Field:   key,
+				Message: fmt.Sprintf("expected %s, got %s", spec.Type, valueType(value)),
+			})
+			continue
+		}
+		if len(spec.Allowed) > 0 {
+			if bad, ok := disallowedValue(value, spec.Allowed); ok {
+				errs = append(errs, ValidationError{
+					Field:   key,
+					Message: fmt.Sprintf("value %q not in %s", bad, formatAllowed(spec.Allowed)),
+				})
+			}
+		}
+	}
+
+	var missing []string
+	for key, spec := range schema {
+		if _, ok := metadata[key]; spec.Required && !ok {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	for _, key := range missing {
+		errs = append(errs, ValidationError{Field: key, Message: "missing required field"})
+	}
+
+	return errs
+}
+
+func validFieldType(typ string) bool {
+	switch typ {
+	case "string", "number", "bool", "date", "array", "object":
+		return true
+	}
+	return false
+}
+
+// matchesFieldType reports whether value has the schema type typ. Dates are
+// accepted as YAML timestamps or YYYY-MM-DD strings.
+func matchesFieldType(value interface{}, typ string) bool {
+	if typ == "date" {
+		if s, ok := value.(string); ok {
+			_, err := time.Parse("2006-01-02", s)
+			return err == nil
+		}
+	}
+	return valueType(value) == typ
+}
+
+// disallowedValue returns the first value (or array element) that is not
+// in allowed.
+func disallowedValue(value interface{}, allowed []interface{}) (string, bool) {
+	values := []interface{}{value}
+	if arr, ok := value.([]interface{}); ok {
+		values = arr
+	}
+	for _, v := range values {
+		s := fmt.Sprint(v)
+		found := false
+		for _, a := range allowed {
+			if fmt.Sprint(a) == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+func formatAllowed(allowed []interface{}) string {
+	parts := make([]string, len(allowed))
+	for i, a := range allowed {
+		parts[i] = fmt.Sprint(a)
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"archive/tar"
 	"archive/zip"
+	"bytes"
 	"compress/gzip"
 	"encoding/json"
 	"fmt"
@@ -11,8 +12,10 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	mq "github.com/muqsitnawaz/mq/lib"
@@ -37,6 +40,9 @@ func main() {
 		case "-v", "--version", "version":
 			fmt.Printf("mq %s\n", version)
 			os.Exit(0)
+		case "--list-ops":
+			printOps()
+			os.Exit(0)
 		case "upgrade":
 			if err := selfUpgrade(); err != nil {
 				log.Fatalf("Upgrade failed: %v", err)
@@ -53,10 +59,28 @@ func main() {
 		os.Exit(1)
 	}
 
-	path := os.Args[1]
-	query := ""
-	if len(os.Args) >= 3 {
-		query = os.Args[2]
+	args, err := parseArgs(os.Args[1:])
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	path, query := args.paths[0], args.query
+
+	if args.validate != "" {
+		ok := true
+		for _, p := range args.paths {
+			ok = validateMetadata(p, args.validate) && ok
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(args.paths) > 1 {
+		if !queryFiles(args) {
+			os.Exit(1)
+		}
+		return
 	}
 
 	// Check if path is a directory
@@ -71,7 +95,7 @@ func main() {
 	}
 
 	// Load the markdown file
-	engine := mql.New()
+	engine := newEngine(args)
 	doc, err := engine.LoadDocument(path)
 	if err != nil {
 		log.Fatalf("Failed to load document: %v", err)
@@ -79,23 +103,337 @@ func main() {
 
 	// If no query provided, show document info
 	if query == "" {
+		printParseStats(path, doc, 0)
 		showDocumentInfo(doc)
 		return
 	}
 
 	// Execute the query
+	start := time.Now()
 	result, err := engine.Query(doc, query)
 	if err != nil {
 		log.Fatalf("Query failed: %v", err)
 	}
+	printParseStats(path, doc, time.Since(start))
 
 	// Display results
+	if args.json {
+		if err := engine.StreamResult(result, os.Stdout); err != nil {
+			log.Fatalf("Failed to write result: %v", err)
+		}
+		return
+	}
+	if args.positions && displayPositions(path, doc.Format(), result) {
+		return
+	}
 	displayResult(result)
 }
 
+// cliArgs holds the parsed command-line arguments.
+type cliArgs struct {
+	paths      []string // one directory, or one or more files
+	query      string
+	positions  bool   // print path:line:col for structural results
+	stats      bool   // print parse timings to stderr
+	components bool   // extract HTML and MDX component blocks
+	json       bool   // print the result as JSON
+	validate   string // frontmatter schema to validate against
+}
+
+// parseArgs separates flags from the positional path and query arguments.
+// A single path may be followed by the query; several paths need the query
+// first (mq '.headings' a.md b.md) or passed with -q.
+func parseArgs(argv []string) (*cliArgs, error) {
+	args := &cliArgs{}
+	var positional []string
+	queryFile := ""
+	queryFlag := false
+
+	for i := 0; i < len(argv); i++ {
+		arg := argv[i]
+		switch {
+		case arg == "-q" || arg == "--query":
+			if i+1 >= len(argv) {
+				return nil, fmt.Errorf("%s requires a query", arg)
+			}
+			i++
+			args.query = argv[i]
+			queryFlag = true
+		case strings.HasPrefix(arg, "--query="):
+			args.query = strings.TrimPrefix(arg, "--query=")
+			queryFlag = true
+		case arg == "--query-file":
+			if i+1 >= len(argv) {
+				return nil, fmt.Errorf("--query-file requires a file path")
+			}
+			i++
+			queryFile = argv[i]
+		case strings.HasPrefix(arg, "--query-file="):
+			queryFile = strings.TrimPrefix(arg, "--query-file=")
+		case arg == "--positions":
+			args.positions = true
+		case arg == "--stats":
+			args.stats = true
+		case arg == "--components":
+			args.components = true
+		case arg == "--json":
+			args.json = true
+		case arg == "--validate":
+			if i+1 >= len(argv) {
+				return nil, fmt.Errorf("--validate requires a schema file")
+			}
+			i++
+			args.validate = argv[i]
+		case strings.HasPrefix(arg, "--validate="):
+			args.validate = strings.TrimPrefix(arg, "--validate=")
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	switch {
+	case queryFlag || queryFile != "" || args.validate != "":
+		args.paths = positional
+	case len(positional) > 2 && looksLikeQuery(positional[0]):
+		args.query = positional[0]
+		args.paths = positional[1:]
+	case len(positional) > 2:
+		return nil, fmt.Errorf("unexpected argument: %s (with several files, put the query first or use -q)", positional[2])
+	case len(positional) == 2 && looksLikeQuery(positional[0]):
+		args.query = positional[0]
+		args.paths = positional[1:]
+	case len(positional) == 2 && isFile(positional[1]):
+		args.paths = positional
+	case len(positional) == 2:
+		args.paths = positional[:1]
+		args.query = positional[1]
+	default:
+		args.paths = positional
+	}
+
+	if len(args.paths) == 0 {
+		return nil, fmt.Errorf("missing file or directory path")
+	}
+
+	if args.validate != "" && (args.query != "" || queryFile != "") {
+		return nil, fmt.Errorf("cannot combine --validate with a query")
+	}
+
+	if queryFile != "" {
+		if args.query != "" {
+			return nil, fmt.Errorf("cannot use both an inline query and --query-file")
+		}
+		query, err := readQueryFile(queryFile)
+		if err != nil {
+			return nil, err
+		}
+		args.query = query
+	}
+
+	return args, nil
+}
+
+// looksLikeQuery reports whether arg is an MQL query rather than a path:
+// it starts like a query and names no existing file.
+func looksLikeQuery(arg string) bool {
+	trimmed := strings.TrimSpace(arg)
+	if !strings.HasPrefix(trimmed, ".") && !strings.HasPrefix(trimmed, "|") {
+		return false
+	}
+	_, err := os.Stat(arg)
+	return err != nil
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// displayCellWidth is the width at which table cells are shortened when
+// printed. Query results and --json output keep the full values.
+const displayCellWidth = 50
+
+// newEngine returns a query engine, recording parse stats and extracting
+// components if requested.
+func newEngine(args *cliArgs) *mql.Engine {
+	opts := []mql.EngineOption{
+		mql.WithTruncateFields(displayCellWidth),
+		mql.WithLimits(mq.DefaultLimits),
+	}
+	if args.stats {
+		opts = append(opts, mql.WithProfiling())
+	}
+	if args.components {
+		opts = append(opts, mql.WithComponents())
+	}
+	return mql.New(opts...)
+}
+
+// printParseStats writes the parse profile of doc, and the query time if
+// any, to stderr. It prints nothing for documents parsed without profiling.
+func printParseStats(path string, doc *mq.Document, query time.Duration) {
+	stats, ok := doc.ParseStats()
+	if !ok {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s: %s", path, stats)
+	if query > 0 {
+		fmt.Fprintf(os.Stderr, ", query %s", query)
+	}
+	fmt.Fprintln(os.Stderr)
+}
+
+// queryFiles runs args.query against each of args.paths, printing a "==> path <=="
+// header before each result. With --json the results are instead written as
+// one JSON object keyed by path, in argument order. A file that fails to
+// load or query is reported on stderr without stopping the others (and left
+// out of the JSON). It reports whether all succeeded.
+func queryFiles(args *cliArgs) bool {
+	engine := newEngine(args)
+	query := args.query
+	jsonOut := args.json && query != ""
+	var jsonResults [][]byte
+	ok := true
+	for i, path := range args.paths {
+		if !jsonOut {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("==> %s <==\n", path)
+		}
+
+		if info, err := os.Stat(path); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			ok = false
+			continue
+		} else if info.IsDir() {
+			fmt.Fprintf(os.Stderr, "%s: is a directory (pass directories on their own)\n", path)
+			ok = false
+			continue
+		}
+
+		doc, err := engine.LoadDocument(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: failed to load document: %v\n", path, err)
+			ok = false
+			continue
+		}
+
+		if query == "" {
+			printParseStats(path, doc, 0)
+			showDocumentInfo(doc)
+			continue
+		}
+
+		start := time.Now()
+		result, err := engine.Query(doc, query)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: query failed: %v\n", path, err)
+			ok = false
+			continue
+		}
+		printParseStats(path, doc, time.Since(start))
+		if jsonOut {
+			var buf bytes.Buffer
+			if err := engine.StreamResult(result, &buf); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+				ok = false
+				continue
+			}
+			key, _ := json.Marshal(path)
+			jsonResults = append(jsonResults, append(append(key, ": "...), bytes.TrimSpace(buf.Bytes())...))
+			continue
+		}
+		if args.positions && displayPositions(path, doc.Format(), result) {
+			continue
+		}
+		displayResult(result)
+	}
+
+	if jsonOut {
+		fmt.Print("{")
+		for i, entry := range jsonResults {
+			if i > 0 {
+				fmt.Print(",")
+			}
+			fmt.Printf("\n  %s", entry)
+		}
+		fmt.Println("\n}")
+	}
+	return ok
+}
+
+// validateMetadata checks the frontmatter of path (or every markdown file
+// under it) against a schema, printing one line per problem. It reports
+// whether all files passed.
+func validateMetadata(path, schemaPath string) bool {
+	schema, err := mq.LoadMetadataSchema(schemaPath)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	var files []string
+	err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if p != path && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if p == path || (strings.HasSuffix(strings.ToLower(p), ".md") && !strings.HasPrefix(info.Name(), ".")) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", path, err)
+	}
+
+	parser := mq.NewParser()
+	failed := 0
+	problems := 0
+	for _, file := range files {
+		doc, err := parser.ParseFile(file)
+		if err != nil {
+			fmt.Printf("%s: %v\n", file, err)
+			failed++
+			problems++
+			continue
+		}
+		errs := doc.ValidateMetadata(schema)
+		for _, e := range errs {
+			fmt.Printf("%s: %s\n", file, e)
+		}
+		if len(errs) > 0 {
+			failed++
+			problems += len(errs)
+		}
+	}
+
+	fmt.Printf("%d files checked, %d with problems (%d total)\n", len(files), failed, problems)
+	return failed == 0
+}
+
+// readQueryFile loads an MQL query from disk.
+func readQueryFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read query file: %w", err)
+	}
+	query := strings.TrimSpace(string(content))
+	if query == "" {
+		return "", fmt.Errorf("query file is empty: %s", path)
+	}
+	return query, nil
+}
+
 func printUsage() {
 	fmt.Printf("mq %s - Query markdown files without reading entire contents\n\n", version)
-	fmt.Println("Usage: mq <file|directory> [query]")
+	fmt.Println("Usage: mq <file|directory> [query | --query-file <file> | --validate <schema>]")
+	fmt.Println("       mq <query> <file> <file>...")
 	fmt.Println("\nWorkflow:")
 	fmt.Println("  1. See structure:  mq <path> '.tree(\"full\")'")
 	fmt.Println("  2. Extract content: mq <file> '.section(\"Name\") | .text'")
@@ -117,6 +455,8 @@ func printUsage() {
 	fmt.Println("Pipes:")
 	fmt.Println("  | .text            Extract raw content")
 	fmt.Println("  | .tree            Show structure of selection")
+	fmt.Println("  | .summary(100)    Summarize in at most 100 characters")
+	fmt.Println("  | .chunk(4000)     Split into pieces of at most 4000 characters")
 	fmt.Println("")
 	fmt.Println("Examples:")
 	fmt.Println("  mq docs/ '.tree(\"full\")'                    # See all docs structure")
@@ -127,10 +467,36 @@ func printUsage() {
 	fmt.Println("  upgrade            Upgrade to latest version")
 	fmt.Println("")
 	fmt.Println("Flags:")
+	fmt.Println("  -q, --query <q>    Query to run (every positional argument is then a path)")
+	fmt.Println("  --query-file <f>   Read the query from a file")
+	fmt.Println("  --positions        Print path:line:col for headings, sections, code, links")
+	fmt.Println("  --stats            Print parse and query timings to stderr")
+	fmt.Println("  --components       Extract HTML and MDX component blocks for .components")
+	fmt.Println("  --json             Print the result as JSON, streaming collections")
+	fmt.Println("  --validate <f>     Check frontmatter against a YAML schema (files or directories)")
+	fmt.Println("  --list-ops         List every selector and function")
 	fmt.Println("  -h, --help         Show this help")
 	fmt.Println("  -v, --version      Show version")
 }
 
+// printOps lists the query language's selectors and functions.
+func printOps() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Selectors:")
+	for _, s := range mql.Selectors() {
+		name := "." + s.Name + s.Args
+		if s.Scope != "document" {
+			name += " [" + s.Scope + "]"
+		}
+		fmt.Fprintf(w, "  %s\t%s\n", name, s.Description)
+	}
+	fmt.Fprintln(w, "\nFunctions:")
+	for _, f := range mql.Functions() {
+		fmt.Fprintf(w, "  %s\t%s\n", f.Name+f.Args, f.Description)
+	}
+	w.Flush()
+}
+
 func checkForUpdates() {
 	if version == "dev" {
 		return
@@ -358,7 +724,8 @@ func extractZip(archivePath, destDir string) error {
 }
 
 func handleDirectory(path string, query string) {
-	// Directory mode supports .tree and .search queries
+	// Directory mode supports .tree and .search queries; other queries
+	// filter the tree to matching files
 	if query == "" {
 		query = ".tree"
 	}
@@ -412,7 +779,12 @@ func handleDirectory(path string, query string) {
 		return
 	}
 
-	log.Fatalf("Directory mode supports: .tree, .tree(\"expand\"), .tree(\"preview\"), .tree(\"full\"), .search(\"term\")")
+	// Any other query filters the tree to files where it yields a result
+	result, err := mql.FilterDir(path, query, mq.TreeModeDefault)
+	if err != nil {
+		log.Fatalf("Directory mode supports: .tree, .tree(\"expand\"), .tree(\"preview\"), .tree(\"full\"), .search(\"term\"), or a query to filter files by: %v", err)
+	}
+	fmt.Print(result.String())
 }
 
 func showDocumentInfo(doc *mq.Document) {
@@ -512,12 +884,13 @@ func showDataInfo(doc *mq.Document) {
 			// Show sample rows
 			if len(table.Rows) > 0 {
 				fmt.Println("\nSample (first 3 rows):")
-				for i, row := range table.Rows {
-					if i >= 3 {
-						fmt.Printf("  ... and %d more rows\n", len(table.Rows)-3)
-						break
-					}
-					fmt.Printf("  %d. %v\n", i+1, row)
+				sample := *table
+				if len(sample.Rows) > 3 {
+					sample.Rows = sample.Rows[:3]
+				}
+				fmt.Print(sample.String())
+				if len(table.Rows) > 3 {
+					fmt.Printf("  ... and %d more rows\n", len(table.Rows)-3)
 				}
 			}
 		}
@@ -555,6 +928,63 @@ func showDataInfo(doc *mq.Document) {
 	}
 }
 
+// displayPositions prints structural results as "path:line:col  text" so
+// editors and scripts can jump to them. It reports false for result types
+// without positions, and for formats that record none, which are then
+// displayed normally.
+func displayPositions(path string, format mq.Format, result interface{}) bool {
+	var out []string
+	pos := func(line, col int, text string) {
+		out = append(out, fmt.Sprintf("%s:%d:%d  %s", path, line, col, text))
+	}
+
+	switch v := result.(type) {
+	case *mq.Heading:
+		pos(v.Line, 1, strings.Repeat("#", v.Level)+" "+v.Text)
+	case []*mq.Heading:
+		for _, h := range v {
+			pos(h.Line, 1, strings.Repeat("#", h.Level)+" "+h.Text)
+		}
+	case *mq.Section:
+		pos(v.Start, 1, fmt.Sprintf("%s %s", strings.Repeat("#", v.Heading.Level), v.Heading.Text))
+	case []*mq.Section:
+		for _, s := range v {
+			pos(s.Start, 1, fmt.Sprintf("%s %s", strings.Repeat("#", s.Heading.Level), s.Heading.Text))
+		}
+	case []*mq.CodeBlock:
+		for _, cb := range v {
+			pos(cb.Line, 1, fmt.Sprintf("```%s (%d lines)", cb.Language, cb.GetLines()))
+		}
+	case []*mq.Link:
+		for _, link := range v {
+			pos(link.Line, link.Col, fmt.Sprintf("[%s](%s)", link.Text, link.URL))
+		}
+	case []*mq.Image:
+		for _, img := range v {
+			pos(img.Line, img.Col, fmt.Sprintf("![%s](%s)", img.AltText, img.URL))
+		}
+	case []*mq.Table:
+		for _, t := range v {
+			pos(t.Line, 1, "table: "+strings.Join(t.Headers, " | "))
+		}
+	case []*mq.List:
+		for _, l := range v {
+			pos(l.Line, 1, fmt.Sprintf("list (%d items)", len(l.Items)))
+		}
+	default:
+		return false
+	}
+
+	if !format.HasPositions() {
+		fmt.Fprintf(os.Stderr, "%s: positions are not available for %s documents\n", path, format)
+		return false
+	}
+	for _, line := range out {
+		fmt.Println(line)
+	}
+	return true
+}
+
 func displayResult(result interface{}) {
 	switch v := result.(type) {
 	case []*mq.Heading:
@@ -601,7 +1031,15 @@ func displayResult(result interface{}) {
 	case []*mq.Image:
 		fmt.Printf("Found %d images:\n", len(v))
 		for i, img := range v {
-			fmt.Printf("%d. %s: %s\n", i+1, img.AltText, img.URL)
+			alt := img.AltText
+			if strings.TrimSpace(alt) == "" {
+				alt = "(no alt text)"
+			}
+			if img.Section != nil {
+				fmt.Printf("%d. %s: %s (%s)\n", i+1, alt, img.URL, img.Section.Heading.Text)
+			} else {
+				fmt.Printf("%d. %s: %s\n", i+1, alt, img.URL)
+			}
 		}
 
 	case []*mq.Table:
@@ -611,6 +1049,80 @@ func displayResult(result interface{}) {
 			fmt.Printf("Headers: %v\n", table.Headers)
 		}
 
+	case *mq.Table:
+		fmt.Print(v.String())
+
+	case []mq.FlatListItem:
+		for _, item := range v {
+			marker := "-"
+			if item.Ordered {
+				marker = fmt.Sprintf("%d.", item.Number)
+			}
+			if item.Checked != nil {
+				if *item.Checked {
+					marker += " [x]"
+				} else {
+					marker += " [ ]"
+				}
+			}
+			fmt.Printf("%s%s %s\n", strings.Repeat("  ", item.Depth), marker, item.Text)
+		}
+
+	case []mql.KeyCount:
+		for _, row := range v {
+			fmt.Printf("%s: %d\n", row.Key, row.Count)
+		}
+
+	case mq.SizeReport:
+		fmt.Printf("Source: %d bytes\nReadable: %d characters\nReduction: %.1f%%\n", v.SourceBytes, v.ReadableChars, v.Ratio*100)
+
+	case []mq.FieldInfo:
+		for _, f := range v {
+			fmt.Printf("%s: %s\n", f.Key, f.Type)
+		}
+
+	case []mq.StructureIssue:
+		if len(v) == 0 {
+			fmt.Println("No structure issues")
+		}
+		for _, issue := range v {
+			fmt.Println(issue)
+		}
+
+	case []*mq.Component:
+		fmt.Printf("Found %d components:\n", len(v))
+		for i, c := range v {
+			fmt.Printf("%d. <%s> (line %d)", i+1, c.Name, c.Line)
+			if c.Text != "" {
+				fmt.Printf(": %s", c.Text)
+			}
+			fmt.Println()
+		}
+
+	case []*mq.Strikethrough:
+		fmt.Printf("Found %d strikethroughs:\n", len(v))
+		for i, st := range v {
+			if st.Section != nil {
+				fmt.Printf("%d. %s (%s, line %d)\n", i+1, st.Text, st.Section.Heading.Text, st.Line)
+			} else {
+				fmt.Printf("%d. %s (line %d)\n", i+1, st.Text, st.Line)
+			}
+		}
+
+	case []mq.Element:
+		fmt.Printf("Found %d elements:\n", len(v))
+		for i, e := range v {
+			fmt.Printf("%d. [%s] line %d: %s\n", i+1, e.Kind(), e.GetLine(), elementLabel(e))
+		}
+
+	case map[string]interface{}:
+		out, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			fmt.Printf("%+v\n", v)
+			break
+		}
+		fmt.Println(string(out))
+
 	case mq.Metadata:
 		fmt.Println("Metadata:")
 		for key, value := range v {
@@ -625,14 +1137,113 @@ func displayResult(result interface{}) {
 			fmt.Printf("%d. %s\n", i+1, s)
 		}
 
+	case []interface{}:
+		if items, more, ok := previewedSlice(v); ok {
+			displayResult(items)
+			fmt.Println(more)
+			break
+		}
+		for i, item := range v {
+			fmt.Printf("%d. %v\n", i+1, item)
+		}
+
 	case *mq.TreeResult:
 		fmt.Print(v.String())
 
+	case *mq.TOCResult:
+		fmt.Print(v.String())
+
 	case *mq.SearchResults:
 		fmt.Print(v.String())
 
+	case *mq.TermFrequencies:
+		fmt.Print(v.String())
+
+	case []*mq.DocumentSymbol:
+		out, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode symbols: %v", err)
+		}
+		fmt.Println(string(out))
+
 	default:
 		fmt.Printf("Result type: %T\n", result)
 		fmt.Printf("Result: %+v\n", result)
 	}
 }
+
+// previewedSlice undoes preview(n) on a typed collection, which yields its
+// first items followed by a "[+k more]" marker: it returns the items as a
+// slice of their own type, so they display like the untruncated
+// collection, and the marker.
+func previewedSlice(v []interface{}) (interface{}, string, bool) {
+	if len(v) < 2 {
+		return nil, "", false
+	}
+	more, ok := v[len(v)-1].(string)
+	if !ok || !strings.HasPrefix(more, "[+") || !strings.HasSuffix(more, " more]") {
+		return nil, "", false
+	}
+	items := v[:len(v)-1]
+	if typed, ok := sameTypeSlice(items); ok {
+		return typed, more, true
+	}
+	if elements, ok := elementSlice(items); ok {
+		return elements, more, true
+	}
+	return nil, "", false
+}
+
+// sameTypeSlice returns items as a slice of their type when they are all
+// pointers or structs of one type.
+func sameTypeSlice(items []interface{}) (interface{}, bool) {
+	typ := reflect.TypeOf(items[0])
+	if typ == nil || (typ.Kind() != reflect.Pointer && typ.Kind() != reflect.Struct) {
+		return nil, false
+	}
+	typed := reflect.MakeSlice(reflect.SliceOf(typ), 0, len(items))
+	for _, item := range items {
+		if reflect.TypeOf(item) != typ {
+			return nil, false
+		}
+		typed = reflect.Append(typed, reflect.ValueOf(item))
+	}
+	return typed.Interface(), true
+}
+
+// elementSlice returns items as []mq.Element when every item is one, as
+// in a preview of .elements.
+func elementSlice(items []interface{}) ([]mq.Element, bool) {
+	elements := make([]mq.Element, 0, len(items))
+	for _, item := range items {
+		e, ok := item.(mq.Element)
+		if !ok {
+			return nil, false
+		}
+		elements = append(elements, e)
+	}
+	return elements, true
+}
+
+// elementLabel returns a one-line description of an element.
+func elementLabel(e mq.Element) string {
+	switch v := e.(type) {
+	case *mq.Heading:
+		return fmt.Sprintf("H%d %s", v.Level, v.Text)
+	case *mq.CodeBlock:
+		lang := v.Language
+		if lang == "" {
+			lang = "plain"
+		}
+		return fmt.Sprintf("%s (%d lines)", lang, v.GetLines())
+	case *mq.Link:
+		return fmt.Sprintf("%s -> %s", v.Text, v.URL)
+	case *mq.Image:
+		return fmt.Sprintf("%s: %s", v.AltText, v.URL)
+	case *mq.Table:
+		return fmt.Sprintf("%d columns, %d rows", len(v.Headers), len(v.Rows))
+	case *mq.List:
+		return fmt.Sprintf("%d items", len(v.Items))
+	}
+	return ""
+}
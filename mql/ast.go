@@ -23,6 +23,7 @@ type Visitor interface {
 	VisitIdentifier(*IdentifierNode) (interface{}, error)
 	VisitIndex(*IndexNode) (interface{}, error)
 	VisitSlice(*SliceNode) (interface{}, error)
+	VisitArray(*ArrayNode) (interface{}, error)
 }
 
 // PipeNode represents a pipe operation (|).
@@ -135,6 +136,7 @@ const (
 	LiteralNumber
 	LiteralBoolean
 	LiteralNull
+	LiteralRegex // Value is the pattern between the slashes
 )
 
 func (n *LiteralNode) String() string {
@@ -143,6 +145,8 @@ func (n *LiteralNode) String() string {
 		return fmt.Sprintf("%q", n.Value)
 	case LiteralNull:
 		return "null"
+	case LiteralRegex:
+		return fmt.Sprintf("/%s/", n.Value)
 	default:
 		return fmt.Sprintf("%v", n.Value)
 	}
@@ -204,6 +208,23 @@ func (n *SliceNode) Accept(v Visitor) (interface{}, error) {
 	return v.VisitSlice(n)
 }
 
+// ArrayNode represents an array literal (e.g., ["api", "v2"]).
+type ArrayNode struct {
+	Elements []QueryNode
+}
+
+func (n *ArrayNode) String() string {
+	elems := make([]string, len(n.Elements))
+	for i, e := range n.Elements {
+		elems[i] = e.String()
+	}
+	return fmt.Sprintf("[%s]", strings.Join(elems, ", "))
+}
+
+func (n *ArrayNode) Accept(v Visitor) (interface{}, error) {
+	return v.VisitArray(n)
+}
+
 // Helper functions for creating AST nodes
 
 // NewPipe creates a new pipe node.
@@ -255,3 +276,8 @@ func NewIndex(object, index QueryNode) *IndexNode {
 func NewSlice(object, start, end QueryNode) *SliceNode {
 	return &SliceNode{Object: object, Start: start, End: end}
 }
+
+// NewArray creates a new array literal node.
+func NewArray(elements ...QueryNode) *ArrayNode {
+	return &ArrayNode{Elements: elements}
+}
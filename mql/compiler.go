@@ -1,9 +1,17 @@
 package mql
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
+	"math/rand"
+	"net/url"
 	"reflect"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	mq "github.com/muqsitnawaz/mq/lib"
 )
@@ -27,10 +35,21 @@ func NewEvalContext(doc *mq.Document) *EvalContext {
 	}
 }
 
+// NewEvalContextFrom creates an evaluation context that starts from
+// current rather than the document, for running a query on a value fetched
+// earlier. doc backs document selectors such as .section and may be nil
+// when the query only uses properties and functions.
+func NewEvalContextFrom(doc *mq.Document, current interface{}) *EvalContext {
+	ctx := NewEvalContext(doc)
+	ctx.Current = current
+	return ctx
+}
+
 // Compiler compiles query AST to executable plans.
 type Compiler struct {
 	// Options
-	strict bool // Strict type checking
+	strict bool   // Strict type checking
+	seed   *int64 // Fixed seed for sample; nil seeds from the clock
 }
 
 // CompilerOption configures the compiler.
@@ -56,6 +75,14 @@ func WithStrictMode() CompilerOption {
 	}
 }
 
+// WithSeed makes sample deterministic: every execution of a plan draws
+// from a random source seeded with seed.
+func WithSeed(seed int64) CompilerOption {
+	return func(c *Compiler) {
+		c.seed = &seed
+	}
+}
+
 // Compile compiles an AST node to an execution plan.
 func (c *Compiler) Compile(node QueryNode) ExecutionPlan {
 	return func(ctx *EvalContext) (interface{}, error) {
@@ -81,6 +108,7 @@ func (c *Compiler) CompileString(query string) (ExecutionPlan, error) {
 type compilerVisitor struct {
 	compiler *Compiler
 	context  *EvalContext
+	rng      *rand.Rand // Created on first use by sample
 }
 
 // SetContext sets the evaluation context.
@@ -114,6 +142,11 @@ func (v *compilerVisitor) VisitPipe(node *PipeNode) (interface{}, error) {
 
 // VisitSelector compiles a selector operation.
 func (v *compilerVisitor) VisitSelector(node *SelectorNode) (interface{}, error) {
+	// .text("mode") takes precedence over plain text property access
+	if node.Name == "text" && len(node.Args) > 0 {
+		return v.textWithMode(node.Args)
+	}
+
 	// Check if selector is a property accessor on current item
 	if v.context.Current != nil && v.context.Current != v.context.Document {
 		// Try to handle as property access
@@ -144,6 +177,13 @@ func (v *compilerVisitor) VisitSelector(node *SelectorNode) (interface{}, error)
 				return section.GetCodeBlocks(langs...), nil
 			}
 		}
+
+		// .head(n) and .tail(n) take a line count relative to the section
+		if node.Name == "head" || node.Name == "tail" {
+			if section, ok := v.context.Current.(*mq.Section); ok {
+				return v.sectionLines(section, node)
+			}
+		}
 	}
 
 	// Get the document from context
@@ -161,28 +201,48 @@ func (v *compilerVisitor) VisitSelector(node *SelectorNode) (interface{}, error)
 		}
 		args[i] = val
 	}
+	if err := rejectRegexArgs(node.Name, args); err != nil {
+		return nil, err
+	}
 
 	// Execute selector based on name
 	switch node.Name {
 	case "headings":
 		levels := extractIntArgs(args)
+		if re, ok := regexArg(args); ok {
+			return doc.GetHeadingsMatching(re, levels...), nil
+		}
 		return doc.GetHeadings(levels...), nil
 
 	case "section":
 		if len(args) == 0 {
 			return nil, fmt.Errorf("section requires a title argument")
 		}
+		if len(args) > 1 {
+			path := extractStringArgs(args)
+			if len(path) != len(args) {
+				return nil, typeMismatch("section path components must be strings")
+			}
+			section, found := doc.GetSectionByPath(path...)
+			if !found {
+				return nil, fmt.Errorf("%w: %s", ErrSectionNotFound, strings.Join(path, " > "))
+			}
+			return section, nil
+		}
 		title, ok := args[0].(string)
 		if !ok {
-			return nil, fmt.Errorf("section title must be a string")
+			return nil, typeMismatch("section title must be a string")
 		}
-		section, found := doc.GetSection(title)
+		section, found := findSection(doc, title)
 		if !found {
-			return nil, fmt.Errorf("section not found: %s", title)
+			return nil, fmt.Errorf("%w: %s", ErrSectionNotFound, title)
 		}
 		return section, nil
 
 	case "sections":
+		if re, ok := regexArg(args); ok {
+			return doc.GetSectionsMatching(re), nil
+		}
 		return doc.GetSections(), nil
 
 	case "code":
@@ -209,6 +269,12 @@ func (v *compilerVisitor) VisitSelector(node *SelectorNode) (interface{}, error)
 	case "metadata":
 		return doc.Metadata(), nil
 
+	case "data":
+		return doc.Data(), nil
+
+	case "meta", "field":
+		return v.metaField(node.Name, args)
+
 	case "owner":
 		owner, ok := doc.GetOwner()
 		if !ok {
@@ -219,6 +285,9 @@ func (v *compilerVisitor) VisitSelector(node *SelectorNode) (interface{}, error)
 	case "tags":
 		return doc.GetTags(), nil
 
+	case "title":
+		return doc.Title(), nil
+
 	case "priority":
 		priority, _ := doc.GetPriority()
 		return priority, nil
@@ -230,6 +299,12 @@ func (v *compilerVisitor) VisitSelector(node *SelectorNode) (interface{}, error)
 	case "length":
 		return getLength(v.context.Current), nil
 
+	case "domains", "without_alt", "only", "unwrap", "summary", "chunk":
+		return v.valueOp(node.Name, args)
+
+	case "html":
+		return renderHTML(v.context.Current)
+
 	case "select", "filter":
 		// These are treated as filters with predicates
 		if len(node.Args) == 0 {
@@ -257,13 +332,23 @@ func (v *compilerVisitor) VisitSelector(node *SelectorNode) (interface{}, error)
 			}
 		}
 
+		// An optional second argument sets the preview length
+		opts := mq.TreeOptions{}
+		if len(args) > 1 {
+			n, ok := toInt(args[1])
+			if !ok || n <= 0 {
+				return nil, fmt.Errorf("tree preview length must be a positive number")
+			}
+			opts.PreviewLength = n
+		}
+
 		// If current context is a section, build tree for that section
 		if section, ok := v.context.Current.(*mq.Section); ok {
-			return buildSectionTree(section, mode), nil
+			return buildSectionTree(section, mode, opts), nil
 		}
 
 		// Otherwise, build tree for the whole document
-		return doc.BuildTree(mode), nil
+		return doc.BuildTreeWithOptions(mode, opts), nil
 
 	case "search":
 		if len(args) == 0 {
@@ -271,12 +356,168 @@ func (v *compilerVisitor) VisitSelector(node *SelectorNode) (interface{}, error)
 		}
 		query, ok := args[0].(string)
 		if !ok {
-			return nil, fmt.Errorf("search query must be a string")
+			return nil, typeMismatch("search query must be a string")
 		}
 		return doc.Search(query), nil
 
+	case "search_elements":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("search_elements requires a query string")
+		}
+		names := extractStringArgs(args)
+		if len(names) != len(args) {
+			return nil, typeMismatch("search_elements query and kinds must be strings")
+		}
+		opts := mq.SearchOptions{Elements: true}
+		for _, name := range names[1:] {
+			kind := mq.ElementKind(name)
+			if !searchKinds[kind] {
+				return nil, fmt.Errorf("search_elements: unknown kind %q (want heading, code, table, list or prose)", name)
+			}
+			opts.Kinds = append(opts.Kinds, kind)
+		}
+		return doc.SearchWithOptions(names[0], opts), nil
+
+	case "lines":
+		bounds := extractIntArgs(args)
+		if len(bounds) == 0 || len(bounds) != len(args) {
+			return nil, fmt.Errorf("lines requires a start and optional end line number")
+		}
+		if len(bounds) == 1 {
+			return doc.GetLines(bounds[0], bounds[0]), nil
+		}
+		return doc.GetLines(bounds[0], bounds[1]), nil
+
+	case "symbols":
+		if !doc.Format().HasPositions() {
+			return nil, fmt.Errorf("symbols needs line positions, which %s documents do not record", doc.Format())
+		}
+		return doc.Symbols(), nil
+
+	case "elements":
+		return doc.Elements(), nil
+
+	case "strikethrough":
+		return doc.GetStrikethroughs(), nil
+
+	case "components":
+		names := extractStringArgs(args)
+		if len(names) != len(args) {
+			return nil, typeMismatch("components names must be strings")
+		}
+		return doc.GetComponents(names...), nil
+
+	case "listitems", "flatten_lists":
+		return doc.GetListItems(), nil
+
+	case "fields":
+		return doc.Fields(), nil
+
+	case "structure_issues":
+		return doc.ValidateStructure(), nil
+
+	case "lead":
+		return doc.Lead(), nil
+
+	case "card":
+		fields := extractStringArgs(args)
+		if len(fields) != len(args) {
+			return nil, typeMismatch("card fields must be strings")
+		}
+		return doc.Card(fields...), nil
+
+	case "reduction":
+		return doc.SizeReduction(), nil
+
+	case "toc":
+		// .toc("md", n) renders markdown links; .toc(n) a TOCResult
+		format := ""
+		if len(args) > 0 {
+			if f, ok := args[0].(string); ok {
+				if f != "md" {
+					return nil, fmt.Errorf("unsupported toc format: %s", f)
+				}
+				format, args = f, args[1:]
+			}
+		}
+		maxLevel := 0
+		if len(args) > 0 {
+			n, ok := toInt(args[0])
+			if !ok || n < 1 || n > 6 || len(args) > 1 {
+				return nil, fmt.Errorf("toc max level must be between 1 and 6")
+			}
+			maxLevel = n
+		}
+		if format == "md" {
+			return doc.GenerateTOC(maxLevel), nil
+		}
+		if section, ok := v.context.Current.(*mq.Section); ok {
+			return section.TOC(maxLevel), nil
+		}
+		return doc.TOC(maxLevel), nil
+
+	case "depth":
+		depth, ok := 0, len(args) > 0
+		if ok {
+			depth, ok = toInt(args[0])
+		}
+		if !ok || depth < 1 {
+			return nil, fmt.Errorf("depth requires a nesting depth of at least 1, e.g. .depth(2)")
+		}
+		deeper := len(args) > 1 && toBool(args[1])
+		return doc.GetSectionsAtDepth(depth, deeper), nil
+
+	case "between":
+		titles := extractStringArgs(args)
+		if len(titles) != len(args) || len(titles) < 2 || len(titles) > 3 {
+			return nil, fmt.Errorf(`between requires a start and end heading, e.g. .between("A", "B")`)
+		}
+		var opts mq.BetweenOptions
+		if len(titles) == 3 {
+			if titles[2] != "inclusive" {
+				return nil, fmt.Errorf("unknown between mode: %s", titles[2])
+			}
+			opts = mq.BetweenOptions{IncludeStart: true, IncludeEnd: true}
+		}
+		content, err := doc.GetContentBetweenWithOptions(titles[0], titles[1], opts)
+		if errors.Is(err, mq.ErrHeadingNotFound) {
+			return nil, fmt.Errorf("%w: %w", ErrSectionNotFound, err)
+		}
+		return content, err
+
+	case "language":
+		return doc.Language(), nil
+
+	case "context":
+		query, ok := "", len(args) > 0
+		if ok {
+			query, ok = args[0].(string)
+		}
+		if !ok || len(args) > 2 {
+			return nil, fmt.Errorf(`context requires a query string and an optional count, e.g. .context("auth flow", 3)`)
+		}
+		k := 3
+		if len(args) == 2 {
+			n, ok := toInt(args[1])
+			if !ok || n < 1 {
+				return nil, fmt.Errorf("context count must be a positive integer")
+			}
+			k = n
+		}
+		return doc.RelevantSections(query, k), nil
+
+	case "tf":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("tf requires a term")
+		}
+		term, ok := args[0].(string)
+		if !ok {
+			return nil, typeMismatch("tf term must be a string")
+		}
+		return doc.RankByTermFrequency(term), nil
+
 	default:
-		return nil, fmt.Errorf("unknown selector: %s", node.Name)
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSelector, node.Name)
 	}
 }
 
@@ -290,112 +531,98 @@ func (v *compilerVisitor) VisitFilter(node *FilterNode) (interface{}, error) {
 	// Handle different collection types
 	switch data := current.(type) {
 	case []*mq.Heading:
-		return v.filterHeadings(data, node.Predicate, v)
+		return filterSlice(data, node.Predicate, v)
 
 	case []*mq.Section:
-		return v.filterSections(data, node.Predicate, v)
+		return filterSlice(data, node.Predicate, v)
 
 	case []*mq.CodeBlock:
-		return v.filterCodeBlocks(data, node.Predicate, v)
+		return filterSlice(data, node.Predicate, v)
 
 	case []*mq.Link:
-		return v.filterLinks(data, node.Predicate, v)
+		return filterSlice(data, node.Predicate, v)
 
-	default:
-		return nil, fmt.Errorf("cannot filter type: %T", current)
-	}
-}
+	case []*mq.Image:
+		return filterSlice(data, node.Predicate, v)
 
-// filterHeadings filters headings based on predicate.
-func (c *compilerVisitor) filterHeadings(headings []*mq.Heading, predicate QueryNode, v *compilerVisitor) ([]*mq.Heading, error) {
-	var result []*mq.Heading
+	case []*mq.Component:
+		return filterSlice(data, node.Predicate, v)
 
-	for _, heading := range headings {
-		// Set current item for predicate evaluation
-		oldCurrent := v.context.Current
-		v.context.Current = heading
+	case []mq.Element:
+		return filterSlice(data, node.Predicate, v)
 
-		// Evaluate predicate
-		match, err := predicate.Accept(v)
-		if err != nil {
-			return nil, err
-		}
+	case []mq.FlatListItem:
+		return filterSlice(data, node.Predicate, v)
 
-		// Restore context
-		v.context.Current = oldCurrent
+	case []KeyCount:
+		return filterSlice(data, node.Predicate, v)
 
-		// Check if predicate matched
-		if toBool(match) {
-			result = append(result, heading)
-		}
-	}
+	case []mq.StructureIssue:
+		return filterSlice(data, node.Predicate, v)
 
-	return result, nil
-}
+	case []mq.TOCEntry:
+		return filterSlice(data, node.Predicate, v)
 
-// filterSections filters sections based on predicate.
-func (c *compilerVisitor) filterSections(sections []*mq.Section, predicate QueryNode, v *compilerVisitor) ([]*mq.Section, error) {
-	var result []*mq.Section
+	case []interface{}:
+		return filterSlice(data, node.Predicate, v)
 
-	for _, section := range sections {
-		oldCurrent := v.context.Current
-		v.context.Current = section
+	case mq.Metadata:
+		return filterMap(map[string]interface{}(data), node.Predicate, v)
 
-		match, err := predicate.Accept(v)
-		if err != nil {
-			return nil, err
-		}
+	case map[string]interface{}:
+		return filterMap(data, node.Predicate, v)
 
-		v.context.Current = oldCurrent
+	case map[interface{}]interface{}:
+		return filterMap(data, node.Predicate, v)
 
-		if toBool(match) {
-			result = append(result, section)
-		}
+	default:
+		return nil, typeMismatch("cannot filter type: %T", current)
 	}
-
-	return result, nil
 }
 
-// filterCodeBlocks filters code blocks based on predicate.
-func (c *compilerVisitor) filterCodeBlocks(blocks []*mq.CodeBlock, predicate QueryNode, v *compilerVisitor) ([]*mq.CodeBlock, error) {
-	var result []*mq.CodeBlock
+// filterSlice keeps the items for which the predicate is true, evaluating
+// the predicate with each item as the current value.
+func filterSlice[T any](items []T, predicate QueryNode, v *compilerVisitor) ([]T, error) {
+	result := []T{}
 
-	for _, block := range blocks {
+	for _, item := range items {
+		// Set current item for predicate evaluation
 		oldCurrent := v.context.Current
-		v.context.Current = block
+		v.context.Current = item
 
 		match, err := predicate.Accept(v)
+
+		// Restore context
+		v.context.Current = oldCurrent
 		if err != nil {
 			return nil, err
 		}
 
-		v.context.Current = oldCurrent
-
 		if toBool(match) {
-			result = append(result, block)
+			result = append(result, item)
 		}
 	}
 
 	return result, nil
 }
 
-// filterLinks filters links based on predicate.
-func (c *compilerVisitor) filterLinks(links []*mq.Link, predicate QueryNode, v *compilerVisitor) ([]*mq.Link, error) {
-	var result []*mq.Link
+// filterMap keeps the entries of an object whose value matches the predicate.
+func filterMap[K comparable](obj map[K]interface{}, predicate QueryNode, v *compilerVisitor) (map[K]interface{}, error) {
+	result := make(map[K]interface{})
 
-	for _, link := range links {
+	for key, value := range obj {
 		oldCurrent := v.context.Current
-		v.context.Current = link
+		v.context.Current = value
 
 		match, err := predicate.Accept(v)
+
+		v.context.Current = oldCurrent
 		if err != nil {
 			return nil, err
 		}
 
-		v.context.Current = oldCurrent
-
 		if toBool(match) {
-			result = append(result, link)
+			result[key] = value
 		}
 	}
 
@@ -404,6 +631,41 @@ func (c *compilerVisitor) filterLinks(links []*mq.Link, predicate QueryNode, v *
 
 // VisitFunction compiles a function call.
 func (v *compilerVisitor) VisitFunction(node *FunctionNode) (interface{}, error) {
+	// map, sort_by, count_by, distinct_by and reduce evaluate their arguments
+	// per element, so they must not be evaluated against the whole collection
+	// first
+	switch node.Name {
+	case "map":
+		if len(node.Args) != 1 {
+			return nil, fmt.Errorf("map requires 1 argument")
+		}
+		return v.mapOperation(node.Args[0])
+
+	case "sort_by":
+		if len(node.Args) != 1 {
+			return nil, fmt.Errorf("sort_by requires 1 argument")
+		}
+		return v.sortBy(node.Args[0])
+
+	case "count_by":
+		if len(node.Args) != 1 {
+			return nil, fmt.Errorf("count_by requires 1 argument")
+		}
+		return v.countBy(node.Args[0])
+
+	case "distinct_by":
+		if len(node.Args) != 1 {
+			return nil, fmt.Errorf("distinct_by requires 1 argument")
+		}
+		return v.distinctBy(node.Args[0])
+
+	case "reduce":
+		if len(node.Args) != 2 {
+			return nil, fmt.Errorf("reduce requires an initial value and an update: reduce(init; expr)")
+		}
+		return v.reduce(node.Args[0], node.Args[1])
+	}
+
 	// Evaluate arguments
 	args := make([]interface{}, len(node.Args))
 	for i, arg := range node.Args {
@@ -413,15 +675,12 @@ func (v *compilerVisitor) VisitFunction(node *FunctionNode) (interface{}, error)
 		}
 		args[i] = val
 	}
+	if err := rejectRegexArgs(node.Name, args); err != nil {
+		return nil, err
+	}
 
 	// Execute function
 	switch node.Name {
-	case "map":
-		if len(node.Args) != 1 {
-			return nil, fmt.Errorf("map requires 1 argument")
-		}
-		return v.mapOperation(node.Args[0])
-
 	case "contains":
 		if len(args) != 1 {
 			return nil, fmt.Errorf("contains requires 1 argument")
@@ -440,14 +699,131 @@ func (v *compilerVisitor) VisitFunction(node *FunctionNode) (interface{}, error)
 		}
 		return endsWith(v.context.Current, args[0])
 
+	case "contains_any", "contains_all":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%s requires at least 1 argument", node.Name)
+		}
+		return containsValues(v.context.Current, flattenArgs(args), node.Name == "contains_all")
+
 	case "length":
 		return getLength(v.context.Current), nil
 
+	case "domains", "without_alt", "only", "unwrap", "summary", "chunk":
+		return v.valueOp(node.Name, args)
+
+	case "flatten":
+		return flattenValues(v.context.Current)
+
+	case "tree_text":
+		headings, err := headingList(v.context.Current, node.Name)
+		if err != nil {
+			return nil, err
+		}
+		return mq.HeadingOutline(headings), nil
+
+	case "outline_json":
+		headings, err := headingList(v.context.Current, node.Name)
+		if err != nil {
+			return nil, err
+		}
+		out, err := json.Marshal(mq.HeadingTree(headings))
+		if err != nil {
+			return nil, fmt.Errorf("encoding outline: %w", err)
+		}
+		return string(out), nil
+
+	case "empty":
+		return isEmpty(v.context.Current), nil
+
+	case "nonempty":
+		return !isEmpty(v.context.Current), nil
+
+	case "default":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("default requires 1 argument")
+		}
+		if isEmpty(v.context.Current) {
+			return args[0], nil
+		}
+		return v.context.Current, nil
+
+	case "preview":
+		n := 100
+		if len(args) > 0 {
+			limits := extractIntArgs(args)
+			if len(limits) != 1 || limits[0] < 0 {
+				return nil, fmt.Errorf("preview requires a non-negative length")
+			}
+			n = limits[0]
+		}
+		return previewValue(v.context.Current, n), nil
+
+	case "sample":
+		limits := extractIntArgs(args)
+		if len(args) != 1 || len(limits) != 1 || limits[0] < 0 {
+			return nil, fmt.Errorf("sample requires a non-negative count")
+		}
+		return sampleValues(v.context.Current, limits[0], v.random())
+
+	case "meta", "field", "path":
+		return v.metaField(node.Name, args)
+
 	default:
 		return nil, fmt.Errorf("unknown function: %s", node.Name)
 	}
 }
 
+// valueOp runs the operations that behave the same whether they are written
+// as a selector (.summary) or as a function call (summary).
+func (v *compilerVisitor) valueOp(name string, args []interface{}) (interface{}, error) {
+	switch name {
+	case "domains":
+		if doc, ok := v.context.Current.(*mq.Document); ok {
+			return linkDomains(doc.GetLinks()), nil
+		}
+		return linkDomains(v.context.Current), nil
+
+	case "without_alt":
+		return imagesWithoutAlt(v.context.Current)
+
+	case "only", "unwrap":
+		return onlyElement(v.context.Current)
+
+	case "summary":
+		return summaryOf(v.context.Current, args)
+
+	case "chunk":
+		return chunkOf(v.context.Current, args)
+
+	default:
+		return nil, fmt.Errorf("unknown function: %s", name)
+	}
+}
+
+// metaField looks up a frontmatter field by name or dotted path, with
+// optional array indices (e.g. "authors[0].name"). Unlike plain selectors it
+// never resolves to structural data, so fields named "sections" or "code"
+// stay reachable.
+func (v *compilerVisitor) metaField(name string, args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%s requires a field name", name)
+	}
+	key, ok := args[0].(string)
+	if !ok {
+		return nil, typeMismatch("%s field name must be a string", name)
+	}
+	doc := v.context.Document
+	if doc == nil {
+		return nil, fmt.Errorf("no document in context")
+	}
+
+	val, found := doc.GetNestedField(key)
+	if !found && v.compiler.strict {
+		return nil, fmt.Errorf("metadata field not found: %s", key)
+	}
+	return val, nil
+}
+
 // VisitBinary compiles a binary operation.
 func (v *compilerVisitor) VisitBinary(node *BinaryNode) (interface{}, error) {
 	// Evaluate left operand
@@ -473,6 +849,9 @@ func (v *compilerVisitor) VisitBinary(node *BinaryNode) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := rejectRegexArgs(node.Operator, []interface{}{left, right}); err != nil {
+		return nil, err
+	}
 
 	// Execute operation
 	switch node.Operator {
@@ -492,6 +871,8 @@ func (v *compilerVisitor) VisitBinary(node *BinaryNode) (interface{}, error) {
 		return toBool(left) && toBool(right), nil
 	case "or":
 		return toBool(left) || toBool(right), nil
+	case "+", "-", "*":
+		return arithmetic(node.Operator, left, right)
 	default:
 		return nil, fmt.Errorf("unknown operator: %s", node.Operator)
 	}
@@ -516,6 +897,14 @@ func (v *compilerVisitor) VisitUnary(node *UnaryNode) (interface{}, error) {
 
 // VisitLiteral compiles a literal value.
 func (v *compilerVisitor) VisitLiteral(node *LiteralNode) (interface{}, error) {
+	if node.Type == LiteralRegex {
+		pattern, _ := node.Value.(string)
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex /%s/: %w", pattern, err)
+		}
+		return re, nil
+	}
 	return node.Value, nil
 }
 
@@ -526,6 +915,28 @@ func (v *compilerVisitor) VisitIdentifier(node *IdentifierNode) (interface{}, er
 		return val, nil
 	}
 
+	// Bare '.' is the current value unless bound by reduce
+	if node.Name == "." {
+		return v.context.Current, nil
+	}
+
+	// Bare empty/nonempty/length/domains/without_alt/only/unwrap/flatten/
+	// tree_text/outline_json/summary act as zero-argument functions unless
+	// the current object has a field with that name
+	switch node.Name {
+	case "empty", "nonempty", "length", "domains", "without_alt", "only", "unwrap", "flatten", "tree_text", "outline_json", "summary":
+		if _, ok := mq.LookupKey(v.context.Current, node.Name); !ok {
+			return v.VisitFunction(NewFunction(node.Name))
+		}
+	}
+
+	// Missing object fields are nil unless strict mode is enabled
+	if v.compiler.strict && isObject(v.context.Current) {
+		if _, ok := mq.LookupKey(v.context.Current, node.Name); !ok {
+			return nil, fmt.Errorf("object has no field: %s", node.Name)
+		}
+	}
+
 	// Access property on current object
 	return getProperty(v.context.Current, node.Name)
 }
@@ -576,9 +987,32 @@ func (v *compilerVisitor) VisitSlice(node *SliceNode) (interface{}, error) {
 	return getSlice(obj, start, end)
 }
 
+// VisitArray compiles an array literal.
+func (v *compilerVisitor) VisitArray(node *ArrayNode) (interface{}, error) {
+	values := make([]interface{}, len(node.Elements))
+	for i, elem := range node.Elements {
+		val, err := elem.Accept(v)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = val
+	}
+	return values, nil
+}
+
 // Helper functions for property access
 
 func getProperty(obj interface{}, name string) (interface{}, error) {
+	// Every structural element exposes its kind and line
+	if e, ok := obj.(mq.Element); ok {
+		switch name {
+		case "kind":
+			return string(e.Kind()), nil
+		case "line":
+			return e.GetLine(), nil
+		}
+	}
+
 	switch v := obj.(type) {
 	case *mq.Heading:
 		switch name {
@@ -598,10 +1032,30 @@ func getProperty(obj interface{}, name string) (interface{}, error) {
 			return v.Heading, nil
 		case "text":
 			return v.GetText(), nil
+		case "body":
+			return v.GetTextWithOptions(mq.SectionTextOptions{BodyOnly: true}), nil
+		case "prose":
+			return v.GetProseText(), nil
+		case "lead":
+			return v.Lead(), nil
 		case "start":
 			return v.Start, nil
 		case "end":
 			return v.End, nil
+		case "path":
+			return v.PathString(" > "), nil
+		case "children":
+			return v.Children, nil
+		case "siblings":
+			return v.Siblings(), nil
+		case "ancestors":
+			return v.Ancestors(), nil
+		case "next":
+			return sectionOrNil(v.Next()), nil
+		case "prev":
+			return sectionOrNil(v.Prev()), nil
+		case "has_code", "has_tables", "has_images", "codecount", "tablecount", "imagecount":
+			return sectionElementProperty(v, name), nil
 		default:
 			return nil, fmt.Errorf("section has no property: %s", name)
 		}
@@ -614,6 +1068,8 @@ func getProperty(obj interface{}, name string) (interface{}, error) {
 			return v.Content, nil
 		case "lines":
 			return v.GetLines(), nil
+		case "contentlines":
+			return v.ContentLines(), nil
 		default:
 			return nil, fmt.Errorf("code block has no property: %s", name)
 		}
@@ -624,48 +1080,232 @@ func getProperty(obj interface{}, name string) (interface{}, error) {
 			return v.Text, nil
 		case "url":
 			return v.URL, nil
+		case "auto":
+			return v.Auto, nil
 		default:
 			return nil, fmt.Errorf("link has no property: %s", name)
 		}
 
-	default:
-		return nil, fmt.Errorf("cannot access property %s on type %T", name, obj)
-	}
-}
+	case *mq.Image:
+		if val, ok := imageProperty(v, name); ok {
+			return val, nil
+		}
+		return nil, fmt.Errorf("image has no property: %s", name)
 
-// Helper functions for type conversion and comparison
+	case *mq.Strikethrough:
+		switch name {
+		case "text":
+			return v.Text, nil
+		case "section":
+			return strikethroughSection(v), nil
+		case "line":
+			return v.Line, nil
+		default:
+			return nil, fmt.Errorf("strikethrough has no property: %s", name)
+		}
 
-func extractIntArgs(args []interface{}) []int {
-	var result []int
-	for _, arg := range args {
-		switch v := arg.(type) {
-		case int:
-			result = append(result, v)
-		case int64:
-			result = append(result, int(v))
-		case float64:
-			result = append(result, int(v))
+	case *mq.Component:
+		if val, ok := componentProperty(v, name); ok {
+			return val, nil
 		}
-	}
-	return result
-}
+		return nil, fmt.Errorf("component has no property: %s", name)
 
-func extractStringArgs(args []interface{}) []string {
-	var result []string
-	for _, arg := range args {
-		if s, ok := arg.(string); ok {
-			result = append(result, s)
+	case *mq.List:
+		if val, ok := listProperty(v, name); ok {
+			return val, nil
 		}
-	}
-	return result
-}
+		return nil, fmt.Errorf("list has no property: %s", name)
 
-func toBool(v interface{}) bool {
-	if v == nil {
-		return false
-	}
+	case mq.ListItem:
+		if val, ok := listItemProperty(v, name); ok {
+			return val, nil
+		}
+		return nil, fmt.Errorf("list item has no property: %s", name)
 
-	switch val := v.(type) {
+	case mq.FlatListItem:
+		if val, ok := flatListItemProperty(v, name); ok {
+			return val, nil
+		}
+		return nil, fmt.Errorf("list item has no property: %s", name)
+
+	case mq.SizeReport:
+		if val, ok := sizeReportProperty(v, name); ok {
+			return val, nil
+		}
+		return nil, fmt.Errorf("reduction has no property: %s", name)
+
+	case KeyCount:
+		switch name {
+		case "key":
+			return v.Key, nil
+		case "count":
+			return v.Count, nil
+		}
+		return nil, fmt.Errorf("count_by row has no property: %s", name)
+
+	case mq.StructureIssue:
+		if val, ok := structureIssueProperty(v, name); ok {
+			return val, nil
+		}
+		return nil, fmt.Errorf("structure issue has no property: %s", name)
+
+	case *mq.TOCResult, mq.TOCEntry:
+		if val, ok := tocProperty(v, name); ok {
+			return val, nil
+		}
+		return nil, fmt.Errorf("table of contents has no property: %s", name)
+
+	case mq.Metadata, map[string]interface{}, map[interface{}]interface{}:
+		val, _ := mq.LookupKey(v, name)
+		return val, nil
+
+	default:
+		return nil, typeMismatch("cannot access property %s on type %T", name, obj)
+	}
+}
+
+// sectionOrNil returns s as an interface value, or an untyped nil for a nil
+// section so that results like .next at the last section compare as null.
+func sectionOrNil(s *mq.Section) interface{} {
+	if s == nil {
+		return nil
+	}
+	return s
+}
+
+// componentProperty returns a property of a component. Attributes are
+// returned as a map so that .attributes | .type reads one.
+func componentProperty(c *mq.Component, property string) (interface{}, bool) {
+	switch property {
+	case "name":
+		return c.Name, true
+	case "attributes":
+		attrs := make(map[string]interface{}, len(c.Attributes))
+		for k, v := range c.Attributes {
+			attrs[k] = v
+		}
+		return attrs, true
+	case "text":
+		return c.Text, true
+	case "content":
+		return c.Content, true
+	case "section":
+		if c.Section == nil {
+			return "", true
+		}
+		return c.Section.Heading.Text, true
+	case "line":
+		return c.Line, true
+	case "self_closing":
+		return c.SelfClosing, true
+	}
+	return nil, false
+}
+
+// strikethroughSection returns the heading of the section enclosing a
+// strikethrough, or "" when it precedes the first heading.
+func strikethroughSection(st *mq.Strikethrough) string {
+	if st.Section == nil {
+		return ""
+	}
+	return st.Section.Heading.Text
+}
+
+// sectionElementProperty reports element presence or counts for a section,
+// including its subsections.
+func sectionElementProperty(s *mq.Section, name string) interface{} {
+	switch name {
+	case "has_code":
+		return len(s.GetCodeBlocks()) > 0
+	case "has_tables":
+		return len(s.GetTables()) > 0
+	case "has_images":
+		return len(s.GetImages()) > 0
+	case "codecount":
+		return len(s.GetCodeBlocks())
+	case "tablecount":
+		return len(s.GetTables())
+	case "imagecount":
+		return len(s.GetImages())
+	}
+	return nil
+}
+
+// isObject reports whether obj is a decoded JSON/YAML object.
+func isObject(obj interface{}) bool {
+	switch obj.(type) {
+	case mq.Metadata, map[string]interface{}, map[interface{}]interface{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Helper functions for type conversion and comparison
+
+// regexOps are the operations that take a /regex/ argument.
+var regexOps = map[string]bool{"headings": true, "sections": true}
+
+// searchKinds are the element kinds search_elements reports matches in.
+var searchKinds = map[mq.ElementKind]bool{
+	mq.ElementHeading: true, mq.ElementCode: true, mq.ElementTable: true,
+	mq.ElementList: true, mq.ElementProse: true,
+}
+
+// rejectRegexArgs returns a type mismatch if op, which does not take a
+// regex, is given one, rather than letting it compare against the
+// pattern's text.
+func rejectRegexArgs(op string, args []interface{}) error {
+	if regexOps[op] {
+		return nil
+	}
+	if re, ok := regexArg(args); ok {
+		return typeMismatch("%s does not take a regex (/%s/); only .headings and .sections match regexes", op, re)
+	}
+	return nil
+}
+
+// regexArg returns the first regex literal among args.
+func regexArg(args []interface{}) (*regexp.Regexp, bool) {
+	for _, arg := range args {
+		if re, ok := arg.(*regexp.Regexp); ok {
+			return re, true
+		}
+	}
+	return nil, false
+}
+
+func extractIntArgs(args []interface{}) []int {
+	var result []int
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case int:
+			result = append(result, v)
+		case int64:
+			result = append(result, int(v))
+		case float64:
+			result = append(result, int(v))
+		}
+	}
+	return result
+}
+
+func extractStringArgs(args []interface{}) []string {
+	var result []string
+	for _, arg := range args {
+		if s, ok := arg.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func toBool(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+
+	switch val := v.(type) {
 	case bool:
 		return val
 	case int, int64:
@@ -694,6 +1334,11 @@ func equals(a, b interface{}) bool {
 		return na == nb
 	}
 
+	// Dates compare by instant, whatever format each side is written in
+	if ta, tb, ok := toTimes(a, b); ok {
+		return ta.Equal(tb)
+	}
+
 	// Fall back to DeepEqual for other types
 	return reflect.DeepEqual(a, b)
 }
@@ -707,6 +1352,10 @@ func lessThan(a, b interface{}) (bool, error) {
 		return na < nb, nil
 	}
 
+	if ta, tb, ok := toTimes(a, b); ok {
+		return ta.Before(tb), nil
+	}
+
 	// String comparison
 	switch va := a.(type) {
 	case string:
@@ -715,7 +1364,18 @@ func lessThan(a, b interface{}) (bool, error) {
 		}
 	}
 
-	return false, fmt.Errorf("cannot compare %T and %T", a, b)
+	return false, typeMismatch("cannot compare %T and %T", a, b)
+}
+
+// toInteger reports the value of an integer operand (int or int64).
+func toInteger(v interface{}) (int64, bool) {
+	switch val := v.(type) {
+	case int:
+		return int64(val), true
+	case int64:
+		return val, true
+	}
+	return 0, false
 }
 
 // toNumber converts various numeric types to float64 for comparison
@@ -729,10 +1389,47 @@ func toNumber(v interface{}) (float64, bool) {
 		return val, true
 	case float32:
 		return float64(val), true
+	case int32:
+		return float64(val), true
+	case uint64:
+		return float64(val), true
 	}
 	return 0, false
 }
 
+// dateFormats are the layouts accepted when comparing dates, tried in order.
+// Values without a zone are taken as UTC.
+var dateFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// toTime converts a time.Time or a string in one of dateFormats to a time.
+func toTime(v interface{}) (time.Time, bool) {
+	switch val := v.(type) {
+	case time.Time:
+		return val, true
+	case string:
+		s := strings.TrimSpace(val)
+		for _, layout := range dateFormats {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// toTimes converts both operands to times, reporting whether both are dates.
+func toTimes(a, b interface{}) (time.Time, time.Time, bool) {
+	ta, aOK := toTime(a)
+	tb, bOK := toTime(b)
+	return ta, tb, aOK && bOK
+}
+
 func lessEqual(a, b interface{}) (bool, error) {
 	lt, err := lessThan(a, b)
 	if err != nil {
@@ -766,7 +1463,7 @@ func negate(v interface{}) (interface{}, error) {
 	case float64:
 		return -val, nil
 	default:
-		return nil, fmt.Errorf("cannot negate %T", v)
+		return nil, typeMismatch("cannot negate %T", v)
 	}
 }
 
@@ -776,6 +1473,217 @@ func contains(obj, search interface{}) (bool, error) {
 	return strings.Contains(objStr, searchStr), nil
 }
 
+// containsValues checks the current collection (or string) against values.
+// With all set every value must be present, otherwise any one suffices.
+func containsValues(obj interface{}, values []interface{}, all bool) (bool, error) {
+	var items []interface{}
+	switch v := obj.(type) {
+	case []interface{}:
+		items = v
+	case []string:
+		for _, s := range v {
+			items = append(items, s)
+		}
+	case string:
+		for _, val := range values {
+			found := strings.Contains(v, fmt.Sprintf("%v", val))
+			if found != all {
+				return found, nil
+			}
+		}
+		return all, nil
+	case nil:
+		return false, nil
+	default:
+		return false, typeMismatch("cannot check membership on type %T", obj)
+	}
+
+	for _, val := range values {
+		found := false
+		for _, item := range items {
+			if equals(item, val) {
+				found = true
+				break
+			}
+		}
+		if found != all {
+			return found, nil
+		}
+	}
+	return all, nil
+}
+
+// flattenArgs expands array arguments so f(["a", "b"]) and f("a", "b")
+// are equivalent.
+func flattenArgs(args []interface{}) []interface{} {
+	var values []interface{}
+	for _, arg := range args {
+		if arr, ok := arg.([]interface{}); ok {
+			values = append(values, arr...)
+		} else {
+			values = append(values, arg)
+		}
+	}
+	return values
+}
+
+// previewValue shortens long values for display: strings (and elements
+// rendered as text) are cut to n characters, collections to n items
+// followed by a "[+k more]" marker.
+func previewValue(obj interface{}, n int) interface{} {
+	switch v := obj.(type) {
+	case string:
+		return mq.TruncateText(v, n)
+	case []string:
+		if len(v) <= n {
+			return v
+		}
+		return append(append([]string{}, v[:n]...), fmt.Sprintf("[+%d more]", len(v)-n))
+	case *mq.Section, *mq.CodeBlock, *mq.Heading:
+		if text, ok := extractTextFromAny(v).(string); ok {
+			return mq.TruncateText(text, n)
+		}
+		return v
+	}
+
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() != reflect.Slice || rv.Len() <= n {
+		return obj
+	}
+	items := make([]interface{}, 0, n+1)
+	for i := 0; i < n; i++ {
+		items = append(items, rv.Index(i).Interface())
+	}
+	return append(items, fmt.Sprintf("[+%d more]", rv.Len()-n))
+}
+
+// chunkOf applies chunk(n) to obj, splitting its text into pieces of at
+// most n characters (see mq.ChunkText). Collections are joined into one
+// text first (see chunkText), except code blocks, which are chunked one by
+// one as fenced blocks labeled with their language.
+func chunkOf(obj interface{}, args []interface{}) (interface{}, error) {
+	sizes := extractIntArgs(args)
+	if len(sizes) != 1 || len(args) != 1 || sizes[0] <= 0 {
+		return nil, fmt.Errorf("chunk requires a positive size in characters, e.g. .chunk(4000)")
+	}
+	n := sizes[0]
+
+	var blocks []*mq.CodeBlock
+	switch v := obj.(type) {
+	case *mq.CodeBlock:
+		blocks = []*mq.CodeBlock{v}
+	case []*mq.CodeBlock:
+		blocks = v
+	default:
+		text, err := chunkText(obj)
+		if err != nil {
+			return nil, err
+		}
+		return mq.ChunkText(text, n), nil
+	}
+
+	chunks := []string{}
+	for _, cb := range blocks {
+		pieces := cb.Chunks(n)
+		if pieces == nil {
+			return nil, fmt.Errorf("chunk size %d leaves no room for %q code inside its fence", n, cb.Language)
+		}
+		chunks = append(chunks, pieces...)
+	}
+	return chunks, nil
+}
+
+// chunkableTypes are the element types of collections chunk(n) accepts;
+// the elements of an []interface{} are checked one by one.
+var chunkableTypes = map[reflect.Type]bool{
+	reflect.TypeOf(""):                         true,
+	reflect.TypeOf((*mq.Section)(nil)):         true,
+	reflect.TypeOf((*mq.CodeBlock)(nil)):       true,
+	reflect.TypeOf((*mq.Document)(nil)):        true,
+	reflect.TypeOf((*interface{})(nil)).Elem(): true,
+}
+
+// chunkText returns the text chunk(n) splits: that of a string, section,
+// code block or document, or of a collection of those joined by blank
+// lines. Sections in a collection contribute only their own text, so a
+// section listed with its subsections is not repeated.
+func chunkText(obj interface{}) (string, error) {
+	switch v := obj.(type) {
+	case string:
+		return v, nil
+	case *mq.Section:
+		return v.GetText(), nil
+	case *mq.CodeBlock:
+		return v.Content, nil
+	case *mq.Document:
+		return v.GetTextContent(), nil
+	}
+
+	rv := reflect.ValueOf(obj)
+	if obj == nil || rv.Kind() != reflect.Slice || !chunkableTypes[rv.Type().Elem()] {
+		return "", typeMismatch("cannot chunk %T", obj)
+	}
+	texts := make([]string, rv.Len())
+	for i := range texts {
+		item := rv.Index(i).Interface()
+		if s, ok := item.(*mq.Section); ok {
+			texts[i] = strings.TrimRight(s.OwnText(), "\n")
+			continue
+		}
+		if reflect.ValueOf(item).Kind() == reflect.Slice {
+			return "", typeMismatch("cannot chunk nested collections")
+		}
+		text, err := chunkText(item)
+		if err != nil {
+			return "", err
+		}
+		texts[i] = text
+	}
+	return strings.Join(texts, "\n\n"), nil
+}
+
+// summaryOf applies summary(n) to obj: sections, code blocks, documents
+// and strings become a sentence-aware summary of at most n characters
+// (default 100), and collections a []string of their elements' summaries.
+func summaryOf(obj interface{}, args []interface{}) (interface{}, error) {
+	n := 100
+	if len(args) > 0 {
+		budgets := extractIntArgs(args)
+		if len(budgets) != 1 || budgets[0] <= 0 {
+			return nil, fmt.Errorf("summary requires a positive character budget")
+		}
+		n = budgets[0]
+	}
+
+	summarize := func(item interface{}) (string, error) {
+		switch v := item.(type) {
+		case string:
+			return mq.Summarize(v, n), nil
+		case *mq.Section:
+			return v.Summary(n), nil
+		case *mq.CodeBlock:
+			return mq.Summarize(v.Content, n), nil
+		case *mq.Document:
+			return v.Summary(n), nil
+		}
+		return "", typeMismatch("cannot summarize %T", item)
+	}
+
+	rv := reflect.ValueOf(obj)
+	if obj == nil || rv.Kind() != reflect.Slice {
+		return summarize(obj)
+	}
+	summaries := make([]string, rv.Len())
+	for i := range summaries {
+		s, err := summarize(rv.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		summaries[i] = s
+	}
+	return summaries, nil
+}
+
 func startsWith(obj, prefix interface{}) (bool, error) {
 	objStr := fmt.Sprintf("%v", obj)
 	prefixStr := fmt.Sprintf("%v", prefix)
@@ -788,10 +1696,217 @@ func endsWith(obj, suffix interface{}) (bool, error) {
 	return strings.HasSuffix(objStr, suffixStr), nil
 }
 
+// imagesWithoutAlt returns the images of a document, section or image
+// collection whose alt text is empty or whitespace, as an accessibility
+// check.
+func imagesWithoutAlt(obj interface{}) ([]*mq.Image, error) {
+	var images []*mq.Image
+	switch v := obj.(type) {
+	case *mq.Document:
+		images = v.GetImages()
+	case *mq.Section:
+		images = v.GetImages()
+	case []*mq.Image:
+		images = v
+	case *mq.Image:
+		images = []*mq.Image{v}
+	default:
+		return nil, typeMismatch("without_alt requires images, got %T", obj)
+	}
+
+	missing := []*mq.Image{}
+	for _, img := range images {
+		if strings.TrimSpace(img.AltText) == "" {
+			missing = append(missing, img)
+		}
+	}
+	return missing, nil
+}
+
+// linkDomains returns the sorted, distinct hosts of the absolute URLs in
+// links, URL strings, or collections of either. Relative and malformed
+// URLs are skipped; subdomains are kept as-is.
+func linkDomains(obj interface{}) []string {
+	var urls []string
+	var collect func(interface{})
+	collect = func(obj interface{}) {
+		switch v := obj.(type) {
+		case *mq.Link:
+			urls = append(urls, v.URL)
+		case *mq.Image:
+			urls = append(urls, v.URL)
+		case string:
+			urls = append(urls, v)
+		case []*mq.Link:
+			for _, l := range v {
+				urls = append(urls, l.URL)
+			}
+		case []*mq.Image:
+			for _, img := range v {
+				urls = append(urls, img.URL)
+			}
+		case []string:
+			urls = append(urls, v...)
+		case []interface{}:
+			for _, item := range v {
+				collect(item)
+			}
+		}
+	}
+	collect(obj)
+
+	seen := make(map[string]bool)
+	domains := []string{}
+	for _, raw := range urls {
+		u, err := url.Parse(strings.TrimSpace(raw))
+		if err != nil || u.Scheme == "" || u.Hostname() == "" {
+			continue
+		}
+		host := strings.ToLower(u.Hostname())
+		if !seen[host] {
+			seen[host] = true
+			domains = append(domains, host)
+		}
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// isEmpty reports whether v is nil, an empty string or an empty collection.
+// These are the falsy values of toBool other than false and 0, which are
+// meaningful values rather than missing ones.
+func isEmpty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	switch v.(type) {
+	case bool, int, int64, float64:
+		return false
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.String:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// sectionLines evaluates .head(n) or .tail(n) on a section. n defaults to 10.
+func (v *compilerVisitor) sectionLines(section *mq.Section, node *SelectorNode) (interface{}, error) {
+	n := 10
+	if len(node.Args) > 0 {
+		arg, err := node.Args[0].Accept(v)
+		if err != nil {
+			return nil, err
+		}
+		counts := extractIntArgs([]interface{}{arg})
+		if len(node.Args) != 1 || len(counts) != 1 || counts[0] < 0 {
+			return nil, fmt.Errorf("%s requires a non-negative line count", node.Name)
+		}
+		n = counts[0]
+	}
+	if node.Name == "head" {
+		return section.Head(n), nil
+	}
+	return section.Tail(n), nil
+}
+
+// textWithMode handles .text("with-meta"), which prepends the document's
+// frontmatter as "Key: value" lines to the text of the document or section.
+func (v *compilerVisitor) textWithMode(argNodes []QueryNode) (interface{}, error) {
+	if len(argNodes) != 1 {
+		return nil, fmt.Errorf("text accepts at most 1 argument")
+	}
+	arg, err := argNodes[0].Accept(v)
+	if err != nil {
+		return nil, err
+	}
+	if mode, _ := arg.(string); mode != "with-meta" {
+		return nil, fmt.Errorf("unknown text mode: %v (expected \"with-meta\")", arg)
+	}
+
+	doc := v.context.Document
+	if doc == nil {
+		return nil, fmt.Errorf("no document in context")
+	}
+	switch current := v.context.Current.(type) {
+	case *mq.Document:
+		return current.GetTextContentWithMetadata(), nil
+	case *mq.Section:
+		if meta := doc.MetadataText(); meta != "" {
+			return meta + "\n" + current.GetText(), nil
+		}
+		return current.GetText(), nil
+	default:
+		return nil, typeMismatch("text(\"with-meta\") requires a document or section, got %T", current)
+	}
+}
+
+// htmlRenderer is implemented by the element types that render to HTML.
+type htmlRenderer interface {
+	HTML() string
+}
+
+// renderHTML renders a result as an HTML fragment. Collections render each
+// element in order and strings become escaped paragraphs.
+func renderHTML(obj interface{}) (interface{}, error) {
+	switch v := obj.(type) {
+	case nil:
+		return "", nil
+	case htmlRenderer:
+		return v.HTML(), nil
+	case string:
+		if v == "" {
+			return "", nil
+		}
+		return "<p>" + html.EscapeString(v) + "</p>\n", nil
+	}
+
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() != reflect.Slice {
+		return nil, typeMismatch("cannot render %T as HTML", obj)
+	}
+	var b strings.Builder
+	for i := 0; i < rv.Len(); i++ {
+		part, err := renderHTML(rv.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(part.(string))
+	}
+	return b.String(), nil
+}
+
+// onlyElement returns the sole element of a single-element collection. It
+// errors when the collection is empty or has more than one element, so
+// queries that expect exactly one match fail loudly instead of guessing.
+func onlyElement(obj interface{}) (interface{}, error) {
+	if obj == nil {
+		return nil, fmt.Errorf("only: expected exactly one element, got none")
+	}
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, typeMismatch("only requires a collection, got %T", obj)
+	}
+	switch rv.Len() {
+	case 0:
+		return nil, fmt.Errorf("only: expected exactly one element, got none")
+	case 1:
+		return rv.Index(0).Interface(), nil
+	default:
+		return nil, fmt.Errorf("only: expected exactly one element, got %d", rv.Len())
+	}
+}
+
 func getLength(obj interface{}) int {
 	if obj == nil {
 		return 0
 	}
+	if toc, ok := obj.(*mq.TOCResult); ok {
+		return len(toc.Entries)
+	}
 
 	rv := reflect.ValueOf(obj)
 	switch rv.Kind() {
@@ -804,6 +1919,8 @@ func getLength(obj interface{}) int {
 
 func extractText(obj interface{}) string {
 	switch v := obj.(type) {
+	case *mq.Document:
+		return v.GetTextContent()
 	case *mq.Heading:
 		return v.Text
 	case *mq.Section:
@@ -812,6 +1929,8 @@ func extractText(obj interface{}) string {
 		return v.Content
 	case *mq.Link:
 		return v.Text
+	case *mq.Component:
+		return v.Text
 	case string:
 		return v
 	default:
@@ -838,13 +1957,59 @@ func (v *compilerVisitor) handleCollectionPropertyAccess(property string) (inter
 				results[i] = section.GetText()
 			}
 			return results, true
+		case "body":
+			results := make([]string, len(items))
+			for i, section := range items {
+				results[i] = section.GetTextWithOptions(mq.SectionTextOptions{BodyOnly: true})
+			}
+			return results, true
+		case "prose":
+			results := make([]string, len(items))
+			for i, section := range items {
+				results[i] = section.GetProseText()
+			}
+			return results, true
+		case "lead":
+			results := make([]string, len(items))
+			for i, section := range items {
+				results[i] = section.Lead()
+			}
+			return results, true
+		case "path":
+			results := make([]string, len(items))
+			for i, section := range items {
+				results[i] = section.PathString(" > ")
+			}
+			return results, true
 		}
 	case []*mq.Heading:
 		// Already handled by extractTextFromAny for .text
 		// Add other properties if needed
 	case []*mq.CodeBlock:
-		// Already handled by extractTextFromAny for .text
-		// Add other properties if needed
+		// .text is handled by extractTextFromAny
+		if property == "language" {
+			results := make([]string, len(items))
+			for i, cb := range items {
+				results[i] = cb.Language
+			}
+			return results, true
+		}
+	case []*mq.Strikethrough:
+		if property == "section" {
+			results := make([]string, len(items))
+			for i, st := range items {
+				results[i] = strikethroughSection(st)
+			}
+			return results, true
+		}
+	case []*mq.Component:
+		if property == "name" {
+			results := make([]string, len(items))
+			for i, c := range items {
+				results[i] = c.Name
+			}
+			return results, true
+		}
 	}
 
 	return nil, false
@@ -854,6 +2019,15 @@ func (v *compilerVisitor) handleCollectionPropertyAccess(property string) (inter
 func (v *compilerVisitor) handlePropertyAccess(property string) (interface{}, bool) {
 	current := v.context.Current
 
+	if e, ok := current.(mq.Element); ok {
+		switch property {
+		case "kind":
+			return string(e.Kind()), true
+		case "line":
+			return e.GetLine(), true
+		}
+	}
+
 	switch item := current.(type) {
 	case *mq.Heading:
 		switch property {
@@ -869,14 +2043,32 @@ func (v *compilerVisitor) handlePropertyAccess(property string) (interface{}, bo
 		switch property {
 		case "text":
 			return item.GetText(), true
+		case "body":
+			return item.GetTextWithOptions(mq.SectionTextOptions{BodyOnly: true}), true
+		case "prose":
+			return item.GetProseText(), true
+		case "lead":
+			return item.Lead(), true
 		case "heading":
 			return item.Heading, true
 		case "children":
 			return item.Children, true
+		case "siblings":
+			return item.Siblings(), true
+		case "ancestors":
+			return item.Ancestors(), true
+		case "next":
+			return sectionOrNil(item.Next()), true
+		case "prev":
+			return sectionOrNil(item.Prev()), true
 		case "start":
 			return item.Start, true
 		case "end":
 			return item.End, true
+		case "path":
+			return item.PathString(" > "), true
+		case "has_code", "has_tables", "has_images", "codecount", "tablecount", "imagecount":
+			return sectionElementProperty(item, property), true
 			// Note: "code" is handled specially in VisitSelector to support arguments
 		}
 
@@ -888,6 +2080,8 @@ func (v *compilerVisitor) handlePropertyAccess(property string) (interface{}, bo
 			return item.Language, true
 		case "lines":
 			return item.GetLines(), true
+		case "contentlines":
+			return item.ContentLines(), true
 		}
 
 	case *mq.Link:
@@ -896,22 +2090,73 @@ func (v *compilerVisitor) handlePropertyAccess(property string) (interface{}, bo
 			return item.Text, true
 		case "url":
 			return item.URL, true
+		case "auto":
+			return item.Auto, true
 		}
 
 	case *mq.Image:
+		return imageProperty(item, property)
+
+	case *mq.Strikethrough:
 		switch property {
-		case "text", "alttext", "alt":
-			return item.AltText, true
-		case "url":
-			return item.URL, true
+		case "text":
+			return item.Text, true
+		case "section":
+			return strikethroughSection(item), true
+		case "line":
+			return item.Line, true
 		}
 
+	case *mq.Component:
+		return componentProperty(item, property)
+
 	case *mq.Table:
 		switch property {
 		case "headers":
 			return item.Headers, true
 		case "rows":
 			return item.Rows, true
+		case "records":
+			records := make([]interface{}, 0, len(item.Rows))
+			for _, record := range item.Records() {
+				m := make(map[string]interface{}, len(record))
+				for k, cell := range record {
+					m[k] = cell
+				}
+				records = append(records, m)
+			}
+			return records, true
+		}
+
+	case *mq.List:
+		return listProperty(item, property)
+
+	case mq.ListItem:
+		return listItemProperty(item, property)
+
+	case mq.FlatListItem:
+		return flatListItemProperty(item, property)
+
+	case mq.SizeReport:
+		return sizeReportProperty(item, property)
+
+	case KeyCount:
+		switch property {
+		case "key":
+			return item.Key, true
+		case "count":
+			return item.Count, true
+		}
+
+	case mq.StructureIssue:
+		return structureIssueProperty(item, property)
+
+	case *mq.TOCResult, mq.TOCEntry:
+		return tocProperty(item, property)
+
+	case mq.Metadata, map[string]interface{}, map[interface{}]interface{}:
+		if val, ok := mq.LookupKey(item, property); ok {
+			return val, true
 		}
 	}
 
@@ -919,6 +2164,143 @@ func (v *compilerVisitor) handlePropertyAccess(property string) (interface{}, bo
 	return nil, false
 }
 
+// imageProperty returns a property of an image. Width and height are 0
+// when the source does not declare them.
+func imageProperty(img *mq.Image, name string) (interface{}, bool) {
+	switch name {
+	case "text", "alttext", "alt":
+		return img.AltText, true
+	case "url":
+		return img.URL, true
+	case "title":
+		return img.Title, true
+	case "width":
+		return img.Width, true
+	case "height":
+		return img.Height, true
+	case "format":
+		return img.Format, true
+	case "section":
+		if img.Section == nil {
+			return "", true
+		}
+		return img.Section.Heading.Text, true
+	}
+	return nil, false
+}
+
+// tocProperty returns a property of a table of contents or one of its
+// entries.
+func tocProperty(obj interface{}, name string) (interface{}, bool) {
+	switch v := obj.(type) {
+	case *mq.TOCResult:
+		switch name {
+		case "entries":
+			return v.Entries, true
+		case "lines":
+			return v.Lines(), true
+		}
+	case mq.TOCEntry:
+		switch name {
+		case "text":
+			return v.Text, true
+		case "level":
+			return v.Level, true
+		case "line":
+			return v.Line, true
+		}
+	}
+	return nil, false
+}
+
+// structureIssueProperty returns a property of a structure issue.
+func structureIssueProperty(issue mq.StructureIssue, name string) (interface{}, bool) {
+	switch name {
+	case "rule":
+		return string(issue.Rule), true
+	case "heading", "text":
+		return issue.Heading, true
+	case "level":
+		return issue.Level, true
+	case "line":
+		return issue.Line, true
+	case "message":
+		return issue.Message, true
+	}
+	return nil, false
+}
+
+// listProperty returns a property of a list.
+func listProperty(l *mq.List, name string) (interface{}, bool) {
+	switch name {
+	case "items":
+		return l.Items, true
+	case "ordered":
+		return l.Ordered, true
+	case "start":
+		return l.Start, true
+	case "loose":
+		return l.Loose, true
+	}
+	return nil, false
+}
+
+// listItemProperty returns a property of a list item. An item that is not
+// a task has a null checked property.
+func listItemProperty(item mq.ListItem, name string) (interface{}, bool) {
+	switch name {
+	case "text":
+		return item.Text, true
+	case "depth":
+		return item.Depth, true
+	case "ordered":
+		return item.Ordered, true
+	case "checked":
+		return checkedValue(item.Checked), true
+	case "children":
+		return item.Children, true
+	}
+	return nil, false
+}
+
+// flatListItemProperty returns a property of an item from .listitems.
+func flatListItemProperty(item mq.FlatListItem, name string) (interface{}, bool) {
+	switch name {
+	case "text":
+		return item.Text, true
+	case "depth":
+		return item.Depth, true
+	case "ordered":
+		return item.Ordered, true
+	case "number":
+		return item.Number, true
+	case "checked":
+		return checkedValue(item.Checked), true
+	}
+	return nil, false
+}
+
+// sizeReportProperty returns a field of a .reduction report.
+func sizeReportProperty(r mq.SizeReport, name string) (interface{}, bool) {
+	switch name {
+	case "source_bytes":
+		return r.SourceBytes, true
+	case "readable_chars":
+		return r.ReadableChars, true
+	case "ratio":
+		return r.Ratio, true
+	}
+	return nil, false
+}
+
+// checkedValue returns a task item's state, or nil for a plain item.
+func checkedValue(checked *bool) interface{} {
+	if checked == nil {
+		return nil
+	}
+	return *checked
+}
+
 // mapOperation applies a transformation to each element in a collection
 func (v *compilerVisitor) mapOperation(transform QueryNode) (interface{}, error) {
 	current := v.context.Current
@@ -1003,6 +2385,20 @@ func (v *compilerVisitor) mapOperation(transform QueryNode) (interface{}, error)
 		}
 		return results, nil
 
+	case []mq.Element:
+		results := make([]interface{}, len(data))
+		for i, item := range data {
+			oldCurrent := v.context.Current
+			v.context.Current = item
+			result, err := transform.Accept(v)
+			if err != nil {
+				return nil, err
+			}
+			results[i] = result
+			v.context.Current = oldCurrent
+		}
+		return results, nil
+
 	case []interface{}:
 		results := make([]interface{}, len(data))
 		for i, item := range data {
@@ -1018,8 +2414,326 @@ func (v *compilerVisitor) mapOperation(transform QueryNode) (interface{}, error)
 		return results, nil
 
 	default:
-		return nil, fmt.Errorf("map can only be applied to collections, got %T", current)
+		// Other element slices, such as list items
+		rv := reflect.ValueOf(current)
+		if rv.Kind() != reflect.Slice {
+			return nil, typeMismatch("map can only be applied to collections, got %T", current)
+		}
+		results := make([]interface{}, rv.Len())
+		for i := range results {
+			oldCurrent := v.context.Current
+			v.context.Current = rv.Index(i).Interface()
+			result, err := transform.Accept(v)
+			if err != nil {
+				return nil, err
+			}
+			results[i] = result
+			v.context.Current = oldCurrent
+		}
+		return results, nil
+	}
+}
+
+// reduce folds a collection into a single value. init is evaluated once
+// against the collection; update is evaluated per element with '.' bound
+// to the accumulator and selectors such as .lines applied to the element.
+func (v *compilerVisitor) reduce(init, update QueryNode) (interface{}, error) {
+	current := v.context.Current
+	rv := reflect.ValueOf(current)
+	if current == nil || rv.Kind() != reflect.Slice {
+		return nil, typeMismatch("reduce can only be applied to collections, got %T", current)
+	}
+
+	acc, err := init.Accept(v)
+	if err != nil {
+		return nil, err
+	}
+
+	prev, hadPrev := v.context.Variables["."]
+	defer func() {
+		v.context.Current = current
+		if hadPrev {
+			v.context.Variables["."] = prev
+		} else {
+			delete(v.context.Variables, ".")
+		}
+	}()
+
+	for i := 0; i < rv.Len(); i++ {
+		v.context.Variables["."] = acc
+		v.context.Current = rv.Index(i).Interface()
+		acc, err = update.Accept(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return acc, nil
+}
+
+// arithmetic applies +, - or * to numbers; + also concatenates strings and
+// collections. Integer operands give an integer result.
+func arithmetic(op string, left, right interface{}) (interface{}, error) {
+	li, lint := toInteger(left)
+	ri, rint := toInteger(right)
+	if lint && rint {
+		switch op {
+		case "+":
+			return int(li + ri), nil
+		case "-":
+			return int(li - ri), nil
+		case "*":
+			return int(li * ri), nil
+		}
+	}
+
+	ln, lok := toNumber(left)
+	rn, rok := toNumber(right)
+	if lok && rok {
+		switch op {
+		case "+":
+			return ln + rn, nil
+		case "-":
+			return ln - rn, nil
+		case "*":
+			return ln * rn, nil
+		}
+	}
+
+	if op == "+" {
+		ls, lstr := left.(string)
+		rs, rstr := right.(string)
+		if lstr && rstr {
+			return ls + rs, nil
+		}
+		lv, rv := reflect.ValueOf(left), reflect.ValueOf(right)
+		if lv.Kind() == reflect.Slice && rv.Kind() == reflect.Slice {
+			result := make([]interface{}, 0, lv.Len()+rv.Len())
+			for _, side := range []reflect.Value{lv, rv} {
+				for i := 0; i < side.Len(); i++ {
+					result = append(result, side.Index(i).Interface())
+				}
+			}
+			return result, nil
+		}
+	}
+
+	return nil, typeMismatch("cannot apply %s to %T and %T", op, left, right)
+}
+
+// sortBy orders a collection by the value of key evaluated against each
+// element, keeping the collection's type. Ties keep their original order
+// and elements whose key is nil sort last.
+func (v *compilerVisitor) sortBy(key QueryNode) (interface{}, error) {
+	current := v.context.Current
+	rv := reflect.ValueOf(current)
+	if current == nil || rv.Kind() != reflect.Slice {
+		return nil, typeMismatch("sort_by can only be applied to collections, got %T", current)
+	}
+
+	n := rv.Len()
+	keys := make([]interface{}, n)
+	order := make([]int, n)
+	oldCurrent := v.context.Current
+	for i := 0; i < n; i++ {
+		v.context.Current = rv.Index(i).Interface()
+		k, err := key.Accept(v)
+		if err != nil {
+			v.context.Current = oldCurrent
+			return nil, err
+		}
+		keys[i] = k
+		order[i] = i
+	}
+	v.context.Current = oldCurrent
+
+	var sortErr error
+	sort.SliceStable(order, func(a, b int) bool {
+		ka, kb := keys[order[a]], keys[order[b]]
+		if ka == nil || kb == nil {
+			return ka != nil
+		}
+		less, err := lessThan(ka, kb)
+		if err != nil && sortErr == nil {
+			sortErr = err
+		}
+		return less
+	})
+	if sortErr != nil {
+		return nil, sortErr
+	}
+
+	sorted := reflect.MakeSlice(rv.Type(), n, n)
+	for i, idx := range order {
+		sorted.Index(i).Set(rv.Index(idx))
+	}
+	return sorted.Interface(), nil
+}
+
+// random returns the visitor's random source, seeded from the compiler's
+// seed if one was set.
+func (v *compilerVisitor) random() *rand.Rand {
+	if v.rng == nil {
+		seed := time.Now().UnixNano()
+		if v.compiler.seed != nil {
+			seed = *v.compiler.seed
+		}
+		v.rng = rand.New(rand.NewSource(seed))
+	}
+	return v.rng
+}
+
+// sampleValues picks up to n elements of a collection uniformly at random
+// using reservoir sampling, returning a slice of the same type.
+func sampleValues(current interface{}, n int, rng *rand.Rand) (interface{}, error) {
+	rv := reflect.ValueOf(current)
+	if current == nil || rv.Kind() != reflect.Slice {
+		return nil, typeMismatch("sample can only be applied to collections, got %T", current)
+	}
+
+	reservoir := reflect.MakeSlice(rv.Type(), 0, min(n, rv.Len()))
+	for i := 0; i < rv.Len(); i++ {
+		if i < n {
+			reservoir = reflect.Append(reservoir, rv.Index(i))
+		} else if j := rng.Intn(i + 1); j < n {
+			reservoir.Index(j).Set(rv.Index(i))
+		}
+	}
+	return reservoir.Interface(), nil
+}
+
+// KeyCount is one row of a count_by frequency table.
+type KeyCount struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// countBy counts the elements of a collection by the string form of key
+// evaluated on each element. Rows are ordered by descending count, then by
+// key, so results are deterministic.
+func (v *compilerVisitor) countBy(key QueryNode) (interface{}, error) {
+	current := v.context.Current
+	rv := reflect.ValueOf(current)
+	if current == nil || rv.Kind() != reflect.Slice {
+		return nil, typeMismatch("count_by can only be applied to collections, got %T", current)
+	}
+
+	counts := make(map[string]int)
+	oldCurrent := v.context.Current
+	defer func() { v.context.Current = oldCurrent }()
+	for i := 0; i < rv.Len(); i++ {
+		v.context.Current = rv.Index(i).Interface()
+		k, err := key.Accept(v)
+		if err != nil {
+			return nil, err
+		}
+		counts[groupKey(k)]++
+	}
+
+	rows := make([]KeyCount, 0, len(counts))
+	for k, n := range counts {
+		rows = append(rows, KeyCount{Key: k, Count: n})
+	}
+	sort.Slice(rows, func(a, b int) bool {
+		if rows[a].Count != rows[b].Count {
+			return rows[a].Count > rows[b].Count
+		}
+		return rows[a].Key < rows[b].Key
+	})
+	return rows, nil
+}
+
+// distinctBy keeps the first element of a collection for each distinct
+// value of key, preserving order and the collection's type.
+func (v *compilerVisitor) distinctBy(key QueryNode) (interface{}, error) {
+	current := v.context.Current
+	rv := reflect.ValueOf(current)
+	if current == nil || rv.Kind() != reflect.Slice {
+		return nil, typeMismatch("distinct_by can only be applied to collections, got %T", current)
+	}
+
+	seen := make(map[string]bool)
+	distinct := reflect.MakeSlice(rv.Type(), 0, rv.Len())
+	oldCurrent := v.context.Current
+	defer func() { v.context.Current = oldCurrent }()
+	for i := 0; i < rv.Len(); i++ {
+		v.context.Current = rv.Index(i).Interface()
+		k, err := key.Accept(v)
+		if err != nil {
+			return nil, err
+		}
+		if g := groupKey(k); !seen[g] {
+			seen[g] = true
+			distinct = reflect.Append(distinct, rv.Index(i))
+		}
 	}
+	return distinct.Interface(), nil
+}
+
+// flattenValues concatenates the elements of a collection of collections,
+// one level deep; elements that are not collections are kept as they are.
+func flattenValues(current interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(current)
+	if current == nil || rv.Kind() != reflect.Slice {
+		return nil, typeMismatch("flatten can only be applied to collections, got %T", current)
+	}
+
+	flat := []interface{}{}
+	for i := 0; i < rv.Len(); i++ {
+		elem := reflect.ValueOf(rv.Index(i).Interface())
+		if elem.Kind() != reflect.Slice {
+			flat = append(flat, rv.Index(i).Interface())
+			continue
+		}
+		for j := 0; j < elem.Len(); j++ {
+			flat = append(flat, elem.Index(j).Interface())
+		}
+	}
+	return flat, nil
+}
+
+// findSection resolves a section title, or a "#anchor" by heading ID. An
+// anchor that matches no ID is still tried as a title, for headings whose
+// text starts with "#".
+func findSection(doc *mq.Document, title string) (*mq.Section, bool) {
+	if id, ok := strings.CutPrefix(title, "#"); ok && id != "" {
+		if section, found := doc.GetSectionByID(id); found {
+			return section, true
+		}
+	}
+	return doc.GetSection(title)
+}
+
+// headingList returns the headings a heading function such as tree_text
+// applies to: a heading collection, a single heading, or a document's
+// headings.
+func headingList(current interface{}, fn string) ([]*mq.Heading, error) {
+	switch c := current.(type) {
+	case *mq.Document:
+		return c.GetHeadings(), nil
+	case *mq.Heading:
+		return []*mq.Heading{c}, nil
+	case []*mq.Heading:
+		return c, nil
+	case []interface{}:
+		headings := make([]*mq.Heading, 0, len(c))
+		for _, item := range c {
+			h, ok := item.(*mq.Heading)
+			if !ok {
+				return nil, typeMismatch("%s can only be applied to headings, got %T", fn, item)
+			}
+			headings = append(headings, h)
+		}
+		return headings, nil
+	}
+	return nil, typeMismatch("%s can only be applied to headings, got %T", fn, current)
+}
+
+// groupKey formats a key for count_by and distinct_by, with nil as "null".
+func groupKey(k interface{}) string {
+	if k == nil {
+		return "null"
+	}
+	return fmt.Sprint(k)
 }
 
 func extractTextFromAny(obj interface{}) interface{} {
@@ -1055,6 +2769,18 @@ func extractTextFromAny(obj interface{}) interface{} {
 			results[i] = img.AltText
 		}
 		return results
+	case []*mq.Strikethrough:
+		results := make([]string, len(v))
+		for i, st := range v {
+			results[i] = st.Text
+		}
+		return results
+	case []*mq.Component:
+		results := make([]string, len(v))
+		for i, c := range v {
+			results[i] = c.Text
+		}
+		return results
 	case []interface{}:
 		results := make([]string, len(v))
 		for i, item := range v {
@@ -1077,7 +2803,7 @@ func getIndex(obj, index interface{}) (interface{}, error) {
 			if i64, ok := index.(int64); ok {
 				idx = int(i64)
 			} else {
-				return nil, fmt.Errorf("array index must be integer")
+				return nil, typeMismatch("array index must be integer")
 			}
 		}
 
@@ -1096,7 +2822,7 @@ func getIndex(obj, index interface{}) (interface{}, error) {
 		return value.Interface(), nil
 
 	default:
-		return nil, fmt.Errorf("cannot index type %T", obj)
+		return nil, typeMismatch("cannot index type %T", obj)
 	}
 }
 
@@ -1104,7 +2830,7 @@ func getSlice(obj, start, end interface{}) (interface{}, error) {
 	rv := reflect.ValueOf(obj)
 
 	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
-		return nil, fmt.Errorf("cannot slice type %T", obj)
+		return nil, typeMismatch("cannot slice type %T", obj)
 	}
 
 	length := rv.Len()
@@ -1152,21 +2878,25 @@ func toInt(v interface{}) (int, bool) {
 }
 
 // buildSectionTree builds a tree result for a single section.
-func buildSectionTree(section *mq.Section, mode mq.TreeMode) *mq.TreeResult {
+func buildSectionTree(section *mq.Section, mode mq.TreeMode, opts mq.TreeOptions) *mq.TreeResult {
+	if opts.PreviewLength <= 0 {
+		opts.PreviewLength = mq.DefaultPreviewLength
+	}
+
 	result := &mq.TreeResult{
 		Path:  section.Heading.Text,
 		Lines: section.End - section.Start + 1,
 		Mode:  mode,
 	}
 
-	node := buildSectionNode(section, mode)
+	node := buildSectionNode(section, mode, opts.PreviewLength)
 	result.Root = []*mq.TreeNode{node}
 
 	return result
 }
 
 // buildSectionNode recursively builds tree nodes from a section.
-func buildSectionNode(section *mq.Section, mode mq.TreeMode) *mq.TreeNode {
+func buildSectionNode(section *mq.Section, mode mq.TreeMode, previewLen int) *mq.TreeNode {
 	node := &mq.TreeNode{
 		Type:  "section",
 		Text:  section.Heading.Text,
@@ -1177,12 +2907,12 @@ func buildSectionNode(section *mq.Section, mode mq.TreeMode) *mq.TreeNode {
 
 	// Add preview text for preview/full modes
 	if mode == mq.TreeModePreview || mode == mq.TreeModeFull {
-		node.Preview = mq.ExtractPreview(section.GetText(), 50)
+		node.Preview = mq.ExtractPreview(section.GetText(), previewLen)
 	}
 
 	// Add child sections
 	for _, child := range section.Children {
-		childNode := buildSectionNode(child, mode)
+		childNode := buildSectionNode(child, mode, previewLen)
 		node.Children = append(node.Children, childNode)
 	}
 
@@ -1,6 +1,8 @@
 package mql
 
 import (
+	"io"
+
 	"github.com/muqsitnawaz/mq/data"
 	"github.com/muqsitnawaz/mq/html"
 	mq "github.com/muqsitnawaz/mq/lib"
@@ -12,20 +14,109 @@ type Engine struct {
 	mqEngine    *mq.Engine            // For backwards compatibility
 	multiEngine *mq.MultiFormatEngine // For multi-format support
 	executor    *QueryExecutor
+	maxOutput   int // Maximum JSON size of a streamed result (0 = unlimited)
+}
+
+// EngineOption configures New.
+type EngineOption func(*engineOptions)
+
+type engineOptions struct {
+	profile        bool
+	components     bool
+	truncateFields int
+	limits         mq.Limits
+	queryOpts      []QueryOption
+}
+
+// WithProfiling makes the engine's parsers record mq.ParseStats on every
+// document they parse.
+func WithProfiling() EngineOption {
+	return func(o *engineOptions) {
+		o.profile = true
+	}
+}
+
+// WithComponents makes the engine's markdown parser extract HTML and MDX
+// component blocks, queried with .components.
+func WithComponents() EngineOption {
+	return func(o *engineOptions) {
+		o.components = true
+	}
+}
+
+// WithTruncateFields makes tables from JSON, JSONL and YAML documents
+// shorten cells longer than n characters when rendered with String, as
+// for display. Query results always carry the full cell values.
+func WithTruncateFields(n int) EngineOption {
+	return func(o *engineOptions) {
+		o.truncateFields = n
+	}
+}
+
+// WithLimits makes the engine reject documents with more headings,
+// sections or elements than l allows, and stop writing results whose JSON
+// (see Engine.StreamResult) grows past l.MaxOutputBytes, with an error
+// matching mq.ErrLimitExceeded. Large
+// JSON, JSONL and YAML arrays are rejected before their sections are
+// built. Engines have no limits by default; mq.DefaultLimits suits
+// untrusted input.
+func WithLimits(l mq.Limits) EngineOption {
+	return func(o *engineOptions) {
+		o.limits = l
+	}
+}
+
+// WithQueryOptions configures how the engine executes queries, as
+// NewQueryExecutor does, e.g. WithQueryOptions(WithSampleSeed(42)) for
+// repeatable sample picks from every query the engine runs.
+func WithQueryOptions(opts ...QueryOption) EngineOption {
+	return func(o *engineOptions) {
+		o.queryOpts = append(o.queryOpts, opts...)
+	}
 }
 
 // New creates a new MQL engine with multi-format support.
-func New() *Engine {
+func New(opts ...EngineOption) *Engine {
+	options := &engineOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	var mdOpts []mq.ParserOption
+	var htmlOpts []html.Option
+	var pdfOpts []pdf.Option
+	if options.profile {
+		mdOpts = append(mdOpts, mq.WithProfiling())
+		htmlOpts = append(htmlOpts, html.WithProfiling())
+		pdfOpts = append(pdfOpts, pdf.WithProfiling())
+	}
+	if options.components {
+		mdOpts = append(mdOpts, mq.WithComponents())
+	}
+
+	maxSections := options.limits.MaxSections
 	return &Engine{
 		mqEngine: mq.New(),
 		multiEngine: mq.NewMultiFormatEngine(
-			mq.WithFormatParser(html.NewParser()),
-			mq.WithFormatParser(pdf.NewParser()),
-			mq.WithFormatParser(data.NewJSONParser()),
-			mq.WithFormatParser(data.NewJSONLParser()),
-			mq.WithFormatParser(data.NewYAMLParser()),
+			mq.WithMarkdownParser(mq.NewParser(mdOpts...)),
+			mq.WithFormatParser(html.NewParser(htmlOpts...)),
+			mq.WithFormatParser(pdf.NewParser(pdfOpts...)),
+			mq.WithFormatParser(data.NewJSONParser(
+				data.WithTruncateFields(options.truncateFields),
+				data.WithMaxSections(maxSections),
+			)),
+			mq.WithFormatParser(data.NewJSONLParser(
+				data.WithJSONLTruncateFields(options.truncateFields),
+				data.WithJSONLMaxSections(maxSections),
+			)),
+			mq.WithFormatParser(data.NewYAMLParser(
+				data.WithYAMLTruncateFields(options.truncateFields),
+				data.WithYAMLMaxSections(maxSections),
+			)),
+			mq.WithLimits(options.limits),
 		),
-		executor: NewQueryExecutor(),
+		executor:  NewQueryExecutor(options.queryOpts...),
+		maxOutput: options.limits.MaxOutputBytes,
 	}
 }
 
@@ -47,9 +138,10 @@ func (e *Engine) ParseDocument(content []byte, path string) (*mq.Document, error
 	return e.multiEngine.Parse(content, path)
 }
 
-// Query executes an MQL query string on a document.
+// Query executes an MQL query string on a document, with the engine's
+// query options (see WithQueryOptions).
 func (e *Engine) Query(doc *mq.Document, queryStr string) (interface{}, error) {
-	return ExecuteQuery(doc, queryStr)
+	return e.executor.Execute(doc, queryStr)
 }
 
 // QueryWithExecutor uses the configured executor for caching support.
@@ -57,6 +149,12 @@ func (e *Engine) QueryWithExecutor(doc *mq.Document, queryStr string) (interface
 	return e.executor.Execute(doc, queryStr)
 }
 
+// QueryFrom executes an MQL query starting from a value returned by an
+// earlier query, such as a section (see QueryExecutor.ExecuteFrom).
+func (e *Engine) QueryFrom(current interface{}, queryStr string) (interface{}, error) {
+	return e.executor.ExecuteFrom(current, queryStr)
+}
+
 // From creates a fluent query builder (direct API from mq).
 func (e *Engine) From(doc *mq.Document) *mq.QueryBuilder {
 	return e.multiEngine.From(doc)
@@ -66,3 +164,48 @@ func (e *Engine) From(doc *mq.Document) *mq.QueryBuilder {
 func (e *Engine) GetMQEngine() *mq.Engine {
 	return e.mqEngine
 }
+
+// QueryStream executes an MQL query on a document and writes the result
+// to w as JSON, as the package-level QueryStream does, within the engine's
+// output limit (see StreamResult).
+func (e *Engine) QueryStream(doc *mq.Document, queryStr string, w io.Writer) error {
+	result, err := e.Query(doc, queryStr)
+	if err != nil {
+		return err
+	}
+	return e.StreamResult(result, w)
+}
+
+// StreamResult writes a query result to w as JSON, as the package-level
+// StreamResult does. Once more than the engine's MaxOutputBytes have been
+// written it stops with an *mq.LimitError; the output written so far is
+// left in w. Results are only measured here, so queries whose results are
+// used in Go are not encoded just to be checked.
+func (e *Engine) StreamResult(result interface{}, w io.Writer) error {
+	if e.maxOutput <= 0 {
+		return StreamResult(result, w)
+	}
+	lw := &limitWriter{w: w, max: e.maxOutput}
+	if err := StreamResult(result, lw); err != nil {
+		if lw.n > lw.max {
+			return &mq.LimitError{Limit: "output bytes", Max: lw.max, Actual: lw.n}
+		}
+		return err
+	}
+	return nil
+}
+
+// limitWriter passes writes through to w, failing once more than max bytes
+// arrive.
+type limitWriter struct {
+	w      io.Writer
+	max, n int
+}
+
+func (lw *limitWriter) Write(p []byte) (int, error) {
+	lw.n += len(p)
+	if lw.n > lw.max {
+		return 0, io.ErrShortWrite
+	}
+	return lw.w.Write(p)
+}
@@ -0,0 +1,42 @@
+package mql
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned (wrapped) by query execution. Use errors.Is to tell them
+// apart, e.g. to treat a missing section differently from a bad query.
+var (
+	ErrSectionNotFound = errors.New("section not found")
+	ErrUnknownSelector = errors.New("unknown selector")
+	ErrTypeMismatch    = errors.New("type mismatch")
+)
+
+// ParseError reports a query that could not be lexed or parsed. Use
+// errors.As to recover the position of the problem.
+type ParseError struct {
+	Phase string // "lexer" or "parse"
+	Line  int    // 1-based line of the offending token
+	Col   int    // 1-based column of the offending token
+	Msg   string // Description of the problem
+}
+
+// Error implements the error interface.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s error at line %d, column %d: %s", e.Phase, e.Line, e.Col, e.Msg)
+}
+
+// typeError is an ErrTypeMismatch carrying a specific message.
+type typeError struct {
+	msg string
+}
+
+func (e *typeError) Error() string { return e.msg }
+func (e *typeError) Unwrap() error { return ErrTypeMismatch }
+
+// typeMismatch returns an error matching ErrTypeMismatch whose message is
+// formatted from format and args.
+func typeMismatch(format string, args ...interface{}) error {
+	return &typeError{msg: fmt.Sprintf(format, args...)}
+}
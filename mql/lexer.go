@@ -32,6 +32,11 @@ const (
 	TokenGreaterEqual
 	TokenAnd
 	TokenOr
+	TokenSemicolon
+	TokenPlus
+	TokenMinus
+	TokenStar
+	TokenRegex
 )
 
 // Token represents a lexical token.
@@ -54,6 +59,8 @@ func (t Token) String() string {
 		return "|"
 	case TokenString:
 		return fmt.Sprintf("STRING(%s)", t.Value)
+	case TokenRegex:
+		return fmt.Sprintf("REGEX(%s)", t.Value)
 	case TokenNumber:
 		return fmt.Sprintf("NUMBER(%s)", t.Value)
 	case TokenIdentifier:
@@ -159,6 +166,18 @@ func (l *Lexer) NextToken() (Token, error) {
 		l.advance()
 		return l.makeToken(TokenColon, ":"), nil
 
+	case ';':
+		l.advance()
+		return l.makeToken(TokenSemicolon, ";"), nil
+
+	case '+':
+		l.advance()
+		return l.makeToken(TokenPlus, "+"), nil
+
+	case '*':
+		l.advance()
+		return l.makeToken(TokenStar, "*"), nil
+
 	case '=':
 		l.advance()
 		if l.peek() == '=' {
@@ -208,9 +227,13 @@ func (l *Lexer) NextToken() (Token, error) {
 		if unicode.IsDigit(rune(ch)) {
 			return l.scanNumber()
 		}
-		if ch == '-' && l.pos+1 < len(l.input) && unicode.IsDigit(rune(l.input[l.pos+1])) {
+		if ch == '-' && l.pos+1 < len(l.input) && unicode.IsDigit(rune(l.input[l.pos+1])) && !l.afterOperand() {
 			return l.scanNumber()
 		}
+		if ch == '-' {
+			l.advance()
+			return l.makeToken(TokenMinus, "-"), nil
+		}
 	}
 
 	return Token{}, l.error(fmt.Sprintf("unexpected character '%c'", ch))
@@ -227,6 +250,12 @@ func (l *Lexer) skipWhitespace() {
 			l.pos++
 			l.line++
 			l.col = 1
+		} else if ch == '#' {
+			// Line comment: skip to end of line
+			for l.pos < len(l.input) && l.input[l.pos] != '\n' {
+				l.pos++
+				l.col++
+			}
 		} else {
 			break
 		}
@@ -399,8 +428,8 @@ func (l *Lexer) scanRegex() (Token, error) {
 		}
 
 		if ch == '/' {
-			l.advance()                                          // skip closing '/'
-			return l.makeToken(TokenString, value.String()), nil // Treat regex as string
+			l.advance() // skip closing '/'
+			return l.makeToken(TokenRegex, value.String()), nil
 		}
 
 		if ch == '\n' {
@@ -414,10 +443,27 @@ func (l *Lexer) scanRegex() (Token, error) {
 	return Token{}, l.error("unterminated regex pattern")
 }
 
-// isRegexContext checks if we're in a context where a regex is expected.
+// afterOperand reports whether the last token ends a value, so that a '-'
+// after it is subtraction, as in `.lines -1`, rather than the sign of a
+// negative number, as in `.[-1]` or `> -1`.
+func (l *Lexer) afterOperand() bool {
+	if len(l.tokens) == 0 {
+		return false
+	}
+	switch l.tokens[len(l.tokens)-1].Type {
+	case TokenNumber, TokenString, TokenIdentifier, TokenDot, TokenRParen, TokenRBracket, TokenRBrace, TokenRegex:
+		return true
+	}
+	return false
+}
+
+// isRegexContext checks if we're in a context where a regex is expected:
+// as an argument, e.g. .sections(/^GET /), or after certain identifiers.
+// There is no division operator, so '/' is never ambiguous there.
 func (l *Lexer) isRegexContext() bool {
-	// Look back at previous tokens to determine context
-	// For now, assume regex after certain identifiers
+	if n := len(l.tokens); n > 0 && (l.tokens[n-1].Type == TokenLParen || l.tokens[n-1].Type == TokenComma) {
+		return true
+	}
 	for i := len(l.tokens) - 1; i >= 0; i-- {
 		token := l.tokens[i]
 		if token.Type == TokenIdentifier {
@@ -446,5 +492,5 @@ func (l *Lexer) makeToken(typ TokenType, value string) Token {
 
 // error creates a lexer error with position information.
 func (l *Lexer) error(msg string) error {
-	return fmt.Errorf("lexer error at line %d, column %d: %s", l.line, l.col, msg)
+	return &ParseError{Phase: "lexer", Line: l.line, Col: l.col, Msg: msg}
 }
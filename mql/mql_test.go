@@ -1,8 +1,18 @@
 package mql_test
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/muqsitnawaz/mq/data"
+	"github.com/muqsitnawaz/mq/html"
 	mq "github.com/muqsitnawaz/mq/lib"
 	"github.com/muqsitnawaz/mq/mql"
 )
@@ -86,6 +96,72 @@ func TestLexer(t *testing.T) {
 				mql.TokenEOF,
 			},
 		},
+		{
+			input: "# all python blocks\n.code # trailing note\n| .length",
+			expected: []mql.TokenType{
+				mql.TokenDot,
+				mql.TokenIdentifier,
+				mql.TokenPipe,
+				mql.TokenDot,
+				mql.TokenIdentifier,
+				mql.TokenEOF,
+			},
+		},
+		{
+			input: `.section("C# Notes") # comment`,
+			expected: []mql.TokenType{
+				mql.TokenDot,
+				mql.TokenIdentifier,
+				mql.TokenLParen,
+				mql.TokenString,
+				mql.TokenRParen,
+				mql.TokenEOF,
+			},
+		},
+		{
+			input: `reduce(0; . + .lines * 2 - 1)`,
+			expected: []mql.TokenType{
+				mql.TokenIdentifier,
+				mql.TokenLParen,
+				mql.TokenNumber,
+				mql.TokenSemicolon,
+				mql.TokenDot,
+				mql.TokenPlus,
+				mql.TokenDot,
+				mql.TokenIdentifier,
+				mql.TokenStar,
+				mql.TokenNumber,
+				mql.TokenMinus,
+				mql.TokenNumber,
+				mql.TokenRParen,
+				mql.TokenEOF,
+			},
+		},
+		{
+			input: `.lines -1 > -2`,
+			expected: []mql.TokenType{
+				mql.TokenDot,
+				mql.TokenIdentifier,
+				mql.TokenMinus,
+				mql.TokenNumber,
+				mql.TokenGreaterThan,
+				mql.TokenNumber,
+				mql.TokenEOF,
+			},
+		},
+		{
+			input: `.headings(2, /^v\d/)`,
+			expected: []mql.TokenType{
+				mql.TokenDot,
+				mql.TokenIdentifier,
+				mql.TokenLParen,
+				mql.TokenNumber,
+				mql.TokenComma,
+				mql.TokenRegex,
+				mql.TokenRParen,
+				mql.TokenEOF,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -124,6 +200,11 @@ func TestParser(t *testing.T) {
 		{"", true},
 		{"|", true},
 		{".", true},
+		{".headings |", true},
+		{"  \n\t\n", true},
+		{".section('Test')\n| .code\n| .length\n\n  \n", false},
+		{"| .headings\n| select(.level == 2)", false},
+		{"# heading texts\n.headings\n  | .text  # one per line\n", false},
 	}
 
 	for _, test := range tests {
@@ -137,6 +218,57 @@ func TestParser(t *testing.T) {
 	}
 }
 
+// TestParsePrecedence pins how existing queries group: comparisons bind
+// tighter than "and", "and" tighter than "or", and arithmetic tighter than
+// comparisons.
+func TestParsePrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`.headings | select(.level == 2)`, `.headings | select((level == 2))`},
+		{`.headings | select(.level == 2 and .text != "")`, `.headings | select(((level == 2) and (text != "")))`},
+		{`.headings | select(.level <= 2 and .level >= 1)`, `.headings | select(((level <= 2) and (level >= 1)))`},
+		{`.code | filter(.language == "go" or .language == "rust")`, `.code | select(((language == "go") or (language == "rust")))`},
+		{`.headings | filter(.level == 1 or .level == 2 and .text == "x")`, `.headings | select(((level == 1) or ((level == 2) and (text == "x"))))`},
+		{`.headings | filter((.level == 1 or .level == 2) and .text != "")`, `.headings | select((((level == 1) or (level == 2)) and (text != "")))`},
+		{`.sections | select(has_code == false)`, `.sections | select((has_code == false))`},
+		{`.headings | filter(.level > -1) | length`, `.headings | select((level > -1)) | length`},
+		{`.headings | length() > 0`, `.headings | (length() > 0)`},
+		{`.headings | map(.level * 2 + 1)`, `.headings | map(((level * 2) + 1))`},
+		{`1 + 2 * 3 > 6`, `((1 + (2 * 3)) > 6)`},
+	}
+
+	for _, tt := range tests {
+		node, err := mql.ParseString(tt.input)
+		if err != nil {
+			t.Errorf("Unexpected error for '%s': %v", tt.input, err)
+			continue
+		}
+		if got := node.String(); got != tt.expected {
+			t.Errorf("Query '%s': expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+
+	// In predicates, true, false and null are literals, not selectors
+	for keyword, typ := range map[string]mql.LiteralType{"true": mql.LiteralBoolean, "false": mql.LiteralBoolean, "null": mql.LiteralNull} {
+		input := "select(" + keyword + ")"
+		node, err := mql.ParseString(input)
+		if err != nil {
+			t.Errorf("Unexpected error for '%s': %v", input, err)
+			continue
+		}
+		filter, ok := node.(*mql.FilterNode)
+		if !ok {
+			t.Errorf("Expected '%s' to parse as a filter, got %T", input, node)
+			continue
+		}
+		if lit, ok := filter.Predicate.(*mql.LiteralNode); !ok || lit.Type != typ {
+			t.Errorf("Expected '%s' to parse as a literal, got %T", keyword, filter.Predicate)
+		}
+	}
+}
+
 func TestCompiler(t *testing.T) {
 	// Parse test document
 	engine := mq.New()
@@ -274,6 +406,22 @@ func TestQueryExecution(t *testing.T) {
 			},
 			desc: "get metadata",
 		},
+		{
+			query: "# python and go blocks\n.code('go', 'python')\n| .length\n",
+			validate: func(result interface{}) bool {
+				n, ok := result.(int)
+				return ok && n == 2
+			},
+			desc: "multi-line query with comment",
+		},
+		{
+			query: "\n  | .headings\n  | select(.level == 2)\n  | .text\n",
+			validate: func(result interface{}) bool {
+				texts, ok := result.([]string)
+				return ok && len(texts) == 2
+			},
+			desc: "multi-line query with leading pipes",
+		},
 	}
 
 	for _, test := range tests {
@@ -493,6 +641,21 @@ func TestAdditionalSelectors(t *testing.T) {
 			},
 			desc: "get all lists",
 		},
+		{
+			query: ".lines(7, 9)",
+			validate: func(result interface{}) bool {
+				text, ok := result.(string)
+				return ok && text == "# Test Document\n\n## Section One"
+			},
+			desc: "raw line range",
+		},
+		{
+			query: ".lines(1)",
+			validate: func(result interface{}) bool {
+				return result == "---"
+			},
+			desc: "single line",
+		},
 		{
 			query: ".priority",
 			validate: func(result interface{}) bool {
@@ -610,15 +773,47 @@ This is for testing purposes.
 			},
 			desc: "filter headings with comparison",
 		},
-		// Skipping nested property access for now - needs parser update
-		// {
-		// 	query: `.sections | select(.heading.text == "First Section")`,
-		// 	validate: func(result interface{}) bool {
-		// 		sections, ok := result.([]*mq.Section)
-		// 		return ok && len(sections) == 1 && sections[0].Heading.Text == "First Section"
-		// 	},
-		// 	desc: "filter sections by heading text",
-		// },
+		{
+			query: `.sections | select(.heading.text == "First Section")`,
+			validate: func(result interface{}) bool {
+				sections, ok := result.([]*mq.Section)
+				return ok && len(sections) == 1 && sections[0].Heading.Text == "First Section"
+			},
+			desc: "filter sections by heading text",
+		},
+		{
+			query: `.sections | select(.heading.level == 2 and .heading.text != "API Documentation")`,
+			validate: func(result interface{}) bool {
+				sections, ok := result.([]*mq.Section)
+				return ok && len(sections) == 2 && sections[0].Heading.Text == "First Section" &&
+					sections[1].Heading.Text == "Testing Section"
+			},
+			desc: "filter sections by heading level",
+		},
+		{
+			query: `.sections | select(.heading.level > 2) | map(.heading.text)`,
+			validate: func(result interface{}) bool {
+				texts, ok := result.([]interface{})
+				return ok && len(texts) == 1 && texts[0] == "Subsection"
+			},
+			desc: "map nested heading text",
+		},
+		{
+			query: `.sections | select(.heading.text.length < 11)`,
+			validate: func(result interface{}) bool {
+				sections, ok := result.([]*mq.Section)
+				return ok && len(sections) == 2 && sections[0].Heading.Text == "Main Title" &&
+					sections[1].Heading.Text == "Subsection"
+			},
+			desc: "filter on a property two levels deep",
+		},
+		{
+			query: `.sections | select(.heading.text.length == 15) | .[0] | .heading.text`,
+			validate: func(result interface{}) bool {
+				return result == "Testing Section"
+			},
+			desc: "nested access in a pipeline stage",
+		},
 	}
 
 	for _, test := range tests {
@@ -784,3 +979,2715 @@ go test ./...
 		}
 	}
 }
+
+func TestFilterDataValues(t *testing.T) {
+	docContent := `---
+owner: alice
+users:
+  - name: bob
+    age: 25
+  - name: carol
+    age: 41
+  - name: dave
+    age: 35
+---
+
+# Team
+`
+
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte(docContent), "team.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	result, err := mql.ExecuteQuery(doc, `.metadata | .users | select(.age > 30)`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	users, ok := result.([]interface{})
+	if !ok {
+		t.Fatalf("Expected []interface{}, got %T", result)
+	}
+	if len(users) != 2 {
+		t.Errorf("Expected 2 users older than 30, got %d", len(users))
+	}
+
+	mqlEngine := mql.New()
+	jsonDoc, err := mqlEngine.ParseDocument([]byte(`{"users": [{"name": "bob", "age": 25}, {"name": "carol", "age": 41}]}`), "team.json")
+	if err != nil {
+		t.Fatalf("Failed to parse JSON: %v", err)
+	}
+
+	result, err = mqlEngine.Query(jsonDoc, `.data | .users | select(.name == "carol")`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	users, ok = result.([]interface{})
+	if !ok || len(users) != 1 {
+		t.Errorf("Expected 1 matching JSON user, got %T %v", result, result)
+	}
+}
+
+func TestPropertyAccessOnDataObjects(t *testing.T) {
+	jsonDoc, err := data.NewJSONParser().Parse([]byte(`{
+  "servers": [
+    {"name": "api", "port": 8080, "tls": {"enabled": true}},
+    {"name": "db", "port": 5432},
+    {"name": "cache", "port": 6379, "region": "us-east"}
+  ]
+}`), "config.json")
+	if err != nil {
+		t.Fatalf("Failed to parse JSON: %v", err)
+	}
+
+	yamlDoc, err := data.NewYAMLParser().Parse([]byte(`servers:
+  - name: api
+    port: 8080
+  - name: db
+    port: 5432
+    region: eu-west
+`), "config.yaml")
+	if err != nil {
+		t.Fatalf("Failed to parse YAML: %v", err)
+	}
+
+	tests := []struct {
+		doc      *mq.Document
+		query    string
+		expected int
+		desc     string
+	}{
+		{jsonDoc, `.data | .servers | select(.port > 6000)`, 2, "numeric comparison on JSON objects"},
+		{jsonDoc, `.data | .servers | select(.name == "db")`, 1, "string comparison on JSON objects"},
+		{jsonDoc, `.data | .servers | select(.region == "us-east")`, 1, "missing keys are nil"},
+		{yamlDoc, `.data | .servers | select(.region == "eu-west")`, 1, "YAML objects"},
+	}
+
+	for _, test := range tests {
+		result, err := mql.ExecuteQuery(test.doc, test.query)
+		if err != nil {
+			t.Errorf("Query '%s' (%s) failed: %v", test.query, test.desc, err)
+			continue
+		}
+		items, ok := result.([]interface{})
+		if !ok || len(items) != test.expected {
+			t.Errorf("Query '%s' (%s): expected %d items, got %T %v",
+				test.query, test.desc, test.expected, result, result)
+		}
+	}
+
+	// Strict mode reports missing fields instead of treating them as nil
+	plan, err := mql.NewCompiler(mql.WithStrictMode()).CompileString(`.data | .servers | select(.region == "us-east")`)
+	if err != nil {
+		t.Fatalf("Failed to compile: %v", err)
+	}
+	if _, err := plan(mql.NewEvalContext(jsonDoc)); err == nil {
+		t.Error("Expected strict mode to fail on missing field")
+	}
+}
+
+func TestMetaFieldLookup(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte(`---
+sections: 3
+config:
+  sidebar:
+    position: 2
+release.channel: beta
+---
+
+# Title
+
+## Body
+`), "meta.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+		desc     string
+	}{
+		{`.meta("sections")`, 3, "field shadowed by a selector name"},
+		{`.field("sections")`, 3, "field alias"},
+		{`.meta("config.sidebar.position")`, 2, "dotted path into nested YAML"},
+		{`.meta("release.channel")`, "beta", "literal key containing dots"},
+		{`.meta("missing")`, nil, "missing field is nil"},
+		{`.meta("config.missing.position")`, nil, "broken path is nil"},
+	}
+
+	for _, test := range tests {
+		result, err := mql.ExecuteQuery(doc, test.query)
+		if err != nil {
+			t.Errorf("Query '%s' (%s) failed: %v", test.query, test.desc, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("Query '%s' (%s): expected %v, got %v", test.query, test.desc, test.expected, result)
+		}
+	}
+
+	// .sections still means structural sections
+	result, err := mql.ExecuteQuery(doc, ".sections")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if _, ok := result.([]*mq.Section); !ok {
+		t.Errorf("Expected .sections to return sections, got %T", result)
+	}
+
+	plan, err := mql.NewCompiler(mql.WithStrictMode()).CompileString(`.meta("missing")`)
+	if err != nil {
+		t.Fatalf("Failed to compile: %v", err)
+	}
+	if _, err := plan(mql.NewEvalContext(doc)); err == nil {
+		t.Error("Expected strict mode to fail on missing metadata field")
+	}
+}
+
+func TestPathFunction(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte(`---
+config:
+  sidebar:
+    position: 2
+authors:
+  - name: alice
+  - name: bob
+---
+
+# Title
+`), "path.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`path("config.sidebar.position")`, 2},
+		{`path("authors[1].name")`, "bob"},
+		{`path("authors.0.name")`, "alice"},
+		{`path("authors[-1].name")`, "bob"},
+		{`path("authors[5].name")`, nil},
+		{`path("config.sidebar.position.deeper")`, nil},
+	}
+
+	for _, test := range tests {
+		result, err := mql.ExecuteQuery(doc, test.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", test.query, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("Query '%s': expected %v, got %v", test.query, test.expected, result)
+		}
+	}
+}
+
+func TestSectionElementPredicates(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte(`# API
+
+## List Users
+
+Returns all users.
+
+`+"```go"+`
+users, _ := client.ListUsers()
+`+"```"+`
+
+## Delete User
+
+Deletes a user. No example yet.
+
+## Limits
+
+| Plan | Limit |
+|------|-------|
+| free | 10 |
+
+![diagram](limits.png)
+`), "api.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{`.sections | select(has_code == false) | .heading | .text`, []string{"Delete User", "Limits"}},
+		{`.sections | select(has_code) | .heading | .text`, []string{"API", "List Users"}},
+		{`.sections | select(has_tables == true and has_images) | .heading | .text`, []string{"API", "Limits"}},
+		{`.sections | select(codecount == 0 and tablecount == 0) | .heading | .text`, []string{"Delete User"}},
+	}
+
+	for _, test := range tests {
+		result, err := mql.ExecuteQuery(doc, test.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", test.query, err)
+			continue
+		}
+		texts, ok := result.([]string)
+		if !ok || !reflect.DeepEqual(texts, test.expected) {
+			t.Errorf("Query '%s': expected %v, got %v", test.query, test.expected, result)
+		}
+	}
+}
+
+func TestContainsAnyAll(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte(testDoc), "test.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected bool
+	}{
+		{`.tags | contains_all(["golang", "testing"])`, true},
+		{`.tags | contains_all(["golang", "rust"])`, false},
+		{`.tags | contains_any(["rust", "testing"])`, true},
+		{`.tags | contains_any(["rust", "python"])`, false},
+		{`.tags | contains_any("rust", "golang")`, true},
+		{`.metadata | .tags | contains_all(["testing"])`, true},
+	}
+
+	for _, test := range tests {
+		result, err := mql.ExecuteQuery(doc, test.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", test.query, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("Query '%s': expected %v, got %v", test.query, test.expected, result)
+		}
+	}
+
+	if _, err := mql.ParseString(`.tags | contains_any(["a", "b"`); err == nil {
+		t.Error("Expected error for unterminated array literal")
+	}
+}
+
+func TestConcurrentQueryExecutor(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte(testDoc), "test.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	executor := mql.NewQueryExecutor(mql.WithQueryCache())
+	queries := []string{
+		`.headings | select(.level == 2) | .text`,
+		`.code("go") | .length`,
+		`.sections | select(has_code) | .heading | .text`,
+		`.tags | contains_any(["golang"])`,
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if _, err := executor.Execute(doc, queries[(g+i)%len(queries)]); err != nil {
+					t.Errorf("Query failed: %v", err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestPreviewFunction(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte(testDoc), "test.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	result, err := mql.ExecuteQuery(doc, `.section("Section One") | .text | preview(20)`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	text, ok := result.(string)
+	if !ok || !strings.HasSuffix(text, "...") || len([]rune(text)) > 23 {
+		t.Errorf("Expected truncated string, got %q", result)
+	}
+
+	result, err = mql.ExecuteQuery(doc, `.headings | .text | preview(2)`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	texts, ok := result.([]string)
+	if !ok || len(texts) != 3 || texts[2] != "[+2 more]" {
+		t.Errorf("Expected 2 headings and a marker, got %v", result)
+	}
+
+	result, err = mql.ExecuteQuery(doc, `.headings | preview(3)`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	items, ok := result.([]interface{})
+	if !ok || len(items) != 4 || items[3] != "[+1 more]" {
+		t.Errorf("Expected 3 headings and a marker, got %v", result)
+	}
+
+	result, err = mql.ExecuteQuery(doc, `.headings | preview(10)`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if _, ok := result.([]*mq.Heading); !ok {
+		t.Errorf("Expected short collections to pass through unchanged, got %T", result)
+	}
+}
+
+func TestEmptyAndDefault(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte(`---
+owner: alice
+tags: []
+count: 0
+---
+
+# Title
+`), "empty.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`.tags | empty`, true},
+		{`.tags | nonempty`, false},
+		{`.headings | nonempty()`, true},
+		{`.owner | default("unknown")`, "alice"},
+		{`.meta("reviewer") | default("unknown")`, "unknown"},
+		{`.meta("count") | default(5)`, 0},
+		{`.priority | default("normal")`, "normal"},
+	}
+
+	for _, test := range tests {
+		result, err := mql.ExecuteQuery(doc, test.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", test.query, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("Query '%s': expected %v, got %v", test.query, test.expected, result)
+		}
+	}
+}
+
+func TestUnlabeledCodeBlocks(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Usage\n\n```bash\nmq README.md '.headings'\n```\n\n```\nTitle\nUsage\n```\n\n## Config\n\n```yaml\nkey: value\n```\n\n```\nkey=value\n```\n"), "plain.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query     string
+		expected  int
+		unlabeled bool
+	}{
+		{`.code`, 4, false},
+		{`.code("")`, 2, true},
+		{`.code("", "bash")`, 3, false},
+		{`.section("Config") | .code("")`, 1, true},
+	}
+
+	for _, test := range tests {
+		result, err := mql.ExecuteQuery(doc, test.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", test.query, err)
+			continue
+		}
+		blocks, ok := result.([]*mq.CodeBlock)
+		if !ok {
+			t.Errorf("Query '%s': expected []*mq.CodeBlock, got %T", test.query, result)
+			continue
+		}
+		if len(blocks) != test.expected {
+			t.Errorf("Query '%s': expected %d blocks, got %d", test.query, test.expected, len(blocks))
+		}
+		for _, cb := range blocks {
+			if test.unlabeled && cb.Language != "" {
+				t.Errorf("Query '%s': got labeled block %q", test.query, cb.Language)
+			}
+		}
+	}
+}
+
+func TestElementsSelector(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Intro\n\nRead the [guide](guide.md).\n\n```bash\nmq --help\n```\n\n## Next\n\nSee [API](api.md).\n"), "elements.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	result, err := mql.ExecuteQuery(doc, `.elements`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	elements, ok := result.([]mq.Element)
+	if !ok || len(elements) != 5 {
+		t.Fatalf("Expected 5 elements, got %T %v", result, result)
+	}
+
+	result, err = mql.ExecuteQuery(doc, `.elements | filter(.kind == "link" and .line > 5)`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	links, ok := result.([]mq.Element)
+	if !ok || len(links) != 1 {
+		t.Fatalf("Expected 1 link after line 5, got %v", result)
+	}
+	if link, ok := links[0].(*mq.Link); !ok || link.URL != "api.md" {
+		t.Errorf("Expected link to api.md, got %v", links[0])
+	}
+}
+
+func TestAutoLinkFilter(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Links\n\nVisit https://example.com or the [docs](docs.md).\n"), "auto.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	result, err := mql.ExecuteQuery(doc, `.links | filter(.auto)`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	links, ok := result.([]*mq.Link)
+	if !ok || len(links) != 1 || links[0].URL != "https://example.com" {
+		t.Errorf("Expected only the bare URL, got %v", result)
+	}
+}
+
+func TestStrikethroughSelector(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Changelog\n\n## v2\n\n- ~~Dropped Go 1.20~~\n\n## v1\n\n- ~~Old flag~~ renamed\n"), "changelog.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	result, err := mql.ExecuteQuery(doc, `.strikethrough | .text`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if !reflect.DeepEqual(result, []string{"Dropped Go 1.20", "Old flag"}) {
+		t.Errorf("Unexpected strikethrough text: %v", result)
+	}
+
+	result, err = mql.ExecuteQuery(doc, `.strikethrough | .section`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if !reflect.DeepEqual(result, []string{"v2", "v1"}) {
+		t.Errorf("Unexpected sections: %v", result)
+	}
+}
+
+func TestFieldsSelector(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte(testDoc), "test.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	result, err := mql.ExecuteQuery(doc, `.fields`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	expected := []mq.FieldInfo{
+		{Key: "owner", Type: "string"},
+		{Key: "tags", Type: "array"},
+		{Key: "priority", Type: "string"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
+
+func TestFilterDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.md": "---\npriority: high\n---\n\n# A\n\n```python\nprint(1)\n```\n",
+		"b.md": "# B\n\n## Install\n\nSteps.\n",
+		"c.md": "---\npriority: low\n---\n\n# C\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{`.code("python") | length > 0`, []string{"a.md"}},
+		{`.section("Install")`, []string{"b.md"}},
+		{`.priority == "low"`, []string{"c.md"}},
+		{`.headings`, []string{"a.md", "b.md", "c.md"}},
+	}
+
+	for _, test := range tests {
+		result, err := mql.FilterDir(dir, test.query, mq.TreeModeDefault)
+		if err != nil {
+			t.Errorf("FilterDir(%q) failed: %v", test.query, err)
+			continue
+		}
+		var names []string
+		for _, node := range result.Root {
+			names = append(names, node.Name)
+		}
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("FilterDir(%q): expected %v, got %v", test.query, test.expected, names)
+		}
+	}
+
+	if _, err := mql.FilterDir(dir, `.code(`, mq.TreeModeDefault); err == nil {
+		t.Error("Expected syntax error to be reported")
+	}
+	if _, err := mql.FilterDir(dir, `.serch("x")`, mq.TreeModeDefault); !errors.Is(err, mql.ErrUnknownSelector) {
+		t.Errorf("Expected an unknown selector error, got %v", err)
+	}
+	if _, err := mql.FilterDir(dir, `.headings | summary(10)`, mq.TreeModeDefault); !errors.Is(err, mql.ErrTypeMismatch) {
+		t.Errorf("Expected a type mismatch error, got %v", err)
+	}
+}
+
+func TestLeadSelector(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Intro\n\nWelcome to mq.\n\n## Setup\n\nInstall it first.\n\n## Notes\n\n```\nno prose\n```\n"), "lead.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`.lead`, "Welcome to mq."},
+		{`.section("Setup") | .lead`, "Install it first."},
+		{`.section("Notes") | .lead`, ""},
+		{`.sections | .lead`, []string{"Welcome to mq.", "Install it first.", ""}},
+		{`.sections | filter(.lead != "") | .heading | .text`, []string{"Intro", "Setup"}},
+	}
+
+	for _, test := range tests {
+		result, err := mql.ExecuteQuery(doc, test.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", test.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("Query '%s': expected %v, got %v", test.query, test.expected, result)
+		}
+	}
+}
+
+func TestLanguageSelector(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Guide\n\nThis is the guide for the project. It explains how to install it and how to use it with the tools that you have, and it is short.\n\n```go\nfmt.Println()\n```\n"), "lang.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	result, err := mql.ExecuteQuery(doc, `.language`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if result != "en" {
+		t.Errorf("Expected en, got %v", result)
+	}
+
+	// On code blocks, .language is still the programming language
+	result, err = mql.ExecuteQuery(doc, `.code | .language`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if !reflect.DeepEqual(result, []string{"go"}) {
+		t.Errorf("Expected [go], got %v", result)
+	}
+}
+
+func TestDomainsFunction(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Links\n\n- [Docs](https://docs.example.com/guide)\n- [Home](https://Example.com)\n- [Again](https://example.com/about)\n- [Port](http://localhost:8080/x)\n- [Local](./local.md)\n- [Anchor](#links)\n- [Mail](mailto:team@example.com)\n- [Bad](http://%zz)\n"), "links.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	expected := []string{"docs.example.com", "example.com", "localhost"}
+	for _, query := range []string{`.links | .domains`, `.links | domains`, `.domains`, `.links | filter(.text != "Home") | domains()`} {
+		result, err := mql.ExecuteQuery(doc, query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("Query '%s': expected %v, got %v", query, expected, result)
+		}
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte(testDoc), "test.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query   string
+		want    error
+		message string
+	}{
+		{`.section("Nope")`, mql.ErrSectionNotFound, "section not found: Nope"},
+		{`.bogus`, mql.ErrUnknownSelector, "unknown selector: bogus"},
+		{`.owner | filter(.level > 1)`, mql.ErrTypeMismatch, "cannot filter type: string"},
+		{`.section(1)`, mql.ErrTypeMismatch, "section title must be a string"},
+	}
+	for _, tt := range tests {
+		_, err := mql.ExecuteQuery(doc, tt.query)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("Query '%s': expected %v, got %v", tt.query, tt.want, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("Query '%s': expected message %q, got %q", tt.query, tt.message, err.Error())
+		}
+	}
+
+	for _, query := range []string{`.headings | filter(`, `.section("unterminated`} {
+		_, err := mql.ExecuteQuery(doc, query)
+		var perr *mql.ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("Query '%s': expected ParseError, got %v", query, err)
+			continue
+		}
+		if perr.Line != 1 || perr.Col < 1 {
+			t.Errorf("Query '%s': unexpected position %d:%d", query, perr.Line, perr.Col)
+		}
+		if !strings.Contains(err.Error(), perr.Error()) {
+			t.Errorf("Query '%s': message %q does not include %q", query, err.Error(), perr.Error())
+		}
+	}
+}
+
+func TestOnlyFunction(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Setup\n\n```python\nprint(1)\n```\n\n```go\nfmt.Println(1)\n```\n\n```go\nfmt.Println(2)\n```\n"), "only.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	for _, query := range []string{`.code("python") | only | .content`, `.code("python") | unwrap() | .content`, `.code("python") | .only | .content`} {
+		result, err := mql.ExecuteQuery(doc, query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", query, err)
+			continue
+		}
+		if content, _ := result.(string); strings.TrimSpace(content) != "print(1)" {
+			t.Errorf("Query '%s': expected print(1), got %q", query, result)
+		}
+	}
+
+	errorTests := []struct {
+		query   string
+		message string
+	}{
+		{`.code("rust") | only`, "only: expected exactly one element, got none"},
+		{`.code("go") | only`, "only: expected exactly one element, got 2"},
+		{`.headings | only | .text | only`, "only requires a collection, got string"},
+	}
+	for _, tt := range errorTests {
+		_, err := mql.ExecuteQuery(doc, tt.query)
+		if err == nil || err.Error() != tt.message {
+			t.Errorf("Query '%s': expected error %q, got %v", tt.query, tt.message, err)
+		}
+	}
+}
+
+func TestPipeArguments(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# A long heading here\n\n## Short\n\n## Medium one\n\n### Tiny\n"), "sort.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`.headings | sort_by(.text | length) | .text`, []string{"Tiny", "Short", "Medium one", "A long heading here"}},
+		{`.headings | sort_by(.level) | .text`, []string{"A long heading here", "Short", "Medium one", "Tiny"}},
+		{`.headings | .sort_by(.text) | .text`, []string{"A long heading here", "Medium one", "Short", "Tiny"}},
+		{`.headings | map(.text | length)`, []interface{}{19, 5, 10, 4}},
+		{`.headings | select(.text | length > 5) | .text`, []string{"A long heading here", "Medium one"}},
+		{`.headings | sort_by(.text | length) | map(.level)`, []interface{}{3, 2, 2, 1}},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("Query '%s': expected %v, got %v", tt.query, tt.expected, result)
+		}
+	}
+
+	if _, err := mql.ExecuteQuery(doc, `.owner | sort_by(.text)`); !errors.Is(err, mql.ErrTypeMismatch) {
+		t.Errorf("Expected type mismatch sorting a non-collection, got %v", err)
+	}
+}
+
+func TestSectionNavigationSelectors(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Guide\n\n## Install\n\n### Linux\n\n### macOS\n\n## Usage\n\n# Appendix\n"), "nav.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`.section("Linux") | .siblings | .heading | .text`, []string{"macOS"}},
+		{`.section("Linux") | .ancestors | .heading | .text`, []string{"Guide", "Install"}},
+		{`.section("Guide") | .siblings | .heading | .text`, []string{"Appendix"}},
+		{`.sections | filter(.ancestors | length == 2) | .heading | .text`, []string{"Linux", "macOS"}},
+		{`.section("Install") | .next | .heading | .text`, "Usage"},
+		{`.section("macOS") | .prev | .heading | .text`, "Linux"},
+		{`.section("Usage") | .next`, nil},
+		{`.section("Guide") | .prev`, nil},
+		{`.sections | filter(.next == null) | .heading | .text`, []string{"macOS", "Usage", "Appendix"}},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("Query '%s': expected %v, got %v", tt.query, tt.expected, result)
+		}
+	}
+}
+
+func TestHTMLSelector(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Guide\n\n## Setup\n\n```go\nx := a < b\n```\n\n| K | V |\n|---|---|\n| a | 1 |\n"), "render.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{`.code | .html`, "<pre><code class=\"language-go\">x := a &lt; b\n</code></pre>\n"},
+		{`.headings | .html`, "<h1 id=\"guide\">Guide</h1>\n<h2 id=\"setup\">Setup</h2>\n"},
+		{`.headings | .text | .html`, "<p>Guide</p>\n<p>Setup</p>\n"},
+		{`.tables | .html`, "<table>\n<thead>\n<tr>\n<th>K</th>\n<th>V</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>a</td>\n<td>1</td>\n</tr>\n</tbody>\n</table>\n"},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("Query '%s': expected %q, got %q", tt.query, tt.expected, result)
+		}
+	}
+
+	result, err := mql.ExecuteQuery(doc, `.section("Setup") | .html`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if s, _ := result.(string); !strings.HasPrefix(s, "<h2 id=\"setup\">Setup</h2>") || !strings.Contains(s, "<table>") {
+		t.Errorf("Expected section subtree HTML, got %q", result)
+	}
+
+	if _, err := mql.ExecuteQuery(doc, `.headings | map(.level) | .html`); !errors.Is(err, mql.ErrTypeMismatch) {
+		t.Errorf("Expected type mismatch rendering numbers, got %v", err)
+	}
+}
+
+func TestTextWithMeta(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("---\ntitle: Guide\ntags: [go, cli]\n---\n# Guide\n\n## Setup\n\nRun it.\n"), "meta.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{`.text`, "Guide\n\nSetup\n\nRun it."},
+		{`.text("with-meta")`, "Title: Guide\nTags: go, cli\n\nGuide\n\nSetup\n\nRun it."},
+		{`.section("Setup") | .text("with-meta")`, "Title: Guide\nTags: go, cli\n\n## Setup\n\nRun it.\n"},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("Query '%s': expected %q, got %q", tt.query, tt.expected, result)
+		}
+	}
+
+	if _, err := mql.ExecuteQuery(doc, `.text("everything")`); err == nil {
+		t.Error("Expected error for unknown text mode")
+	}
+}
+
+func TestHeadTailSelectors(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Intro\n\none\ntwo\nthree\n\n# Next\n"), "headtail.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`.section("Intro") | .head(1)`, "one"},
+		{`.section("Intro") | .tail(2)`, "two\nthree"},
+		{`.section("Intro") | .head`, "one\ntwo\nthree"},
+		{`.section("Intro") | .tail(100)`, "one\ntwo\nthree"},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("Query '%s': expected %q, got %q", tt.query, tt.expected, result)
+		}
+	}
+
+	if _, err := mql.ExecuteQuery(doc, `.section("Intro") | .head(-1)`); err == nil {
+		t.Error("Expected error for negative line count")
+	}
+}
+
+func TestListProperties(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Steps\n\n3. Install\n4. Configure\n   - [x] edit config\n   - restart\n"), "lists.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`.lists | only | .start`, 3},
+		{`.lists | only | .ordered`, true},
+		{`.lists | only | .items | map(.text)`, []interface{}{"Install", "Configure"}},
+		{`.lists | only | .items | map(.depth)`, []interface{}{0, 0}},
+		{`.lists | only | .items | map(.children | length)`, []interface{}{0, 2}},
+		{`.lists | only | .items | map(.checked)`, []interface{}{nil, nil}},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("Query '%s': expected %v, got %v", tt.query, tt.expected, result)
+		}
+	}
+}
+
+func TestListItemsSelector(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Todo\n\n- [x] write\n- review\n  1. [ ] tests\n"), "todo.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`.listitems | map(.text)`, []interface{}{"write", "review", "tests"}},
+		{`.listitems | map(.depth)`, []interface{}{0, 0, 1}},
+		{`.flatten_lists | map(.ordered)`, []interface{}{false, false, true}},
+		{`.listitems | map(.number)`, []interface{}{0, 0, 1}},
+		{`.listitems | map(.checked)`, []interface{}{true, nil, false}},
+		{`.listitems | filter(.depth > 0) | map(.text)`, []interface{}{"tests"}},
+		{`.listitems | length`, 3},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("Query '%s': expected %v, got %v", tt.query, tt.expected, result)
+		}
+	}
+}
+
+func TestImageProperties(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Gallery\n\n![Logo](logo.png)\n![Diagram](img/arch.svg \"Architecture\")\n"), "gallery.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`.images | map(.format)`, []interface{}{"png", "svg"}},
+		{`.images | map(.width)`, []interface{}{0, 0}},
+		{`.images | select(.format == "svg") | map(.title)`, []interface{}{"Architecture"}},
+		{`.images | select(.width > 600) | length`, 0},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("Query '%s': expected %v, got %v", tt.query, tt.expected, result)
+		}
+	}
+}
+
+func TestTreePreviewLength(t *testing.T) {
+	engine := mq.New()
+	content := "# Guide\n\nThis guide walks through installing, configuring and operating the service in production.\n"
+	doc, err := engine.ParseDocument([]byte(content), "guide.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{`.tree("preview")`, "This guide walks through installing, configuring…"},
+		{`.tree("preview", 80)`, "This guide walks through installing, configuring and operating the service in…"},
+		{`.section("Guide") | .tree("preview", 10)`, "This guide…"},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		tree, ok := result.(*mq.TreeResult)
+		if !ok || len(tree.Root) != 1 {
+			t.Errorf("Query '%s': expected a tree with one root, got %T", tt.query, result)
+			continue
+		}
+		if tree.Root[0].Preview != tt.expected {
+			t.Errorf("Query '%s': expected preview %q, got %q", tt.query, tt.expected, tree.Root[0].Preview)
+		}
+	}
+
+	if _, err := mql.ExecuteQuery(doc, `.tree("preview", 0)`); err == nil {
+		t.Error("Expected error for non-positive preview length")
+	}
+}
+
+func TestReductionSelector(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("---\ntitle: x\n---\n# Body\n"), "size.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`.reduction | .source_bytes`, 24},
+		{`.reduction | .readable_chars`, 4},
+		{`.reduction | .ratio > 0.5`, true},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("Query '%s': expected %v, got %v", tt.query, tt.expected, result)
+		}
+	}
+}
+
+func TestOpsAreImplemented(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("---\nowner: x\n---\n# Intro\n\nText.\n\n## Child\n\n- item\n"), "ops.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, s := range mql.Selectors() {
+		if seen["."+s.Name] {
+			t.Errorf("Duplicate selector %s", s.Name)
+		}
+		seen["."+s.Name] = true
+		if s.Description == "" {
+			t.Errorf("Selector %s has no description", s.Name)
+		}
+
+		query := "." + s.Name
+		if s.Scope == "section" {
+			query = `.section("Intro") | .` + s.Name
+		}
+		if _, err := mql.ExecuteQuery(doc, query); errors.Is(err, mql.ErrUnknownSelector) {
+			t.Errorf("Selector %s is listed but not implemented: %v", s.Name, err)
+		}
+	}
+
+	for _, f := range mql.Functions() {
+		if seen[f.Name] {
+			t.Errorf("Duplicate function %s", f.Name)
+		}
+		seen[f.Name] = true
+		if f.Description == "" {
+			t.Errorf("Function %s has no description", f.Name)
+		}
+
+		query := ".headings | " + f.Name + "(1)"
+		if _, err := mql.ExecuteQuery(doc, query); err != nil && strings.Contains(err.Error(), "unknown function") {
+			t.Errorf("Function %s is listed but not implemented: %v", f.Name, err)
+		}
+	}
+
+	if _, err := mql.ExecuteQuery(doc, ".headings | nosuchfunction(1)"); err == nil || !strings.Contains(err.Error(), "unknown function") {
+		t.Errorf("Expected unknown function error to detect missing functions, got %v", err)
+	}
+}
+
+func TestCountBy(t *testing.T) {
+	engine := mq.New()
+	content := "# A\n\n```go\na\n```\n\n```python\nb\n```\n\n## B\n\n```go\nc\n```\n\n## C\n\n```\nd\n```\n"
+	doc, err := engine.ParseDocument([]byte(content), "count.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`.code | count_by(.language)`, []mql.KeyCount{{Key: "go", Count: 2}, {Key: "", Count: 1}, {Key: "python", Count: 1}}},
+		{`.headings | count_by(.level)`, []mql.KeyCount{{Key: "2", Count: 2}, {Key: "1", Count: 1}}},
+		{`.code | count_by(.language) | map(.key)`, []interface{}{"go", "", "python"}},
+		{`.code | count_by(.language) | select(.count > 1) | map(.key)`, []interface{}{"go"}},
+		{`.code | .count_by(.language) | length`, 3},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("Query '%s': expected %v, got %v", tt.query, tt.expected, result)
+		}
+	}
+
+	if _, err := mql.ExecuteQuery(doc, `.owner | count_by(.x)`); !errors.Is(err, mql.ErrTypeMismatch) {
+		t.Errorf("Expected type mismatch for count_by on a non-collection, got %v", err)
+	}
+}
+
+func TestDateComparisons(t *testing.T) {
+	engine := mq.New()
+	content := "---\npublished: 2024-03-05\nupdated: 2024-03-05T10:00:00Z\nrevision: 3\n---\n# Post\n"
+	doc, err := engine.ParseDocument([]byte(content), "post.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected bool
+	}{
+		{`.meta("published") > "2024-01-01"`, true},
+		{`.meta("published") < "2024-01-01"`, false},
+		{`.meta("published") >= "2024-03-05"`, true},
+		{`.meta("published") == "2024-03-05T00:00:00Z"`, true},
+		{`.meta("published") < "2024-03-05T09:00:00+02:00"`, true},
+		{`.meta("updated") > "2024-03-05 09:59"`, true},
+		{`.meta("updated") <= "2024-03-05T12:00:00+02:00"`, true},
+		{`.meta("updated") > .meta("published")`, true},
+		{`.meta("revision") > 2`, true},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("Query '%s': expected %v, got %v", tt.query, tt.expected, result)
+		}
+	}
+
+	if _, err := mql.ExecuteQuery(doc, `.meta("published") > 2024`); !errors.Is(err, mql.ErrTypeMismatch) {
+		t.Errorf("Expected type mismatch comparing a date with a number, got %v", err)
+	}
+}
+
+func TestSample(t *testing.T) {
+	engine := mq.New()
+	content := "# A\n\n## B\n\n## C\n\n## D\n\n## E\n\n## F\n"
+	doc, err := engine.ParseDocument([]byte(content), "sample.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	executor := mql.NewQueryExecutor(mql.WithSampleSeed(42))
+	first, err := executor.Execute(doc, `.headings | sample(3)`)
+	if err != nil {
+		t.Fatalf("sample failed: %v", err)
+	}
+	headings, ok := first.([]*mq.Heading)
+	if !ok || len(headings) != 3 {
+		t.Fatalf("Expected 3 headings, got %#v", first)
+	}
+	seen := make(map[string]bool)
+	for _, h := range headings {
+		if seen[h.Text] {
+			t.Errorf("Heading %q sampled twice", h.Text)
+		}
+		seen[h.Text] = true
+	}
+
+	for i := 0; i < 5; i++ {
+		again, err := executor.Execute(doc, `.headings | sample(3)`)
+		if err != nil {
+			t.Fatalf("sample failed: %v", err)
+		}
+		if !reflect.DeepEqual(again, first) {
+			t.Errorf("Same seed gave %v, then %v", first, again)
+		}
+	}
+
+	// The seed can be set on an engine too
+	seeded := mql.New(mql.WithQueryOptions(mql.WithSampleSeed(42)))
+	if picked, err := seeded.Query(doc, `.headings | sample(3)`); err != nil || !reflect.DeepEqual(picked, first) {
+		t.Errorf("Expected the engine's seed to give %v, got %v (%v)", first, picked, err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`.headings | sample(10) | map(.text)`, []interface{}{"A", "B", "C", "D", "E", "F"}},
+		{`.headings | sample(0) | length`, 0},
+		{`.headings | sample(2) | length`, 2},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("Query '%s': expected %v, got %v", tt.query, tt.expected, result)
+		}
+	}
+
+	if _, err := mql.ExecuteQuery(doc, `.owner | sample(1)`); !errors.Is(err, mql.ErrTypeMismatch) {
+		t.Errorf("Expected type mismatch for sample on a non-collection, got %v", err)
+	}
+	if _, err := mql.ExecuteQuery(doc, `.headings | sample(-1)`); err == nil {
+		t.Error("Expected an error for a negative sample size")
+	}
+}
+
+func TestHeadinglessDocuments(t *testing.T) {
+	engine := mql.New()
+	tests := []struct {
+		name    string
+		content string
+		title   string
+		text    string
+	}{
+		{"note.md", "Just a note.\n\nMore text.\n", "note", "Just a note."},
+		{"rows.json", `[{"a": 1}, {"a": 2}]`, "Array (2 items)", `"a": 1`},
+		{"count.yaml", "42\n", "Value", "42"},
+	}
+	for _, tt := range tests {
+		doc, err := engine.ParseDocument([]byte(tt.content), tt.name)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", tt.name, err)
+		}
+
+		result, err := mql.ExecuteQuery(doc, `.sections | map(.heading | .text)`)
+		if err != nil {
+			t.Fatalf("%s: query failed: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(result, []interface{}{tt.title}) {
+			t.Errorf("%s: expected one section titled %q, got %v", tt.name, tt.title, result)
+		}
+
+		text, err := mql.ExecuteQuery(doc, `.section("`+tt.title+`") | .text`)
+		if err != nil {
+			t.Errorf("%s: section lookup failed: %v", tt.name, err)
+			continue
+		}
+		if s, _ := text.(string); !strings.Contains(s, tt.text) {
+			t.Errorf("%s: expected section text to contain %q, got %q", tt.name, tt.text, s)
+		}
+	}
+}
+
+func TestTOC(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Guide\n\n## Setup\n\n### Linux\n"), "guide.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`.toc("md")`, "- [Guide](#guide)\n  - [Setup](#setup)\n    - [Linux](#linux)\n"},
+		{`.toc("md", 2)`, "- [Guide](#guide)\n  - [Setup](#setup)\n"},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("Query '%s': expected %q, got %q", tt.query, tt.expected, result)
+		}
+	}
+
+	rendered := []struct {
+		query    string
+		expected string
+	}{
+		{`.toc`, "Guide\n  Setup\n    Linux\n"},
+		{`.toc(2)`, "Guide\n  Setup\n"},
+		{`.toc(1)`, "Guide\n"},
+		{`.section("Setup") | .toc`, "  Setup\n    Linux\n"},
+		{`.section("Setup") | .toc(2)`, "  Setup\n"},
+	}
+	for _, tt := range rendered {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		toc, ok := result.(*mq.TOCResult)
+		if !ok {
+			t.Errorf("Query '%s': expected *mq.TOCResult, got %T", tt.query, result)
+			continue
+		}
+		if toc.String() != tt.expected {
+			t.Errorf("Query '%s': expected %q, got %q", tt.query, tt.expected, toc.String())
+		}
+	}
+
+	props := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`.toc | length`, 3},
+		{`.toc(2) | length`, 2},
+		{`.toc | .lines`, []string{"Guide", "  Setup", "    Linux"}},
+		{`.toc | .entries | select(.level > 1) | map(.text)`, []interface{}{"Setup", "Linux"}},
+		{`.toc | .entries | .[2] | .line`, 5},
+	}
+	for _, tt := range props {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("Query '%s': expected %#v, got %#v", tt.query, tt.expected, result)
+		}
+	}
+
+	var buf strings.Builder
+	if err := mql.QueryStream(doc, `.toc(1)`, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != `{"entries":[{"text":"Guide","level":1,"line":1}]}` {
+		t.Errorf("Unexpected TOC JSON: %s", got)
+	}
+
+	empty, err := engine.ParseDocument([]byte(""), "empty.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	result, err := mql.ExecuteQuery(empty, `.toc`)
+	if err != nil {
+		t.Fatalf("Query '.toc' on an empty document failed: %v", err)
+	}
+	if toc := result.(*mq.TOCResult); len(toc.Entries) != 0 || toc.String() != "" {
+		t.Errorf("Expected an empty TOC, got %q", toc.String())
+	}
+
+	for _, query := range []string{`.toc("html")`, `.toc("md", 0)`, `.toc(7)`, `.toc(2, 3)`} {
+		if _, err := mql.ExecuteQuery(doc, query); err == nil {
+			t.Errorf("Expected an error for %s", query)
+		}
+	}
+}
+
+func TestBetween(t *testing.T) {
+	engine := mq.New()
+	content := "# Guide\n\n## Install\n\nRun it.\n\n## Configure\n\nEdit it.\n\n## FAQ\n\nAsk.\n"
+	doc, err := engine.ParseDocument([]byte(content), "guide.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{`.between("Install", "FAQ")`, "Run it.\n\n## Configure\n\nEdit it."},
+		{`.between("Install", "Configure", "inclusive")`, "## Install\n\nRun it.\n\n## Configure\n\nEdit it."},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("Query '%s': expected %q, got %q", tt.query, tt.expected, result)
+		}
+	}
+
+	if _, err := mql.ExecuteQuery(doc, `.between("Install", "Missing")`); !errors.Is(err, mql.ErrSectionNotFound) {
+		t.Errorf("Expected ErrSectionNotFound, got %v", err)
+	}
+	if _, err := mql.ExecuteQuery(doc, `.between("FAQ", "Install")`); err == nil {
+		t.Error("Expected an error for headings out of order")
+	}
+	if _, err := mql.ExecuteQuery(doc, `.between("Install")`); err == nil {
+		t.Error("Expected an error for a missing end heading argument")
+	}
+}
+
+func TestDistinctBy(t *testing.T) {
+	engine := mq.New()
+	content := "# A\n\nSee [docs](https://x.dev/docs), [guide](https://x.dev/guide) and [the docs](https://x.dev/docs).\n\n## B\n\n## C\n\n# D\n"
+	doc, err := engine.ParseDocument([]byte(content), "distinct.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`.links | distinct_by(.url) | map(.text)`, []interface{}{"docs", "guide"}},
+		{`.links | distinct_by(.url) | length`, 2},
+		{`.headings | distinct_by(.level) | map(.text)`, []interface{}{"A", "B"}},
+		{`.headings | .distinct_by(.level) | length`, 2},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("Query '%s': expected %v, got %v", tt.query, tt.expected, result)
+		}
+	}
+
+	result, err := mql.ExecuteQuery(doc, `.links | distinct_by(.url)`)
+	if err != nil {
+		t.Fatalf("distinct_by failed: %v", err)
+	}
+	if _, ok := result.([]*mq.Link); !ok {
+		t.Errorf("Expected distinct_by to keep the collection type, got %T", result)
+	}
+
+	if _, err := mql.ExecuteQuery(doc, `.owner | distinct_by(.x)`); !errors.Is(err, mql.ErrTypeMismatch) {
+		t.Errorf("Expected type mismatch for distinct_by on a non-collection, got %v", err)
+	}
+}
+
+func TestCodeContentLines(t *testing.T) {
+	engine := mq.New()
+	content := "# Setup\n\n```bash\nexport A=1\ncurl -s https://x.dev\n```\n\n```bash\ncurl -O file\n```\n\n```python\nprint(1)\n```\n"
+	doc, err := engine.ParseDocument([]byte(content), "setup.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`.code("python") | only | .contentlines`, []string{"print(1)"}},
+		{`.code("bash") | map(.contentlines)`, []interface{}{[]string{"export A=1", "curl -s https://x.dev"}, []string{"curl -O file"}}},
+		{`.code("bash") | map(.contentlines) | flatten | select(. | contains("curl"))`, []interface{}{"curl -s https://x.dev", "curl -O file"}},
+		{`.code("bash") | map(.lines)`, []interface{}{2, 1}},
+		{`.code | map(.contentlines) | flatten | length`, 4},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("Query '%s': expected %#v, got %#v", tt.query, tt.expected, result)
+		}
+	}
+
+	if _, err := mql.ExecuteQuery(doc, `.owner | flatten`); !errors.Is(err, mql.ErrTypeMismatch) {
+		t.Errorf("Expected type mismatch for flatten on a non-collection, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	engine := mq.New()
+	content := "---\npublished: 2024-03-05\n---\n# Guide\n\n## Install\n\n## FAQ\n"
+	doc, err := engine.ParseDocument([]byte(content), "guide.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected []mql.Diagnostic
+	}{
+		{`.section("Install") | .code | map(.language)`, nil},
+		{`.sections | select(has_code == false) | .heading`, nil},
+		{`.headings | sort_by(.level) | map(.text)`, nil},
+		{`.meta("published") > "2024-01-01"`, nil},
+		{`.section("Usage")`, []mql.Diagnostic{
+			{Severity: mql.SeverityWarning, Line: 1, Col: 2, Message: `no section titled "Usage" in this document`},
+		}},
+		{`.section("Guide", "FAQ", "Missing")`, []mql.Diagnostic{
+			{Severity: mql.SeverityWarning, Line: 1, Col: 2, Message: `no section at path "Guide > FAQ > Missing" in this document`},
+		}},
+		{`.foo`, []mql.Diagnostic{
+			{Severity: mql.SeverityError, Line: 1, Col: 2, Message: ".foo is not a selector or a frontmatter field"},
+		}},
+		{`.published`, []mql.Diagnostic{
+			{Severity: mql.SeverityError, Line: 1, Col: 2, Message: `.published is a frontmatter field, not a selector; use .meta("published")`},
+		}},
+		{`.headings | .level`, []mql.Diagnostic{
+			{Severity: mql.SeverityError, Line: 1, Col: 14, Message: ".level is a property of each Heading; use map(.level)"},
+		}},
+		{".code\n| map(.lang)", []mql.Diagnostic{
+			{Severity: mql.SeverityError, Line: 2, Col: 8, Message: "CodeBlock has no property lang"},
+		}},
+		{`.search("x") | .heading`, []mql.Diagnostic{
+			{Severity: mql.SeverityError, Line: 1, Col: 17, Message: "SearchResults has no property heading"},
+		}},
+		{`.data | .users`, nil},
+		{`.headings | frobnicate(1)`, []mql.Diagnostic{
+			{Severity: mql.SeverityError, Line: 1, Col: 13, Message: "unknown function: frobnicate"},
+		}},
+		{`.headings | `, []mql.Diagnostic{
+			{Severity: mql.SeverityError, Line: 1, Col: 11, Message: "expected expression after '|'"},
+		}},
+	}
+	for _, tt := range tests {
+		diags := mql.Validate(tt.query, doc)
+		if !reflect.DeepEqual(diags, tt.expected) {
+			t.Errorf("Validate(%q): expected %v, got %v", tt.query, tt.expected, diags)
+		}
+	}
+
+	if diags := mql.Validate(`.section("Usage")`, nil); len(diags) != 0 {
+		t.Errorf("Expected no document checks without a document, got %v", diags)
+	}
+}
+
+func TestPipeIndexing(t *testing.T) {
+	engine := mq.New()
+	content := "# A\n\n## B\n\n## C\n\n- x\n- y\n"
+	doc, err := engine.ParseDocument([]byte(content), "index.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`.headings | .[0] | .text`, "A"},
+		{`.headings | [1] | .text`, "B"},
+		{`.headings | [1:3] | map(.text)`, []interface{}{"B", "C"}},
+		{`.headings | .[:2] | length`, 2},
+		{`.headings | map(.text) | .[1:]`, []interface{}{"B", "C"}},
+		{`.headings[2] | .text`, "C"},
+		{`.lists[0] | .items | length`, 2},
+		{`(.headings | map(.text))[0]`, "A"},
+		{`.lists | map(.items) | map(.[1] | .text)`, []interface{}{"y"}},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("Query '%s': expected %v, got %v", tt.query, tt.expected, result)
+		}
+	}
+
+	if _, err := mql.ExecuteQuery(doc, `.headings | .[5]`); err == nil {
+		t.Error("Expected an error for an out-of-range index")
+	}
+	if _, err := mql.ExecuteQuery(doc, `.headings | [0`); err == nil {
+		t.Error("Expected a parse error for an unclosed index")
+	}
+}
+
+func TestTreeText(t *testing.T) {
+	engine := mq.New()
+	content := "## Install\n\n### From source\n\n#### Linux\n\n## Usage\n\n### Flags\n"
+	doc, err := engine.ParseDocument([]byte(content), "guide.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{`.headings | tree_text`, "- Install\n  - From source\n    - Linux\n- Usage\n  - Flags\n"},
+		{`.headings(2, 3) | tree_text()`, "- Install\n  - From source\n- Usage\n  - Flags\n"},
+		{`.headings | select(.level > 2) | tree_text`, "- From source\n  - Linux\n- Flags\n"},
+		{`.headings | select(.text == "Usage") | only | tree_text`, "- Usage\n"},
+		{`.headings(6) | tree_text`, ""},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("Query '%s': expected %q, got %q", tt.query, tt.expected, result)
+		}
+	}
+
+	if _, err := mql.ExecuteQuery(doc, `.code | tree_text`); !errors.Is(err, mql.ErrTypeMismatch) {
+		t.Errorf("Expected type mismatch for tree_text on code blocks, got %v", err)
+	}
+
+	// HTML headings carry no line numbers, so the outline relies on
+	// GetHeadings keeping document order
+	page, err := html.ParseHTML([]byte("<html><body><h2>Two</h2><p>Text.</p><h3>Three</h3><p>More.</p><h2>Four</h2><p>End.</p></body></html>"), "page.html")
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+	result, err := mql.ExecuteQuery(page, `.headings | tree_text`)
+	if err != nil {
+		t.Fatalf("tree_text on HTML failed: %v", err)
+	}
+	if expected := "- Two\n  - Three\n- Four\n"; result != expected {
+		t.Errorf("HTML outline: expected %q, got %q", expected, result)
+	}
+}
+
+func TestSectionByAnchor(t *testing.T) {
+	engine := mq.New()
+	content := "# Auth\n\n## OAuth2 Flow (v2)\n\nTokens.\n\n## Setup\n\nSteps.\n\n### Setup\n\nAgain.\n\n## #hashtag\n\nTagged.\n"
+	doc, err := engine.ParseDocument([]byte(content), "auth.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`.section("#oauth2-flow-v2") | .heading | .text`, "OAuth2 Flow (v2)"},
+		{`.section("#setup-1") | .heading | .level`, 3},
+		{`.section("#auth") | .heading | .text`, "Auth"},
+		{`.section("#hashtag") | .heading | .text`, "#hashtag"},
+		{`.section("Auth") | .heading | .text`, "Auth"},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("Query '%s': expected %v, got %v", tt.query, tt.expected, result)
+		}
+	}
+
+	if _, err := mql.ExecuteQuery(doc, `.section("#missing")`); !errors.Is(err, mql.ErrSectionNotFound) {
+		t.Errorf("Expected ErrSectionNotFound for an unknown anchor, got %v", err)
+	}
+	if h, ok := doc.GetHeadingByID("oauth2-flow-v2"); !ok || h.Level != 2 {
+		t.Errorf("Expected GetHeadingByID to find the H2, got %v", h)
+	}
+}
+
+func TestSectionBody(t *testing.T) {
+	engine := mq.New()
+	content := "# Guide\n\n## Install\n\nRun make.\n\n## Usage\n\nCall it.\n"
+	doc, err := engine.ParseDocument([]byte(content), "guide.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`.section("Install") | .text`, "## Install\n\nRun make.\n"},
+		{`.section("Install") | .body`, "Run make.\n"},
+		{`.sections[1:] | .body`, []string{"Run make.\n", "Call it.\n"}},
+		{`.sections | map(.body) | .[0]`, "## Install\n\nRun make.\n\n## Usage\n\nCall it.\n"},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("Query '%s': expected %#v, got %#v", tt.query, tt.expected, result)
+		}
+	}
+}
+
+func TestQueryStream(t *testing.T) {
+	engine := mq.New()
+	content := "---\ntitle: Guide\nextra:\n  nested: true\n---\n# Guide\n\nIntro <b>.\n\n## Install\n\n```go\nfmt.Println()\n```\n"
+	doc, err := engine.ParseDocument([]byte(content), "guide.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	var buf strings.Builder
+	if err := mql.QueryStream(doc, `.sections`, &buf); err != nil {
+		t.Fatalf("QueryStream failed: %v", err)
+	}
+	out := buf.String()
+	if lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n"); len(lines) != 4 || lines[0] != "[" || lines[3] != "]" {
+		t.Errorf("Expected one section per line, got:\n%s", out)
+	}
+	var sections []map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &sections); err != nil {
+		t.Fatalf("Expected valid JSON, got %v:\n%s", err, out)
+	}
+	if sections[1]["title"] != "Install" || sections[1]["path"] != "Guide > Install" || sections[1]["level"] != 2.0 {
+		t.Errorf("Unexpected section %v", sections[1])
+	}
+	if !strings.Contains(out, "Intro <b>.") {
+		t.Errorf("Expected HTML characters to stay unescaped, got:\n%s", out)
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{`.code | .[0]`, `{"language":"go","content":"fmt.Println()\n","lines":1,"line":12}` + "\n"},
+		{`.headings | map(.text)`, "[\n\"Guide\",\n\"Install\"\n]\n"},
+		{`.links`, "[]\n"},
+		{`.code | length`, "1\n"},
+		{`.metadata | .extra`, `{"nested":true}` + "\n"},
+	}
+	for _, tt := range tests {
+		var buf strings.Builder
+		if err := mql.QueryStream(doc, tt.query, &buf); err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if buf.String() != tt.expected {
+			t.Errorf("Query '%s': expected %q, got %q", tt.query, tt.expected, buf.String())
+		}
+	}
+
+	if err := mql.QueryStream(doc, `.section("Missing")`, &buf); !errors.Is(err, mql.ErrSectionNotFound) {
+		t.Errorf("Expected the query error, got %v", err)
+	}
+
+	lists, err := engine.ParseDocument([]byte("# Steps\n\n3. Build\n\n4. Test\n"), "steps.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	buf.Reset()
+	if err := mql.QueryStream(lists, `.lists | .[0]`, &buf); err != nil {
+		t.Fatalf("QueryStream failed: %v", err)
+	}
+	want := `{"ordered":true,"start":3,"loose":true,"items":[{"text":"Build","depth":0,"ordered":true},{"text":"Test","depth":0,"ordered":true}],"line":3}` + "\n"
+	if buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestTitleSelector(t *testing.T) {
+	engine := mq.New()
+	for content, expected := range map[string]string{
+		"---\ntitle: Field Guide\n---\n# Heading\n": "Field Guide",
+		"# Heading\n\nText.\n":                      "Heading",
+		"Text only.\n":                              "doc",
+	} {
+		doc, err := engine.ParseDocument([]byte(content), "doc.md")
+		if err != nil {
+			t.Fatalf("Failed to parse document: %v", err)
+		}
+		result, err := mql.ExecuteQuery(doc, `.title`)
+		if err != nil {
+			t.Errorf("Query '.title' failed: %v", err)
+			continue
+		}
+		if result != expected {
+			t.Errorf("Query '.title' on %q: expected %q, got %v", content, expected, result)
+		}
+	}
+}
+
+func TestDataTableCellsKeepFullValues(t *testing.T) {
+	long := strings.Repeat("lorem ipsum ", 10)
+	content := []byte(`[{"id": 1, "note": "` + long + `", "score": 0.125}, {"id": 2, "note": "short", "score": 3}]`)
+
+	doc, err := data.NewJSONParser().Parse(content, "notes.json")
+	if err != nil {
+		t.Fatalf("Failed to parse JSON: %v", err)
+	}
+	result, err := mql.ExecuteQuery(doc, `.tables[0] | .rows`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	rows, ok := result.([][]string)
+	if !ok || len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %T %v", result, result)
+	}
+	if rows[0][1] != long {
+		t.Errorf("Expected the full note, got %q", rows[0][1])
+	}
+	if rows[0][2] != "0.125" {
+		t.Errorf("Expected score 0.125, got %q", rows[0][2])
+	}
+	if strings.Contains(doc.GetTables()[0].String(), "...") {
+		t.Error("Expected no truncation by default")
+	}
+
+	// Truncation only affects the rendered table
+	doc, err = data.NewJSONParser(data.WithTruncateFields(20)).Parse(content, "notes.json")
+	if err != nil {
+		t.Fatalf("Failed to parse JSON: %v", err)
+	}
+	table := doc.GetTables()[0]
+	if table.Rows[0][1] != long {
+		t.Errorf("Expected rows to keep the full note, got %q", table.Rows[0][1])
+	}
+	rendered := table.String()
+	if !strings.Contains(rendered, "| 1 | lorem ipsum lorem... | 0.125 |") {
+		t.Errorf("Expected the note shortened to 20 characters, got:\n%s", rendered)
+	}
+	if !strings.Contains(rendered, "| 2 | short | 3 |") {
+		t.Errorf("Expected short cells unchanged, got:\n%s", rendered)
+	}
+
+	jsonl := []byte(`{"id": 1, "note": "` + long + `"}` + "\n" + `{"id": 2, "note": "short"}` + "\n")
+	doc, err = data.NewJSONLParser(data.WithJSONLTruncateFields(20)).Parse(jsonl, "notes.jsonl")
+	if err != nil {
+		t.Fatalf("Failed to parse JSONL: %v", err)
+	}
+	if got := doc.GetTables()[0].Rows[0][1]; got != long {
+		t.Errorf("Expected the full JSONL note, got %q", got)
+	}
+
+	yamlContent := []byte("- id: 1\n  note: " + long + "\n- id: 2\n  note: short\n")
+	doc, err = data.NewYAMLParser(data.WithYAMLTruncateFields(20)).Parse(yamlContent, "notes.yaml")
+	if err != nil {
+		t.Fatalf("Failed to parse YAML: %v", err)
+	}
+	table = doc.GetTables()[0]
+	if table.Rows[0][1] != strings.TrimSpace(long) {
+		t.Errorf("Expected the full YAML note, got %q", table.Rows[0][1])
+	}
+	if !strings.Contains(table.String(), "lorem...") {
+		t.Errorf("Expected the YAML note shortened when rendered, got:\n%s", table.String())
+	}
+}
+
+func TestComponentsSelector(t *testing.T) {
+	content := []byte(`# Guide
+
+<Callout type="warning">
+Back up first.
+</Callout>
+
+<Callout type="info">Optional step.</Callout>
+
+<Badge label="new" />
+`)
+	doc, err := mql.New(mql.WithComponents()).ParseDocument(content, "guide.mdx")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	result, err := mql.ExecuteQuery(doc, `.components | .name`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if !reflect.DeepEqual(result, []string{"Callout", "Callout", "Badge"}) {
+		t.Errorf("Expected component names, got %v", result)
+	}
+
+	result, err = mql.ExecuteQuery(doc, `.components("Callout") | map(.text)`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	texts, ok := result.([]interface{})
+	if !ok || len(texts) != 2 || texts[0] != "Back up first." || texts[1] != "Optional step." {
+		t.Errorf("Expected Callout texts, got %v", result)
+	}
+
+	result, err = mql.ExecuteQuery(doc, `.components("Badge") | .[0] | .attributes | .label`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if result != "new" {
+		t.Errorf("Expected Badge label new, got %v", result)
+	}
+
+	result, err = mql.ExecuteQuery(doc, `.components | select(.self_closing) | length`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if result != 1 {
+		t.Errorf("Expected 1 self-closing component, got %v", result)
+	}
+}
+
+func TestOutlineJSON(t *testing.T) {
+	engine := mq.New()
+	content := "# Guide\n\n## Install\n\n### From Source\n\n## Usage\n\n#### Deep\n\n# Appendix\n"
+	doc, err := engine.ParseDocument([]byte(content), "guide.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	result, err := mql.ExecuteQuery(doc, `.headings | outline_json`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	out, ok := result.(string)
+	if !ok {
+		t.Fatalf("Expected a JSON string, got %T", result)
+	}
+
+	var tree []struct {
+		Text     string `json:"text"`
+		Level    int    `json:"level"`
+		ID       string `json:"id"`
+		Children []struct {
+			Text     string `json:"text"`
+			ID       string `json:"id"`
+			Children []struct {
+				Text  string `json:"text"`
+				Level int    `json:"level"`
+			} `json:"children"`
+		} `json:"children"`
+	}
+	if err := json.Unmarshal([]byte(out), &tree); err != nil {
+		t.Fatalf("Output is not valid JSON: %v\n%s", err, out)
+	}
+	if len(tree) != 2 || tree[0].Text != "Guide" || tree[1].Text != "Appendix" {
+		t.Fatalf("Expected roots Guide and Appendix, got %s", out)
+	}
+	guide := tree[0]
+	if guide.Level != 1 || guide.ID != "guide" || len(guide.Children) != 2 {
+		t.Fatalf("Unexpected Guide node: %s", out)
+	}
+	if install := guide.Children[0]; install.ID != "install" || len(install.Children) != 1 || install.Children[0].Text != "From Source" {
+		t.Errorf("Expected From Source under Install, got %s", out)
+	}
+	// A skipped level nests under the closest shallower heading
+	if usage := guide.Children[1]; len(usage.Children) != 1 || usage.Children[0].Level != 4 {
+		t.Errorf("Expected the H4 under Usage, got %s", out)
+	}
+
+	result, err = mql.ExecuteQuery(doc, `.headings(2) | outline_json`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if result != `[{"text":"Install","level":2,"id":"install","children":[]},{"text":"Usage","level":2,"id":"usage","children":[]}]` {
+		t.Errorf("Unexpected outline for H2s: %v", result)
+	}
+
+	result, err = mql.ExecuteQuery(doc, `.headings(6) | outline_json`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if result != "[]" {
+		t.Errorf("Expected an empty array, got %v", result)
+	}
+
+	page, err := html.ParseHTML([]byte("<html><body><h2>Two</h2><p>Text.</p><h3>Three</h3><p>More.</p><h2>Four</h2><p>End.</p></body></html>"), "page.html")
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+	result, err = mql.ExecuteQuery(page, `.headings | outline_json`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if result != `[{"text":"Two","level":2,"id":"two","children":[{"text":"Three","level":3,"id":"three","children":[]}]},{"text":"Four","level":2,"id":"four","children":[]}]` {
+		t.Errorf("Expected Three under Two in the HTML outline, got %v", result)
+	}
+
+	if _, err := mql.ExecuteQuery(doc, `.code | outline_json`); !errors.Is(err, mql.ErrTypeMismatch) {
+		t.Errorf("Expected type mismatch for outline_json on code blocks, got %v", err)
+	}
+}
+
+func TestExecuteFrom(t *testing.T) {
+	engine := mq.New()
+	content := "# API\n\n## Auth\n\n```go\nlogin()\n```\n\nSee [docs](https://example.com/auth).\n\n## Limits\n\n```sh\ncurl\n```\n"
+	doc, err := engine.ParseDocument([]byte(content), "api.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	executor := mql.NewQueryExecutor(mql.WithQueryCache())
+	section, err := executor.Execute(doc, `.section("Auth")`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	result, err := executor.ExecuteFrom(section, `.code | .language`)
+	if err != nil {
+		t.Fatalf("ExecuteFrom failed: %v", err)
+	}
+	if !reflect.DeepEqual(result, []string{"go"}) {
+		t.Errorf("Expected the section's code languages, got %v", result)
+	}
+
+	// Document selectors still resolve against the section's document
+	result, err = executor.ExecuteFrom(section, `.section("Limits") | .code | .language`)
+	if err != nil {
+		t.Fatalf("ExecuteFrom failed: %v", err)
+	}
+	if !reflect.DeepEqual(result, []string{"sh"}) {
+		t.Errorf("Expected the Limits code languages, got %v", result)
+	}
+
+	// The same compiled fragment applied to each element
+	sections, err := executor.Execute(doc, `.sections[1:]`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	var titles []interface{}
+	for _, s := range sections.([]*mq.Section) {
+		title, err := executor.ExecuteFrom(s, `.heading | .text`)
+		if err != nil {
+			t.Fatalf("ExecuteFrom failed: %v", err)
+		}
+		titles = append(titles, title)
+	}
+	if !reflect.DeepEqual(titles, []interface{}{"Auth", "Limits"}) {
+		t.Errorf("Expected section titles, got %v", titles)
+	}
+
+	// Values without a document support properties and functions only
+	result, err = executor.ExecuteFrom(doc.GetLinks(), `map(.url)`)
+	if err != nil {
+		t.Fatalf("ExecuteFrom failed: %v", err)
+	}
+	if !reflect.DeepEqual(result, []interface{}{"https://example.com/auth"}) {
+		t.Errorf("Expected link URLs, got %v", result)
+	}
+	if _, err := executor.ExecuteFrom(doc.GetLinks(), `.headings`); err == nil {
+		t.Error("Expected an error for a document selector without a document")
+	}
+
+	result, err = executor.ExecuteFrom(doc, `.headings | length`)
+	if err != nil || result != 3 {
+		t.Errorf("Expected 3 headings from the document, got %v (%v)", result, err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	markdown := []byte(strings.Repeat("# Title\n\n[link](https://example.com)\n\n", 5))
+	items := []byte(`[1, "two", {"three": 3}, [4]]`)
+
+	cases := []struct {
+		name    string
+		limits  mq.Limits
+		content []byte
+		path    string
+	}{
+		{"headings", mq.Limits{MaxHeadings: 4}, markdown, "doc.md"},
+		{"sections", mq.Limits{MaxSections: 4}, markdown, "doc.md"},
+		{"elements", mq.Limits{MaxElements: 4}, markdown, "doc.md"},
+		{"sections", mq.Limits{MaxSections: 3}, items, "data.json"},
+	}
+	for _, tc := range cases {
+		engine := mql.New(mql.WithLimits(tc.limits))
+		_, err := engine.ParseDocument(tc.content, tc.path)
+		if !errors.Is(err, mq.ErrLimitExceeded) {
+			t.Errorf("%s %s: expected ErrLimitExceeded, got %v", tc.path, tc.name, err)
+			continue
+		}
+		var limitErr *mq.LimitError
+		if !errors.As(err, &limitErr) || limitErr.Limit != tc.name {
+			t.Errorf("%s: expected %s limit error, got %v", tc.path, tc.name, err)
+		}
+	}
+
+	// At the limit, and without limits, documents parse in full
+	engine := mql.New(mql.WithLimits(mq.Limits{MaxHeadings: 5, MaxSections: 5, MaxElements: 5}))
+	if _, err := engine.ParseDocument(markdown, "doc.md"); err != nil {
+		t.Errorf("document at the limits: %v", err)
+	}
+	many := []byte("[" + strings.Repeat(`"x", 1, `, 100) + `"last"]`)
+	doc, err := mql.New().ParseDocument(many, "many.json")
+	if err != nil {
+		t.Fatalf("unlimited engine: %v", err)
+	}
+	if n := len(doc.GetSections()); n != 201 {
+		t.Errorf("expected every array item as a section, got %d", n)
+	}
+
+	// Output size
+	engine = mql.New(mql.WithLimits(mq.Limits{MaxOutputBytes: 200}))
+	doc, err = engine.ParseDocument(markdown, "doc.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	if err := engine.QueryStream(doc, ".headings | length", &buf); err != nil || buf.String() != "5\n" {
+		t.Errorf("small result: %q, %v", buf.String(), err)
+	}
+	buf.Reset()
+	err = engine.QueryStream(doc, ".sections", &buf)
+	var limitErr *mq.LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != "output bytes" || limitErr.Max != 200 {
+		t.Errorf("expected output bytes limit error, got %v", err)
+	}
+	if buf.Len() > 200 {
+		t.Errorf("expected output to stop at the limit, got %d bytes", buf.Len())
+	}
+	// Results used in Go are not measured
+	sections, err := engine.QueryWithExecutor(doc, ".sections")
+	if err != nil {
+		t.Errorf("expected an unlimited result, got %v", err)
+	}
+	if err := engine.StreamResult(sections, io.Discard); !errors.Is(err, mq.ErrLimitExceeded) {
+		t.Errorf("expected the streamed result to be limited, got %v", err)
+	}
+}
+
+func TestWithoutAlt(t *testing.T) {
+	content := []byte(`# Guide
+
+![](empty.png) and ![Diagram](diagram.png)
+
+## Setup
+
+![   ](blank.png)
+`)
+	engine := mql.New()
+	doc, err := engine.ParseDocument(content, "test.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{".images | without_alt", ".images | .without_alt", ".without_alt"} {
+		result, err := engine.Query(doc, query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		images, ok := result.([]*mq.Image)
+		if !ok || len(images) != 2 {
+			t.Fatalf("%s: expected 2 images, got %#v", query, result)
+		}
+		if images[0].URL != "empty.png" || images[1].URL != "blank.png" {
+			t.Errorf("%s: unexpected images %s, %s", query, images[0].URL, images[1].URL)
+		}
+	}
+
+	result, err := engine.Query(doc, `.images | without_alt | map(.section)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, []interface{}{"Guide", "Setup"}) {
+		t.Errorf("expected enclosing sections, got %#v", result)
+	}
+
+	result, err = engine.Query(doc, `.section("Setup") | without_alt | length`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != 1 {
+		t.Errorf("expected 1 image in Setup, got %v", result)
+	}
+
+	if _, err := engine.Query(doc, ".links | without_alt"); !errors.Is(err, mql.ErrTypeMismatch) {
+		t.Errorf("expected type mismatch for links, got %v", err)
+	}
+}
+
+func TestStructureIssues(t *testing.T) {
+	doc, err := mq.NewParser().Parse([]byte("# Guide\n\n### Options\n\nText.\n\n# Appendix\n"), "test.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := mql.ExecuteQuery(doc, `.structure_issues | map(.rule)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{"skipped-level", "multiple-h1", "empty-section"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+
+	result, err = mql.ExecuteQuery(doc, `.structure_issues | select(.level == 1) | map(.heading)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, []interface{}{"Appendix", "Appendix"}) {
+		t.Errorf("expected both Appendix issues, got %v", result)
+	}
+
+	schema, err := mql.ResultSchema(`.structure_issues | map(.line)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.Type != "array" || schema.Items == nil || schema.Items.Type != "number" {
+		t.Errorf("expected array of numbers, got %+v", schema)
+	}
+}
+
+func TestCardSelector(t *testing.T) {
+	doc, err := mq.NewParser().Parse([]byte("---\ntitle: Notes\ntags: [a]\n---\n# Notes\n\nHello.\n"), "notes.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := mql.ExecuteQuery(doc, `.card`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"first_paragraph":"Hello.","owner":null,"sections":1,"tags":["a"],"title":"Notes"}`
+	if string(out) != expected {
+		t.Errorf("expected %s, got %s", expected, out)
+	}
+
+	result, err = mql.ExecuteQuery(doc, `.card("title", "format") | .format`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "markdown" {
+		t.Errorf("expected markdown, got %v", result)
+	}
+
+	if _, err := mql.ExecuteQuery(doc, `.card("title", 2)`); !errors.Is(err, mql.ErrTypeMismatch) {
+		t.Errorf("expected type mismatch for a numeric field, got %v", err)
+	}
+}
+
+func TestRegexSelectors(t *testing.T) {
+	doc, err := mq.NewParser().Parse([]byte(`# API
+
+## GET /users
+
+List users.
+
+## POST /users
+
+Create a user.
+
+### GET /users/{id} example
+
+## Errors
+
+## GET /health
+`), "api.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query    string
+		expected []interface{}
+	}{
+		{`.sections(/^GET /) | map(.heading | .text)`, []interface{}{"GET /users", "GET /users/{id} example", "GET /health"}},
+		{`.sections(/^(GET|POST) \/users$/) | map(.heading | .text)`, []interface{}{"GET /users", "POST /users"}},
+		{`.headings(/users/) | map(.level)`, []interface{}{2, 2, 3}},
+		{`.headings(3, /^GET/) | map(.text)`, []interface{}{"GET /users/{id} example"}},
+		{`.sections(/nothing/) | map(.heading | .text)`, []interface{}{}},
+	}
+	for _, test := range tests {
+		result, err := mql.ExecuteQuery(doc, test.query)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.query, test.expected, result)
+		}
+	}
+
+	_, err = mql.ExecuteQuery(doc, `.sections(/[a-/)`)
+	var perr *mql.ParseError
+	if !errors.As(err, &perr) || !strings.Contains(perr.Msg, "invalid regex") || perr.Col != 11 {
+		t.Errorf("expected invalid regex parse error at column 11, got %v", err)
+	}
+	if diags := mql.Validate(`.headings(/(/)`, nil); len(diags) != 1 || diags[0].Severity != mql.SeverityError {
+		t.Errorf("expected one validation error, got %v", diags)
+	}
+
+	for _, query := range []string{
+		`.headings | select(.text | contains(/^Inst/))`,
+		`.section(/Install/)`,
+	} {
+		if _, err := mql.ExecuteQuery(doc, query); !errors.Is(err, mql.ErrTypeMismatch) {
+			t.Errorf("%s: expected a type mismatch for a regex argument, got %v", query, err)
+		}
+	}
+}
+
+func TestTablesNormalizeAcrossFormats(t *testing.T) {
+	sources := map[string]string{
+		"table.md": `| Plan | Price |
+|------|-------|
+| Free |  0 |
+| Pro  | 10 |
+`,
+		"table.html": `<html><body><main><table>
+<thead><tr><th> Plan </th><th>Price</th></tr></thead>
+<tbody>
+<tr><td>Free</td><td>
+  0
+</td></tr>
+<tr><td>Pro</td><td>10</td></tr>
+</tbody></table></main></body></html>`,
+		"table.json": `[{"Plan": "Free", "Price": 0}, {"Plan": "Pro", "Price": 10}]`,
+	}
+	expected := []interface{}{
+		map[string]interface{}{"Plan": "Free", "Price": "0"},
+		map[string]interface{}{"Plan": "Pro", "Price": "10"},
+	}
+
+	engine := mql.New()
+	for path, content := range sources {
+		doc, err := engine.ParseDocument([]byte(content), path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		result, err := engine.Query(doc, `.tables[0] | .records`)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("%s: expected %v, got %v", path, expected, result)
+		}
+	}
+
+	// Ragged HTML: colspan, no header row, and a row wider than the rest
+	doc, err := engine.ParseDocument([]byte(`<html><body><main><table>
+<tr><td>Name</td><td colspan="2">Contact</td></tr>
+<tr><td>Ann</td><td>a@example.com</td><td>555</td><td>extra</td></tr>
+<tr><td>Bob</td></tr>
+</table></main></body></html>`), "ragged.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := doc.GetTables()[0]
+	if got := strings.Join(table.Headers, ","); got != "Name,Contact,Contact 2,Column 4" {
+		t.Errorf("unexpected headers: %s", got)
+	}
+	if !reflect.DeepEqual(table.Rows, [][]string{{"Ann", "a@example.com", "555", "extra"}, {"Bob", "", "", ""}}) {
+		t.Errorf("unexpected rows: %q", table.Rows)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	doc, err := mq.NewParser().Parse([]byte(`# API
+
+Authentication uses tokens. Tokens expire after an hour.
+
+## Errors
+
+Errors are JSON objects.
+
+`+"```go\nreturn err\n```"+`
+`), "api.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`.section("API") | .summary(40)`, "Authentication uses tokens. …"},
+		{`.section("API") | summary(60)`, "Authentication uses tokens. Tokens expire after an hour. …"},
+		{`.sections | summary(30)`, []string{"Authentication uses tokens. …", "Errors are JSON objects."}},
+		{`.code("go") | summary`, []string{"return err"}},
+		{`.section("Errors") | .text | summary(6)`, "## …"},
+	}
+	for _, test := range tests {
+		result, err := mql.ExecuteQuery(doc, test.query)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("%s: expected %#v, got %#v", test.query, test.expected, result)
+		}
+	}
+
+	if _, err := mql.ExecuteQuery(doc, `.section("API") | .summary(0)`); err == nil {
+		t.Error("expected an error for a zero budget")
+	}
+	if _, err := mql.ExecuteQuery(doc, `.headings | summary(10)`); !errors.Is(err, mql.ErrTypeMismatch) {
+		t.Errorf("expected type mismatch summarizing headings, got %v", err)
+	}
+}
+
+func TestDepth(t *testing.T) {
+	doc, err := mq.NewParser().Parse([]byte("# Guide\n\n### Quick start\n\n#### Shell\n\n## Setup\n\n# Reference\n\n## API\n"), "guide.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{`.depth(1)`, []string{"Guide", "Reference"}},
+		{`.depth(2)`, []string{"Quick start", "Setup", "API"}},
+		{`.depth(3)`, []string{"Shell"}},
+		{`.depth(4)`, nil},
+		{`.depth(2, true)`, []string{"Quick start", "Shell", "Setup", "API"}},
+	}
+	for _, test := range tests {
+		result, err := mql.ExecuteQuery(doc, test.query)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		var titles []string
+		for _, s := range result.([]*mq.Section) {
+			titles = append(titles, s.Heading.Text)
+		}
+		if !reflect.DeepEqual(titles, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.query, test.expected, titles)
+		}
+	}
+
+	for _, query := range []string{`.depth`, `.depth(0)`, `.depth("two")`} {
+		if _, err := mql.ExecuteQuery(doc, query); err == nil {
+			t.Errorf("expected an error for %s", query)
+		}
+	}
+}
+
+func TestChunk(t *testing.T) {
+	doc, err := mq.NewParser().Parse([]byte("# Guide\n\nInstall it first.\n\nThen run it.\n\n```go\nfmt.Println(1)\nfmt.Println(2)\n```\n\n```sh\nmq .\n```\n"), "guide.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{`.section("Guide") | .text | chunk(30)`, []string{"# Guide\n\nInstall it first.", "Then run it.", "```go\nfmt.Println(1)", "fmt.Println(2)\n```", "```sh\nmq .\n```"}},
+		{`.code | .chunk(25)`, []string{"```go\nfmt.Println(1)\n```", "```go\nfmt.Println(2)\n```", "```sh\nmq .\n```"}},
+		{`.code("sh") | .[0] | chunk(100)`, []string{"```sh\nmq .\n```"}},
+		{`.headings | .text | chunk(100)`, []string{"Guide"}},
+	}
+	for _, test := range tests {
+		result, err := mql.ExecuteQuery(doc, test.query)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("%s: expected %q, got %q", test.query, test.expected, result)
+		}
+	}
+
+	for _, query := range []string{`.chunk`, `.chunk(0)`, `.code | chunk(5)`} {
+		if _, err := mql.ExecuteQuery(doc, query); err == nil {
+			t.Errorf("expected an error for %s", query)
+		}
+	}
+	for _, query := range []string{`.headings | chunk(100)`, `.links | chunk(100)`, `.headings | length | chunk(100)`} {
+		if _, err := mql.ExecuteQuery(doc, query); !errors.Is(err, mql.ErrTypeMismatch) {
+			t.Errorf("%s: expected a type mismatch, got %v", query, err)
+		}
+	}
+
+	// A section and its subsection are chunked without repeating text
+	nested, err := mq.NewParser().Parse([]byte("# Guide\n\nIntro.\n\n## Install\n\nRun it.\n"), "guide.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := mql.ExecuteQuery(nested, `.sections | chunk(100)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"# Guide\n\nIntro.\n\n## Install\n\nRun it."}; !reflect.DeepEqual(result, want) {
+		t.Errorf("expected %q, got %q", want, result)
+	}
+}
+
+func TestContext(t *testing.T) {
+	doc, err := mq.NewParser().Parse([]byte(`# Guide
+
+## Authentication
+
+Clients authenticate with a token. The token flow starts at login.
+
+## Sessions
+
+A session starts after login and expires after an hour.
+
+## Errors
+
+Errors are returned as JSON.
+`), "guide.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{`.context("token flow")`, []string{"Authentication"}},
+		{`.context("login session")`, []string{"Sessions", "Authentication"}},
+		{`.context("login session", 1)`, []string{"Sessions"}},
+		{`.context("JSON errors after login")`, []string{"Errors", "Sessions", "Authentication"}},
+		{`.context("billing")`, nil},
+	}
+	for _, test := range tests {
+		result, err := mql.ExecuteQuery(doc, test.query)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		var titles []string
+		for _, s := range result.([]*mq.Section) {
+			titles = append(titles, s.Heading.Text)
+		}
+		if !reflect.DeepEqual(titles, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.query, test.expected, titles)
+		}
+	}
+
+	for _, query := range []string{`.context`, `.context(3)`, `.context("auth", 0)`} {
+		if _, err := mql.ExecuteQuery(doc, query); err == nil {
+			t.Errorf("expected an error for %s", query)
+		}
+	}
+}
+
+func TestSectionPath(t *testing.T) {
+	doc, err := mq.NewParser().Parse([]byte("# Guide\n\n## Setup\n\n### Linux\n"), "guide.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`.section("Linux") | .path`, "Guide > Setup > Linux"},
+		{`.sections | .path`, []string{"Guide", "Guide > Setup", "Guide > Setup > Linux"}},
+		{`.sections | map(.path)`, []interface{}{"Guide", "Guide > Setup", "Guide > Setup > Linux"}},
+		{`.sections | select(.path == "Guide > Setup") | length`, 1},
+	}
+	for _, test := range tests {
+		result, err := mql.ExecuteQuery(doc, test.query)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("%s: expected %#v, got %#v", test.query, test.expected, result)
+		}
+	}
+}
+
+func TestResultSchema(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{`.headings`, "array<Heading>"},
+		{`.headings | .[0]`, "Heading"},
+		{`.headings | [1:3]`, "array<Heading>"},
+		{`.owner`, "string"},
+		{`.code | length`, "number"},
+		{`.code("go") | only | .content`, "string"},
+		{`.sections | .heading`, "array<Heading>"},
+		{`.section("API") | .children`, "array<Section>"},
+		{`.headings | map(.level)`, "array<number>"},
+		{`.sections | map(.heading.level)`, "array<number>"},
+		{`.sections | .[0] | .heading.text`, "string"},
+		{`.headings | filter(.level == 2) | .text`, "array<string>"},
+		{`.links | map(.url | startswith("https"))`, "array<boolean>"},
+		{`.elements | map(.kind)`, "array<string>"},
+		{`.metadata | .authors`, "unknown"},
+		{`.meta("title")`, "unknown"},
+		{`.code | length > 0`, "boolean"},
+		{`.toc(2)`, "TOCResult"},
+		{`.search("x")`, "SearchResults"},
+		{`.section("x") | .path`, "string"},
+		{`.sections | .path`, "array<string>"},
+		{`.tf("x")`, "TermFrequencies"},
+		{`.meta("owner")`, "unknown"},
+		{`.toc | .entries | map(.level)`, "array<number>"},
+		{`.toc("md")`, "string"},
+		{`.listitems | .[0]`, "FlatListItem"},
+		{`.listitems | .[0] | .children`, "unknown"},
+		{`.flatten_lists | map(.depth)`, "array<number>"},
+		{`.lists | .[0] | .items | .[0] | .children`, "array<ListItem>"},
+	}
+	for _, tt := range tests {
+		schema, err := mql.ResultSchema(tt.query)
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if got := schema.String(); got != tt.expected {
+			t.Errorf("Query '%s': expected %s, got %s", tt.query, tt.expected, got)
+		}
+	}
+
+	schema, _ := mql.ResultSchema(`.headings`)
+	if schema.Type != "array" || schema.Items == nil || schema.Items.Title != "Heading" {
+		t.Errorf("Unexpected schema structure: %+v", schema)
+	}
+
+	var perr *mql.ParseError
+	if _, err := mql.ResultSchema(`.headings | filter(`); !errors.As(err, &perr) {
+		t.Errorf("Expected ParseError for invalid query, got %v", err)
+	}
+}
+
+func TestReduce(t *testing.T) {
+	engine := mq.New()
+	doc, err := engine.ParseDocument([]byte("# Guide\n\n```go\na\nb\n```\n\n## Setup\n\n```sh\nmake\n```\n\n### Linux\n"), "reduce.md")
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`.code | reduce(0; . + .lines)`, 3},
+		{`.headings | reduce(0; . + .level)`, 6},
+		{`.headings | reduce(""; . + .text + "/")`, "Guide/Setup/Linux/"},
+		{`.headings | reduce(0; . + (.text | length))`, 15},
+		{`.headings | reduce(1; . * .level)`, 6},
+		{`.headings | filter(.level > 1) | reduce([]; . + [.text])`, []interface{}{"Setup", "Linux"}},
+		{`.code("rust") | reduce(0; . + .lines)`, 0},
+		{`1 - 2`, -1},
+		{`1 + 2 * 3`, 7},
+		{`2`, 2},
+		{`.headings | length - 1`, 2},
+		{`.headings | length() * 2 > 5`, true},
+		{`.headings | map(reduce(0; . + 1))`, nil},
+		{`.headings | reduce(0; . + .level -1)`, 3},
+		{`.headings | reduce(0, . + .level)`, 6},
+		{`.headings | filter(.level > -1) | length`, 3},
+		{`.section("Guide"; "Setup")`, nil},
+		{`.headings | map(.level; .text)`, nil},
+	}
+	for _, tt := range tests {
+		result, err := mql.ExecuteQuery(doc, tt.query)
+		if tt.expected == nil {
+			if err == nil {
+				t.Errorf("Query '%s': expected error, got %v", tt.query, result)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Query '%s' failed: %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("Query '%s': expected %v (%T), got %v (%T)", tt.query, tt.expected, tt.expected, result, result)
+		}
+	}
+
+	if schema, err := mql.ResultSchema(`.code | reduce(0; . + .lines)`); err != nil || schema.String() != "number" {
+		t.Errorf("Expected number schema for reduce, got %v (%v)", schema, err)
+	}
+}
+
+func TestSearchElementsSelector(t *testing.T) {
+	doc, err := mq.NewParser().Parse([]byte("# Deploy\n\nRun deploy from CI.\n\n```bash\n./deploy.sh\n```\n"), "ops.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := mql.ExecuteQuery(doc, `.search_elements("deploy", "code", "prose")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, ok := result.(*mq.SearchResults)
+	if !ok {
+		t.Fatalf("expected search results, got %T", result)
+	}
+	var got []string
+	for _, m := range results.Matches {
+		got = append(got, m.Where())
+	}
+	if strings.Join(got, ",") != "prose,code block (bash)" {
+		t.Errorf("expected prose and code matches, got %v", got)
+	}
+
+	if _, err := mql.ExecuteQuery(doc, `.search_elements("deploy", "link")`); err == nil {
+		t.Error("expected an error for a kind search does not report")
+	}
+	if _, err := mql.ExecuteQuery(doc, `.search_elements("deploy", 1)`); !errors.Is(err, mql.ErrTypeMismatch) {
+		t.Errorf("expected type mismatch for a numeric kind, got %v", err)
+	}
+}
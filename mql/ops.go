@@ -0,0 +1,118 @@
+package mql
+
+// SelectorInfo describes a selector such as .headings or .section("Name").
+type SelectorInfo struct {
+	Name        string `json:"name"`
+	Args        string `json:"args,omitempty"` // Argument signature, e.g. `("name", ...)`
+	Scope       string `json:"scope"`          // "document", or "section" for selectors applied to a section
+	Description string `json:"description"`
+}
+
+// FunctionInfo describes a function such as map(expr) or length.
+type FunctionInfo struct {
+	Name        string `json:"name"`
+	Args        string `json:"args,omitempty"` // Argument signature, e.g. `(init; expr)`
+	Description string `json:"description"`
+}
+
+var selectors = []SelectorInfo{
+	{Name: "tree", Args: `(mode?, length?)`, Scope: "document", Description: "Structure with line ranges; modes \"compact\", \"preview\", \"full\""},
+	{Name: "search", Args: `("term")`, Scope: "document", Description: "Sections containing a term"},
+	{Name: "search_elements", Args: `("term", kinds...)`, Scope: "document", Description: "Headings, code blocks, table cells, list items and paragraphs containing a term, optionally of the given kinds"},
+	{Name: "tf", Args: `("term")`, Scope: "document", Description: "Sections ranked by term frequency"},
+	{Name: "context", Args: `("query", k?)`, Scope: "document", Description: "The k (default 3) sections most relevant to a query, as whole sections"},
+	{Name: "section", Args: `("name", ...)`, Scope: "document", Description: "Section by heading, #anchor, or ancestor path"},
+	{Name: "sections", Args: `(/regex/?)`, Scope: "document", Description: "All sections, or those whose heading matches a regex"},
+	{Name: "depth", Args: `(n, deeper?)`, Scope: "document", Description: "Sections nested n levels deep (1 = top level), optionally with everything below"},
+	{Name: "headings", Args: `(levels..., /regex/?)`, Scope: "document", Description: "Headings, optionally of the given levels or matching a regex"},
+	{Name: "code", Args: `("lang", ...)`, Scope: "document", Description: "Code blocks, optionally by language (also on a section)"},
+	{Name: "links", Scope: "document", Description: "Links, including bare URLs and emails"},
+	{Name: "images", Scope: "document", Description: "Images"},
+	{Name: "tables", Scope: "document", Description: "Tables"},
+	{Name: "lists", Scope: "document", Description: "Top-level lists with nested items"},
+	{Name: "listitems", Scope: "document", Description: "Every list item with its depth (alias: flatten_lists)"},
+	{Name: "flatten_lists", Scope: "document", Description: "Every list item with its depth (alias: listitems)"},
+	{Name: "symbols", Scope: "document", Description: "LSP-style outline with line/col ranges"},
+	{Name: "elements", Scope: "document", Description: "Structural elements in source order"},
+	{Name: "strikethrough", Scope: "document", Description: "Struck-through spans with their enclosing section"},
+	{Name: "components", Args: `("Name", ...)`, Scope: "document", Description: "HTML and MDX component blocks, optionally by tag name (parsers WithComponents)"},
+	{Name: "lines", Args: `(start, end?)`, Scope: "document", Description: "Raw source for a line range"},
+	{Name: "metadata", Scope: "document", Description: "Frontmatter"},
+	{Name: "meta", Args: `("a.b")`, Scope: "document", Description: "Frontmatter field by name or dotted path (alias: field)"},
+	{Name: "field", Args: `("a.b")`, Scope: "document", Description: "Frontmatter field by name or dotted path (alias: meta)"},
+	{Name: "fields", Scope: "document", Description: "Frontmatter keys with inferred types"},
+	{Name: "structure_issues", Scope: "document", Description: "Heading hierarchy problems: skipped levels, multiple H1s, empty sections"},
+	{Name: "title", Scope: "document", Description: "Document title: frontmatter, declared title, first H1, or file name"},
+	{Name: "owner", Scope: "document", Description: "Frontmatter owner"},
+	{Name: "tags", Scope: "document", Description: "Frontmatter tags"},
+	{Name: "priority", Scope: "document", Description: "Frontmatter priority"},
+	{Name: "data", Scope: "document", Description: "Decoded value of JSON/JSONL/YAML files"},
+	{Name: "language", Scope: "document", Description: "Natural language of the document"},
+	{Name: "text", Args: `("with-meta"?)`, Scope: "document", Description: "Raw content of the document or current value"},
+	{Name: "lead", Scope: "document", Description: "First paragraph of the document or section"},
+	{Name: "summary", Args: `(n?)`, Scope: "document", Description: "Sentence-aware summary of at most n characters (default 100) of the current value"},
+	{Name: "chunk", Args: `(n)`, Scope: "document", Description: "Split the current value's text into pieces of at most n characters"},
+	{Name: "card", Args: `(fields...)`, Scope: "document", Description: "Summary object of frontmatter and structure; default title, owner, tags, sections, first_paragraph"},
+	{Name: "html", Scope: "document", Description: "Render the current value as an HTML fragment"},
+	{Name: "reduction", Scope: "document", Description: "Source size vs. extracted text"},
+	{Name: "toc", Args: `("md"?, maxLevel?)`, Scope: "document", Description: "Table of contents of the document or section, or with \"md\" a markdown one with anchor links"},
+	{Name: "between", Args: `("start", "end", "inclusive"?)`, Scope: "document", Description: "Raw source between two headings"},
+	{Name: "length", Scope: "document", Description: "Length of the current value"},
+	{Name: "domains", Scope: "document", Description: "Distinct hosts of absolute link URLs"},
+	{Name: "without_alt", Scope: "document", Description: "Images with empty or whitespace alt text"},
+	{Name: "only", Scope: "document", Description: "Sole element of a one-item collection (alias: unwrap)"},
+	{Name: "unwrap", Scope: "document", Description: "Sole element of a one-item collection (alias: only)"},
+	{Name: "children", Scope: "section", Description: "Direct subsections"},
+	{Name: "siblings", Scope: "section", Description: "Other sections at the same level"},
+	{Name: "ancestors", Scope: "section", Description: "Enclosing sections from the root down"},
+	{Name: "next", Scope: "section", Description: "Next section at the same level, or null"},
+	{Name: "prev", Scope: "section", Description: "Previous section at the same level, or null"},
+	{Name: "head", Args: `(n?)`, Scope: "section", Description: "First lines of the section body (default 10)"},
+	{Name: "tail", Args: `(n?)`, Scope: "section", Description: "Last lines of the section body (default 10)"},
+	{Name: "prose", Scope: "section", Description: "Section text without code blocks or tables"},
+	{Name: "body", Scope: "section", Description: "Section text without its heading line"},
+	{Name: "path", Scope: "section", Description: "Heading path of the section"},
+}
+
+var functions = []FunctionInfo{
+	{Name: "select", Args: `(predicate)`, Description: "Keep elements matching a predicate (alias: filter)"},
+	{Name: "filter", Args: `(predicate)`, Description: "Keep elements matching a predicate (alias: select)"},
+	{Name: "map", Args: `(expr)`, Description: "Apply an expression to each element"},
+	{Name: "sort_by", Args: `(expr)`, Description: "Order a collection by a key"},
+	{Name: "count_by", Args: `(expr)`, Description: "Frequency table of a key, most common first"},
+	{Name: "distinct_by", Args: `(expr)`, Description: "First element for each distinct key, in order"},
+	{Name: "sample", Args: `(n)`, Description: "Up to n elements chosen at random"},
+	{Name: "reduce", Args: `(init; expr)`, Description: "Fold a collection; '.' is the accumulator"},
+	{Name: "contains", Args: `("s")`, Description: "Substring or element membership"},
+	{Name: "startswith", Args: `("s")`, Description: "String prefix test"},
+	{Name: "endswith", Args: `("s")`, Description: "String suffix test"},
+	{Name: "contains_any", Args: `([values])`, Description: "True if any value is present"},
+	{Name: "contains_all", Args: `([values])`, Description: "True if every value is present"},
+	{Name: "length", Description: "Length of a string or collection"},
+	{Name: "empty", Description: "True for nil, \"\" or an empty collection"},
+	{Name: "nonempty", Description: "Negation of empty"},
+	{Name: "default", Args: `(value)`, Description: "Fallback for a nil or empty value"},
+	{Name: "preview", Args: `(n?)`, Description: "Truncate a string or collection (default 100)"},
+	{Name: "summary", Args: `(n?)`, Description: "Sentence-aware summary of at most n characters (default 100); maps over collections"},
+	{Name: "chunk", Args: `(n)`, Description: "Split text into pieces of at most n characters at paragraph, line, then word boundaries; code blocks chunk separately as fenced blocks"},
+	{Name: "domains", Description: "Distinct hosts of absolute link URLs"},
+	{Name: "without_alt", Description: "Images with empty or whitespace alt text"},
+	{Name: "only", Description: "Sole element of a one-item collection (alias: unwrap)"},
+	{Name: "unwrap", Description: "Sole element of a one-item collection (alias: only)"},
+	{Name: "flatten", Description: "Concatenate nested collections one level deep"},
+	{Name: "tree_text", Description: "Indented text outline of a heading list"},
+	{Name: "outline_json", Description: "Nested JSON tree of a heading list (text, level, id, children)"},
+	{Name: "meta", Args: `("a.b")`, Description: "Frontmatter field by name or dotted path (alias: field)"},
+	{Name: "field", Args: `("a.b")`, Description: "Frontmatter field by name or dotted path (alias: meta)"},
+	{Name: "path", Args: `("a.b[0].c")`, Description: "Nested frontmatter value with array indices"},
+}
+
+// Selectors returns every supported selector in documentation order.
+func Selectors() []SelectorInfo {
+	return append([]SelectorInfo(nil), selectors...)
+}
+
+// Functions returns every supported function in documentation order.
+func Functions() []FunctionInfo {
+	return append([]FunctionInfo(nil), functions...)
+}
@@ -2,6 +2,7 @@ package mql
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
 )
 
@@ -37,6 +38,15 @@ func ParseString(query string) (QueryNode, error) {
 
 // Parse parses the tokens into an AST.
 func (p *Parser) Parse() (QueryNode, error) {
+	if p.current().Type == TokenEOF {
+		return nil, p.error("empty query")
+	}
+
+	// Allow a leading pipe so multi-line queries can start every line with '|'
+	if p.current().Type == TokenPipe && p.peek().Type != TokenEOF {
+		p.advance()
+	}
+
 	ast, err := p.parseExpression()
 	if err != nil {
 		return nil, err
@@ -52,7 +62,7 @@ func (p *Parser) Parse() (QueryNode, error) {
 
 // parseExpression parses a full expression (handles pipes).
 func (p *Parser) parseExpression() (QueryNode, error) {
-	left, err := p.parsePrimary()
+	left, err := p.parseStage()
 	if err != nil {
 		return nil, err
 	}
@@ -61,7 +71,11 @@ func (p *Parser) parseExpression() (QueryNode, error) {
 	for p.current().Type == TokenPipe {
 		p.advance() // consume pipe
 
-		right, err := p.parsePrimary()
+		if p.current().Type == TokenEOF {
+			return nil, p.error("expected expression after '|'")
+		}
+
+		right, err := p.parseStage()
 		if err != nil {
 			return nil, err
 		}
@@ -72,14 +86,62 @@ func (p *Parser) parseExpression() (QueryNode, error) {
 	return left, nil
 }
 
-// parsePrimary parses a primary expression.
+// parseStage parses one pipeline stage: primary expressions combined with
+// arithmetic and optionally compared against a value, as in
+// `.code("go") | length() > 0` or `.headings | length() - 1`.
+func (p *Parser) parseStage() (QueryNode, error) {
+	left, err := p.parseAdditive(p.parsePrimary)
+	if err != nil {
+		return nil, err
+	}
+
+	token := p.current()
+	switch token.Type {
+	case TokenEquals, TokenNotEquals, TokenLessThan, TokenLessEqual, TokenGreaterThan, TokenGreaterEqual:
+		p.advance()
+		right, err := p.parseAdditive(p.parseProperty)
+		if err != nil {
+			return nil, err
+		}
+		return NewBinary(left, token.Value, right), nil
+	}
+
+	return left, nil
+}
+
+// parsePrimary parses a primary expression, followed by any number of
+// index or slice suffixes as in `.headings[0]` or `.code[1:3]`.
 func (p *Parser) parsePrimary() (QueryNode, error) {
+	node, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.current().Type == TokenLBracket {
+		if node, err = p.parseIndex(node); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+// parseOperand parses a primary expression without index suffixes.
+func (p *Parser) parseOperand() (QueryNode, error) {
 	token := p.current()
 
 	switch token.Type {
 	case TokenDot:
+		// `.[0]` indexes the current value, as in jq
+		if p.peek().Type == TokenLBracket {
+			p.advance()
+			return NewIdentifier("."), nil
+		}
 		return p.parseSelector()
 
+	case TokenLBracket:
+		// A bare `[0]` or `[1:3]` stage indexes the piped value
+		return NewIdentifier("."), nil
+
 	case TokenIdentifier:
 		// Check if it's a function call or selector
 		if p.peek().Type == TokenLParen {
@@ -105,6 +167,13 @@ func (p *Parser) parsePrimary() (QueryNode, error) {
 		p.advance()
 		return NewLiteral(token.Value, LiteralString), nil
 
+	case TokenRegex:
+		if _, err := regexp.Compile(token.Value); err != nil {
+			return nil, p.error("invalid regex /%s/: %v", token.Value, err)
+		}
+		p.advance()
+		return NewLiteral(token.Value, LiteralRegex), nil
+
 	case TokenNumber:
 		p.advance()
 		num, err := p.parseNumber(token.Value)
@@ -135,7 +204,7 @@ func (p *Parser) parseSelector() (QueryNode, error) {
 	var args []QueryNode
 	if p.current().Type == TokenLParen {
 		var err error
-		args, err = p.parseArguments()
+		args, err = p.parseArguments(name)
 		if err != nil {
 			return nil, err
 		}
@@ -150,16 +219,30 @@ func (p *Parser) parseSelector() (QueryNode, error) {
 		}
 		return NewFilter(args[0]), nil
 
-	case "map":
+	case "map", "sort_by", "count_by", "distinct_by":
 		if len(args) == 0 {
-			return nil, p.error("map requires a transformation argument")
+			return nil, p.error("%s requires a transformation argument", name)
 		}
-		return NewFunction("map", args...), nil
+		return NewFunction(name, args...), nil
 
 	default:
-		// Regular selector
-		return NewSelector(name, args...), nil
+		// Regular selector, possibly chained as in .heading.text
+		return p.parseMemberAccess(NewSelector(name, args...), p.parseSelector)
+	}
+}
+
+// parseMemberAccess parses a `.name` chained onto node, as in
+// .heading.text, into a pipe reading the property from node's result.
+// parse parses the rest of the chain, including further links.
+func (p *Parser) parseMemberAccess(node QueryNode, parse func() (QueryNode, error)) (QueryNode, error) {
+	if p.current().Type != TokenDot || p.peek().Type != TokenIdentifier {
+		return node, nil
 	}
+	member, err := parse()
+	if err != nil {
+		return nil, err
+	}
+	return NewPipe(node, member), nil
 }
 
 // parseFunction parses a function call.
@@ -171,7 +254,7 @@ func (p *Parser) parseFunction() (QueryNode, error) {
 	name := p.current().Value
 	p.advance()
 
-	args, err := p.parseArguments()
+	args, err := p.parseArguments(name)
 	if err != nil {
 		return nil, err
 	}
@@ -189,8 +272,9 @@ func (p *Parser) parseFunction() (QueryNode, error) {
 	}
 }
 
-// parseArguments parses function arguments.
-func (p *Parser) parseArguments() ([]QueryNode, error) {
+// parseArguments parses the arguments of the function or selector name.
+// They are separated by commas, or by semicolons in reduce(init; expr).
+func (p *Parser) parseArguments(name string) ([]QueryNode, error) {
 	if err := p.expect(TokenLParen); err != nil {
 		return nil, err
 	}
@@ -211,10 +295,13 @@ func (p *Parser) parseArguments() ([]QueryNode, error) {
 		}
 		args = append(args, arg)
 
-		if p.current().Type == TokenComma {
-			p.advance() // consume comma
+		if p.current().Type == TokenComma || (p.current().Type == TokenSemicolon && name == "reduce") {
+			p.advance() // consume separator
 			continue
 		}
+		if p.current().Type == TokenSemicolon {
+			return nil, p.error("';' separates arguments only in reduce(init; expr); use ',' in %s", name)
+		}
 
 		if p.current().Type == TokenRParen {
 			p.advance() // consume )
@@ -228,14 +315,70 @@ func (p *Parser) parseArguments() ([]QueryNode, error) {
 }
 
 // parseArgument parses a single argument (could be expression or predicate).
+// Arguments may themselves be pipelines, as in `sort_by(.text | length)`,
+// which are evaluated against each element by the enclosing function.
 func (p *Parser) parseArgument() (QueryNode, error) {
-	// Try to parse as a comparison/predicate first
-	return p.parseComparison()
+	left, err := p.parseLogical()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.current().Type == TokenPipe {
+		p.advance() // consume pipe
+		right, err := p.parseLogical()
+		if err != nil {
+			return nil, err
+		}
+		left = NewPipe(left, right)
+	}
+
+	return left, nil
 }
 
-// parseComparison parses comparison expressions.
+// parseLogical parses "or" expressions, the loosest-binding operator.
+func (p *Parser) parseLogical() (QueryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.current().Type == TokenOr {
+		token := p.current()
+		p.advance()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = NewBinary(left, token.Value, right)
+	}
+
+	return left, nil
+}
+
+// parseAnd parses "and" expressions, which bind tighter than "or".
+func (p *Parser) parseAnd() (QueryNode, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.current().Type == TokenAnd {
+		token := p.current()
+		p.advance()
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = NewBinary(left, token.Value, right)
+	}
+
+	return left, nil
+}
+
+// parseComparison parses comparison expressions, so that
+// `.level == 2 and .text != ""` groups as two comparisons.
 func (p *Parser) parseComparison() (QueryNode, error) {
-	left, err := p.parseLogical()
+	left, err := p.parseAdditive(p.parseProperty)
 	if err != nil {
 		return nil, err
 	}
@@ -245,7 +388,7 @@ func (p *Parser) parseComparison() (QueryNode, error) {
 	switch token.Type {
 	case TokenEquals, TokenNotEquals, TokenLessThan, TokenLessEqual, TokenGreaterThan, TokenGreaterEqual:
 		p.advance()
-		right, err := p.parseLogical()
+		right, err := p.parseAdditive(p.parseProperty)
 		if err != nil {
 			return nil, err
 		}
@@ -255,25 +398,42 @@ func (p *Parser) parseComparison() (QueryNode, error) {
 	return left, nil
 }
 
-// parseLogical parses logical operations (and/or).
-func (p *Parser) parseLogical() (QueryNode, error) {
-	left, err := p.parseProperty()
+// parseAdditive parses "+" and "-" between operands read by operand, as in
+// `reduce(0; . + .lines)`.
+func (p *Parser) parseAdditive(operand func() (QueryNode, error)) (QueryNode, error) {
+	left, err := p.parseMultiplicative(operand)
 	if err != nil {
 		return nil, err
 	}
 
-	for {
+	for p.current().Type == TokenPlus || p.current().Type == TokenMinus {
 		token := p.current()
-		if token.Type == TokenAnd || token.Type == TokenOr {
-			p.advance()
-			right, err := p.parseProperty()
-			if err != nil {
-				return nil, err
-			}
-			left = NewBinary(left, token.Value, right)
-		} else {
-			break
+		p.advance()
+		right, err := p.parseMultiplicative(operand)
+		if err != nil {
+			return nil, err
 		}
+		left = NewBinary(left, token.Value, right)
+	}
+
+	return left, nil
+}
+
+// parseMultiplicative parses "*", which binds tighter than "+" and "-".
+func (p *Parser) parseMultiplicative(operand func() (QueryNode, error)) (QueryNode, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.current().Type == TokenStar {
+		token := p.current()
+		p.advance()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = NewBinary(left, token.Value, right)
 	}
 
 	return left, nil
@@ -288,7 +448,16 @@ func (p *Parser) parseProperty() (QueryNode, error) {
 		// Property access starting with dot
 		p.advance()
 		if p.current().Type != TokenIdentifier {
-			return nil, p.error("expected property name after '.', got %s", p.current())
+			// Bare '.' is the current value (the accumulator in reduce),
+			// optionally indexed as in `.[0]`
+			node := QueryNode(NewIdentifier("."))
+			for p.current().Type == TokenLBracket {
+				var err error
+				if node, err = p.parseIndex(node); err != nil {
+					return nil, err
+				}
+			}
+			return node, nil
 		}
 		name := p.current().Value
 		p.advance()
@@ -298,45 +467,63 @@ func (p *Parser) parseProperty() (QueryNode, error) {
 
 		// Handle array/object indexing
 		for p.current().Type == TokenLBracket {
-			node, _ = p.parseIndex(node)
+			var err error
+			if node, err = p.parseIndex(node); err != nil {
+				return nil, err
+			}
 		}
 
 		// Handle function calls on properties
 		if p.current().Type == TokenLParen {
-			args, err := p.parseArguments()
+			args, err := p.parseArguments(name)
 			if err != nil {
 				return nil, err
 			}
 			return NewFunction(name, args...), nil
 		}
 
-		return node, nil
+		return p.parseMemberAccess(node, p.parseProperty)
 
 	case TokenIdentifier:
+		if lit, ok := keywordLiteral(token.Value); ok && p.peek().Type != TokenLParen {
+			p.advance()
+			return lit, nil
+		}
+
 		// Simple identifier
 		p.advance()
 		node := QueryNode(NewIdentifier(token.Value))
 
 		// Handle array/object indexing
 		for p.current().Type == TokenLBracket {
-			node, _ = p.parseIndex(node)
+			var err error
+			if node, err = p.parseIndex(node); err != nil {
+				return nil, err
+			}
 		}
 
 		// Handle function call
 		if p.current().Type == TokenLParen {
-			args, err := p.parseArguments()
+			args, err := p.parseArguments(token.Value)
 			if err != nil {
 				return nil, err
 			}
 			return NewFunction(token.Value, args...), nil
 		}
 
-		return node, nil
+		return p.parseMemberAccess(node, p.parseProperty)
 
 	case TokenString:
 		p.advance()
 		return NewLiteral(token.Value, LiteralString), nil
 
+	case TokenRegex:
+		if _, err := regexp.Compile(token.Value); err != nil {
+			return nil, p.error("invalid regex /%s/: %v", token.Value, err)
+		}
+		p.advance()
+		return NewLiteral(token.Value, LiteralRegex), nil
+
 	case TokenNumber:
 		p.advance()
 		num, err := p.parseNumber(token.Value)
@@ -345,10 +532,13 @@ func (p *Parser) parseProperty() (QueryNode, error) {
 		}
 		return NewLiteral(num, LiteralNumber), nil
 
+	case TokenLBracket:
+		return p.parseArray()
+
 	case TokenLParen:
 		// Grouped expression
 		p.advance()
-		expr, err := p.parseComparison()
+		expr, err := p.parseArgument()
 		if err != nil {
 			return nil, err
 		}
@@ -362,6 +552,44 @@ func (p *Parser) parseProperty() (QueryNode, error) {
 	}
 }
 
+// parseArray parses an array literal such as ["api", "v2"].
+func (p *Parser) parseArray() (QueryNode, error) {
+	if err := p.expect(TokenLBracket); err != nil {
+		return nil, err
+	}
+
+	var elements []QueryNode
+	for p.current().Type != TokenRBracket {
+		elem, err := p.parseLogical()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, elem)
+
+		if p.current().Type == TokenComma {
+			p.advance()
+		} else if p.current().Type != TokenRBracket {
+			return nil, p.error("expected ',' or ']' in array, got %s", p.current())
+		}
+	}
+	p.advance() // consume ]
+
+	return NewArray(elements...), nil
+}
+
+// keywordLiteral maps the keywords true, false and null to literals.
+func keywordLiteral(name string) (QueryNode, bool) {
+	switch name {
+	case "true":
+		return NewLiteral(true, LiteralBoolean), true
+	case "false":
+		return NewLiteral(false, LiteralBoolean), true
+	case "null":
+		return NewLiteral(nil, LiteralNull), true
+	}
+	return nil, false
+}
+
 // parseIndex parses array/object indexing.
 func (p *Parser) parseIndex(object QueryNode) (QueryNode, error) {
 	if err := p.expect(TokenLBracket); err != nil {
@@ -419,9 +647,9 @@ func (p *Parser) parseIndex(object QueryNode) (QueryNode, error) {
 
 // parseNumber parses a number from string.
 func (p *Parser) parseNumber(s string) (interface{}, error) {
-	// Try integer first
-	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
-		return i, nil
+	// Try integer first; integers are ints, like lengths and counts
+	if i, err := strconv.ParseInt(s, 10, 0); err == nil {
+		return int(i), nil
 	}
 
 	// Try float
@@ -470,5 +698,5 @@ func (p *Parser) expect(typ TokenType) error {
 func (p *Parser) error(format string, args ...interface{}) error {
 	token := p.current()
 	msg := fmt.Sprintf(format, args...)
-	return fmt.Errorf("parse error at line %d, column %d: %s", token.Line, token.Col, msg)
+	return &ParseError{Phase: "parse", Line: token.Line, Col: token.Col, Msg: msg}
 }
@@ -1,7 +1,9 @@
 package mql
 
 import (
+	"errors"
 	"fmt"
+	"sync"
 
 	mq "github.com/muqsitnawaz/mq/lib"
 )
@@ -31,6 +33,7 @@ type QueryOption func(*queryOptions)
 type queryOptions struct {
 	strict bool
 	cache  bool
+	seed   *int64
 }
 
 // WithQueryCache enables query plan caching.
@@ -40,10 +43,21 @@ func WithQueryCache() QueryOption {
 	}
 }
 
+// WithSampleSeed fixes the seed used by sample, making its picks
+// reproducible across runs.
+func WithSampleSeed(seed int64) QueryOption {
+	return func(o *queryOptions) {
+		o.seed = &seed
+	}
+}
+
 // QueryExecutor provides advanced query execution with options.
+// It is safe for concurrent use; compiled plans are shared across goroutines
+// and each execution gets its own EvalContext.
 type QueryExecutor struct {
 	engine   *Engine
 	compiler *Compiler
+	mu       sync.RWMutex
 	cache    map[string]ExecutionPlan
 }
 
@@ -58,6 +72,9 @@ func NewQueryExecutor(opts ...QueryOption) *QueryExecutor {
 	if options.strict {
 		compilerOpts = append(compilerOpts, WithStrictMode())
 	}
+	if options.seed != nil {
+		compilerOpts = append(compilerOpts, WithSeed(*options.seed))
+	}
 
 	qe := &QueryExecutor{
 		compiler: NewCompiler(compilerOpts...),
@@ -72,28 +89,80 @@ func NewQueryExecutor(opts ...QueryOption) *QueryExecutor {
 
 // Execute executes a query on a document.
 func (qe *QueryExecutor) Execute(doc *mq.Document, query string) (interface{}, error) {
-	var plan ExecutionPlan
-	var err error
-
-	// Check cache if enabled
-	if qe.cache != nil {
-		if cached, ok := qe.cache[query]; ok {
-			plan = cached
-		} else {
-			plan, err = qe.compiler.CompileString(query)
-			if err != nil {
-				return nil, err
-			}
-			qe.cache[query] = plan
-		}
-	} else {
-		plan, err = qe.compiler.CompileString(query)
-		if err != nil {
-			return nil, err
-		}
+	plan, err := qe.plan(query)
+	if err != nil {
+		return nil, err
 	}
 
 	// Execute the plan
 	ctx := NewEvalContext(doc)
 	return plan(ctx)
 }
+
+// ExecuteFrom executes a query starting from current instead of a
+// document, e.g. ".heading.text" on a section or "map(.url)" on links
+// returned by an earlier query. Document selectors such as .section use
+// current's document when current is a document or a parsed section; for
+// other values the query may only use properties and functions.
+func (qe *QueryExecutor) ExecuteFrom(current interface{}, query string) (interface{}, error) {
+	plan, err := qe.plan(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc *mq.Document
+	switch c := current.(type) {
+	case *mq.Document:
+		doc = c
+	case *mq.Section:
+		doc = c.Document()
+	}
+	return plan(NewEvalContextFrom(doc, current))
+}
+
+// plan compiles query, reusing a cached plan if caching is enabled.
+func (qe *QueryExecutor) plan(query string) (ExecutionPlan, error) {
+	if qe.cache == nil {
+		return qe.compiler.CompileString(query)
+	}
+
+	qe.mu.RLock()
+	cached, ok := qe.cache[query]
+	qe.mu.RUnlock()
+	if ok {
+		return cached, nil
+	}
+
+	plan, err := qe.compiler.CompileString(query)
+	if err != nil {
+		return nil, err
+	}
+	qe.mu.Lock()
+	qe.cache[query] = plan
+	qe.mu.Unlock()
+	return plan, nil
+}
+
+// FilterDir builds a directory tree of the markdown files under dir for
+// which query yields a truthy, non-empty result, e.g.
+// `.code("python") | length > 0`. A query that fails on a file because of
+// its content (such as a missing section) counts as no match. Syntax
+// errors are reported up front, and errors matching ErrUnknownSelector or
+// ErrTypeMismatch, which mean the query itself is wrong, stop the walk.
+func FilterDir(dir string, query string, mode mq.TreeMode) (*mq.DirTreeResult, error) {
+	plan, err := NewCompiler().CompileString(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return mq.FilterDirTree(dir, mode, func(doc *mq.Document) (bool, error) {
+		result, err := plan(NewEvalContext(doc))
+		if errors.Is(err, ErrUnknownSelector) || errors.Is(err, ErrTypeMismatch) {
+			return false, err
+		}
+		if err != nil {
+			return false, nil
+		}
+		return toBool(result), nil
+	})
+}
@@ -0,0 +1,392 @@
+package mql
+
+import (
+	"fmt"
+)
+
+// Schema describes the statically inferred type of a query result, in the
+// spirit of JSON Schema: arrays carry an item schema and structural objects
+// are named by Title ("Heading", "Section", ...).
+type Schema struct {
+	Type  string  `json:"type"`            // array, object, string, number, boolean, null or unknown
+	Title string  `json:"title,omitempty"` // Element type for objects (e.g. "Heading")
+	Items *Schema `json:"items,omitempty"` // Item schema for arrays
+}
+
+// String returns a compact form such as "array<Heading>" or "string".
+func (s Schema) String() string {
+	switch {
+	case s.Type == "array" && s.Items != nil:
+		return fmt.Sprintf("array<%s>", s.Items)
+	case s.Type == "object" && s.Title != "":
+		return s.Title
+	}
+	return s.Type
+}
+
+var (
+	unknownSchema = &Schema{Type: "unknown"}
+	stringSchema  = &Schema{Type: "string"}
+	numberSchema  = &Schema{Type: "number"}
+	boolSchema    = &Schema{Type: "boolean"}
+	nullSchema    = &Schema{Type: "null"}
+)
+
+func objectSchema(title string) *Schema { return &Schema{Type: "object", Title: title} }
+func arrayOf(items *Schema) *Schema     { return &Schema{Type: "array", Items: items} }
+
+// documentSelectors maps document-level selectors to their result types.
+var documentSelectors = map[string]*Schema{
+	"headings":         arrayOf(objectSchema("Heading")),
+	"section":          objectSchema("Section"),
+	"sections":         arrayOf(objectSchema("Section")),
+	"search":           objectSchema("SearchResults"),
+	"search_elements":  objectSchema("SearchResults"),
+	"tf":               objectSchema("TermFrequencies"),
+	"context":          arrayOf(objectSchema("Section")),
+	"code":             arrayOf(objectSchema("CodeBlock")),
+	"links":            arrayOf(objectSchema("Link")),
+	"images":           arrayOf(objectSchema("Image")),
+	"tables":           arrayOf(objectSchema("Table")),
+	"lists":            arrayOf(objectSchema("List")),
+	"elements":         arrayOf(objectSchema("Element")),
+	"strikethrough":    arrayOf(objectSchema("Strikethrough")),
+	"components":       arrayOf(objectSchema("Component")),
+	"listitems":        arrayOf(objectSchema("FlatListItem")),
+	"flatten_lists":    arrayOf(objectSchema("FlatListItem")),
+	"symbols":          arrayOf(objectSchema("DocumentSymbol")),
+	"fields":           arrayOf(objectSchema("FieldInfo")),
+	"structure_issues": arrayOf(objectSchema("StructureIssue")),
+	"metadata":         &Schema{Type: "object"},
+	"data":             unknownSchema,
+	"meta":             unknownSchema,
+	"field":            unknownSchema,
+	"title":            stringSchema,
+	"owner":            stringSchema,
+	"priority":         stringSchema,
+	"tags":             arrayOf(stringSchema),
+	"text":             stringSchema,
+	"lead":             stringSchema,
+	"card":             &Schema{Type: "object"},
+	"language":         stringSchema,
+	"lines":            stringSchema,
+	"html":             stringSchema,
+	"length":           numberSchema,
+	"domains":          arrayOf(stringSchema),
+	"without_alt":      arrayOf(objectSchema("Image")),
+	"tree":             objectSchema("TreeResult"),
+	"reduction":        objectSchema("SizeReport"),
+	"toc":              objectSchema("TOCResult"),
+	"between":          stringSchema,
+	"depth":            arrayOf(objectSchema("Section")),
+	"chunk":            arrayOf(stringSchema),
+}
+
+// elementProperties maps the properties of each structural element type.
+var elementProperties = map[string]map[string]*Schema{
+	"Heading": {
+		"text": stringSchema, "level": numberSchema, "id": stringSchema,
+	},
+	"Section": {
+		"heading": objectSchema("Heading"), "text": stringSchema, "body": stringSchema, "prose": stringSchema,
+		"lead": stringSchema, "path": stringSchema, "start": numberSchema,
+		"end": numberSchema, "children": arrayOf(objectSchema("Section")),
+		"siblings": arrayOf(objectSchema("Section")), "ancestors": arrayOf(objectSchema("Section")),
+		"next": objectSchema("Section"), "prev": objectSchema("Section"),
+		"has_code": boolSchema, "has_tables": boolSchema, "has_images": boolSchema,
+		"codecount": numberSchema, "tablecount": numberSchema, "imagecount": numberSchema,
+		"code": arrayOf(objectSchema("CodeBlock")), "head": stringSchema, "tail": stringSchema,
+	},
+	"CodeBlock": {
+		"content": stringSchema, "text": stringSchema, "language": stringSchema, "lines": numberSchema,
+		"contentlines": arrayOf(stringSchema),
+	},
+	"Link": {
+		"text": stringSchema, "url": stringSchema, "auto": boolSchema,
+	},
+	"Image": {
+		"text": stringSchema, "alt": stringSchema, "alttext": stringSchema, "url": stringSchema,
+		"title": stringSchema, "width": numberSchema, "height": numberSchema, "format": stringSchema,
+		"section": stringSchema,
+	},
+	"Table": {
+		"headers": arrayOf(stringSchema), "rows": arrayOf(arrayOf(stringSchema)),
+		"records": arrayOf(&Schema{Type: "object"}),
+	},
+	"Strikethrough": {
+		"text": stringSchema, "section": stringSchema,
+	},
+	"Component": {
+		"name": stringSchema, "attributes": &Schema{Type: "object"}, "text": stringSchema, "content": stringSchema,
+		"section": stringSchema, "line": numberSchema, "self_closing": boolSchema,
+	},
+	"StructureIssue": {
+		"rule": stringSchema, "heading": stringSchema, "text": stringSchema, "level": numberSchema,
+		"line": numberSchema, "message": stringSchema,
+	},
+	"TOCResult": {
+		"entries": arrayOf(objectSchema("TOCEntry")), "lines": arrayOf(stringSchema),
+	},
+	"TOCEntry": {
+		"text": stringSchema, "level": numberSchema, "line": numberSchema,
+	},
+	// Rendered results with no queryable properties
+	"SearchResults":   {},
+	"TermFrequencies": {},
+	"KeyCount": {
+		"key": stringSchema, "count": numberSchema,
+	},
+	"SizeReport": {
+		"source_bytes": numberSchema, "readable_chars": numberSchema, "ratio": numberSchema,
+	},
+	"List": {
+		"items": arrayOf(objectSchema("ListItem")), "ordered": boolSchema, "start": numberSchema, "loose": boolSchema,
+	},
+	"ListItem": {
+		"text": stringSchema, "depth": numberSchema, "ordered": boolSchema, "checked": boolSchema,
+		"children": arrayOf(objectSchema("ListItem")),
+	},
+	"FlatListItem": {
+		"text": stringSchema, "depth": numberSchema, "ordered": boolSchema, "number": numberSchema,
+		"checked": boolSchema,
+	},
+}
+
+// collectionProperties lists the properties that map over a collection
+// (e.g. `.sections | .heading` yields array<Heading>).
+var collectionProperties = map[string]map[string]bool{
+	"Section":       {"heading": true, "text": true, "body": true, "prose": true, "lead": true, "path": true},
+	"Heading":       {"text": true},
+	"CodeBlock":     {"text": true, "language": true},
+	"Link":          {"text": true},
+	"Image":         {"text": true},
+	"Strikethrough": {"text": true, "section": true},
+	"Component":     {"text": true, "name": true},
+}
+
+// elementKinds are the titles that carry a kind and a line.
+var elementKinds = map[string]bool{
+	"Heading": true, "CodeBlock": true, "Link": true, "Image": true,
+	"Table": true, "List": true, "Strikethrough": true, "Element": true,
+}
+
+// ResultSchema statically infers the result type of query without running
+// it, e.g. ".headings" is array<Heading> and ".code | length" is number.
+// Parts of the result that cannot be inferred (such as frontmatter values)
+// have type "unknown".
+func ResultSchema(query string) (Schema, error) {
+	ast, err := ParseString(query)
+	if err != nil {
+		return Schema{}, fmt.Errorf("parsing query: %w", err)
+	}
+
+	v := &schemaVisitor{current: objectSchema("Document")}
+	result, err := ast.Accept(v)
+	if err != nil {
+		return Schema{}, err
+	}
+	return *result.(*Schema), nil
+}
+
+// schemaVisitor maps each AST node to the schema of its output, given the
+// schema of its input in current.
+type schemaVisitor struct {
+	current *Schema
+}
+
+func (v *schemaVisitor) infer(node QueryNode, input *Schema) *Schema {
+	old := v.current
+	v.current = input
+	result, _ := node.Accept(v)
+	v.current = old
+	return result.(*Schema)
+}
+
+func (v *schemaVisitor) VisitPipe(node *PipeNode) (interface{}, error) {
+	left := v.infer(node.Left, v.current)
+	return v.infer(node.Right, left), nil
+}
+
+func (v *schemaVisitor) VisitSelector(node *SelectorNode) (interface{}, error) {
+	if node.Name == "toc" && len(node.Args) > 0 {
+		if lit, ok := node.Args[0].(*LiteralNode); ok && lit.Type == LiteralString {
+			return stringSchema, nil
+		}
+	}
+	return v.property(node.Name), nil
+}
+
+// property infers the result of selector or identifier name on the
+// current input.
+func (v *schemaVisitor) property(name string) *Schema {
+	current := v.current
+
+	switch name {
+	case "only", "unwrap":
+		if current.Type == "array" && current.Items != nil {
+			return current.Items
+		}
+		return unknownSchema
+	case "tree_text", "outline_json":
+		return stringSchema
+	case "flatten":
+		if current.Type == "array" && current.Items != nil && current.Items.Type == "array" && current.Items.Items != nil {
+			return arrayOf(current.Items.Items)
+		}
+		return arrayOf(unknownSchema)
+	case "empty", "nonempty":
+		return boolSchema
+	case "summary":
+		if current.Type == "array" {
+			return arrayOf(stringSchema)
+		}
+		return stringSchema
+	}
+
+	if current.Type == "object" {
+		if elementKinds[current.Title] {
+			switch name {
+			case "kind":
+				return stringSchema
+			case "line":
+				return numberSchema
+			}
+		}
+		if s, ok := elementProperties[current.Title][name]; ok {
+			return s
+		}
+		if current.Title == "" {
+			return unknownSchema // metadata and data objects
+		}
+	}
+
+	if current.Type == "array" && current.Items != nil && current.Items.Type == "object" {
+		if collectionProperties[current.Items.Title][name] {
+			return arrayOf(elementProperties[current.Items.Title][name])
+		}
+		if name == "text" {
+			return arrayOf(stringSchema)
+		}
+	}
+
+	if s, ok := documentSelectors[name]; ok {
+		return s
+	}
+	return unknownSchema
+}
+
+func (v *schemaVisitor) VisitFilter(node *FilterNode) (interface{}, error) {
+	return v.current, nil
+}
+
+func (v *schemaVisitor) VisitFunction(node *FunctionNode) (interface{}, error) {
+	switch node.Name {
+	case "map":
+		if len(node.Args) == 1 && v.current.Type == "array" && v.current.Items != nil {
+			return arrayOf(v.infer(node.Args[0], v.current.Items)), nil
+		}
+		return arrayOf(unknownSchema), nil
+	case "count_by":
+		return arrayOf(objectSchema("KeyCount")), nil
+	case "sort_by", "distinct_by", "preview", "sample":
+		return v.current, nil
+	case "reduce":
+		if len(node.Args) == 2 {
+			return v.infer(node.Args[0], v.current), nil
+		}
+		return unknownSchema, nil
+	case "default":
+		if v.current.Type == "unknown" && len(node.Args) == 1 {
+			return v.infer(node.Args[0], v.current), nil
+		}
+		return v.current, nil
+	case "contains", "startswith", "endswith", "contains_any", "contains_all", "empty", "nonempty":
+		return boolSchema, nil
+	case "length":
+		return numberSchema, nil
+	case "domains":
+		return arrayOf(stringSchema), nil
+	case "without_alt":
+		return arrayOf(objectSchema("Image")), nil
+	case "only", "unwrap", "flatten", "tree_text", "outline_json", "summary":
+		return v.property(node.Name), nil
+	case "chunk":
+		return arrayOf(stringSchema), nil
+	}
+	return unknownSchema, nil
+}
+
+func (v *schemaVisitor) VisitBinary(node *BinaryNode) (interface{}, error) {
+	switch node.Operator {
+	case "+", "-", "*":
+		left := v.infer(node.Left, v.current)
+		if left.Type == "unknown" {
+			return v.infer(node.Right, v.current), nil
+		}
+		return left, nil
+	}
+	return boolSchema, nil
+}
+
+func (v *schemaVisitor) VisitUnary(node *UnaryNode) (interface{}, error) {
+	if node.Operator == "-" {
+		return numberSchema, nil
+	}
+	return boolSchema, nil
+}
+
+func (v *schemaVisitor) VisitLiteral(node *LiteralNode) (interface{}, error) {
+	switch node.Type {
+	case LiteralString, LiteralRegex:
+		return stringSchema, nil
+	case LiteralNumber:
+		return numberSchema, nil
+	case LiteralBoolean:
+		return boolSchema, nil
+	}
+	return nullSchema, nil
+}
+
+func (v *schemaVisitor) VisitIdentifier(node *IdentifierNode) (interface{}, error) {
+	if node.Name == "." {
+		return v.current, nil
+	}
+	if node.Name == "length" {
+		return numberSchema, nil
+	}
+	if node.Name == "domains" {
+		return arrayOf(stringSchema), nil
+	}
+	if node.Name == "without_alt" {
+		return arrayOf(objectSchema("Image")), nil
+	}
+	return v.property(node.Name), nil
+}
+
+func (v *schemaVisitor) VisitIndex(node *IndexNode) (interface{}, error) {
+	obj := v.infer(node.Object, v.current)
+	if obj.Type == "array" && obj.Items != nil {
+		return obj.Items, nil
+	}
+	return unknownSchema, nil
+}
+
+func (v *schemaVisitor) VisitSlice(node *SliceNode) (interface{}, error) {
+	return v.infer(node.Object, v.current), nil
+}
+
+func (v *schemaVisitor) VisitArray(node *ArrayNode) (interface{}, error) {
+	var items *Schema
+	for _, elem := range node.Elements {
+		s := v.infer(elem, v.current)
+		if items == nil {
+			items = s
+		} else if items.String() != s.String() {
+			items = unknownSchema
+		}
+	}
+	if items == nil {
+		items = unknownSchema
+	}
+	return arrayOf(items), nil
+}
@@ -0,0 +1,256 @@
+package mql
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+
+	mq "github.com/muqsitnawaz/mq/lib"
+)
+
+// QueryStream executes a query on doc and writes the result to w as JSON.
+// The result itself is built in memory by ExecuteQuery; what streams is its
+// encoding. Collections are written as an array with one element per line,
+// each element encoded and flushed before the next, so the JSON text of
+// large results (such as every section's text) is never held at once.
+// Elements are encoded as plain objects (a section as its title, level,
+// path, line range and text); results with a text rendering, such as
+// .tree, are encoded as a JSON string.
+func QueryStream(doc *mq.Document, query string, w io.Writer) error {
+	result, err := ExecuteQuery(doc, query)
+	if err != nil {
+		return err
+	}
+	return StreamResult(result, w)
+}
+
+// StreamResult writes a query result to w as QueryStream does.
+func StreamResult(result interface{}, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+
+	rv := reflect.ValueOf(result)
+	if result == nil || rv.Kind() != reflect.Slice {
+		if err := enc.Encode(streamValue(result)); err != nil {
+			return fmt.Errorf("encoding result: %w", err)
+		}
+		return bw.Flush()
+	}
+
+	// Elements are encoded one by one into a reused buffer
+	var elem bytes.Buffer
+	elemEnc := json.NewEncoder(&elem)
+	elemEnc.SetEscapeHTML(false)
+
+	bw.WriteString("[")
+	for i := 0; i < rv.Len(); i++ {
+		if i > 0 {
+			bw.WriteString(",")
+		}
+		bw.WriteString("\n")
+		elem.Reset()
+		if err := elemEnc.Encode(streamValue(rv.Index(i).Interface())); err != nil {
+			return fmt.Errorf("encoding element %d: %w", i, err)
+		}
+		bw.Write(bytes.TrimSuffix(elem.Bytes(), []byte("\n")))
+		if bw.Buffered() >= 64*1024 {
+			if err := bw.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if rv.Len() > 0 {
+		bw.WriteString("\n")
+	}
+	bw.WriteString("]\n")
+	return bw.Flush()
+}
+
+// Plain JSON shapes for elements, which otherwise reference their AST
+// nodes and parent sections.
+type (
+	sectionJSON struct {
+		Title string `json:"title"`
+		Level int    `json:"level"`
+		Path  string `json:"path"`
+		Start int    `json:"start"`
+		End   int    `json:"end"`
+		Text  string `json:"text"`
+	}
+	headingJSON struct {
+		Level int    `json:"level"`
+		Text  string `json:"text"`
+		ID    string `json:"id,omitempty"`
+		Line  int    `json:"line"`
+	}
+	codeJSON struct {
+		Language string `json:"language"`
+		Content  string `json:"content"`
+		Lines    int    `json:"lines"`
+		Line     int    `json:"line"`
+	}
+	linkJSON struct {
+		Text string `json:"text"`
+		URL  string `json:"url"`
+		Line int    `json:"line"`
+	}
+	imageJSON struct {
+		Alt     string `json:"alt"`
+		URL     string `json:"url"`
+		Title   string `json:"title,omitempty"`
+		Section string `json:"section,omitempty"`
+		Line    int    `json:"line"`
+	}
+	tableJSON struct {
+		Headers []string   `json:"headers"`
+		Rows    [][]string `json:"rows"`
+		Line    int        `json:"line"`
+	}
+	listJSON struct {
+		Ordered bool           `json:"ordered"`
+		Start   int            `json:"start,omitempty"`
+		Loose   bool           `json:"loose"`
+		Items   []listItemJSON `json:"items"`
+		Line    int            `json:"line"`
+	}
+	listItemJSON struct {
+		Text     string         `json:"text"`
+		Depth    int            `json:"depth"`
+		Ordered  bool           `json:"ordered"`
+		Number   int            `json:"number,omitempty"` // Flattened items only
+		Checked  *bool          `json:"checked,omitempty"`
+		Children []listItemJSON `json:"children,omitempty"`
+	}
+	strikethroughJSON struct {
+		Text    string `json:"text"`
+		Section string `json:"section,omitempty"`
+		Line    int    `json:"line"`
+	}
+	componentJSON struct {
+		Name        string            `json:"name"`
+		Attributes  map[string]string `json:"attributes"`
+		Text        string            `json:"text"`
+		Content     string            `json:"content,omitempty"`
+		SelfClosing bool              `json:"self_closing,omitempty"`
+		Section     string            `json:"section,omitempty"`
+		Line        int               `json:"line"`
+	}
+	documentJSON struct {
+		Path   string `json:"path"`
+		Format string `json:"format"`
+		Title  string `json:"title,omitempty"`
+	}
+)
+
+// streamValue converts a result value into something encoding/json can
+// write without following AST or parent pointers.
+func streamValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case *mq.Section:
+		if val == nil {
+			return nil
+		}
+		return sectionJSON{
+			Title: val.Heading.Text,
+			Level: val.Heading.Level,
+			Path:  val.PathString(" > "),
+			Start: val.Start,
+			End:   val.End,
+			Text:  val.GetText(),
+		}
+	case *mq.Heading:
+		return headingJSON{Level: val.Level, Text: val.Text, ID: val.ID, Line: val.Line}
+	case *mq.CodeBlock:
+		return codeJSON{Language: val.Language, Content: val.Content, Lines: val.GetLines(), Line: val.Line}
+	case *mq.Link:
+		return linkJSON{Text: val.Text, URL: val.URL, Line: val.Line}
+	case *mq.Image:
+		img := imageJSON{Alt: val.AltText, URL: val.URL, Title: val.Title, Line: val.Line}
+		if val.Section != nil {
+			img.Section = val.Section.Heading.Text
+		}
+		return img
+	case *mq.Table:
+		return tableJSON{Headers: val.Headers, Rows: val.Rows, Line: val.Line}
+	case *mq.List:
+		return listJSON{Ordered: val.Ordered, Start: val.Start, Loose: val.Loose, Items: listItemsJSON(val.Items), Line: val.Line}
+	case *mq.Strikethrough:
+		st := strikethroughJSON{Text: val.Text, Line: val.Line}
+		if val.Section != nil {
+			st.Section = val.Section.Heading.Text
+		}
+		return st
+	case *mq.Component:
+		c := componentJSON{
+			Name:        val.Name,
+			Attributes:  val.Attributes,
+			Text:        val.Text,
+			Content:     val.Content,
+			SelfClosing: val.SelfClosing,
+			Line:        val.Line,
+		}
+		if val.Section != nil {
+			c.Section = val.Section.Heading.Text
+		}
+		return c
+	case mq.FlatListItem:
+		return listItemJSON{Text: val.Text, Depth: val.Depth, Ordered: val.Ordered, Number: val.Number, Checked: val.Checked}
+	case *mq.Document:
+		return documentJSON{Path: val.Path(), Format: val.Format().String(), Title: val.Title()}
+	case *mq.TOCResult:
+		// Entries carry their own JSON tags
+		return val
+	case *mq.TreeResult, *mq.SearchResults, *mq.TermFrequencies, *mq.DirTreeResult:
+		// Rendered results
+		return val.(fmt.Stringer).String()
+	case map[interface{}]interface{}:
+		// Nested frontmatter maps decode with interface{} keys
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			m[fmt.Sprint(k)] = streamValue(item)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			m[k] = streamValue(item)
+		}
+		return m
+	case mq.Metadata:
+		return streamValue(map[string]interface{}(val))
+	case []interface{}:
+		items := make([]interface{}, len(val))
+		for i, item := range val {
+			items[i] = streamValue(item)
+		}
+		return items
+	}
+
+	// Nested typed collections, e.g. map(.children)
+	if rv := reflect.ValueOf(v); v != nil && rv.Kind() == reflect.Slice && (rv.Type().Elem().Kind() == reflect.Ptr || rv.Type().Elem().Kind() == reflect.Interface) {
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = streamValue(rv.Index(i).Interface())
+		}
+		return items
+	}
+	return v
+}
+
+func listItemsJSON(items []mq.ListItem) []listItemJSON {
+	out := make([]listItemJSON, len(items))
+	for i, item := range items {
+		out[i] = listItemJSON{
+			Text:     item.Text,
+			Depth:    item.Depth,
+			Ordered:  item.Ordered,
+			Checked:  item.Checked,
+			Children: listItemsJSON(item.Children),
+		}
+	}
+	return out
+}
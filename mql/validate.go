@@ -0,0 +1,308 @@
+package mql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	mq "github.com/muqsitnawaz/mq/lib"
+)
+
+// Severity grades a Diagnostic.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"   // The query will fail
+	SeverityWarning Severity = "warning" // The query may fail or match nothing on this document
+)
+
+// Diagnostic is a problem found by Validate, positioned at the offending
+// token of the query.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Line     int      `json:"line"` // 1-based line in the query
+	Col      int      `json:"col"`  // 1-based column in the query
+	Message  string   `json:"message"`
+}
+
+// String formats the diagnostic as "line:col: severity: message".
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s", d.Line, d.Col, d.Severity, d.Message)
+}
+
+// Validate checks query without running it, as a linter for stored queries.
+// It reports syntax errors, unknown selectors and functions, and properties
+// that the inferred input type (see ResultSchema) does not have. With a
+// document it also warns about sections and headings the document lacks
+// and points frontmatter fields used as selectors to .meta. The checks are
+// shallow: a query without diagnostics can still fail at run time. doc may
+// be nil.
+func Validate(query string, doc *mq.Document) []Diagnostic {
+	tokens, err := Lex(query)
+	if err == nil {
+		var ast QueryNode
+		if ast, err = Parse(tokens); err == nil {
+			v := &validator{doc: doc, tokens: tokens, current: objectSchema("Document")}
+			ast.Accept(v)
+			return v.diags
+		}
+	}
+
+	var perr *ParseError
+	if errors.As(err, &perr) {
+		return []Diagnostic{{Severity: SeverityError, Line: perr.Line, Col: perr.Col, Message: perr.Msg}}
+	}
+	return []Diagnostic{{Severity: SeverityError, Line: 1, Col: 1, Message: err.Error()}}
+}
+
+// validator walks a query in source order, tracking the inferred schema of
+// the current value.
+type validator struct {
+	doc     *mq.Document
+	tokens  []Token
+	next    int // index of the next unmatched token
+	current *Schema
+	diags   []Diagnostic
+}
+
+func (v *validator) visit(node QueryNode, input *Schema) {
+	old := v.current
+	v.current = input
+	node.Accept(v)
+	v.current = old
+}
+
+// infer returns the schema of node's output for the given input.
+func (v *validator) infer(node QueryNode, input *Schema) *Schema {
+	return (&schemaVisitor{}).infer(node, input)
+}
+
+// items is the element schema when the input is a collection.
+func (v *validator) items() *Schema {
+	if v.current.Type == "array" && v.current.Items != nil {
+		return v.current.Items
+	}
+	return unknownSchema
+}
+
+// locate finds the next identifier token with the given name, so that
+// diagnostics point at the right occurrence of repeated names.
+func (v *validator) locate(name string) Token {
+	for i := v.next; i < len(v.tokens); i++ {
+		if v.tokens[i].Type == TokenIdentifier && v.tokens[i].Value == name {
+			v.next = i + 1
+			return v.tokens[i]
+		}
+	}
+	return Token{Line: 1, Col: 1}
+}
+
+func (v *validator) report(tok Token, severity Severity, format string, args ...interface{}) {
+	v.diags = append(v.diags, Diagnostic{
+		Severity: severity,
+		Line:     tok.Line,
+		Col:      tok.Col,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) VisitPipe(node *PipeNode) (interface{}, error) {
+	v.visit(node.Left, v.current)
+	v.visit(node.Right, v.infer(node.Left, v.current))
+	return nil, nil
+}
+
+func (v *validator) VisitSelector(node *SelectorNode) (interface{}, error) {
+	tok := v.locate(node.Name)
+	v.checkName(tok, node.Name, "."+node.Name)
+	for _, arg := range node.Args {
+		v.visit(arg, v.current)
+	}
+
+	if v.doc == nil {
+		return nil, nil
+	}
+	titles, ok := literalStrings(node.Args)
+	switch {
+	case !ok:
+	case node.Name == "section" && len(titles) == 1:
+		if _, found := findSection(v.doc, titles[0]); !found {
+			v.report(tok, SeverityWarning, "no section titled %q in this document", titles[0])
+		}
+	case node.Name == "section" && len(titles) > 1:
+		if _, found := v.doc.GetSectionByPath(titles...); !found {
+			v.report(tok, SeverityWarning, "no section at path %q in this document", strings.Join(titles, " > "))
+		}
+	case node.Name == "between" && len(titles) >= 2:
+		if _, err := v.doc.GetContentBetween(titles[0], titles[1]); err != nil {
+			v.report(tok, SeverityWarning, "%v", err)
+		}
+	}
+	return nil, nil
+}
+
+func (v *validator) VisitFilter(node *FilterNode) (interface{}, error) {
+	v.visit(node.Predicate, v.items())
+	return nil, nil
+}
+
+func (v *validator) VisitFunction(node *FunctionNode) (interface{}, error) {
+	tok := v.locate(node.Name)
+	if !isFunction(node.Name) {
+		v.report(tok, SeverityError, "unknown function: %s", node.Name)
+	}
+
+	switch node.Name {
+	case "map", "sort_by", "count_by", "distinct_by":
+		for _, arg := range node.Args {
+			v.visit(arg, v.items())
+		}
+	case "reduce":
+		// '.' is the accumulator, but other selectors read the element
+		for i, arg := range node.Args {
+			if i == 0 {
+				v.visit(arg, v.current)
+			} else {
+				v.visit(arg, v.items())
+			}
+		}
+	default:
+		for _, arg := range node.Args {
+			v.visit(arg, v.current)
+		}
+	}
+	return nil, nil
+}
+
+func (v *validator) VisitBinary(node *BinaryNode) (interface{}, error) {
+	v.visit(node.Left, v.current)
+	v.visit(node.Right, v.current)
+	return nil, nil
+}
+
+func (v *validator) VisitUnary(node *UnaryNode) (interface{}, error) {
+	v.visit(node.Operand, v.current)
+	return nil, nil
+}
+
+func (v *validator) VisitLiteral(node *LiteralNode) (interface{}, error) {
+	return nil, nil
+}
+
+func (v *validator) VisitIdentifier(node *IdentifierNode) (interface{}, error) {
+	if node.Name != "." {
+		v.checkName(v.locate(node.Name), node.Name, node.Name)
+	}
+	return nil, nil
+}
+
+func (v *validator) VisitIndex(node *IndexNode) (interface{}, error) {
+	v.visit(node.Object, v.current)
+	v.visit(node.Index, v.current)
+	return nil, nil
+}
+
+func (v *validator) VisitSlice(node *SliceNode) (interface{}, error) {
+	v.visit(node.Object, v.current)
+	if node.Start != nil {
+		v.visit(node.Start, v.current)
+	}
+	if node.End != nil {
+		v.visit(node.End, v.current)
+	}
+	return nil, nil
+}
+
+func (v *validator) VisitArray(node *ArrayNode) (interface{}, error) {
+	for _, elem := range node.Elements {
+		v.visit(elem, v.current)
+	}
+	return nil, nil
+}
+
+// checkName reports a selector or bare identifier that cannot apply to the
+// current input. Inputs of unknown type, such as frontmatter values, are
+// not checked.
+func (v *validator) checkName(tok Token, name, display string) {
+	current := v.current
+	switch {
+	case current.Type == "object" && current.Title == "Document":
+		if isSelector(name) {
+			return
+		}
+		if v.doc != nil {
+			if _, ok := v.doc.GetMetadataField(name); ok {
+				v.report(tok, SeverityError, "%s is a frontmatter field, not a selector; use .meta(%q)", display, name)
+				return
+			}
+		}
+		v.report(tok, SeverityError, "%s is not a selector or a frontmatter field", display)
+
+	case current.Type == "object" && elementProperties[current.Title] != nil:
+		if hasProperty(current.Title, name) || isSelector(name) {
+			return
+		}
+		v.report(tok, SeverityError, "%s has no property %s", current.Title, name)
+
+	case current.Type == "array" && current.Items != nil && elementProperties[current.Items.Title] != nil:
+		title := current.Items.Title
+		if collectionProperties[title][name] || name == "text" || isSelector(name) {
+			return
+		}
+		if hasProperty(title, name) {
+			v.report(tok, SeverityError, "%s is a property of each %s; use map(.%s)", display, title, name)
+			return
+		}
+		v.report(tok, SeverityError, "%s has no property %s", title, name)
+	}
+}
+
+// hasProperty reports whether elements titled title have property name.
+func hasProperty(title, name string) bool {
+	if _, ok := elementProperties[title][name]; ok {
+		return true
+	}
+	return elementKinds[title] && (name == "kind" || name == "line")
+}
+
+// isSelector reports whether name is a selector, or a function that may be
+// written as one (e.g. .length).
+func isSelector(name string) bool {
+	if _, ok := documentSelectors[name]; ok {
+		return true
+	}
+	for _, s := range selectors {
+		if s.Name == name {
+			return true
+		}
+	}
+	return isFunction(name)
+}
+
+// isFunction reports whether name is a function.
+func isFunction(name string) bool {
+	for _, f := range functions {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// literalStrings returns the values of args if they are all string
+// literals.
+func literalStrings(args []QueryNode) ([]string, bool) {
+	values := make([]string, len(args))
+	for i, arg := range args {
+		lit, ok := arg.(*LiteralNode)
+		if !ok || lit.Type != LiteralString {
+			return nil, false
+		}
+		s, ok := lit.Value.(string)
+		if !ok {
+			return nil, false
+		}
+		values[i] = s
+	}
+	return values, true
+}
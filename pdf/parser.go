@@ -25,6 +25,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	mq "github.com/muqsitnawaz/mq/lib"
 )
@@ -35,6 +36,7 @@ type Parser struct {
 	inferHeadings   bool    // Infer headings from font size changes
 	inferTables     bool    // Detect tables from aligned text
 	headingMinRatio float64 // Min font size ratio to consider heading (e.g., 1.2 = 20% larger)
+	profile         bool    // Record mq.ParseStats
 }
 
 // Option configures the parser.
@@ -75,6 +77,14 @@ func WithHeadingRatio(ratio float64) Option {
 	}
 }
 
+// WithProfiling records mq.ParseStats on every parsed document: text
+// extraction time and structure inference time.
+func WithProfiling() Option {
+	return func(p *Parser) {
+		p.profile = true
+	}
+}
+
 // Format implements mq.FormatParser.
 func (p *Parser) Format() mq.Format {
 	return mq.FormatPDF
@@ -148,9 +158,18 @@ type structureResult struct {
 }
 
 func (e *extractor) extract() (*mq.Document, error) {
+	var start, parsed time.Time
+	if e.parser.profile {
+		start = time.Now()
+	}
+
 	// Extract text content using pdftotext (fast, reliable)
 	text := e.extractBasicText()
 
+	if e.parser.profile {
+		parsed = time.Now()
+	}
+
 	// Try to extract structure using PyMuPDF (headings, tables)
 	var headings []*mq.Heading
 	var sections []*mq.Section
@@ -173,14 +192,16 @@ func (e *extractor) extract() (*mq.Document, error) {
 
 		// Convert tables
 		for _, t := range structure.Tables {
-			tables = append(tables, &mq.Table{
+			table := &mq.Table{
 				Headers: t.Headers,
 				Rows:    nil, // We don't extract full table data yet
-			})
+			}
+			table.Normalize()
+			tables = append(tables, table)
 		}
 	}
 
-	return mq.NewDocument(
+	doc := mq.NewDocument(
 		e.source,
 		e.path,
 		mq.FormatPDF,
@@ -193,7 +214,17 @@ func (e *extractor) extract() (*mq.Document, error) {
 		tables,
 		nil, // lists - would be detected from bullets
 		text,
-	), nil
+	)
+
+	if e.parser.profile {
+		end := time.Now()
+		doc.SetParseStats(mq.ParseStats{
+			Parse: parsed.Sub(start),
+			Index: end.Sub(parsed),
+			Total: end.Sub(start),
+		})
+	}
+	return doc, nil
 }
 
 // extractStructure uses PyMuPDF to extract headings and tables.